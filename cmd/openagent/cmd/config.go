@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ferg-cod3s/openagent/pkg/agent"
+)
+
+// fileConfig is the content of the --config file.
+//
+//	agents:
+//	  reviewer:
+//	    provider: anthropic
+//	    model: claude-3-5-sonnet-20241022
+//	    system_prompt: You review Go code.
+type fileConfig struct {
+	// Agents are the named agents workflow agent steps may refer to.
+	Agents map[string]agentDef `yaml:"agents"`
+}
+
+// agentDef defines a named agent in the config file.
+type agentDef struct {
+	Description  string  `yaml:"description"`
+	Provider     string  `yaml:"provider"`
+	Model        string  `yaml:"model"`
+	SystemPrompt string  `yaml:"system_prompt"`
+	MaxTokens    int     `yaml:"max_tokens"`
+	Temperature  float64 `yaml:"temperature"`
+	Timeout      string  `yaml:"timeout"`
+}
+
+// loadConfig reads the --config file, or $HOME/.openagent.yaml when the
+// flag is not set. A missing default file is an empty configuration.
+func loadConfig() (*fileConfig, error) {
+	path := cfgFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return &fileConfig{}, nil
+		}
+		path = filepath.Join(home, ".openagent.yaml")
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && cfgFile == "" {
+		return &fileConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	cfg := &fileConfig{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// namedAgents returns the agents defined in the config file.
+func namedAgents() (map[string]agent.Config, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.agents()
+}
+
+// agents returns the named agents as agent configurations.
+func (c *fileConfig) agents() (map[string]agent.Config, error) {
+	out := make(map[string]agent.Config, len(c.Agents))
+	for name, def := range c.Agents {
+		cfg := agent.Config{
+			Name:         name,
+			Description:  def.Description,
+			Provider:     def.Provider,
+			Model:        def.Model,
+			SystemPrompt: def.SystemPrompt,
+			MaxTokens:    def.MaxTokens,
+			Temperature:  def.Temperature,
+		}
+		if def.Timeout != "" {
+			d, err := time.ParseDuration(def.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("config: agents.%s.timeout: invalid duration %q", name, def.Timeout)
+			}
+			cfg.Timeout = d
+		}
+		out[name] = cfg
+	}
+	return out, nil
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamedAgents(t *testing.T) {
+	saved := cfgFile
+	t.Cleanup(func() { cfgFile = saved })
+	t.Setenv("HOME", t.TempDir())
+
+	// Without a config file there are no named agents.
+	cfgFile = ""
+	agents, err := namedAgents()
+	if err != nil || len(agents) != 0 {
+		t.Fatalf("expected no agents, got %v, %v", agents, err)
+	}
+
+	dir := t.TempDir()
+	write := func(src string) string {
+		path := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	cfgFile = write(`agents:
+  reviewer:
+    provider: anthropic
+    model: large
+    system_prompt: You review Go code.
+    max_tokens: 1000
+    timeout: 2m
+`)
+	agents, err = namedAgents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := agents["reviewer"]
+	if !ok || r.Name != "reviewer" || r.Provider != "anthropic" || r.Model != "large" || r.SystemPrompt != "You review Go code." || r.MaxTokens != 1000 || r.Timeout != 2*time.Minute {
+		t.Errorf("unexpected agents %+v", agents)
+	}
+
+	for src, want := range map[string]string{
+		"agents:\n  reviewer:\n    modle: large\n":  "field modle not found",
+		"agents:\n  reviewer:\n    timeout: soon\n": `agents.reviewer.timeout: invalid duration "soon"`,
+	} {
+		cfgFile = write(src)
+		if _, err := namedAgents(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected error containing %q, got %v", src, want, err)
+		}
+	}
+
+	// An explicit config file must exist.
+	cfgFile = filepath.Join(dir, "missing.yaml")
+	if _, err := namedAgents(); err == nil {
+		t.Error("expected an error for a missing config file")
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+// progress renders workflow events as a run goes: a line when a step
+// starts, retries, is skipped or finishes, indented by nesting, and a
+// periodic line listing the steps still running.
+type progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	start   time.Time
+	running map[string]time.Time
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// newProgress starts a renderer writing to w, listing running steps
+// every interval.
+func newProgress(w io.Writer, every time.Duration) *progress {
+	p := &progress{
+		w:       w,
+		start:   time.Now(),
+		running: make(map[string]time.Time),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.tick(every)
+	return p
+}
+
+// OnEvent renders an event.
+func (p *progress) OnEvent(ev workflow.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch ev.Type {
+	case workflow.EventRunStarted:
+		p.start = ev.Time
+		fmt.Fprintf(p.w, "Running workflow %s (run %s)\n", ev.Workflow, ev.RunID)
+	case workflow.EventStepStarted:
+		p.running[ev.Path] = ev.Time
+		p.line(ev, "started", "")
+	case workflow.EventStepRetrying:
+		p.line(ev, "retrying", fmt.Sprintf("attempt %d failed: %s; next in %v", ev.Attempt, ev.Error, ev.Backoff.Round(time.Millisecond)))
+	case workflow.EventStepSkipped:
+		p.line(ev, "skipped", ev.Reason)
+	case workflow.EventStepCompleted, workflow.EventStepFailed:
+		delete(p.running, ev.Path)
+		detail := ev.Duration.Round(time.Millisecond).String()
+		if ev.Attempt > 1 {
+			detail += fmt.Sprintf(", %d attempts", ev.Attempt)
+		}
+		if ev.Error != "" {
+			detail += ": " + ev.Error
+		}
+		p.line(ev, string(ev.Status), detail)
+	}
+}
+
+// line writes a step event with the step's path, indented by its depth.
+func (p *progress) line(ev workflow.Event, status, detail string) {
+	indent := strings.Repeat("  ", strings.Count(ev.Path, "/"))
+	s := fmt.Sprintf("%s %s%-10s %s", p.elapsed(ev.Time), indent, status, ev.Path)
+	if detail != "" {
+		s += " (" + detail + ")"
+	}
+	fmt.Fprintln(p.w, s)
+}
+
+func (p *progress) elapsed(t time.Time) string {
+	return fmt.Sprintf("[%7.1fs]", t.Sub(p.start).Seconds())
+}
+
+func (p *progress) tick(every time.Duration) {
+	defer close(p.done)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-t.C:
+			p.report(now)
+		}
+	}
+}
+
+// report lists the steps still running, longest first.
+func (p *progress) report(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.running) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(p.running))
+	for path := range p.running {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if !p.running[paths[i]].Equal(p.running[paths[j]]) {
+			return p.running[paths[i]].Before(p.running[paths[j]])
+		}
+		return paths[i] < paths[j]
+	})
+	items := make([]string, len(paths))
+	for i, path := range paths {
+		items[i] = fmt.Sprintf("%s (%v)", path, now.Sub(p.running[path]).Round(time.Second))
+	}
+	fmt.Fprintf(p.w, "%s running: %s\n", p.elapsed(now), strings.Join(items, ", "))
+}
+
+// Close stops the periodic report.
+func (p *progress) Close() {
+	close(p.stop)
+	<-p.done
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	// The ticker never fires during the test; reports are driven directly.
+	p := newProgress(&buf, time.Hour)
+	defer p.Close()
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+	for _, ev := range []workflow.Event{
+		{Type: workflow.EventRunStarted, Time: t0, Workflow: "deploy", RunID: "r1"},
+		{Type: workflow.EventStepStarted, Time: at(time.Second), Path: "build"},
+		{Type: workflow.EventStepStarted, Time: at(2 * time.Second), Path: "loop/0/test"},
+		{Type: workflow.EventStepRetrying, Time: at(3 * time.Second), Path: "build", Attempt: 1, Error: "timeout", Backoff: 500 * time.Millisecond},
+		{Type: workflow.EventStepSkipped, Time: at(4 * time.Second), Path: "lint", Reason: "condition is false"},
+	} {
+		p.OnEvent(ev)
+	}
+	p.report(at(10 * time.Second))
+	p.OnEvent(workflow.Event{Type: workflow.EventStepCompleted, Time: at(12 * time.Second), Path: "build", Status: workflow.StatusCompleted, Duration: 11 * time.Second, Attempt: 2})
+	p.OnEvent(workflow.Event{Type: workflow.EventStepFailed, Time: at(13 * time.Second), Path: "loop/0/test", Status: workflow.StatusFailed, Duration: 11 * time.Second, Attempt: 1, Error: "exit 1"})
+	// Nothing is running, so nothing is reported.
+	p.report(at(20 * time.Second))
+
+	want := []string{
+		"Running workflow deploy (run r1)",
+		"[    1.0s] started    build",
+		"[    2.0s]     started    loop/0/test",
+		"[    3.0s] retrying   build (attempt 1 failed: timeout; next in 500ms)",
+		"[    4.0s] skipped    lint (condition is false)",
+		"[   10.0s] running: build (9s), loop/0/test (8s)",
+		"[   12.0s] completed  build (11s, 2 attempts)",
+		"[   13.0s]     failed     loop/0/test (11s: exit 1)",
+	}
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("expected %d lines, got %d:\n%s", len(want), len(got), buf.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d: expected %q, got %q", i+1, want[i], got[i])
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
 )
 
 var (
@@ -56,18 +58,44 @@ func init() {
 var runCmd = &cobra.Command{
 	Use:   "run [workflow]",
 	Short: "Run a workflow or agent",
-	Long:  `Run a workflow from a YAML file or execute an agent interactively.`,
-	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Run a workflow from a YAML file or execute an agent interactively.
+
+Workflows run with the built-in actions (see "openagent workflow actions").
+LLM actions use Anthropic or OpenAI when ANTHROPIC_API_KEY or
+OPENAI_API_KEY is set, and Ollama otherwise.
+
+With --state-dir, runs are checkpointed there as their steps finish. An
+interrupted or failed run continues with --resume <run-id>, which skips
+the steps that completed and keeps the run's original inputs. Checkpoints
+hold the inputs and every step output; a run's checkpoint is deleted once
+it completes.
+
+Progress is printed as steps start and finish, with a periodic list of
+the steps still running. --events records every run event as JSON lines
+for dashboards and debugging.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
 			fmt.Println("Starting interactive agent mode...")
-			return
+			return nil
 		}
-		fmt.Printf("Running workflow: %s\n", args[0])
+		return runWorkflow(cmd, args[0])
 	},
 }
 
 func init() {
+	runCmd.Flags().StringArrayVarP(&runFlags.inputs, "input", "i", nil, "workflow input as key=value (repeatable)")
+	runCmd.Flags().StringVar(&runFlags.root, "root", "", "directory file, shell and git actions work in (default is the current directory)")
+	runCmd.Flags().StringVar(&runFlags.provider, "provider", "", "default LLM provider: anthropic, openai or ollama")
+	runCmd.Flags().StringVar(&runFlags.model, "model", "", "default model")
+	runCmd.Flags().StringVar(&runFlags.memoryDir, "memory-dir", "", "directory of a persistent memory store (default is in-memory)")
+	runCmd.Flags().BoolVar(&runFlags.sandbox, "sandbox", false, "run shell.exec commands in the sandbox")
+	runCmd.Flags().StringArrayVar(&runFlags.allowCmds, "allow-cmd", nil, "command allowed in the sandbox (repeatable)")
+	runCmd.Flags().BoolVar(&runFlags.json, "json", false, "print the result as JSON")
+	runCmd.Flags().StringVar(&runFlags.stateDir, "state-dir", "", "directory runs are checkpointed in (default is no checkpoints)")
+	runCmd.Flags().StringVar(&runFlags.resume, "resume", "", "resume the checkpointed run with this ID")
+	runCmd.Flags().StringVar(&runFlags.events, "events", "", "append run events to this file as JSON lines")
 	rootCmd.AddCommand(runCmd)
 }
 
@@ -154,14 +182,44 @@ var workflowCmd = &cobra.Command{
 var workflowValidateCmd = &cobra.Command{
 	Use:   "validate [file]",
 	Short: "Validate a workflow file",
-	Args:  cobra.ExactArgs(1),
+	Long: `Validate a workflow file without running it.
+
+Every problem is reported at once, with its line and column: unknown
+fields and step types, duplicate step IDs, dependency cycles, actions
+that are not built in, invalid durations and references to steps that
+are out of scope. Steps that can never run, such as those disabled with
+if: false, are reported as warnings.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return validateWorkflow(cmd, args[0])
+	},
+}
+
+var workflowSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of workflow files",
+	Long: `Print the JSON Schema of workflow files, for editors to complete and
+check workflows as they are written. With the YAML language server, save
+it and add this comment to the top of a workflow:
+
+  # yaml-language-server: $schema=workflow.schema.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Fprint(cmd.OutOrStdout(), string(workflow.JSONSchema()))
+	},
+}
+
+var workflowActionsCmd = &cobra.Command{
+	Use:   "actions",
+	Short: "List the built-in workflow actions",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("Validating workflow: %s\n", args[0])
-		fmt.Println("Workflow is valid.")
+		printActions()
 	},
 }
 
 func init() {
 	workflowCmd.AddCommand(workflowValidateCmd)
+	workflowCmd.AddCommand(workflowActionsCmd)
+	workflowCmd.AddCommand(workflowSchemaCmd)
 	rootCmd.AddCommand(workflowCmd)
 }
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/provider"
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+	"github.com/ferg-cod3s/openagent/pkg/workflow/actions"
+)
+
+// runFlags holds the flags of the run command.
+var runFlags struct {
+	inputs    []string
+	root      string
+	provider  string
+	model     string
+	memoryDir string
+	sandbox   bool
+	allowCmds []string
+	json      bool
+	stateDir  string
+	resume    string
+	events    string
+}
+
+// progressInterval is how often the progress renderer lists the steps
+// still running.
+const progressInterval = 30 * time.Second
+
+// runWorkflow parses, validates and runs the workflow at path.
+func runWorkflow(cmd *cobra.Command, path string) error {
+	w, err := workflow.NewParser().ParseFile(path)
+	if err != nil {
+		return err
+	}
+	inputs, err := parseInputs(runFlags.inputs)
+	if err != nil {
+		return err
+	}
+	if runFlags.resume != "" && len(inputs) > 0 {
+		return fmt.Errorf("--input cannot be used with --resume: a resumed run keeps its inputs")
+	}
+
+	cfg, closeStore, err := actionConfig()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	engine := workflow.NewEngine()
+	if err := actions.Register(engine, cfg); err != nil {
+		return err
+	}
+	if err := checkWorkflow(cmd, path, w, engine, cfg); err != nil {
+		return err
+	}
+	if runFlags.stateDir != "" {
+		if engine.Store, err = workflow.NewFileRunStore(runFlags.stateDir); err != nil {
+			return err
+		}
+	} else if runFlags.resume != "" {
+		return fmt.Errorf("--resume needs a --state-dir")
+	}
+	var eventLog *workflow.EventLog
+	if runFlags.events != "" {
+		f, err := os.OpenFile(runFlags.events, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open event log: %w", err)
+		}
+		defer f.Close()
+		eventLog = workflow.NewEventLog(f)
+		engine.AddObserver(eventLog)
+	}
+	if !runFlags.json {
+		p := newProgress(cmd.OutOrStdout(), progressInterval)
+		defer p.Close()
+		engine.AddObserver(p)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var result *workflow.WorkflowResult
+	if runFlags.resume != "" {
+		result, err = engine.Resume(ctx, w, runFlags.resume)
+	} else {
+		result, err = engine.ExecuteWithInputs(ctx, w, inputs)
+	}
+	if eventLog != nil && eventLog.Err() != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: event log: %v\n", eventLog.Err())
+	}
+	if result == nil {
+		return err
+	}
+	if err == nil && engine.Store != nil && result.Status == workflow.StatusCompleted {
+		if derr := engine.Store.Delete(context.Background(), result.RunID); derr != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: delete checkpoint: %v\n", derr)
+		}
+	}
+	if runFlags.json {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(result); encErr != nil {
+			return encErr
+		}
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "Workflow %s in %v\n", result.Status, result.Duration.Round(time.Millisecond))
+		if err != nil && engine.Store != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "Resume with: openagent run %s --state-dir %s --resume %s\n", path, runFlags.stateDir, result.RunID)
+		}
+	}
+	return err
+}
+
+// validateWorkflow parses and validates the workflow at path against the
+// built-in actions and the agents of the config file.
+func validateWorkflow(cmd *cobra.Command, path string) error {
+	w, err := workflow.NewParser().ParseFile(path)
+	if err != nil {
+		return err
+	}
+	agents, err := namedAgents()
+	if err != nil {
+		return err
+	}
+	cfg := actions.Config{Agents: agents}
+	engine := workflow.NewEngine()
+	if err := actions.Register(engine, cfg); err != nil {
+		return err
+	}
+	if err := checkWorkflow(cmd, path, w, engine, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Workflow is valid.")
+	return nil
+}
+
+// checkWorkflow validates w against the actions of engine and the named
+// agents, printing each problem as path:line:column: field: message.
+// Warnings are printed the same way but do not fail the check.
+func checkWorkflow(cmd *cobra.Command, path string, w *workflow.Workflow, engine *workflow.DefaultEngine, agents workflow.AgentRegistry) error {
+	v := workflow.NewValidator()
+	v.Actions = engine
+	v.Agents = agents
+	warnings, err := v.Check(w)
+	printProblems(cmd, path, "warning: ", warnings)
+	var errs workflow.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	printProblems(cmd, path, "", errs)
+	if len(errs) == 1 {
+		return fmt.Errorf("invalid workflow: 1 problem")
+	}
+	return fmt.Errorf("invalid workflow: %d problems", len(errs))
+}
+
+func printProblems(cmd *cobra.Command, path, kind string, errs workflow.ValidationErrors) {
+	for _, verr := range errs {
+		if verr.Line > 0 {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s:%d:%d: %s%s: %s\n", path, verr.Line, verr.Column, kind, verr.Field, verr.Message)
+		} else {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: %s%s: %s\n", path, kind, verr.Field, verr.Message)
+		}
+	}
+}
+
+// parseInputs parses key=value inputs. Values are read as YAML, so
+// numbers, booleans and lists keep their types.
+func parseInputs(pairs []string) (map[string]interface{}, error) {
+	inputs := make(map[string]interface{}, len(pairs))
+	for _, pair := range pairs {
+		key, raw, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid input %q: expected key=value", pair)
+		}
+		var v interface{}
+		if err := yaml.Unmarshal([]byte(raw), &v); err != nil {
+			v = raw
+		}
+		if v == nil {
+			v = raw
+		}
+		inputs[key] = v
+	}
+	return inputs, nil
+}
+
+// actionConfig configures the built-in actions from the flags, the
+// environment and the config file. The returned function closes the
+// memory store.
+func actionConfig() (actions.Config, func(), error) {
+	agents, err := namedAgents()
+	if err != nil {
+		return actions.Config{}, nil, err
+	}
+	providers := map[string]provider.Provider{
+		string(provider.Ollama): provider.NewOllama(provider.Config{BaseURL: os.Getenv("OLLAMA_HOST"), Model: runFlags.model}),
+	}
+	defaultProvider := string(provider.Ollama)
+	for _, p := range []struct {
+		ptype provider.ProviderType
+		env   string
+	}{{provider.OpenAI, "OPENAI_API_KEY"}, {provider.Anthropic, "ANTHROPIC_API_KEY"}} {
+		if key := os.Getenv(p.env); key != "" {
+			prov, err := provider.New(p.ptype, provider.Config{APIKey: key, Model: runFlags.model})
+			if err != nil {
+				return actions.Config{}, nil, err
+			}
+			providers[string(p.ptype)] = prov
+			defaultProvider = string(p.ptype)
+		}
+	}
+	if runFlags.provider != "" {
+		if _, ok := providers[runFlags.provider]; !ok {
+			return actions.Config{}, nil, fmt.Errorf("provider %s is not configured", runFlags.provider)
+		}
+		defaultProvider = runFlags.provider
+	}
+
+	cfg := actions.Config{
+		Providers:       providers,
+		DefaultProvider: defaultProvider,
+		Model:           runFlags.model,
+		Root:            runFlags.root,
+		Memory:          memory.NewInMemoryStore(),
+		Agents:          agents,
+	}
+	if runFlags.sandbox {
+		cfg.Shell.Sandbox = &actions.Sandbox{AllowedCommands: runFlags.allowCmds}
+	}
+	closeStore := func() {}
+	if runFlags.memoryDir != "" {
+		store, err := memory.NewFileStore(memory.FileStoreConfig{Dir: runFlags.memoryDir})
+		if err != nil {
+			return actions.Config{}, nil, err
+		}
+		cfg.Memory = store
+		closeStore = func() { _ = store.Close() }
+	}
+	return cfg, closeStore, nil
+}
+
+// printActions lists the built-in actions with their inputs and outputs.
+func printActions() {
+	for _, s := range actions.Schemas() {
+		fmt.Printf("%s\n  %s\n", s.Name, s.Description)
+		for _, group := range []struct {
+			title  string
+			fields []actions.Field
+		}{{"inputs", s.Inputs}, {"outputs", s.Outputs}} {
+			fmt.Printf("  %s:\n", group.title)
+			for _, f := range group.fields {
+				required := ""
+				if f.Required {
+					required = ", required"
+				}
+				fmt.Printf("    %-14s %s%s. %s\n", f.Name, f.Type, required, f.Description)
+			}
+		}
+		fmt.Println()
+	}
+}
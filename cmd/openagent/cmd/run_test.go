@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ferg-cod3s/openagent/pkg/agent"
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+	"github.com/ferg-cod3s/openagent/pkg/workflow/actions"
+)
+
+func TestParseInputs(t *testing.T) {
+	got, err := parseInputs([]string{"repo=openagent", "n=3", "ok=true", "items=[a, b]", "expr=a=b", "empty=", "raw={unclosed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"repo":  "openagent",
+		"n":     3,
+		"ok":    true,
+		"items": []interface{}{"a", "b"},
+		"expr":  "a=b",
+		"empty": "",
+		"raw":   "{unclosed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for _, pair := range []string{"novalue", "=x"} {
+		if _, err := parseInputs([]string{pair}); err == nil || !strings.Contains(err.Error(), "expected key=value") {
+			t.Errorf("%q: expected key=value error, got %v", pair, err)
+		}
+	}
+}
+
+func TestActionConfig(t *testing.T) {
+	saved := runFlags
+	t.Cleanup(func() { runFlags = saved })
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	providers := func(cfg map[string]bool) []string {
+		var names []string
+		for _, name := range []string{"anthropic", "ollama", "openai"} {
+			if cfg[name] {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	tests := []struct {
+		name     string
+		env      map[string]string
+		provider string
+		want     string
+		wantAll  []string
+		err      string
+	}{
+		{name: "ollama only", want: "ollama", wantAll: []string{"ollama"}},
+		{name: "openai key", env: map[string]string{"OPENAI_API_KEY": "k"}, want: "openai", wantAll: []string{"ollama", "openai"}},
+		{name: "both keys", env: map[string]string{"OPENAI_API_KEY": "k", "ANTHROPIC_API_KEY": "k"}, want: "anthropic", wantAll: []string{"anthropic", "ollama", "openai"}},
+		{name: "chosen provider", env: map[string]string{"ANTHROPIC_API_KEY": "k"}, provider: "ollama", want: "ollama", wantAll: []string{"anthropic", "ollama"}},
+		{name: "unconfigured provider", provider: "openai", err: "provider openai is not configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"OPENAI_API_KEY", "ANTHROPIC_API_KEY"} {
+				t.Setenv(key, tt.env[key])
+			}
+			runFlags = saved
+			runFlags.provider = tt.provider
+			cfg, closeStore, err := actionConfig()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeStore()
+			names := make(map[string]bool)
+			for name := range cfg.Providers {
+				names[name] = true
+			}
+			if cfg.DefaultProvider != tt.want || !reflect.DeepEqual(providers(names), tt.wantAll) {
+				t.Errorf("expected %s of %v, got %s of %v", tt.want, tt.wantAll, cfg.DefaultProvider, providers(names))
+			}
+			if cfg.Shell.Sandbox != nil {
+				t.Error("expected no sandbox without --sandbox")
+			}
+			if _, ok := cfg.Memory.(*memory.InMemoryStore); !ok {
+				t.Errorf("expected an in-memory store, got %T", cfg.Memory)
+			}
+		})
+	}
+
+	// The sandbox and memory flags configure the actions.
+	runFlags = saved
+	runFlags.model = "small"
+	runFlags.root = "work"
+	runFlags.sandbox = true
+	runFlags.allowCmds = []string{"go", "git"}
+	runFlags.memoryDir = t.TempDir()
+	cfg, closeStore, err := actionConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore()
+	if cfg.Model != "small" || cfg.Root != "work" {
+		t.Errorf("unexpected model %q and root %q", cfg.Model, cfg.Root)
+	}
+	if cfg.Shell.Sandbox == nil || !reflect.DeepEqual(cfg.Shell.Sandbox.AllowedCommands, []string{"go", "git"}) {
+		t.Errorf("unexpected sandbox %+v", cfg.Shell.Sandbox)
+	}
+	if _, ok := cfg.Memory.(*memory.FileStore); !ok {
+		t.Errorf("expected a file store, got %T", cfg.Memory)
+	}
+}
+
+func TestCheckWorkflow(t *testing.T) {
+	cfg := actions.Config{Agents: map[string]agent.Config{"reviewer": {Name: "reviewer"}}}
+	engine := workflow.NewEngine()
+	if err := actions.Register(engine, cfg); err != nil {
+		t.Fatal(err)
+	}
+	check := func(src string) (string, error) {
+		w, err := workflow.NewParser().Parse([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetErr(&out)
+		err = checkWorkflow(cmd, "w.yaml", w, engine, cfg)
+		return out.String(), err
+	}
+
+	out, err := check(`name: test
+steps:
+  - id: a
+    name: A
+    action: shell.exec
+  - id: b
+    name: B
+    action: shell.exec
+    if: false
+`)
+	if err != nil || out != "w.yaml:9:5: warning: steps[1].if: always false, so the step never runs\n" {
+		t.Errorf("expected only a warning, got %q, %v", out, err)
+	}
+
+	out, err = check(`name: test
+steps:
+  - id: a
+    name: A
+    action: nope
+    timeot: 1s
+`)
+	want := "w.yaml:5:5: steps[0].action: unknown action \"nope\"\nw.yaml:6:5: steps[0].timeot: unknown field\n"
+	if out != want || err == nil || err.Error() != "invalid workflow: 2 problems" {
+		t.Errorf("expected\n%s, got\n%s, %v", want, out, err)
+	}
+
+	out, err = check(`name: test
+steps:
+  - id: a
+    name: A
+    type: agent
+    agent: reviewer
+    prompt: hi
+  - id: b
+    name: B
+    type: agent
+    agent: nobody
+    prompt: hi
+`)
+	want = "w.yaml:11:5: steps[1].agent: unknown agent \"nobody\"\n"
+	if out != want || err == nil {
+		t.Errorf("expected\n%s, got\n%s, %v", want, out, err)
+	}
+
+	// Workflows built in code have no positions.
+	var buf bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetErr(&buf)
+	err = checkWorkflow(cmd, "w.yaml", &workflow.Workflow{Steps: []workflow.Step{{ID: "a", Name: "A", Action: "shell.exec"}}}, engine, cfg)
+	if buf.String() != "w.yaml: name: required\n" || err == nil || err.Error() != "invalid workflow: 1 problem" {
+		t.Errorf("unexpected output %q, %v", buf.String(), err)
+	}
+}
+
+func TestRunWorkflowCheckpoints(t *testing.T) {
+	saved := runFlags
+	t.Cleanup(func() { runFlags = saved })
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	root := t.TempDir()
+	path := filepath.Join(root, "w.yaml")
+	src := "name: read\nsteps:\n  - id: read\n    name: Read notes\n    action: file.read\n    with:\n      path: notes.txt\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run := func(stateDir string) ([]*workflow.RunState, error) {
+		runFlags = saved
+		runFlags.root = root
+		runFlags.stateDir = stateDir
+		runFlags.json = true
+		cmd := &cobra.Command{}
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		err := runWorkflow(cmd, path)
+		if stateDir == "" {
+			return nil, err
+		}
+		store, serr := workflow.NewFileRunStore(stateDir)
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		runs, serr := store.List(context.Background())
+		if serr != nil {
+			t.Fatal(serr)
+		}
+		return runs, err
+	}
+
+	// Without --state-dir nothing is written.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if _, err := run(""); err == nil {
+		t.Fatal("expected the read of a missing file to fail")
+	}
+	if _, err := os.Stat(filepath.Join(root, ".openagent")); !os.IsNotExist(err) {
+		t.Errorf("expected no state directory, got %v", err)
+	}
+
+	// A failed run keeps its checkpoint for --resume.
+	stateDir := t.TempDir()
+	runs, err := run(stateDir)
+	if err == nil || len(runs) != 1 {
+		t.Fatalf("expected a failed run with a checkpoint, got %d runs (%v)", len(runs), err)
+	}
+
+	// A completed run deletes its checkpoint.
+	os.WriteFile(filepath.Join(root, "notes.txt"), []byte("hi"), 0o644)
+	stateDir = t.TempDir()
+	runs, err = run(stateDir)
+	if err != nil || len(runs) != 0 {
+		t.Errorf("expected no checkpoint after a completed run, got %d runs (%v)", len(runs), err)
+	}
+}
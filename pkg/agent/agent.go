@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ferg-cod3s/openagent/pkg/memory"
 	"github.com/ferg-cod3s/openagent/pkg/provider"
 )
 
@@ -54,6 +55,9 @@ type Agent struct {
 	history  []provider.Message
 	policy   Policy
 	hooks    []Hook
+
+	memory       memory.VectorStore
+	memoryConfig MemoryConfig
 }
 
 // Policy defines constraints and behaviors for an agent.
@@ -86,6 +90,7 @@ type Result struct {
 	Output    string             `json:"output"`
 	Messages  []provider.Message `json:"messages,omitempty"`
 	Usage     *provider.Usage    `json:"usage,omitempty"`
+	Memories  []*memory.Memory   `json:"memories,omitempty"`
 	Error     error              `json:"-"`
 	Duration  time.Duration      `json:"duration"`
 	Timestamp time.Time          `json:"timestamp"`
@@ -178,14 +183,33 @@ func (a *Agent) Run(ctx context.Context, input string) (*Result, error) {
 		}
 	}
 
+	a.mu.RLock()
+	store, memCfg := a.memory, a.memoryConfig
+	a.mu.RUnlock()
+
+	// Retrieve relevant memories
+	var memoryBlock string
+	if store != nil {
+		block, recalled, err := a.recall(ctx, store, memCfg, input)
+		if err != nil {
+			result.Error = err
+			return result, err
+		}
+		memoryBlock = block
+		result.Memories = recalled
+	}
+
 	// Build messages
-	messages := make([]provider.Message, 0, len(a.history)+2)
+	messages := make([]provider.Message, 0, len(a.history)+3)
 	if a.config.SystemPrompt != "" {
 		messages = append(messages, provider.Message{
 			Role:    "system",
 			Content: a.config.SystemPrompt,
 		})
 	}
+	if memoryBlock != "" {
+		messages = append(messages, memoryMessage(memoryBlock))
+	}
 	messages = append(messages, a.history...)
 	messages = append(messages, provider.Message{
 		Role:    "user",
@@ -229,6 +253,16 @@ func (a *Agent) Run(ctx context.Context, input string) (*Result, error) {
 	result.Usage = &resp.Usage
 	result.Duration = time.Since(start)
 
+	// Write the exchange back as an episode. The output is kept, but the
+	// run is not reported as a success when the episode is lost.
+	if store != nil && !memCfg.DisableWriteBack {
+		if err := a.remember(ctx, store, input, resp.Content); err != nil {
+			result.Success = false
+			result.Error = err
+			return result, err
+		}
+	}
+
 	// Execute after hooks
 	for _, h := range a.hooks {
 		if err := h.AfterRun(ctx, a, result); err != nil {
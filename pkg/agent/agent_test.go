@@ -2,9 +2,13 @@ package agent
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/memory/memorytest"
 	"github.com/ferg-cod3s/openagent/pkg/provider"
 )
 
@@ -13,6 +17,7 @@ type mockProvider struct {
 	name     string
 	response *provider.CompletionResponse
 	err      error
+	requests []*provider.CompletionRequest
 }
 
 func (m *mockProvider) Name() string {
@@ -20,6 +25,7 @@ func (m *mockProvider) Name() string {
 }
 
 func (m *mockProvider) Complete(ctx context.Context, req *provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	m.requests = append(m.requests, req)
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -167,3 +173,147 @@ func TestRestrictivePolicy(t *testing.T) {
 		t.Error("expected error for non-allowed action")
 	}
 }
+
+func TestAgentRunWithMemory(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewInMemoryVectorStore(memorytest.NewHashEmbedder(64))
+	for _, content := range []string{
+		"the database migration tool is goose",
+		"deploys run through the release pipeline",
+	} {
+		if err := store.Save(ctx, &memory.Memory{Type: memory.TypeSemantic, Content: content}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	p := &mockProvider{
+		name:     "test",
+		response: &provider.CompletionResponse{Content: "use goose"},
+	}
+	a := New(Config{ID: "test-agent", SystemPrompt: "You are helpful."}, p)
+	a.SetMemory(store, MemoryConfig{TopK: 1})
+
+	result, err := a.Run(ctx, "which database migration tool")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	msgs := p.requests[0].Messages
+	if len(msgs) != 3 {
+		t.Fatalf("expected system, memory and user messages, got %d", len(msgs))
+	}
+	if msgs[1].Role != "system" || !strings.Contains(msgs[1].Content, "goose") {
+		t.Errorf("expected memory context message, got %+v", msgs[1])
+	}
+	if strings.Contains(msgs[1].Content, "release pipeline") {
+		t.Error("expected only the top memory to be injected")
+	}
+	if len(result.Memories) != 1 {
+		t.Errorf("expected 1 recalled memory, got %d", len(result.Memories))
+	}
+
+	episodes, err := store.List(ctx, &memory.Filter{Type: memory.TypeEpisodic})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(episodes) != 1 {
+		t.Fatalf("expected 1 episode written back, got %d", len(episodes))
+	}
+	if !strings.Contains(episodes[0].Content, "which database migration tool") || !strings.Contains(episodes[0].Content, "use goose") {
+		t.Errorf("unexpected episode content %q", episodes[0].Content)
+	}
+	if episodes[0].Metadata[MetaAgentID] != "test-agent" {
+		t.Errorf("expected agent_id metadata, got %v", episodes[0].Metadata)
+	}
+}
+
+func TestAgentMemoryTokenBudget(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewInMemoryVectorStore(memorytest.NewHashEmbedder(64))
+	long := strings.Repeat("config ", 100)
+	if err := store.Save(ctx, &memory.Memory{Content: "config lives in config.yaml"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Save(ctx, &memory.Memory{Content: long}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	p := &mockProvider{name: "test", response: &provider.CompletionResponse{Content: "ok"}}
+	a := New(Config{ID: "test-agent"}, p)
+	a.SetMemory(store, MemoryConfig{TokenBudget: 40, DisableWriteBack: true})
+
+	if _, err := a.Run(ctx, "config"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	block := p.requests[0].Messages[0].Content
+	if estimateTokens(block) > 40 {
+		t.Errorf("context block exceeds budget: %d tokens", estimateTokens(block))
+	}
+	if !strings.Contains(block, "config.yaml") {
+		t.Errorf("expected fitting memory in context block, got %q", block)
+	}
+	if strings.Contains(block, long) {
+		t.Error("expected oversized memory to be dropped")
+	}
+
+	all, err := store.List(ctx, nil)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected no write-back, got %d memories", len(all))
+	}
+}
+
+// failingSaveStore is a vector store whose saves fail.
+type failingSaveStore struct {
+	memory.VectorStore
+}
+
+func (s failingSaveStore) Save(ctx context.Context, m *memory.Memory) error {
+	return errors.New("disk full")
+}
+
+func TestAgentMemoryWriteBackError(t *testing.T) {
+	store := failingSaveStore{memory.NewInMemoryVectorStore(memorytest.NewHashEmbedder(64))}
+	p := &mockProvider{name: "test", response: &provider.CompletionResponse{Content: "ok"}}
+	a := New(Config{ID: "test-agent"}, p)
+	a.SetMemory(store, MemoryConfig{})
+
+	result, err := a.Run(context.Background(), "hello")
+	if err == nil || !strings.Contains(err.Error(), "save episode") {
+		t.Fatalf("expected write-back error, got %v", err)
+	}
+	if result.Success || result.Error != err {
+		t.Errorf("expected failed result carrying the error, got success=%v error=%v", result.Success, result.Error)
+	}
+	if result.Output != "ok" {
+		t.Errorf("expected output to be kept, got %q", result.Output)
+	}
+}
+
+func TestAgentMemoryMinScore(t *testing.T) {
+	ctx := context.Background()
+	for name, scorer := range map[string]*memory.RecallScorer{"similarity": nil, "scorer": memory.DefaultRecallScorer()} {
+		t.Run(name, func(t *testing.T) {
+			store := memory.NewInMemoryVectorStore(memorytest.NewHashEmbedder(64))
+			store.Save(ctx, &memory.Memory{Content: "which database migration tool", Importance: 1})
+			store.Save(ctx, &memory.Memory{Content: "lunch is at noon", Importance: 1})
+
+			p := &mockProvider{name: "test", response: &provider.CompletionResponse{Content: "ok"}}
+			a := New(Config{ID: "test-agent"}, p)
+			a.SetMemory(store, MemoryConfig{MinScore: 0.5, Scorer: scorer, DisableWriteBack: true})
+
+			result, err := a.Run(ctx, "which database migration tool")
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			// The same threshold keeps only the similar memory, however
+			// recent and important the other one is.
+			if len(result.Memories) != 1 || result.Memories[0].Content != "which database migration tool" {
+				t.Errorf("expected only the similar memory, got %v", result.Memories)
+			}
+		})
+	}
+}
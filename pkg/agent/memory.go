@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/provider"
+)
+
+// Metadata keys set on episodes written back by an agent.
+const (
+	MetaAgentID = "agent_id"
+)
+
+const memoryContextHeader = "Relevant memories from previous work:\n"
+
+// MemoryConfig controls retrieval-augmented context for an agent.
+type MemoryConfig struct {
+	// TopK is the number of memories retrieved per run. Defaults to 5.
+	TopK int `json:"top_k,omitempty"`
+	// TokenBudget caps the estimated size of the injected context block.
+	// Defaults to 1000 tokens.
+	TokenBudget int `json:"token_budget,omitempty"`
+	// MinScore drops retrieved memories whose similarity to the input is
+	// below it, in [0, 1]. With a Scorer it applies to the similarity
+	// before scoring, not to the combined score.
+	MinScore float64 `json:"min_score,omitempty"`
+	// DisableWriteBack stops runs from being saved as episodic memories.
+	DisableWriteBack bool `json:"disable_write_back,omitempty"`
+	// Scorer, if set, ranks candidates with recency and importance as well
+	// as similarity and records each injected memory as accessed.
+	Scorer *memory.RecallScorer `json:"-"`
+}
+
+// SetMemory enables retrieval-augmented context backed by store. Before each
+// completion the top matching memories for the input are injected as a
+// system message; after each successful run the exchange is saved as an
+// episodic memory.
+func (a *Agent) SetMemory(store memory.VectorStore, cfg MemoryConfig) {
+	if cfg.TopK == 0 {
+		cfg.TopK = 5
+	}
+	if cfg.TokenBudget == 0 {
+		cfg.TokenBudget = 1000
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.memory = store
+	a.memoryConfig = cfg
+}
+
+// recall retrieves memories relevant to input and renders them as a
+// context block that fits the token budget. It returns the memories that
+// were included.
+func (a *Agent) recall(ctx context.Context, store memory.VectorStore, cfg MemoryConfig, input string) (string, []*memory.Memory, error) {
+	var found []*memory.Memory
+	var err error
+	if cfg.Scorer != nil {
+		r := memory.NewRetriever(store, cfg.Scorer)
+		r.MinSimilarity = cfg.MinScore
+		found, err = r.Recall(ctx, input, cfg.TopK)
+	} else {
+		found, err = store.SearchByText(ctx, input, cfg.TopK)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("retrieve memories: %w", err)
+	}
+	if cfg.Scorer == nil {
+		kept := found[:0]
+		for _, m := range found {
+			if m.Score >= cfg.MinScore {
+				kept = append(kept, m)
+			}
+		}
+		found = kept
+	}
+
+	var b strings.Builder
+	used := estimateTokens(memoryContextHeader)
+	var included []*memory.Memory
+	for _, m := range found {
+		line := "- " + strings.TrimSpace(m.Content) + "\n"
+		cost := estimateTokens(line)
+		if used+cost > cfg.TokenBudget {
+			// A smaller, lower-ranked memory may still fit.
+			continue
+		}
+		used += cost
+		b.WriteString(line)
+		included = append(included, m)
+	}
+	if len(included) == 0 {
+		return "", nil, nil
+	}
+	return memoryContextHeader + b.String(), included, nil
+}
+
+// remember saves a completed exchange as an episodic memory.
+func (a *Agent) remember(ctx context.Context, store memory.VectorStore, input, output string) error {
+	m := &memory.Memory{
+		Type:     memory.TypeEpisodic,
+		Content:  "User: " + input + "\nAssistant: " + output,
+		Metadata: map[string]interface{}{MetaAgentID: a.config.ID},
+	}
+	if err := store.Save(ctx, m); err != nil {
+		return fmt.Errorf("save episode: %w", err)
+	}
+	return nil
+}
+
+// memoryMessage wraps a context block as a system message.
+func memoryMessage(block string) provider.Message {
+	return provider.Message{Role: "system", Content: block}
+}
+
+// estimateTokens approximates a token count at four characters per token.
+func estimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Default BM25 parameters.
+const (
+	DefaultBM25K1 = 1.2
+	DefaultBM25B  = 0.75
+)
+
+// KeywordHit is a single BM25 match.
+type KeywordHit struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// BM25Index is an in-memory Okapi BM25 index over memory content, tokenized
+// with Tokenize. It is safe for concurrent use.
+type BM25Index struct {
+	mu       sync.RWMutex
+	k1, b    float64
+	postings map[string]map[string]int // term -> id -> frequency
+	docTerms map[string][]string       // id -> distinct terms
+	lengths  map[string]int
+	totalLen int
+}
+
+// NewBM25Index creates an index with the default parameters.
+func NewBM25Index() *BM25Index {
+	return NewBM25IndexWithParams(DefaultBM25K1, DefaultBM25B)
+}
+
+// NewBM25IndexWithParams creates an index with custom k1 (term frequency
+// saturation) and b (length normalization) parameters.
+func NewBM25IndexWithParams(k1, b float64) *BM25Index {
+	return &BM25Index{
+		k1:       k1,
+		b:        b,
+		postings: make(map[string]map[string]int),
+		docTerms: make(map[string][]string),
+		lengths:  make(map[string]int),
+	}
+}
+
+// Add indexes a memory's content, replacing any previous entry for its ID.
+func (idx *BM25Index) Add(m *Memory) {
+	tokens := Tokenize(m.Content)
+	tf := make(map[string]int, len(tokens))
+	for _, t := range tokens {
+		tf[t]++
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(m.ID)
+	terms := make([]string, 0, len(tf))
+	for t, f := range tf {
+		if idx.postings[t] == nil {
+			idx.postings[t] = make(map[string]int)
+		}
+		idx.postings[t][m.ID] = f
+		terms = append(terms, t)
+	}
+	idx.docTerms[m.ID] = terms
+	idx.lengths[m.ID] = len(tokens)
+	idx.totalLen += len(tokens)
+}
+
+// Remove drops a memory from the index.
+func (idx *BM25Index) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *BM25Index) remove(id string) {
+	terms, ok := idx.docTerms[id]
+	if !ok {
+		return
+	}
+	for _, t := range terms {
+		delete(idx.postings[t], id)
+		if len(idx.postings[t]) == 0 {
+			delete(idx.postings, t)
+		}
+	}
+	idx.totalLen -= idx.lengths[id]
+	delete(idx.docTerms, id)
+	delete(idx.lengths, id)
+}
+
+// Clear empties the index.
+func (idx *BM25Index) Clear() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.postings = make(map[string]map[string]int)
+	idx.docTerms = make(map[string][]string)
+	idx.lengths = make(map[string]int)
+	idx.totalLen = 0
+}
+
+// Len returns the number of indexed memories.
+func (idx *BM25Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docTerms)
+}
+
+// Search returns the best matching memory IDs for query, highest score
+// first. A limit of zero or less returns all matches.
+func (idx *BM25Index) Search(query string, limit int) []KeywordHit {
+	terms := make(map[string]bool)
+	for _, t := range Tokenize(query) {
+		terms[t] = true
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.docTerms))
+	if n == 0 || len(terms) == 0 {
+		return nil
+	}
+	avgLen := float64(idx.totalLen) / n
+
+	scores := make(map[string]float64)
+	for t := range terms {
+		posting := idx.postings[t]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range posting {
+			f := float64(tf)
+			norm := 1 - idx.b
+			if avgLen > 0 {
+				norm += idx.b * float64(idx.lengths[id]) / avgLen
+			}
+			scores[id] += idf * f * (idx.k1 + 1) / (f + idx.k1*norm)
+		}
+	}
+
+	hits := make([]KeywordHit, 0, len(scores))
+	for id, s := range scores {
+		hits = append(hits, KeywordHit{ID: id, Score: s})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && limit < len(hits) {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,71 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/memory/memorytest"
+)
+
+func TestInMemoryStoreConformance(t *testing.T) {
+	memorytest.TestStore(t, func(t *testing.T) memory.Store {
+		return memory.NewInMemoryStore()
+	})
+}
+
+func TestFileStoreConformance(t *testing.T) {
+	memorytest.TestStore(t, func(t *testing.T) memory.Store {
+		s, err := memory.NewFileStore(memory.FileStoreConfig{Dir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestInMemoryVectorStoreConformance(t *testing.T) {
+	memorytest.TestVectorStore(t, func(t *testing.T, e memory.Embedder) memory.VectorStore {
+		return memory.NewInMemoryVectorStore(e)
+	})
+}
+
+func TestScopedStoreConformance(t *testing.T) {
+	memorytest.TestVectorStore(t, func(t *testing.T, e memory.Embedder) memory.VectorStore {
+		n := memory.NewNamespaced(memory.NewInMemoryVectorStore(e))
+		// A neighbouring namespace must not leak into the one under test.
+		other, err := n.Scope(memory.Namespace{Tenant: "other"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := other.Save(context.Background(), &memory.Memory{ID: "noise", Content: "go rust python"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		s, err := n.Scope(memory.Namespace{Tenant: "acme", Agent: "coder", Project: "api"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return s
+	})
+}
+
+func TestQuantizedVectorStoreConformance(t *testing.T) {
+	// Lossy methods keep full precision so embeddings round-trip exactly.
+	for _, cfg := range []memory.QuantizationConfig{
+		{Method: memory.QuantizeFloat32},
+		{Method: memory.QuantizeInt8, KeepFullPrecision: true},
+		{Method: memory.QuantizeBinary, KeepFullPrecision: true},
+	} {
+		cfg := cfg
+		t.Run(string(cfg.Method), func(t *testing.T) {
+			memorytest.TestVectorStore(t, func(t *testing.T, e memory.Embedder) memory.VectorStore {
+				s, err := memory.NewQuantizedVectorStore(e, cfg)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return s
+			})
+		})
+	}
+}
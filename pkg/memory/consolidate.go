@@ -0,0 +1,298 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/provider"
+)
+
+// Metadata keys recording consolidation provenance.
+const (
+	// MetaSources lists the IDs of the episodes a semantic fact was
+	// distilled from.
+	MetaSources = "sources"
+	// MetaSupersededBy lists the IDs of the facts that replaced an
+	// archived episode.
+	MetaSupersededBy = "superseded_by"
+)
+
+const consolidationPrompt = `You consolidate an AI coding agent's episodic memories into durable semantic facts.
+Given a group of related episodes, state the general facts or lessons they support.
+Reply with one fact per line, each starting with "- ". Write facts that stand on their own without referring to the episodes.`
+
+// ConsolidationConfig contains consolidation configuration.
+type ConsolidationConfig struct {
+	// SimilarityThreshold is the minimum cosine similarity between an
+	// episode and a cluster's centroid for it to join the cluster.
+	// Defaults to 0.8.
+	SimilarityThreshold float64 `json:"similarity_threshold,omitempty"`
+	// TimeWindow is the maximum gap between consecutive episodes in a
+	// cluster. Defaults to 24 hours.
+	TimeWindow time.Duration `json:"time_window,omitempty"`
+	// MinClusterSize is the fewest episodes worth consolidating. Defaults to 2.
+	MinClusterSize int `json:"min_cluster_size,omitempty"`
+	// Model is passed to the provider; empty uses the provider default.
+	Model string `json:"model,omitempty"`
+	// Embedder, if set, embeds episodes stored without an embedding.
+	// Episodes that still lack an embedding are skipped.
+	Embedder Embedder `json:"-"`
+}
+
+// ConsolidationReport summarizes a consolidation run.
+type ConsolidationReport struct {
+	Clusters int       `json:"clusters"`
+	Facts    []*Memory `json:"facts"`
+	Archived []string  `json:"archived"`
+}
+
+// Consolidator distills clusters of related episodic memories into semantic
+// facts using an LLM. Facts link back to their episodes through MetaSources,
+// and the episodes are archived with MetaSupersededBy pointing at the facts.
+type Consolidator struct {
+	store    Store
+	provider provider.Provider
+	config   ConsolidationConfig
+	// OnError, if set, receives errors from scheduled runs.
+	OnError func(error)
+
+	mu   sync.Mutex
+	loop periodic
+}
+
+// NewConsolidator creates a consolidator for the given store.
+func NewConsolidator(s Store, p provider.Provider, cfg ConsolidationConfig) *Consolidator {
+	if cfg.SimilarityThreshold == 0 {
+		cfg.SimilarityThreshold = 0.8
+	}
+	if cfg.TimeWindow == 0 {
+		cfg.TimeWindow = 24 * time.Hour
+	}
+	if cfg.MinClusterSize == 0 {
+		cfg.MinClusterSize = 2
+	}
+	return &Consolidator{store: s, provider: p, config: cfg}
+}
+
+// Start runs consolidation every interval until ctx is done or Stop is called.
+func (c *Consolidator) Start(ctx context.Context, interval time.Duration) {
+	c.loop.start(ctx, interval, func(ctx context.Context, _ time.Time) {
+		if _, err := c.Run(ctx); err != nil && c.OnError != nil {
+			c.OnError(err)
+		}
+	})
+}
+
+// Stop halts scheduled consolidation and waits for an in-flight run.
+func (c *Consolidator) Stop() {
+	c.loop.stop()
+}
+
+// Run consolidates all live, unarchived episodic memories once.
+func (c *Consolidator) Run(ctx context.Context) (*ConsolidationReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	episodes, err := c.store.List(ctx, &Filter{Type: TypeEpisodic, OrderBy: OrderByCreatedAt})
+	if err != nil {
+		return nil, fmt.Errorf("consolidate: list episodes: %w", err)
+	}
+	if err := c.ensureEmbeddings(ctx, episodes); err != nil {
+		return nil, err
+	}
+
+	report := &ConsolidationReport{}
+	for _, cluster := range c.cluster(episodes) {
+		if len(cluster) < c.config.MinClusterSize {
+			continue
+		}
+		report.Clusters++
+
+		facts, err := c.distill(ctx, cluster)
+		if err != nil {
+			return report, err
+		}
+		if len(facts) == 0 {
+			continue
+		}
+		report.Facts = append(report.Facts, facts...)
+
+		// Facts are saved before episodes are archived, so an interruption
+		// leaves duplicates rather than losing information.
+		factIDs := make([]interface{}, len(facts))
+		for i, f := range facts {
+			factIDs[i] = f.ID
+		}
+		for _, ep := range cluster {
+			ep = ep.clone()
+			ep.Archived = true
+			if ep.Metadata == nil {
+				ep.Metadata = make(map[string]interface{})
+			}
+			ep.Metadata[MetaSupersededBy] = factIDs
+			if err := c.store.Save(ctx, ep); err != nil {
+				return report, fmt.Errorf("consolidate: archive episode %s: %w", ep.ID, err)
+			}
+			report.Archived = append(report.Archived, ep.ID)
+		}
+	}
+	return report, nil
+}
+
+func (c *Consolidator) ensureEmbeddings(ctx context.Context, episodes []*Memory) error {
+	if c.config.Embedder == nil {
+		return nil
+	}
+	var missing []*Memory
+	var texts []string
+	for i, ep := range episodes {
+		if ep.Embedding == nil && ep.Content != "" {
+			// Work on a copy; some stores hand out their internal pointers.
+			episodes[i] = ep.clone()
+			missing = append(missing, episodes[i])
+			texts = append(texts, ep.Content)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	embeddings, err := c.config.Embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("consolidate: embed episodes: %w", err)
+	}
+	for i, ep := range missing {
+		ep.Embedding = embeddings[i]
+	}
+	return nil
+}
+
+// cluster greedily groups episodes, oldest first. An episode joins the most
+// similar open cluster whose centroid is within the similarity threshold and
+// whose latest episode is within the time window; otherwise it starts a new
+// cluster.
+func (c *Consolidator) cluster(episodes []*Memory) [][]*Memory {
+	type group struct {
+		members  []*Memory
+		centroid []float64
+		last     time.Time
+	}
+	var groups []*group
+
+	for _, ep := range episodes {
+		if ep.Embedding == nil {
+			continue
+		}
+		var best *group
+		bestSim := c.config.SimilarityThreshold
+		for _, g := range groups {
+			if ep.CreatedAt.Sub(g.last) > c.config.TimeWindow {
+				continue
+			}
+			if sim := CosineSimilarity(ep.Embedding, g.centroid); sim >= bestSim {
+				best, bestSim = g, sim
+			}
+		}
+		if best == nil {
+			best = &group{centroid: make([]float64, len(ep.Embedding))}
+			groups = append(groups, best)
+		}
+
+		n := float64(len(best.members))
+		for i := range best.centroid {
+			best.centroid[i] = (best.centroid[i]*n + ep.Embedding[i]) / (n + 1)
+		}
+		best.members = append(best.members, ep)
+		best.last = ep.CreatedAt
+	}
+
+	clusters := make([][]*Memory, len(groups))
+	for i, g := range groups {
+		clusters[i] = g.members
+	}
+	return clusters
+}
+
+// distill asks the provider for facts supported by the cluster and saves
+// them as semantic memories.
+func (c *Consolidator) distill(ctx context.Context, cluster []*Memory) ([]*Memory, error) {
+	var b strings.Builder
+	b.WriteString("Episodes:\n")
+	for i, ep := range cluster {
+		fmt.Fprintf(&b, "%d. [%s] %s\n", i+1, ep.CreatedAt.UTC().Format(time.RFC3339), ep.Content)
+	}
+
+	resp, err := c.provider.Complete(ctx, &provider.CompletionRequest{
+		Model: c.config.Model,
+		Messages: []provider.Message{
+			{Role: "system", Content: consolidationPrompt},
+			{Role: "user", Content: b.String()},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("consolidate: distill: %w", err)
+	}
+
+	sources := make([]interface{}, len(cluster))
+	var importance float64
+	for i, ep := range cluster {
+		sources[i] = ep.ID
+		if ep.Importance > importance {
+			importance = ep.Importance
+		}
+	}
+
+	var facts []*Memory
+	for _, text := range parseFacts(resp.Content) {
+		f := &Memory{
+			Type:       TypeSemantic,
+			Content:    text,
+			Importance: importance,
+			Metadata:   map[string]interface{}{MetaSources: sources},
+		}
+		if c.config.Embedder != nil {
+			if f.Embedding, err = c.config.Embedder.Embed(ctx, text); err != nil {
+				return nil, fmt.Errorf("consolidate: embed fact: %w", err)
+			}
+		}
+		if err := c.store.Save(ctx, f); err != nil {
+			return nil, fmt.Errorf("consolidate: save fact: %w", err)
+		}
+		facts = append(facts, f)
+	}
+	return facts, nil
+}
+
+// parseFacts extracts "- " bulleted lines from a response. A response
+// without bullets is treated as a single fact.
+func parseFacts(content string) []string {
+	var facts []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if fact, ok := strings.CutPrefix(line, "- "); ok {
+			if fact = strings.TrimSpace(fact); fact != "" {
+				facts = append(facts, fact)
+			}
+		}
+	}
+	if len(facts) == 0 {
+		if content = strings.TrimSpace(content); content != "" {
+			facts = append(facts, content)
+		}
+	}
+	return facts
+}
+
+// Sources returns the provenance IDs recorded on a consolidated fact.
+func Sources(m *Memory) []string {
+	raw, _ := m.Metadata[MetaSources].([]interface{})
+	ids := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if id, ok := v.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/provider"
+)
+
+// factProvider answers every completion with a fixed response and records
+// the prompts it was given.
+type factProvider struct {
+	response string
+	prompts  []string
+}
+
+func (p *factProvider) Name() string { return "fact" }
+
+func (p *factProvider) Complete(ctx context.Context, req *provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	p.prompts = append(p.prompts, req.Messages[len(req.Messages)-1].Content)
+	return &provider.CompletionResponse{Content: p.response}, nil
+}
+
+func (p *factProvider) Stream(ctx context.Context, req *provider.CompletionRequest, handler provider.StreamHandler) error {
+	return nil
+}
+
+func (p *factProvider) Models(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func TestConsolidator(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+	base := time.Now().Add(-48 * time.Hour)
+
+	episodes := []*Memory{
+		{ID: "db1", Content: "database test failed", CreatedAt: base, Importance: 0.4},
+		{ID: "db2", Content: "database test failed again", CreatedAt: base.Add(time.Hour), Importance: 0.9},
+		{ID: "deploy", Content: "deploy config", CreatedAt: base.Add(2 * time.Hour)},
+		// Similar content, but too far from the database cluster in time.
+		{ID: "db3", Content: "database test failed", CreatedAt: base.Add(40 * time.Hour)},
+	}
+	for _, ep := range episodes {
+		ep.Type = TypeEpisodic
+		store.Save(ctx, ep)
+	}
+
+	p := &factProvider{response: "- The database tests are flaky\n- Run them twice before bisecting"}
+	c := NewConsolidator(store, p, ConsolidationConfig{Embedder: newWordEmbedder(), TimeWindow: 12 * time.Hour})
+	report, err := c.Run(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Clusters != 1 || len(p.prompts) != 1 {
+		t.Fatalf("expected 1 consolidated cluster, got %d (%d prompts)", report.Clusters, len(p.prompts))
+	}
+	if !strings.Contains(p.prompts[0], "database test failed again") {
+		t.Errorf("expected episodes in prompt, got %q", p.prompts[0])
+	}
+	if len(report.Facts) != 2 {
+		t.Fatalf("expected 2 facts, got %d", len(report.Facts))
+	}
+
+	fact, err := store.Get(ctx, report.Facts[0].ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fact.Type != TypeSemantic || fact.Content != "The database tests are flaky" {
+		t.Errorf("unexpected fact: %s %q", fact.Type, fact.Content)
+	}
+	if got := Sources(fact); !reflect.DeepEqual(got, []string{"db1", "db2"}) {
+		t.Errorf("expected sources [db1 db2], got %v", got)
+	}
+	if fact.Importance != 0.9 {
+		t.Errorf("expected fact to inherit max importance 0.9, got %v", fact.Importance)
+	}
+
+	if !reflect.DeepEqual(report.Archived, []string{"db1", "db2"}) {
+		t.Errorf("expected db1 and db2 archived, got %v", report.Archived)
+	}
+	archived, _ := store.Get(ctx, "db1")
+	if !archived.Archived || archived.Metadata[MetaSupersededBy] == nil {
+		t.Error("expected archived episode with superseded_by link")
+	}
+	live, _ := store.List(ctx, &Filter{Type: TypeEpisodic})
+	if len(live) != 2 {
+		t.Errorf("expected 2 live episodes, got %d", len(live))
+	}
+
+	// A second run has nothing left to consolidate.
+	report, err = c.Run(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Clusters != 0 {
+		t.Errorf("expected no clusters on second run, got %d", report.Clusters)
+	}
+}
+
+func TestParseFacts(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"- a\n- b", []string{"a", "b"}},
+		{"Facts:\n  - a  \n\n-   \n- b", []string{"a", "b"}},
+		{"single fact without bullets", []string{"single fact without bullets"}},
+		{"   ", nil},
+	}
+	for _, tt := range tests {
+		if got := parseFacts(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFacts(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// AccessRecorder is implemented by stores that can record a retrieval
+// without treating it as a content update.
+type AccessRecorder interface {
+	// RecordAccess increments the memory's AccessCount and sets its
+	// LastAccessedAt to at, leaving UpdatedAt untouched.
+	RecordAccess(ctx context.Context, id string, at time.Time) error
+}
+
+// RecordAccess records a retrieval of the memory with the given ID. Stores
+// that do not implement AccessRecorder fall back to Get followed by Save,
+// which also refreshes UpdatedAt.
+func RecordAccess(ctx context.Context, s Store, id string, at time.Time) error {
+	if r, ok := s.(AccessRecorder); ok {
+		return r.RecordAccess(ctx, id, at)
+	}
+	m, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	m = m.clone()
+	m.AccessCount++
+	m.LastAccessedAt = &at
+	return s.Save(ctx, m)
+}
+
+// DefaultDecayRate is the per-hour recency decay factor used by the
+// generative agents memory stream.
+const DefaultDecayRate = 0.995
+
+// RecallScorer ranks memories by a weighted sum of recency, importance and
+// relevance, in the style of generative agents. Recency decays
+// exponentially with hours since the memory was last accessed (or created),
+// importance is Memory.Importance, and relevance is the query similarity.
+type RecallScorer struct {
+	RecencyWeight    float64 `json:"recency_weight"`
+	ImportanceWeight float64 `json:"importance_weight"`
+	RelevanceWeight  float64 `json:"relevance_weight"`
+	// DecayRate is the recency multiplier applied per elapsed hour.
+	DecayRate float64 `json:"decay_rate"`
+}
+
+// DefaultRecallScorer weights all three signals equally.
+func DefaultRecallScorer() *RecallScorer {
+	return &RecallScorer{
+		RecencyWeight:    1,
+		ImportanceWeight: 1,
+		RelevanceWeight:  1,
+		DecayRate:        DefaultDecayRate,
+	}
+}
+
+// Recency returns the decayed recency of m at now, in (0, 1].
+func (s *RecallScorer) Recency(m *Memory, now time.Time) float64 {
+	last := m.CreatedAt
+	if m.LastAccessedAt != nil && m.LastAccessedAt.After(last) {
+		last = *m.LastAccessedAt
+	}
+	hours := now.Sub(last).Hours()
+	if hours < 0 {
+		hours = 0
+	}
+	return math.Pow(s.DecayRate, hours)
+}
+
+// Score combines recency, importance and a similarity in [-1, 1] into a
+// single retrieval score. Negative similarity counts as no relevance.
+func (s *RecallScorer) Score(m *Memory, similarity float64, now time.Time) float64 {
+	return s.RecencyWeight*s.Recency(m, now) +
+		s.ImportanceWeight*clamp01(m.Importance) +
+		s.RelevanceWeight*clamp01(similarity)
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// Retriever recalls memories from a VectorStore ranked by a RecallScorer and
+// records each returned memory as accessed.
+type Retriever struct {
+	store  VectorStore
+	scorer *RecallScorer
+	// Candidates is how many similarity matches are rescored. Defaults to
+	// four times the requested limit, at least 20.
+	Candidates int
+	// MinSimilarity drops candidates whose similarity to the query is
+	// below it, before they are scored.
+	MinSimilarity float64
+}
+
+// NewRetriever creates a retriever. A nil scorer uses DefaultRecallScorer.
+func NewRetriever(store VectorStore, scorer *RecallScorer) *Retriever {
+	if scorer == nil {
+		scorer = DefaultRecallScorer()
+	}
+	return &Retriever{store: store, scorer: scorer}
+}
+
+// Recall returns up to limit memories for query, best first, with Score set
+// to the combined recall score.
+func (r *Retriever) Recall(ctx context.Context, query string, limit int) ([]*Memory, error) {
+	candidates := r.Candidates
+	if candidates <= 0 {
+		candidates = 4 * limit
+		if candidates < 20 {
+			candidates = 20
+		}
+	}
+	found, err := r.store.SearchByText(ctx, query, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("recall: %w", err)
+	}
+
+	now := time.Now()
+	kept := found[:0]
+	for _, m := range found {
+		if m.Score < r.MinSimilarity {
+			continue
+		}
+		m.Score = r.scorer.Score(m, m.Score, now)
+		kept = append(kept, m)
+	}
+	found = kept
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Score > found[j].Score
+	})
+	if limit > 0 && limit < len(found) {
+		found = found[:limit]
+	}
+
+	for _, m := range found {
+		if err := RecordAccess(ctx, r.store, m.ID, now); err != nil {
+			return nil, fmt.Errorf("recall: record access: %w", err)
+		}
+		m.AccessCount++
+		m.LastAccessedAt = &now
+	}
+	return found, nil
+}
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRecallScorer(t *testing.T) {
+	s := DefaultRecallScorer()
+	now := time.Now()
+
+	fresh := &Memory{CreatedAt: now}
+	if r := s.Recency(fresh, now); r != 1 {
+		t.Errorf("expected recency 1 for a new memory, got %v", r)
+	}
+
+	dayOld := &Memory{CreatedAt: now.Add(-24 * time.Hour)}
+	if r, want := s.Recency(dayOld, now), math.Pow(DefaultDecayRate, 24); math.Abs(r-want) > 1e-9 {
+		t.Errorf("expected recency %v, got %v", want, r)
+	}
+
+	// Access refreshes recency.
+	dayOld.LastAccessedAt = &now
+	if r := s.Recency(dayOld, now); r != 1 {
+		t.Errorf("expected recency 1 after access, got %v", r)
+	}
+
+	important := &Memory{CreatedAt: now.Add(-48 * time.Hour), Importance: 1}
+	trivial := &Memory{CreatedAt: now.Add(-48 * time.Hour), Importance: 0.1}
+	if s.Score(important, 0.5, now) <= s.Score(trivial, 0.5, now) {
+		t.Error("expected importance to raise the score")
+	}
+	if s.Score(trivial, -1, now) != s.Score(trivial, 0, now) {
+		t.Error("expected negative similarity to count as zero relevance")
+	}
+}
+
+func TestRetriever(t *testing.T) {
+	store := NewInMemoryVectorStore(newWordEmbedder())
+	ctx := context.Background()
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	store.Save(ctx, &Memory{ID: "trivia", Content: "go test", CreatedAt: old, Importance: 0})
+	store.Save(ctx, &Memory{ID: "lesson", Content: "go test", CreatedAt: old, Importance: 1})
+	store.Save(ctx, &Memory{ID: "unrelated", Content: "deploy", CreatedAt: old})
+
+	r := NewRetriever(store, nil)
+	got, err := r.Recall(ctx, "go test", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != "lesson" {
+		t.Fatalf("expected important lesson first, got %d results", len(got))
+	}
+
+	stored, _ := store.Get(ctx, "lesson")
+	if stored.AccessCount != 1 || stored.LastAccessedAt == nil {
+		t.Errorf("expected access to be recorded, got count %d", stored.AccessCount)
+	}
+	if unrelated, _ := store.Get(ctx, "unrelated"); unrelated.AccessCount != 0 {
+		t.Error("expected unreturned memory to stay unaccessed")
+	}
+}
+
+func TestExpireMemories(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+
+	expiring := &Memory{ID: "short", Content: "short lived"}
+	expiring.ExpireAfter(time.Millisecond)
+	store.Save(ctx, expiring)
+	store.Save(ctx, &Memory{ID: "long", Content: "long lived"})
+
+	n, err := ExpireMemories(ctx, store, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 expired memory, got %d", n)
+	}
+	if _, err := store.Get(ctx, "short"); err == nil {
+		t.Error("expected expired memory to be deleted")
+	}
+}
+
+func TestExpirer(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+
+	m := &Memory{Content: "ttl"}
+	m.ExpireAfter(5 * time.Millisecond)
+	store.Save(ctx, m)
+
+	e := NewExpirer(store, 5*time.Millisecond)
+	e.Start(ctx)
+	defer e.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := store.Get(ctx, m.ID); err != nil {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("expected background expiry to delete the memory")
+}
+
+func TestSession(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+
+	s := NewSession(store, time.Hour)
+	scratch := &Memory{Content: "current file is main.go"}
+	s.Save(ctx, scratch)
+	fact := &Memory{Type: TypeSemantic, Content: "repo uses cobra"}
+	s.Save(ctx, fact)
+	other := NewSession(store, 0)
+	other.Save(ctx, &Memory{Content: "other session scratch"})
+
+	if scratch.Type != TypeWorking || scratch.ExpiresAt == nil {
+		t.Error("expected untyped session memory to become expiring working memory")
+	}
+	if fact.ExpiresAt != nil {
+		t.Error("expected non-working memory to keep no expiry")
+	}
+	working, _ := s.Working(ctx)
+	if len(working) != 1 {
+		t.Errorf("expected 1 working memory, got %d", len(working))
+	}
+
+	if err := s.End(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.Get(ctx, scratch.ID); err == nil {
+		t.Error("expected working memory to be deleted at session end")
+	}
+	if _, err := store.Get(ctx, fact.ID); err != nil {
+		t.Error("expected semantic memory to survive session end")
+	}
+	if remaining, _ := other.Working(ctx); len(remaining) != 1 {
+		t.Error("expected other session's working memory to survive")
+	}
+}
@@ -0,0 +1,276 @@
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Metadata keys maintained by DedupStore.
+const (
+	// MetaContentHash is the SHA-256 of a memory's normalized content.
+	MetaContentHash = "content_hash"
+	// MetaOccurrences counts how many times a memory has been saved,
+	// including duplicates folded into it.
+	MetaOccurrences = "occurrences"
+)
+
+// maxCachedSignatures bounds the MinHash signatures a DedupStore keeps.
+const maxCachedSignatures = 10000
+
+// ErrDuplicate is returned by DedupStore.Save under DedupReject.
+var ErrDuplicate = errors.New("duplicate memory")
+
+// DedupPolicy decides what happens when a saved memory duplicates an
+// existing one.
+type DedupPolicy string
+
+const (
+	// DedupMerge folds the new memory's metadata into the existing one,
+	// keeps the higher importance and bumps its occurrence count.
+	DedupMerge DedupPolicy = "merge"
+	// DedupCount only bumps the existing memory's occurrence count.
+	DedupCount DedupPolicy = "count"
+	// DedupReject returns ErrDuplicate.
+	DedupReject DedupPolicy = "reject"
+)
+
+// DedupConfig contains duplicate detection configuration. Exact duplicates
+// of the normalized content are always detected; the near-duplicate checks
+// are enabled by setting their thresholds.
+type DedupConfig struct {
+	// Policy defaults to DedupMerge.
+	Policy DedupPolicy `json:"policy,omitempty"`
+	// SimilarityThreshold, if positive, treats memories whose embeddings
+	// have at least this cosine similarity as duplicates.
+	SimilarityThreshold float64 `json:"similarity_threshold,omitempty"`
+	// MinHashThreshold, if positive, treats memories whose estimated word
+	// shingle Jaccard similarity is at least this as duplicates.
+	MinHashThreshold float64 `json:"minhash_threshold,omitempty"`
+	// MinHashPermutations is the signature length. Defaults to
+	// DefaultMinHashPermutations.
+	MinHashPermutations int `json:"minhash_permutations,omitempty"`
+	// Embedder, if set, embeds memories saved without an embedding for the
+	// similarity check.
+	Embedder Embedder `json:"-"`
+}
+
+// DedupStore wraps a Store and detects duplicates on Save. Only memories of
+// the same type are compared. Near-duplicate search uses the wrapped store's
+// vector search when it is a VectorStore and scans List otherwise. Other
+// methods pass through; Search and SearchByText return an error when the
+// wrapped store is not a VectorStore.
+type DedupStore struct {
+	Store
+	config DedupConfig
+
+	mu         sync.Mutex
+	signatures map[string][]uint64 // by content hash
+}
+
+// NewDedupStore wraps s with duplicate detection.
+func NewDedupStore(s Store, cfg DedupConfig) *DedupStore {
+	if cfg.Policy == "" {
+		cfg.Policy = DedupMerge
+	}
+	if cfg.MinHashPermutations == 0 {
+		cfg.MinHashPermutations = DefaultMinHashPermutations
+	}
+	return &DedupStore{Store: s, config: cfg, signatures: make(map[string][]uint64)}
+}
+
+// NormalizeContent lowercases text and collapses whitespace.
+func NormalizeContent(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// ContentHash returns the hex SHA-256 of the normalized text.
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(NormalizeContent(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Occurrences returns the occurrence count recorded on m, or 1 if none is.
+func Occurrences(m *Memory) int {
+	switch v := m.Metadata[MetaOccurrences].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 1
+}
+
+// Save stores m unless it duplicates an existing memory, in which case the
+// policy applies. When a duplicate is folded into an existing memory, m.ID
+// is set to that memory's ID.
+func (s *DedupStore) Save(ctx context.Context, m *Memory) error {
+	if m.Metadata == nil {
+		m.Metadata = make(map[string]interface{})
+	}
+	hash := ContentHash(m.Content)
+	m.Metadata[MetaContentHash] = hash
+
+	if m.Embedding == nil && s.config.Embedder != nil && s.config.SimilarityThreshold > 0 && m.Content != "" {
+		emb, err := s.config.Embedder.Embed(ctx, m.Content)
+		if err != nil {
+			return fmt.Errorf("dedup: embed memory: %w", err)
+		}
+		m.Embedding = emb
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dup, err := s.findDuplicate(ctx, m, hash)
+	if err != nil {
+		return err
+	}
+	if dup == nil {
+		if _, ok := m.Metadata[MetaOccurrences]; !ok {
+			m.Metadata[MetaOccurrences] = 1
+		}
+		return s.Store.Save(ctx, m)
+	}
+
+	if s.config.Policy == DedupReject {
+		return fmt.Errorf("%w: matches %s", ErrDuplicate, dup.ID)
+	}
+	merged := dup.clone()
+	if merged.Metadata == nil {
+		merged.Metadata = make(map[string]interface{})
+	}
+	if s.config.Policy == DedupMerge {
+		for k, v := range m.Metadata {
+			if k == MetaContentHash || k == MetaOccurrences {
+				continue
+			}
+			merged.Metadata[k] = v
+		}
+		if m.Importance > merged.Importance {
+			merged.Importance = m.Importance
+		}
+	}
+	merged.Metadata[MetaOccurrences] = Occurrences(dup) + 1
+	if err := s.Store.Save(ctx, merged); err != nil {
+		return err
+	}
+	m.ID = dup.ID
+	return nil
+}
+
+// findDuplicate returns an existing memory that m duplicates, or nil.
+func (s *DedupStore) findDuplicate(ctx context.Context, m *Memory, hash string) (*Memory, error) {
+	exact, err := s.Store.List(ctx, &Filter{
+		Type:     m.Type,
+		Metadata: map[string]interface{}{MetaContentHash: hash},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dedup: find exact duplicates: %w", err)
+	}
+	for _, e := range exact {
+		if e.ID != m.ID && e.Type == m.Type {
+			return e, nil
+		}
+	}
+
+	nearEmbedding := s.config.SimilarityThreshold > 0 && m.Embedding != nil
+	nearMinHash := s.config.MinHashThreshold > 0 && m.Content != ""
+	if !nearEmbedding && !nearMinHash {
+		return nil, nil
+	}
+
+	if vs, ok := s.Store.(VectorStore); ok && nearEmbedding && !nearMinHash {
+		// Results are best first, so the first memory of the same type is
+		// the closest; widen the search until one turns up.
+		found, err := searchOwned(1, func(k int) ([]*Memory, error) {
+			return vs.Search(ctx, m.Embedding, k)
+		}, func(e *Memory) *Memory {
+			if e.ID == m.ID || e.Type != m.Type {
+				return nil
+			}
+			return e
+		})
+		if err != nil {
+			return nil, fmt.Errorf("dedup: search near duplicates: %w", err)
+		}
+		if len(found) == 1 && found[0].Score >= s.config.SimilarityThreshold {
+			return found[0], nil
+		}
+		return nil, nil
+	}
+
+	candidates, err := s.Store.List(ctx, &Filter{Type: m.Type})
+	if err != nil {
+		return nil, fmt.Errorf("dedup: list candidates: %w", err)
+	}
+	var sig []uint64
+	if nearMinHash {
+		sig = s.signature(hash, m.Content)
+	}
+	for _, e := range candidates {
+		if e.ID == m.ID {
+			continue
+		}
+		if nearEmbedding && e.Embedding != nil &&
+			CosineSimilarity(m.Embedding, e.Embedding) >= s.config.SimilarityThreshold {
+			return e, nil
+		}
+		if nearMinHash {
+			other := s.signature(ContentHash(e.Content), e.Content)
+			if MinHashSimilarity(sig, other) >= s.config.MinHashThreshold {
+				return e, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// signature returns the cached MinHash signature for content. Signatures
+// are keyed by content hash, so entries never go stale; once the cache
+// holds maxCachedSignatures, an arbitrary entry is evicted to make room.
+// The caller holds mu.
+func (s *DedupStore) signature(hash, content string) []uint64 {
+	if sig, ok := s.signatures[hash]; ok {
+		return sig
+	}
+	sig := MinHashSignature(content, s.config.MinHashPermutations)
+	if len(s.signatures) >= maxCachedSignatures {
+		for h := range s.signatures {
+			delete(s.signatures, h)
+			break
+		}
+	}
+	s.signatures[hash] = sig
+	return sig
+}
+
+// Clear removes all memories and drops the cached signatures.
+func (s *DedupStore) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signatures = make(map[string][]uint64)
+	return s.Store.Clear(ctx)
+}
+
+// Search finds similar memories using the wrapped VectorStore.
+func (s *DedupStore) Search(ctx context.Context, embedding []float64, limit int) ([]*Memory, error) {
+	vs, ok := s.Store.(VectorStore)
+	if !ok {
+		return nil, fmt.Errorf("search: store does not support vector search")
+	}
+	return vs.Search(ctx, embedding, limit)
+}
+
+// SearchByText finds similar memories using the wrapped VectorStore.
+func (s *DedupStore) SearchByText(ctx context.Context, text string, limit int) ([]*Memory, error) {
+	vs, ok := s.Store.(VectorStore)
+	if !ok {
+		return nil, fmt.Errorf("search by text: store does not support vector search")
+	}
+	return vs.SearchByText(ctx, text, limit)
+}
@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDedupExact(t *testing.T) {
+	ctx := context.Background()
+	s := NewDedupStore(NewInMemoryStore(), DedupConfig{})
+
+	first := &Memory{Type: TypeSemantic, Content: "Run go vet before committing", Metadata: map[string]interface{}{"repo": "api"}}
+	if err := s.Save(ctx, first); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	again := &Memory{Type: TypeSemantic, Content: "  run GO vet   before committing ", Importance: 0.9, Metadata: map[string]interface{}{"source": "review"}}
+	if err := s.Save(ctx, again); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("expected duplicate to resolve to %s, got %s", first.ID, again.ID)
+	}
+
+	all, _ := s.List(ctx, nil)
+	if len(all) != 1 {
+		t.Fatalf("expected 1 memory, got %d", len(all))
+	}
+	got := all[0]
+	if Occurrences(got) != 2 {
+		t.Errorf("expected 2 occurrences, got %d", Occurrences(got))
+	}
+	if got.Metadata["repo"] != "api" || got.Metadata["source"] != "review" {
+		t.Errorf("expected merged metadata, got %v", got.Metadata)
+	}
+	if got.Importance != 0.9 {
+		t.Errorf("expected importance 0.9, got %v", got.Importance)
+	}
+
+	// Other types are not compared.
+	if err := s.Save(ctx, &Memory{Type: TypeEpisodic, Content: "run go vet before committing"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if all, _ := s.List(ctx, nil); len(all) != 2 {
+		t.Errorf("expected 2 memories, got %d", len(all))
+	}
+}
+
+func TestDedupPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	reject := NewDedupStore(NewInMemoryStore(), DedupConfig{Policy: DedupReject})
+	reject.Save(ctx, &Memory{Content: "lesson"})
+	if err := reject.Save(ctx, &Memory{Content: "Lesson"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+
+	count := NewDedupStore(NewInMemoryStore(), DedupConfig{Policy: DedupCount})
+	count.Save(ctx, &Memory{Content: "lesson", Metadata: map[string]interface{}{"a": 1}})
+	dup := &Memory{Content: "lesson", Metadata: map[string]interface{}{"b": 2}}
+	count.Save(ctx, dup)
+	got, _ := count.Get(ctx, dup.ID)
+	if Occurrences(got) != 2 {
+		t.Errorf("expected 2 occurrences, got %d", Occurrences(got))
+	}
+	if _, ok := got.Metadata["b"]; ok {
+		t.Error("expected count policy to leave metadata unmerged")
+	}
+}
+
+func TestDedupNearDuplicates(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Embedding", func(t *testing.T) {
+		s := NewDedupStore(NewInMemoryVectorStore(newWordEmbedder()), DedupConfig{
+			SimilarityThreshold: 0.95,
+			Embedder:            newWordEmbedder(),
+		})
+		s.Save(ctx, &Memory{Content: "go test"})
+		s.Save(ctx, &Memory{Content: "go test!"})
+		s.Save(ctx, &Memory{Content: "deploy config"})
+		if all, _ := s.List(ctx, nil); len(all) != 2 {
+			t.Errorf("expected 2 memories, got %d", len(all))
+		}
+	})
+
+	t.Run("EmbeddingOtherTypesCloser", func(t *testing.T) {
+		vs := NewInMemoryVectorStore(nil)
+		for i := 0; i < 20; i++ {
+			vs.Save(ctx, &Memory{Type: TypeEpisodic, Content: fmt.Sprintf("episode %d", i), Embedding: []float64{1, 0}})
+		}
+		s := NewDedupStore(vs, DedupConfig{SimilarityThreshold: 0.95})
+		s.Save(ctx, &Memory{Type: TypeSemantic, Content: "fact", Embedding: []float64{0.99, 0.14}})
+		s.Save(ctx, &Memory{Type: TypeSemantic, Content: "same fact", Embedding: []float64{1, 0}})
+		if all, _ := s.List(ctx, &Filter{Type: TypeSemantic}); len(all) != 1 {
+			t.Errorf("expected 1 semantic memory, got %d", len(all))
+		}
+	})
+
+	t.Run("MinHash", func(t *testing.T) {
+		s := NewDedupStore(NewInMemoryStore(), DedupConfig{MinHashThreshold: 0.5})
+		s.Save(ctx, &Memory{Content: "always run the database migrations before starting the integration tests"})
+		s.Save(ctx, &Memory{Content: "always run the database migrations before starting the integration tests locally"})
+		s.Save(ctx, &Memory{Content: "the deploy pipeline needs a signed tag"})
+		if all, _ := s.List(ctx, nil); len(all) != 2 {
+			t.Errorf("expected 2 memories, got %d", len(all))
+		}
+	})
+}
+
+func TestDedupSignatureCache(t *testing.T) {
+	s := NewDedupStore(NewInMemoryStore(), DedupConfig{MinHashPermutations: 4})
+	for i := 0; i < maxCachedSignatures+10; i++ {
+		content := fmt.Sprintf("memory %d", i)
+		s.signature(ContentHash(content), content)
+	}
+	if n := len(s.signatures); n > maxCachedSignatures {
+		t.Errorf("expected at most %d cached signatures, got %d", maxCachedSignatures, n)
+	}
+	if err := s.Clear(context.Background()); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if n := len(s.signatures); n != 0 {
+		t.Errorf("expected Clear to drop cached signatures, got %d", n)
+	}
+}
+
+func TestMinHashSimilarity(t *testing.T) {
+	a := MinHashSignature("the quick brown fox jumps over the lazy dog", 128)
+	b := MinHashSignature("The quick brown fox jumps over the lazy dog", 128)
+	c := MinHashSignature("an entirely different sentence about databases", 128)
+	if sim := MinHashSimilarity(a, b); sim != 1 {
+		t.Errorf("expected identical signatures, got %v", sim)
+	}
+	if sim := MinHashSimilarity(a, c); sim > 0.2 {
+		t.Errorf("expected low similarity, got %v", sim)
+	}
+}
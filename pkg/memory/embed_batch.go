@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchConfig contains BatchingEmbedder configuration.
+type BatchConfig struct {
+	// MaxBatch is the most texts sent in one EmbedBatch call. Defaults to 64.
+	MaxBatch int `json:"max_batch,omitempty"`
+	// MaxWait is how long the first Embed call in a batch waits for others
+	// to join it. Defaults to 10ms.
+	MaxWait time.Duration `json:"max_wait,omitempty"`
+	// Timeout bounds each EmbedBatch call, so a hung embedder fails its
+	// batch instead of holding it forever. Defaults to one minute.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// BatchingEmbedder coalesces concurrent Embed calls into EmbedBatch calls
+// on the wrapped embedder. A batch is sent when it reaches MaxBatch texts
+// or MaxWait after its first text arrived, whichever comes first.
+//
+// Batches run detached from any single caller's context, bounded by
+// Timeout, so one caller giving up does not fail the others; a cancelled
+// caller returns immediately and its result is discarded.
+type BatchingEmbedder struct {
+	embedder Embedder
+	config   BatchConfig
+
+	mu      sync.Mutex
+	pending []*embedRequest
+	timer   *time.Timer
+	// batch counts flushes, so a timer that fires after its batch was
+	// already sent does not flush the next one early.
+	batch uint64
+}
+
+type embedRequest struct {
+	text string
+	done chan embedResult
+}
+
+type embedResult struct {
+	embedding []float64
+	err       error
+}
+
+// NewBatchingEmbedder wraps e with request coalescing.
+func NewBatchingEmbedder(e Embedder, cfg BatchConfig) *BatchingEmbedder {
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 64
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = 10 * time.Millisecond
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	return &BatchingEmbedder{embedder: e, config: cfg}
+}
+
+// Embed queues text for the next batch and waits for its embedding.
+func (b *BatchingEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	req := &embedRequest{text: text, done: make(chan embedResult, 1)}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, req)
+	switch {
+	case len(b.pending) >= b.config.MaxBatch:
+		b.flushLocked()
+	case len(b.pending) == 1:
+		batch := b.batch
+		b.timer = time.AfterFunc(b.config.MaxWait, func() { b.flush(batch) })
+	}
+	b.mu.Unlock()
+
+	select {
+	case res := <-req.done:
+		return res.embedding, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// EmbedBatch embeds texts directly, split into chunks of at most MaxBatch.
+func (b *BatchingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	out := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += b.config.MaxBatch {
+		end := start + b.config.MaxBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+		embeddings, err := b.embedder.EmbedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, embeddings...)
+	}
+	return out, nil
+}
+
+// Dimension returns the wrapped embedder's dimension.
+func (b *BatchingEmbedder) Dimension() int {
+	return b.embedder.Dimension()
+}
+
+// Model returns the wrapped embedder's model, if it names one.
+func (b *BatchingEmbedder) Model() string {
+	return EmbedderModel(b.embedder)
+}
+
+// flush sends the pending requests if they still form the given batch.
+func (b *BatchingEmbedder) flush(batch uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.batch == batch {
+		b.flushLocked()
+	}
+}
+
+// flushLocked sends the pending requests as one batch. The caller holds mu.
+func (b *BatchingEmbedder) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+	batch := b.pending
+	b.pending = nil
+	b.batch++
+	go b.run(batch)
+}
+
+func (b *BatchingEmbedder) run(batch []*embedRequest) {
+	texts := make([]string, len(batch))
+	for i, req := range batch {
+		texts[i] = req.text
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.Timeout)
+	defer cancel()
+	embeddings, err := b.embedder.EmbedBatch(ctx, texts)
+	if err == nil && len(embeddings) != len(batch) {
+		err = fmt.Errorf("embedder returned %d embeddings for %d texts", len(embeddings), len(batch))
+	}
+	for i, req := range batch {
+		if err != nil {
+			req.done <- embedResult{err: err}
+		} else {
+			req.done <- embedResult{embedding: embeddings[i]}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultEmbeddingCacheSize is the default number of embeddings held in the
+// memory tier of a CachingEmbedder.
+const DefaultEmbeddingCacheSize = 10000
+
+// CacheConfig contains CachingEmbedder configuration.
+type CacheConfig struct {
+	// Model is part of every cache key so embeddings from different models
+	// never mix. Defaults to the wrapped embedder's model.
+	Model string `json:"model,omitempty"`
+	// MaxEntries bounds the memory tier, evicting least recently used
+	// entries. Zero uses DefaultEmbeddingCacheSize.
+	MaxEntries int `json:"max_entries,omitempty"`
+	// Dir, if set, enables the disk tier: one file per embedding.
+	Dir string `json:"dir,omitempty"`
+}
+
+// CacheStats counts cache lookups by tier.
+type CacheStats struct {
+	MemoryHits int64 `json:"memory_hits"`
+	DiskHits   int64 `json:"disk_hits"`
+	Misses     int64 `json:"misses"`
+}
+
+// CachingEmbedder is an Embedder that caches another embedder's results,
+// keyed on the model and a hash of the exact text. Lookups try memory, then
+// disk, then the wrapped embedder.
+type CachingEmbedder struct {
+	embedder Embedder
+	config   CacheConfig
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	key       string
+	embedding []float64
+}
+
+// NewCachingEmbedder wraps e with a cache.
+func NewCachingEmbedder(e Embedder, cfg CacheConfig) (*CachingEmbedder, error) {
+	if cfg.Model == "" {
+		cfg.Model = EmbedderModel(e)
+	}
+	if cfg.MaxEntries == 0 {
+		cfg.MaxEntries = DefaultEmbeddingCacheSize
+	}
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+			return nil, fmt.Errorf("create embedding cache dir: %w", err)
+		}
+	}
+	return &CachingEmbedder{
+		embedder: e,
+		config:   cfg,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}, nil
+}
+
+// Embed returns the cached embedding for text, computing it on a miss.
+func (c *CachingEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	out, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+// EmbedBatch returns embeddings for texts, sending only cache misses to the
+// wrapped embedder in a single batch.
+func (c *CachingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	out := make([][]float64, len(texts))
+	keys := make([]string, len(texts))
+	missing := make(map[string][]int) // key to positions in texts
+	var order []string
+
+	for i, text := range texts {
+		key := c.key(text)
+		keys[i] = key
+		if emb, ok := c.lookup(key); ok {
+			out[i] = emb
+			continue
+		}
+		if _, seen := missing[key]; !seen {
+			order = append(order, key)
+		}
+		missing[key] = append(missing[key], i)
+	}
+	if len(order) == 0 {
+		return out, nil
+	}
+
+	batch := make([]string, len(order))
+	for i, key := range order {
+		batch[i] = texts[missing[key][0]]
+	}
+	embeddings, err := c.embedder.EmbedBatch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(batch) {
+		return nil, fmt.Errorf("embedder returned %d embeddings for %d texts", len(embeddings), len(batch))
+	}
+	for i, key := range order {
+		if err := c.store(key, embeddings[i]); err != nil {
+			return nil, err
+		}
+		for _, pos := range missing[key] {
+			out[pos] = copyEmbedding(embeddings[i])
+		}
+	}
+	return out, nil
+}
+
+// Dimension returns the wrapped embedder's dimension.
+func (c *CachingEmbedder) Dimension() int {
+	return c.embedder.Dimension()
+}
+
+// Model returns the model the cache is keyed on.
+func (c *CachingEmbedder) Model() string {
+	return c.config.Model
+}
+
+// Stats returns lookup counts since the embedder was created.
+func (c *CachingEmbedder) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *CachingEmbedder) key(text string) string {
+	sum := sha256.Sum256([]byte(c.config.Model + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookup checks the memory tier, then the disk tier, promoting disk hits
+// into memory. Returned embeddings are copies.
+func (c *CachingEmbedder) lookup(key string) ([]float64, bool) {
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		c.lru.MoveToFront(el)
+		c.stats.MemoryHits++
+		emb := copyEmbedding(el.Value.(*cacheEntry).embedding)
+		c.mu.Unlock()
+		return emb, true
+	}
+	c.mu.Unlock()
+
+	if c.config.Dir != "" {
+		if emb, err := c.readDisk(key); err == nil {
+			c.mu.Lock()
+			c.stats.DiskHits++
+			c.insert(key, emb)
+			c.mu.Unlock()
+			return copyEmbedding(emb), true
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.Misses++
+	c.mu.Unlock()
+	return nil, false
+}
+
+func (c *CachingEmbedder) store(key string, emb []float64) error {
+	c.mu.Lock()
+	c.insert(key, copyEmbedding(emb))
+	c.mu.Unlock()
+	if c.config.Dir != "" {
+		if err := c.writeDisk(key, emb); err != nil {
+			return fmt.Errorf("write embedding cache: %w", err)
+		}
+	}
+	return nil
+}
+
+// insert adds an entry to the memory tier. The caller holds mu.
+func (c *CachingEmbedder) insert(key string, emb []float64) {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*cacheEntry).embedding = emb
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, embedding: emb})
+	for c.lru.Len() > c.config.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// diskPath shards files by the first two hex digits of the key.
+func (c *CachingEmbedder) diskPath(key string) string {
+	return filepath.Join(c.config.Dir, key[:2], key)
+}
+
+func (c *CachingEmbedder) readDisk(key string) ([]float64, error) {
+	buf, err := os.ReadFile(c.diskPath(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)%8 != 0 {
+		return nil, fmt.Errorf("corrupt embedding cache entry %s", key)
+	}
+	emb := make([]float64, len(buf)/8)
+	for i := range emb {
+		emb[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf[i*8:]))
+	}
+	return emb, nil
+}
+
+// writeDisk writes through a temp file and rename so readers never see a
+// partial entry.
+func (c *CachingEmbedder) writeDisk(key string, emb []float64) error {
+	path := c.diskPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	buf := make([]byte, len(emb)*8)
+	for i, f := range emb {
+		binary.LittleEndian.PutUint64(buf[i*8:], math.Float64bits(f))
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func copyEmbedding(v []float64) []float64 {
+	return append([]float64(nil), v...)
+}
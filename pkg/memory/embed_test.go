@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingEmbedder records the batches it is asked to embed.
+type countingEmbedder struct {
+	*wordEmbedder
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (e *countingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	e.mu.Lock()
+	e.batches = append(e.batches, append([]string(nil), texts...))
+	e.mu.Unlock()
+	return e.wordEmbedder.EmbedBatch(ctx, texts)
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	out, err := e.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+func (e *countingEmbedder) calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.batches)
+}
+
+func TestCachingEmbedder(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingEmbedder{wordEmbedder: newWordEmbedder()}
+	dir := t.TempDir()
+
+	c, err := NewCachingEmbedder(inner, CacheConfig{Model: "words", MaxEntries: 2, Dir: dir})
+	if err != nil {
+		t.Fatalf("NewCachingEmbedder failed: %v", err)
+	}
+	first, _ := c.Embed(ctx, "go test")
+	again, _ := c.Embed(ctx, "go test")
+	if inner.calls() != 1 {
+		t.Errorf("expected 1 call to the embedder, got %d", inner.calls())
+	}
+	if CosineSimilarity(first, again) < 0.999 {
+		t.Error("expected cached embedding to match")
+	}
+
+	// A batch sends only misses, once each.
+	if _, err := c.EmbedBatch(ctx, []string{"go test", "deploy", "deploy", "rust"}); err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if got := inner.batches[len(inner.batches)-1]; len(got) != 2 {
+		t.Errorf("expected 2 misses in batch, got %v", got)
+	}
+
+	// A fresh cache over the same directory hits disk.
+	cold, _ := NewCachingEmbedder(inner, CacheConfig{Model: "words", Dir: dir})
+	before := inner.calls()
+	cold.Embed(ctx, "go test")
+	if inner.calls() != before {
+		t.Error("expected disk tier hit")
+	}
+	if s := cold.Stats(); s.DiskHits != 1 {
+		t.Errorf("expected 1 disk hit, got %+v", s)
+	}
+
+	// A different model does not share entries.
+	other, _ := NewCachingEmbedder(inner, CacheConfig{Model: "other", Dir: dir})
+	other.Embed(ctx, "go test")
+	if inner.calls() != before+1 {
+		t.Error("expected a miss for a different model")
+	}
+}
+
+func TestBatchingEmbedder(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingEmbedder{wordEmbedder: newWordEmbedder()}
+	b := NewBatchingEmbedder(inner, BatchConfig{MaxBatch: 4, MaxWait: time.Hour})
+
+	// Four concurrent calls fill a batch without waiting for MaxWait.
+	var wg sync.WaitGroup
+	texts := []string{"go", "rust", "python", "test"}
+	results := make([][]float64, len(texts))
+	for i, text := range texts {
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			emb, err := b.Embed(ctx, text)
+			if err != nil {
+				t.Errorf("Embed failed: %v", err)
+			}
+			results[i] = emb
+		}(i, text)
+	}
+	wg.Wait()
+	if inner.calls() != 1 || len(inner.batches[0]) != 4 {
+		t.Errorf("expected one batch of 4, got %v", inner.batches)
+	}
+	for i, text := range texts {
+		want, _ := newWordEmbedder().Embed(ctx, text)
+		if CosineSimilarity(results[i], want) < 0.999 {
+			t.Errorf("result %d does not match %q", i, text)
+		}
+	}
+
+	// A lone call is sent after MaxWait.
+	b = NewBatchingEmbedder(inner, BatchConfig{MaxBatch: 4, MaxWait: 5 * time.Millisecond})
+	if _, err := b.Embed(ctx, "deploy"); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if inner.calls() != 2 {
+		t.Errorf("expected a second batch, got %d", inner.calls())
+	}
+
+	// A cancelled caller returns without waiting.
+	b = NewBatchingEmbedder(inner, BatchConfig{MaxBatch: 4, MaxWait: time.Hour})
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := b.Embed(cctx, "config"); err == nil {
+		t.Error("expected context error")
+	}
+}
+
+// hungEmbedder blocks until its context is done.
+type hungEmbedder struct {
+	*wordEmbedder
+}
+
+func (e hungEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestBatchingEmbedderTimeout(t *testing.T) {
+	b := NewBatchingEmbedder(hungEmbedder{newWordEmbedder()}, BatchConfig{MaxBatch: 1, Timeout: 10 * time.Millisecond})
+	if _, err := b.Embed(context.Background(), "go"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the batch to time out, got %v", err)
+	}
+}
+
+func TestBatchingEmbedderStaleTimer(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingEmbedder{wordEmbedder: newWordEmbedder()}
+	b := NewBatchingEmbedder(inner, BatchConfig{MaxBatch: 2, MaxWait: time.Hour})
+
+	// The first batch fills up before its timer fires.
+	var wg sync.WaitGroup
+	for _, text := range []string{"go", "rust"} {
+		wg.Add(1)
+		go func(text string) {
+			defer wg.Done()
+			b.Embed(ctx, text)
+		}(text)
+	}
+	wg.Wait()
+
+	// Its timer firing late leaves the next batch waiting.
+	b.mu.Lock()
+	b.pending = append(b.pending, &embedRequest{text: "deploy", done: make(chan embedResult, 1)})
+	b.mu.Unlock()
+	b.flush(0)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) != 1 {
+		t.Errorf("expected the next batch to keep waiting, got %d pending", len(b.pending))
+	}
+}
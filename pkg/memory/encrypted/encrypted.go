@@ -0,0 +1,412 @@
+// Package encrypted implements encryption at rest for memory stores.
+//
+// Store wraps any memory.Store and seals each memory's Content and Metadata
+// with envelope encryption: a fresh AES-256-GCM data key per memory, itself
+// wrapped by a KeyProvider. The wrapped store only ever sees ciphertext in a
+// single metadata entry. IDs, types, timestamps, importance and retention
+// fields stay in the clear so the wrapped store can filter and order on
+// them. Embeddings are sealed too unless plaintext embeddings are enabled
+// to allow vector search.
+package encrypted
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+)
+
+// MetaEnvelope is the metadata key holding a sealed memory's envelope. It
+// is namespaced so it does not collide with user keys in memories stored
+// before encryption; user metadata is sealed inside the envelope.
+const MetaEnvelope = "_openagent_envelope"
+
+// ErrUnencrypted is returned in strict mode for stored memories that were
+// not sealed by this package.
+var ErrUnencrypted = errors.New("memory is not encrypted")
+
+// envelopeVersion is the envelope format written by this package.
+const envelopeVersion = 1
+
+// Config contains encrypted store configuration.
+type Config struct {
+	// Keys wraps and unwraps data keys. Required.
+	Keys KeyProvider
+	// PlaintextEmbeddings stores embeddings unencrypted so the wrapped
+	// store can search them. Embeddings leak information about content, so
+	// this must be chosen explicitly.
+	PlaintextEmbeddings bool
+	// Embedder, if set, embeds memories saved without an embedding and
+	// backs SearchByText. The wrapped store never sees content, so it
+	// cannot embed on its own.
+	Embedder memory.Embedder
+	// Strict rejects stored memories without an envelope with
+	// ErrUnencrypted instead of returning them as plaintext. Enable it
+	// once Rotate has sealed memories stored before encryption.
+	Strict bool
+}
+
+// Store is a memory.Store that encrypts memories before passing them to the
+// wrapped store. It implements memory.VectorStore when plaintext
+// embeddings are enabled and the wrapped store is a VectorStore; otherwise
+// its search methods return an error.
+type Store struct {
+	base   memory.Store
+	config Config
+}
+
+// envelope is the sealed form of a memory.
+type envelope struct {
+	Version int    `json:"v"`
+	KeyID   string `json:"kid"`
+	DEK     string `json:"dek"`
+	Data    string `json:"data"`
+}
+
+// payload is the plaintext sealed inside an envelope.
+type payload struct {
+	Content   string                 `json:"content,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Embedding []float64              `json:"embedding,omitempty"`
+}
+
+// NewStore wraps base with encryption.
+func NewStore(base memory.Store, cfg Config) (*Store, error) {
+	if cfg.Keys == nil {
+		return nil, fmt.Errorf("encrypted store: key provider is required")
+	}
+	return &Store{base: base, config: cfg}, nil
+}
+
+// Save encrypts and stores a memory. The caller's memory receives the
+// assigned ID and timestamps.
+func (s *Store) Save(ctx context.Context, m *memory.Memory) error {
+	if m.Embedding == nil && s.config.Embedder != nil && m.Content != "" {
+		emb, err := s.config.Embedder.Embed(ctx, m.Content)
+		if err != nil {
+			return fmt.Errorf("embed memory: %w", err)
+		}
+		m.Embedding = emb
+	}
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+
+	sealed, err := s.seal(ctx, m)
+	if err != nil {
+		return err
+	}
+	if err := s.base.Save(ctx, sealed); err != nil {
+		return err
+	}
+	m.CreatedAt = sealed.CreatedAt
+	m.UpdatedAt = sealed.UpdatedAt
+	return nil
+}
+
+// Get retrieves and decrypts a memory.
+func (s *Store) Get(ctx context.Context, id string) (*memory.Memory, error) {
+	m, err := s.base.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(ctx, m)
+}
+
+// Delete removes a memory.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.base.Delete(ctx, id)
+}
+
+// Clear removes all memories.
+func (s *Store) Clear(ctx context.Context) error {
+	return s.base.Clear(ctx)
+}
+
+// List returns decrypted memories matching the filter. Metadata conditions,
+// limit and offset are applied after decryption, since the wrapped store
+// cannot see metadata.
+func (s *Store) List(ctx context.Context, filter *memory.Filter) ([]*memory.Memory, error) {
+	var f memory.Filter
+	if filter != nil {
+		f = *filter
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	meta, limit, offset := f.Metadata, f.Limit, f.Offset
+	f.Metadata, f.Limit, f.Offset = nil, 0, 0
+
+	sealed, err := s.base.List(ctx, &f)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*memory.Memory, 0, len(sealed))
+	for _, sm := range sealed {
+		m, err := s.open(ctx, sm)
+		if err != nil {
+			return nil, err
+		}
+		if matchesMetadata(m, meta) {
+			result = append(result, m)
+		}
+	}
+
+	if offset > 0 {
+		if offset >= len(result) {
+			return []*memory.Memory{}, nil
+		}
+		result = result[offset:]
+	}
+	if limit > 0 && limit < len(result) {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func matchesMetadata(m *memory.Memory, want map[string]interface{}) bool {
+	for k, v := range want {
+		got, ok := m.Metadata[k]
+		if !ok || !memory.MetadataEqual(got, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordAccess records a retrieval without re-encrypting the memory.
+func (s *Store) RecordAccess(ctx context.Context, id string, at time.Time) error {
+	return memory.RecordAccess(ctx, s.base, id, at)
+}
+
+// Search finds similar memories using the wrapped store's vector search.
+func (s *Store) Search(ctx context.Context, embedding []float64, limit int) ([]*memory.Memory, error) {
+	vs, err := s.vectorStore()
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	found, err := vs.Search(ctx, embedding, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*memory.Memory, len(found))
+	for i, sm := range found {
+		if result[i], err = s.open(ctx, sm); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// SearchByText embeds text with the configured Embedder and searches by it.
+func (s *Store) SearchByText(ctx context.Context, text string, limit int) ([]*memory.Memory, error) {
+	if s.config.Embedder == nil {
+		return nil, fmt.Errorf("search by text: no embedder configured")
+	}
+	if _, err := s.vectorStore(); err != nil {
+		return nil, fmt.Errorf("search by text: %w", err)
+	}
+	emb, err := s.config.Embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	return s.Search(ctx, emb, limit)
+}
+
+func (s *Store) vectorStore() (memory.VectorStore, error) {
+	if !s.config.PlaintextEmbeddings {
+		return nil, fmt.Errorf("embeddings are encrypted; enable PlaintextEmbeddings to search")
+	}
+	vs, ok := s.base.(memory.VectorStore)
+	if !ok {
+		return nil, fmt.Errorf("store does not support vector search")
+	}
+	return vs, nil
+}
+
+// RotationReport summarizes a key rotation.
+type RotationReport struct {
+	// Rewrapped memories had their data key re-wrapped with the current key.
+	Rewrapped int `json:"rewrapped"`
+	// Encrypted memories were stored in plaintext and are now sealed.
+	Encrypted int `json:"encrypted"`
+	// Current memories already used the current key.
+	Current int `json:"current"`
+}
+
+// Rotate re-wraps every memory's data key with the provider's current key
+// and seals any memories that were stored before encryption was enabled.
+// Content is not re-encrypted; retire the old key once Rotate succeeds.
+// Rotated memories get a new UpdatedAt from the wrapped store.
+func (s *Store) Rotate(ctx context.Context) (*RotationReport, error) {
+	current, err := s.config.Keys.CurrentKeyID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("rotate: %w", err)
+	}
+	all, err := s.base.List(ctx, &memory.Filter{IncludeExpired: true, IncludeArchived: true})
+	if err != nil {
+		return nil, fmt.Errorf("rotate: list memories: %w", err)
+	}
+
+	report := &RotationReport{}
+	for _, sm := range all {
+		env, ok, err := envelopeOf(sm)
+		if err != nil {
+			return report, fmt.Errorf("rotate %s: %w", sm.ID, err)
+		}
+		if !ok {
+			m := *sm
+			if err := s.Save(ctx, &m); err != nil {
+				return report, fmt.Errorf("rotate %s: %w", sm.ID, err)
+			}
+			report.Encrypted++
+			continue
+		}
+		if env.KeyID == current {
+			report.Current++
+			continue
+		}
+
+		dek, err := s.unwrap(ctx, env)
+		if err != nil {
+			return report, fmt.Errorf("rotate %s: %w", sm.ID, err)
+		}
+		if err := s.wrap(ctx, env, dek); err != nil {
+			return report, fmt.Errorf("rotate %s: %w", sm.ID, err)
+		}
+		rewrapped := *sm
+		rewrapped.Metadata = map[string]interface{}{MetaEnvelope: env}
+		if err := s.base.Save(ctx, &rewrapped); err != nil {
+			return report, fmt.Errorf("rotate %s: %w", sm.ID, err)
+		}
+		report.Rewrapped++
+	}
+	return report, nil
+}
+
+// seal returns a copy of m with its sensitive fields replaced by an
+// envelope. The ID is bound to the ciphertext so envelopes cannot be moved
+// between memories.
+func (s *Store) seal(ctx context.Context, m *memory.Memory) (*memory.Memory, error) {
+	p := payload{Content: m.Content, Metadata: m.Metadata}
+	if !s.config.PlaintextEmbeddings {
+		p.Embedding = m.Embedding
+	}
+	plaintext, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt memory: %w", err)
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, fmt.Errorf("encrypt memory: %w", err)
+	}
+	data, err := seal(dek, plaintext, []byte(m.ID))
+	if err != nil {
+		return nil, fmt.Errorf("encrypt memory: %w", err)
+	}
+	env := &envelope{Version: envelopeVersion, Data: base64.StdEncoding.EncodeToString(data)}
+	if err := s.wrap(ctx, env, dek); err != nil {
+		return nil, fmt.Errorf("encrypt memory: %w", err)
+	}
+
+	sealed := *m
+	sealed.Content = ""
+	sealed.Metadata = map[string]interface{}{MetaEnvelope: env}
+	sealed.Score = 0
+	if !s.config.PlaintextEmbeddings {
+		sealed.Embedding = nil
+	}
+	return &sealed, nil
+}
+
+// open returns a decrypted copy of a stored memory. Memories stored before
+// encryption was enabled are returned as they are, unless the store is
+// strict.
+func (s *Store) open(ctx context.Context, sm *memory.Memory) (*memory.Memory, error) {
+	env, ok, err := envelopeOf(sm)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt memory %s: %w", sm.ID, err)
+	}
+	m := *sm
+	if !ok {
+		if s.config.Strict {
+			return nil, fmt.Errorf("decrypt memory %s: %w", sm.ID, ErrUnencrypted)
+		}
+		return &m, nil
+	}
+	dek, err := s.unwrap(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt memory %s: %w", sm.ID, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(env.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt memory %s: %w", sm.ID, err)
+	}
+	plaintext, err := open(dek, data, []byte(sm.ID))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt memory %s: %w", sm.ID, err)
+	}
+	var p payload
+	if err := json.Unmarshal(plaintext, &p); err != nil {
+		return nil, fmt.Errorf("decrypt memory %s: %w", sm.ID, err)
+	}
+	m.Content = p.Content
+	m.Metadata = p.Metadata
+	if !s.config.PlaintextEmbeddings || p.Embedding != nil {
+		m.Embedding = p.Embedding
+	}
+	return &m, nil
+}
+
+func (s *Store) wrap(ctx context.Context, env *envelope, dek []byte) error {
+	id, wrapped, err := s.config.Keys.WrapKey(ctx, dek)
+	if err != nil {
+		return err
+	}
+	env.KeyID = id
+	env.DEK = base64.StdEncoding.EncodeToString(wrapped)
+	return nil
+}
+
+func (s *Store) unwrap(ctx context.Context, env *envelope) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(env.DEK)
+	if err != nil {
+		return nil, err
+	}
+	return s.config.Keys.UnwrapKey(ctx, env.KeyID, wrapped)
+}
+
+// envelopeOf extracts the envelope from a stored memory. Stores that
+// round-trip metadata through JSON return it as a generic map, so it is
+// re-decoded rather than type-asserted.
+func envelopeOf(m *memory.Memory) (*envelope, bool, error) {
+	raw, ok := m.Metadata[MetaEnvelope]
+	if !ok {
+		return nil, false, nil
+	}
+	if env, ok := raw.(*envelope); ok {
+		// Copy, since in-memory stores hand out their own records.
+		c := *env
+		return &c, true, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, false, err
+	}
+	if env.Version != envelopeVersion {
+		return nil, false, fmt.Errorf("unsupported envelope version %d", env.Version)
+	}
+	return &env, true, nil
+}
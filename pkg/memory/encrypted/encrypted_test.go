@@ -0,0 +1,247 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/memory/memorytest"
+)
+
+func newKMS(t *testing.T, ids ...string) *LocalKMS {
+	t.Helper()
+	k := NewLocalKMS()
+	for _, id := range ids {
+		key, err := GenerateKey()
+		if err != nil {
+			t.Fatalf("GenerateKey failed: %v", err)
+		}
+		if err := k.AddKey(id, key); err != nil {
+			t.Fatalf("AddKey failed: %v", err)
+		}
+	}
+	return k
+}
+
+func TestStoreEncryptsAtRest(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	base, err := memory.NewFileStore(memory.FileStoreConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer base.Close()
+
+	s, err := NewStore(base, Config{Keys: newKMS(t, "k1")})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	m := &memory.Memory{
+		Type:      memory.TypeSemantic,
+		Content:   "the staging password is hunter2",
+		Metadata:  map[string]interface{}{"repo": "secret-project"},
+		Embedding: []float64{0.25, 0.5},
+	}
+	if err := s.Save(ctx, m); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	log, err := os.ReadFile(filepath.Join(dir, "memory.log"))
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	for _, secret := range []string{"hunter2", "secret-project", "0.25"} {
+		if bytes.Contains(log, []byte(secret)) {
+			t.Errorf("found %q in plaintext on disk", secret)
+		}
+	}
+
+	got, err := s.Get(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Content != m.Content || got.Metadata["repo"] != "secret-project" || len(got.Embedding) != 2 {
+		t.Errorf("unexpected decrypted memory %+v", got)
+	}
+
+	raw, _ := base.Get(ctx, m.ID)
+	if raw.Content != "" || raw.Embedding != nil {
+		t.Errorf("expected sealed memory in base store, got %+v", raw)
+	}
+
+	if _, err := s.Search(ctx, []float64{1, 0}, 1); err == nil {
+		t.Error("expected search to require plaintext embeddings")
+	}
+}
+
+func TestStoreListMetadataFilter(t *testing.T) {
+	ctx := context.Background()
+	s, _ := NewStore(memory.NewInMemoryStore(), Config{Keys: newKMS(t, "k1")})
+	for i, repo := range []string{"api", "web", "api", "api"} {
+		s.Save(ctx, &memory.Memory{ID: string(rune('a' + i)), Metadata: map[string]interface{}{"repo": repo}})
+	}
+	got, err := s.List(ctx, &memory.Filter{
+		Metadata: map[string]interface{}{"repo": "api"},
+		OrderBy:  memory.OrderByCreatedAt,
+		Offset:   1,
+		Limit:    1,
+	})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "c" {
+		t.Errorf("expected [c], got %v", got)
+	}
+}
+
+func TestStoreRotate(t *testing.T) {
+	ctx := context.Background()
+	base := memory.NewInMemoryStore()
+	kms := newKMS(t, "old")
+
+	// A memory written before encryption was enabled.
+	base.Save(ctx, &memory.Memory{ID: "legacy", Content: "plaintext"})
+
+	s, _ := NewStore(base, Config{Keys: kms})
+	s.Save(ctx, &memory.Memory{ID: "a", Content: "alpha"})
+
+	key, _ := GenerateKey()
+	kms.AddKey("new", key)
+	kms.SetCurrent("new")
+
+	report, err := s.Rotate(ctx)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if report.Rewrapped != 1 || report.Encrypted != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if err := kms.RemoveKey("old"); err != nil {
+		t.Fatalf("RemoveKey failed: %v", err)
+	}
+	for id, want := range map[string]string{"a": "alpha", "legacy": "plaintext"} {
+		got, err := s.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("Get(%s) after rotation failed: %v", id, err)
+		}
+		if got.Content != want {
+			t.Errorf("expected %q, got %q", want, got.Content)
+		}
+	}
+	if raw, _ := base.Get(ctx, "legacy"); raw.Content != "" {
+		t.Error("expected legacy memory to be sealed")
+	}
+
+	// Data wrapped with a removed key cannot be read.
+	kms2 := newKMS(t, "other")
+	s2, _ := NewStore(base, Config{Keys: kms2})
+	if _, err := s2.Get(ctx, "a"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestKeyProviders(t *testing.T) {
+	k1, _ := GenerateKey()
+	k2, _ := GenerateKey()
+	spec := "k2:" + base64.StdEncoding.EncodeToString(k2) + ",k1:" + base64.StdEncoding.EncodeToString(k1)
+
+	t.Setenv("OPENAGENT_TEST_KEYS", spec)
+	env, err := EnvKeyProvider("OPENAGENT_TEST_KEYS")
+	if err != nil {
+		t.Fatalf("EnvKeyProvider failed: %v", err)
+	}
+	if id, _ := env.CurrentKeyID(context.Background()); id != "k2" {
+		t.Errorf("expected first key to be current, got %s", id)
+	}
+
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("# keyring\nk1:"+base64.StdEncoding.EncodeToString(k1)+"\n"), 0o600)
+	file, err := FileKeyProvider(path)
+	if err != nil {
+		t.Fatalf("FileKeyProvider failed: %v", err)
+	}
+
+	// Keys are interchangeable across providers holding the same material.
+	id, wrapped, err := env.WrapKey(context.Background(), []byte("data key"))
+	if err != nil {
+		t.Fatalf("WrapKey failed: %v", err)
+	}
+	if _, err := file.UnwrapKey(context.Background(), id, wrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey for k2 in file provider, got %v", err)
+	}
+
+	for _, bad := range []string{"", "nocolon", "k:not-base64!", "k:" + base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParseKeyring(bad); err == nil {
+			t.Errorf("expected error for keyring %q", bad)
+		}
+	}
+}
+
+func TestStoreConformance(t *testing.T) {
+	memorytest.TestStore(t, func(t *testing.T) memory.Store {
+		s, err := NewStore(memory.NewInMemoryStore(), Config{Keys: newKMS(t, "k1")})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return s
+	})
+}
+
+func TestVectorStoreConformance(t *testing.T) {
+	memorytest.TestVectorStore(t, func(t *testing.T, e memory.Embedder) memory.VectorStore {
+		s, err := NewStore(memory.NewInMemoryVectorStore(nil), Config{
+			Keys:                newKMS(t, "k1"),
+			PlaintextEmbeddings: true,
+			Embedder:            e,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return s
+	})
+}
+
+func TestStoreUnencryptedMemories(t *testing.T) {
+	ctx := context.Background()
+	base := memory.NewInMemoryStore()
+	kms := newKMS(t, "k1")
+
+	// A plaintext memory with a user metadata key named "encrypted".
+	base.Save(ctx, &memory.Memory{ID: "plain", Content: "plaintext", Metadata: map[string]interface{}{"encrypted": true}})
+
+	s, _ := NewStore(base, Config{Keys: kms})
+	got, err := s.Get(ctx, "plain")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Content != "plaintext" || got.Metadata["encrypted"] != true {
+		t.Errorf("unexpected memory %+v", got)
+	}
+
+	strict, _ := NewStore(base, Config{Keys: kms, Strict: true})
+	if _, err := strict.Get(ctx, "plain"); !errors.Is(err, ErrUnencrypted) {
+		t.Errorf("expected ErrUnencrypted, got %v", err)
+	}
+	if _, err := strict.List(ctx, nil); !errors.Is(err, ErrUnencrypted) {
+		t.Errorf("expected ErrUnencrypted from List, got %v", err)
+	}
+
+	// Rotate seals it, after which strict reads succeed and the user key
+	// survives.
+	if _, err := strict.Rotate(ctx); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	got, err = strict.Get(ctx, "plain")
+	if err != nil {
+		t.Fatalf("Get after Rotate failed: %v", err)
+	}
+	if got.Content != "plaintext" || got.Metadata["encrypted"] != true {
+		t.Errorf("unexpected memory after rotation %+v", got)
+	}
+}
@@ -0,0 +1,215 @@
+package encrypted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownKey is returned when data was wrapped with a key the provider
+// does not hold.
+var ErrUnknownKey = errors.New("unknown key")
+
+// KeyProvider wraps and unwraps data keys with key-encryption keys it
+// manages, in the style of a KMS. Implementations must be safe for
+// concurrent use.
+type KeyProvider interface {
+	// WrapKey encrypts dek with the current key and returns that key's ID.
+	WrapKey(ctx context.Context, dek []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped with the key keyID.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	// CurrentKeyID returns the ID of the key new data keys are wrapped with.
+	CurrentKeyID(ctx context.Context) (string, error)
+}
+
+// LocalKMS is an in-process KeyProvider holding 256-bit AES keys. It stands
+// in for a cloud KMS and backs the env and file providers.
+type LocalKMS struct {
+	mu      sync.RWMutex
+	keys    map[string][]byte
+	current string
+}
+
+// NewLocalKMS creates a provider with no keys. Add at least one with AddKey.
+func NewLocalKMS() *LocalKMS {
+	return &LocalKMS{keys: make(map[string][]byte)}
+}
+
+// GenerateKey returns a random 256-bit key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	return key, nil
+}
+
+// AddKey adds a 256-bit key. The first key added becomes current.
+func (k *LocalKMS) AddKey(id string, key []byte) error {
+	if id == "" {
+		return fmt.Errorf("add key: id is required")
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("add key %s: want 32 bytes, got %d", id, len(key))
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = append([]byte(nil), key...)
+	if k.current == "" {
+		k.current = id
+	}
+	return nil
+}
+
+// SetCurrent makes id the key new data keys are wrapped with. Older keys
+// stay available for unwrapping until removed.
+func (k *LocalKMS) SetCurrent(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	k.current = id
+	return nil
+}
+
+// RemoveKey forgets a retired key. The current key cannot be removed.
+func (k *LocalKMS) RemoveKey(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id == k.current {
+		return fmt.Errorf("remove key %s: key is current", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// CurrentKeyID returns the current key ID.
+func (k *LocalKMS) CurrentKeyID(ctx context.Context) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.current == "" {
+		return "", fmt.Errorf("no keys configured")
+	}
+	return k.current, nil
+}
+
+// WrapKey encrypts dek with the current key using AES-GCM.
+func (k *LocalKMS) WrapKey(ctx context.Context, dek []byte) (string, []byte, error) {
+	k.mu.RLock()
+	id, key := k.current, k.keys[k.current]
+	k.mu.RUnlock()
+	if id == "" {
+		return "", nil, fmt.Errorf("wrap key: no keys configured")
+	}
+	wrapped, err := seal(key, dek, []byte(id))
+	if err != nil {
+		return "", nil, fmt.Errorf("wrap key: %w", err)
+	}
+	return id, wrapped, nil
+}
+
+// UnwrapKey decrypts a data key wrapped with key id.
+func (k *LocalKMS) UnwrapKey(ctx context.Context, id string, wrapped []byte) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.keys[id]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	dek, err := open(key, wrapped, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key %s: %w", id, err)
+	}
+	return dek, nil
+}
+
+// ParseKeyring builds a LocalKMS from a keyring spec: comma- or
+// newline-separated "id:base64key" entries. The first entry is current, so
+// rotating means prepending a new key and keeping the old ones until every
+// memory has been rotated.
+func ParseKeyring(spec string) (*LocalKMS, error) {
+	k := NewLocalKMS()
+	entries := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("parse keyring: entry %q is not id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("parse keyring: key %s: %w", id, err)
+		}
+		if err := k.AddKey(strings.TrimSpace(id), key); err != nil {
+			return nil, fmt.Errorf("parse keyring: %w", err)
+		}
+	}
+	if k.current == "" {
+		return nil, fmt.Errorf("parse keyring: no keys")
+	}
+	return k, nil
+}
+
+// EnvKeyProvider reads a keyring spec from the named environment variable.
+func EnvKeyProvider(name string) (*LocalKMS, error) {
+	spec := os.Getenv(name)
+	if spec == "" {
+		return nil, fmt.Errorf("env key provider: %s is not set", name)
+	}
+	return ParseKeyring(spec)
+}
+
+// FileKeyProvider reads a keyring spec from a file, which should be
+// readable only by its owner.
+func FileKeyProvider(path string) (*LocalKMS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("file key provider: %w", err)
+	}
+	return ParseKeyring(string(data))
+}
+
+// seal encrypts plaintext with AES-GCM under key, prefixing the nonce.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open reverses seal.
+func open(key, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, data := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, data, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
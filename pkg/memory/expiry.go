@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetaSessionID is the metadata key Session uses to tag its memories.
+const MetaSessionID = "session_id"
+
+// ExpireMemories deletes every memory in s that has expired at now and
+// returns how many were removed.
+func ExpireMemories(ctx context.Context, s Store, now time.Time) (int, error) {
+	all, err := s.List(ctx, &Filter{IncludeExpired: true, IncludeArchived: true})
+	if err != nil {
+		return 0, fmt.Errorf("list memories: %w", err)
+	}
+	n := 0
+	for _, m := range all {
+		if !m.Expired(now) {
+			continue
+		}
+		if err := s.Delete(ctx, m.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return n, fmt.Errorf("delete expired memory %s: %w", m.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Expirer periodically deletes expired memories from a store.
+type Expirer struct {
+	store    Store
+	interval time.Duration
+	// OnError, if set, receives sweep errors; otherwise they are dropped and
+	// the next sweep retries.
+	OnError func(error)
+
+	loop periodic
+}
+
+// NewExpirer creates an expirer that sweeps s every interval.
+func NewExpirer(s Store, interval time.Duration) *Expirer {
+	return &Expirer{store: s, interval: interval}
+}
+
+// Start begins sweeping in the background until ctx is done or Stop is
+// called. Calling Start on a running expirer has no effect.
+func (e *Expirer) Start(ctx context.Context) {
+	e.loop.start(ctx, e.interval, func(ctx context.Context, now time.Time) {
+		if _, err := ExpireMemories(ctx, e.store, now); err != nil && e.OnError != nil {
+			e.OnError(err)
+		}
+	})
+}
+
+// Stop halts background sweeping and waits for an in-flight sweep to finish.
+func (e *Expirer) Stop() {
+	e.loop.stop()
+}
+
+// Session scopes working memory to a single agent session. Working memories
+// saved through a session are deleted when it ends, and optionally carry a
+// TTL so they still expire if the process dies before End is called.
+type Session struct {
+	ID    string
+	store Store
+	ttl   time.Duration
+}
+
+// NewSession starts a session on s. A zero ttl leaves working memories
+// without an expiry.
+func NewSession(s Store, ttl time.Duration) *Session {
+	return &Session{ID: uuid.New().String(), store: s, ttl: ttl}
+}
+
+// Save stores a memory tagged with the session ID. Memories without a type
+// default to TypeWorking.
+func (s *Session) Save(ctx context.Context, m *Memory) error {
+	if m.Type == "" {
+		m.Type = TypeWorking
+	}
+	if m.Metadata == nil {
+		m.Metadata = make(map[string]interface{})
+	}
+	m.Metadata[MetaSessionID] = s.ID
+	if m.Type == TypeWorking && s.ttl > 0 && m.ExpiresAt == nil {
+		m.ExpireAfter(s.ttl)
+	}
+	return s.store.Save(ctx, m)
+}
+
+// Working returns the session's live working memories, newest first.
+func (s *Session) Working(ctx context.Context) ([]*Memory, error) {
+	return s.store.List(ctx, &Filter{
+		Type:     TypeWorking,
+		Metadata: map[string]interface{}{MetaSessionID: s.ID},
+	})
+}
+
+// End deletes the session's working memories. Other memory types saved
+// through the session are kept.
+func (s *Session) End(ctx context.Context) error {
+	working, err := s.store.List(ctx, &Filter{
+		Type:            TypeWorking,
+		Metadata:        map[string]interface{}{MetaSessionID: s.ID},
+		IncludeExpired:  true,
+		IncludeArchived: true,
+	})
+	if err != nil {
+		return fmt.Errorf("end session: %w", err)
+	}
+	for _, m := range working {
+		if err := s.store.Delete(ctx, m.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("end session: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Export format identifiers written in the header line.
+const (
+	ExportFormat  = "openagent-memory"
+	ExportVersion = 1
+)
+
+// importBatchSize is how many memories are read before their conflicts are
+// resolved and the ones to write are re-embedded in one EmbedBatch call.
+const importBatchSize = 64
+
+// ExportHeader is the first line of an export. Model and Dimension describe
+// the embeddings that follow; an empty Model means it was not known.
+type ExportHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	Model      string    `json:"model,omitempty"`
+	Dimension  int       `json:"dimension,omitempty"`
+	ExportedAt time.Time `json:"exported_at"`
+	Count      int       `json:"count"`
+}
+
+// ExportOptions controls Export.
+type ExportOptions struct {
+	// Filter selects the memories to export. Nil exports everything,
+	// including expired and archived memories.
+	Filter *Filter
+	// Model names the embedding model recorded in the header.
+	Model string
+}
+
+// Export writes the memories selected by opts to w as JSONL: a header line
+// followed by one memory per line with its embedding, metadata and
+// timestamps.
+func Export(ctx context.Context, s Store, w io.Writer, opts ExportOptions) (*ExportHeader, error) {
+	filter := opts.Filter
+	if filter == nil {
+		filter = &Filter{IncludeExpired: true, IncludeArchived: true}
+	}
+	memories, err := s.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("export: list memories: %w", err)
+	}
+
+	header := &ExportHeader{
+		Format:     ExportFormat,
+		Version:    ExportVersion,
+		Model:      opts.Model,
+		ExportedAt: time.Now().UTC(),
+		Count:      len(memories),
+	}
+	for _, m := range memories {
+		if len(m.Embedding) > 0 {
+			header.Dimension = len(m.Embedding)
+			break
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(header); err != nil {
+		return nil, fmt.Errorf("export: write header: %w", err)
+	}
+	for _, m := range memories {
+		c := m.clone()
+		c.Score = 0
+		if err := enc.Encode(c); err != nil {
+			return nil, fmt.Errorf("export: write memory %s: %w", m.ID, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("export: %w", err)
+	}
+	return header, nil
+}
+
+// ConflictStrategy decides what Import does with a memory whose ID already
+// exists in the target store.
+type ConflictStrategy string
+
+const (
+	// ConflictSkip keeps the existing memory and drops the imported one.
+	ConflictSkip ConflictStrategy = "skip"
+	// ConflictOverwrite replaces the existing memory.
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	// ConflictRename imports the memory under a new ID.
+	ConflictRename ConflictStrategy = "rename"
+)
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	// OnConflict defaults to ConflictSkip.
+	OnConflict ConflictStrategy
+	// Embedder, if set, re-embeds imported content when the export's model
+	// or dimension differs from the embedder's.
+	Embedder Embedder
+	// Model names the target embedding model. Defaults to the Embedder's
+	// model when it implements ModelNamer.
+	Model string
+}
+
+// ImportReport summarizes an import.
+type ImportReport struct {
+	Header      ExportHeader      `json:"header"`
+	Imported    int               `json:"imported"`
+	Skipped     int               `json:"skipped"`
+	Overwritten int               `json:"overwritten"`
+	Reembedded  int               `json:"reembedded"`
+	Renamed     map[string]string `json:"renamed,omitempty"`
+}
+
+// Import reads an export produced by Export and saves its memories into s.
+// Creation timestamps are preserved; stores set UpdatedAt on save as usual.
+func Import(ctx context.Context, s Store, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	switch opts.OnConflict {
+	case "":
+		opts.OnConflict = ConflictSkip
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return nil, fmt.Errorf("import: unknown conflict strategy %q", opts.OnConflict)
+	}
+	if opts.Model == "" && opts.Embedder != nil {
+		opts.Model = EmbedderModel(opts.Embedder)
+	}
+
+	br := bufio.NewReader(r)
+	line, err := readLine(br)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("import: missing header")
+		}
+		return nil, fmt.Errorf("import: read header: %w", err)
+	}
+	report := &ImportReport{}
+	if err := json.Unmarshal(line, &report.Header); err != nil {
+		return nil, fmt.Errorf("import: line 1: decode header: %w", err)
+	}
+	if report.Header.Format != ExportFormat {
+		return nil, fmt.Errorf("import: unknown format %q", report.Header.Format)
+	}
+	if report.Header.Version > ExportVersion {
+		return nil, fmt.Errorf("import: format version %d is newer than supported version %d", report.Header.Version, ExportVersion)
+	}
+	reembed := opts.Embedder != nil &&
+		(report.Header.Model != opts.Model || report.Header.Dimension != opts.Embedder.Dimension())
+
+	var batch []*Memory
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// Conflicts are resolved first so that skipped memories are not
+		// re-embedded.
+		var write []*Memory
+		pending := make(map[string]bool, len(batch))
+		for _, m := range batch {
+			ok, err := resolveConflict(ctx, s, m, opts.OnConflict, pending, report)
+			if err != nil {
+				return err
+			}
+			if ok {
+				write = append(write, m)
+				if m.ID != "" {
+					pending[m.ID] = true
+				}
+			}
+		}
+		if reembed && len(write) > 0 {
+			if err := reembedAll(ctx, opts.Embedder, write); err != nil {
+				return err
+			}
+			report.Reembedded += len(write)
+		}
+		for _, m := range write {
+			if err := s.Save(ctx, m); err != nil {
+				return fmt.Errorf("import: save %s: %w", m.ID, err)
+			}
+			report.Imported++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for n := 2; ; n++ {
+		line, err := readLine(br)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("import: line %d: %w", n, err)
+		}
+		if len(line) == 0 {
+			continue
+		}
+		m := &Memory{}
+		if err := json.Unmarshal(line, m); err != nil {
+			return report, fmt.Errorf("import: line %d: decode memory: %w", n, err)
+		}
+		m.Score = 0
+		batch = append(batch, m)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// readLine returns the next line without its trailing newline. It returns
+// io.EOF only when no data remains.
+func readLine(br *bufio.Reader) ([]byte, error) {
+	line, err := br.ReadBytes('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+		return nil, err
+	}
+	return bytes.TrimSpace(line), nil
+}
+
+func reembedAll(ctx context.Context, e Embedder, batch []*Memory) error {
+	texts := make([]string, len(batch))
+	for i, m := range batch {
+		texts[i] = m.Content
+	}
+	embeddings, err := e.EmbedBatch(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("import: re-embed: %w", err)
+	}
+	for i, m := range batch {
+		m.Embedding = embeddings[i]
+	}
+	return nil
+}
+
+// resolveConflict applies strategy to m if its ID exists in s or is
+// pending in the current batch, and reports whether m should be saved.
+func resolveConflict(ctx context.Context, s Store, m *Memory, strategy ConflictStrategy, pending map[string]bool, report *ImportReport) (bool, error) {
+	exists := m.ID != "" && pending[m.ID]
+	if m.ID != "" && !exists {
+		_, err := s.Get(ctx, m.ID)
+		switch {
+		case err == nil:
+			exists = true
+		case !errors.Is(err, ErrNotFound):
+			return false, fmt.Errorf("import: check %s: %w", m.ID, err)
+		}
+	}
+
+	if exists {
+		switch strategy {
+		case ConflictSkip:
+			report.Skipped++
+			return false, nil
+		case ConflictOverwrite:
+			report.Overwritten++
+		case ConflictRename:
+			if report.Renamed == nil {
+				report.Renamed = make(map[string]string)
+			}
+			old := m.ID
+			m.ID = uuid.New().String()
+			report.Renamed[old] = m.ID
+		}
+	}
+	return true, nil
+}
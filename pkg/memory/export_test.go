@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := NewInMemoryVectorStore(newWordEmbedder())
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	src.Save(ctx, &Memory{
+		ID:         "a",
+		Type:       TypeSemantic,
+		Content:    "go test",
+		Metadata:   map[string]interface{}{"repo": "api"},
+		CreatedAt:  created,
+		Importance: 0.7,
+	})
+	src.Save(ctx, &Memory{ID: "b", Type: TypeEpisodic, Content: "deploy", Archived: true})
+
+	var buf bytes.Buffer
+	header, err := Export(ctx, src, &buf, ExportOptions{Model: "words"})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if header.Count != 2 || header.Dimension != newWordEmbedder().Dimension() {
+		t.Errorf("unexpected header %+v", header)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Errorf("expected 3 lines, got %d", lines)
+	}
+
+	dst := NewInMemoryStore()
+	report, err := Import(ctx, dst, &buf, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if report.Imported != 2 || report.Header.Model != "words" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	got, err := dst.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Content != "go test" || got.Importance != 0.7 || got.Metadata["repo"] != "api" {
+		t.Errorf("unexpected memory %+v", got)
+	}
+	if !got.CreatedAt.Equal(created) {
+		t.Errorf("expected CreatedAt %v, got %v", created, got.CreatedAt)
+	}
+	want, _ := newWordEmbedder().Embed(ctx, "go test")
+	if CosineSimilarity(got.Embedding, want) < 0.999 {
+		t.Errorf("expected embedding to survive the round trip, got %v", got.Embedding)
+	}
+	if b, err := dst.Get(ctx, "b"); err != nil || !b.Archived {
+		t.Errorf("expected archived memory to be exported, got %+v (%v)", b, err)
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	ctx := context.Background()
+	src := NewInMemoryStore()
+	src.Save(ctx, &Memory{ID: "a", Content: "imported"})
+	var export bytes.Buffer
+	if _, err := Export(ctx, src, &export, ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	tests := []struct {
+		strategy ConflictStrategy
+		content  string
+		count    int
+	}{
+		{ConflictSkip, "existing", 1},
+		{ConflictOverwrite, "imported", 1},
+		{ConflictRename, "existing", 2},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			dst := NewInMemoryStore()
+			dst.Save(ctx, &Memory{ID: "a", Content: "existing"})
+
+			report, err := Import(ctx, dst, bytes.NewReader(export.Bytes()), ImportOptions{OnConflict: tt.strategy})
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			got, _ := dst.Get(ctx, "a")
+			if got.Content != tt.content {
+				t.Errorf("expected %q, got %q", tt.content, got.Content)
+			}
+			all, _ := dst.List(ctx, nil)
+			if len(all) != tt.count {
+				t.Errorf("expected %d memories, got %d", tt.count, len(all))
+			}
+			if tt.strategy == ConflictRename {
+				renamed, err := dst.Get(ctx, report.Renamed["a"])
+				if err != nil || renamed.Content != "imported" {
+					t.Errorf("expected renamed memory, got %+v (%v)", renamed, err)
+				}
+			}
+		})
+	}
+
+	if _, err := Import(ctx, NewInMemoryStore(), bytes.NewReader(export.Bytes()), ImportOptions{OnConflict: "merge"}); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+func TestImportReembeds(t *testing.T) {
+	ctx := context.Background()
+	src := NewInMemoryStore()
+	src.Save(ctx, &Memory{ID: "a", Content: "go test", Embedding: []float64{1, 0}})
+	var export bytes.Buffer
+	if _, err := Export(ctx, src, &export, ExportOptions{Model: "old-model"}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	e := newWordEmbedder()
+	dst := NewInMemoryStore()
+	report, err := Import(ctx, dst, &export, ImportOptions{Embedder: e, Model: "words"})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if report.Reembedded != 1 {
+		t.Errorf("expected 1 re-embedded memory, got %d", report.Reembedded)
+	}
+	got, _ := dst.Get(ctx, "a")
+	if len(got.Embedding) != e.Dimension() {
+		t.Errorf("expected embedding of dimension %d, got %d", e.Dimension(), len(got.Embedding))
+	}
+}
+
+func TestImportReembedsOnlyWritten(t *testing.T) {
+	ctx := context.Background()
+	src := NewInMemoryStore()
+	src.Save(ctx, &Memory{ID: "a", Content: "go test", Embedding: []float64{1, 0}})
+	src.Save(ctx, &Memory{ID: "b", Content: "deploy config", Embedding: []float64{0, 1}})
+	var export bytes.Buffer
+	if _, err := Export(ctx, src, &export, ExportOptions{Model: "old-model"}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	dst := NewInMemoryStore()
+	dst.Save(ctx, &Memory{ID: "a", Content: "existing", Embedding: []float64{1, 0}})
+	report, err := Import(ctx, dst, &export, ImportOptions{Embedder: newWordEmbedder(), Model: "words"})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if report.Skipped != 1 || report.Imported != 1 || report.Reembedded != 1 {
+		t.Errorf("expected 1 skipped, imported and re-embedded, got %+v", report)
+	}
+	if got, _ := dst.Get(ctx, "a"); len(got.Embedding) != 2 {
+		t.Errorf("expected skipped memory to keep its embedding, got %v", got.Embedding)
+	}
+}
+
+func TestImportRejectsBadInput(t *testing.T) {
+	ctx := context.Background()
+	for name, input := range map[string]string{
+		"empty":      "",
+		"format":     `{"format":"other","version":1}` + "\n",
+		"version":    `{"format":"openagent-memory","version":99}` + "\n",
+		"bad memory": `{"format":"openagent-memory","version":1}` + "\n{not json}\n",
+	} {
+		if _, err := Import(ctx, NewInMemoryStore(), strings.NewReader(input), ImportOptions{}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
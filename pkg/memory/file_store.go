@@ -0,0 +1,439 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	fileStoreLogName          = "memory.log"
+	defaultCompactThreshold   = 1000
+	fileStoreRecordHeaderSize = 9 // 8 hex digits of CRC32 plus a space
+)
+
+// Log operations recorded by FileStore.
+const (
+	opPut    = "put"
+	opDelete = "delete"
+	opClear  = "clear"
+)
+
+// FileStoreConfig contains FileStore configuration.
+type FileStoreConfig struct {
+	// Dir is the directory holding the log. It is created if missing.
+	Dir string `json:"dir"`
+	// CompactThreshold is the number of dead records tolerated before the
+	// log is rewritten. Zero uses the default; a negative value disables
+	// automatic compaction.
+	CompactThreshold int `json:"compact_threshold,omitempty"`
+	// OnCompactError, if set, is called when automatic compaction fails.
+	// The write that triggered it has already succeeded, so the error is
+	// not returned to the caller; compaction is retried on later writes.
+	OnCompactError func(error) `json:"-"`
+}
+
+// FileStore implements Store as an append-only log in a local directory.
+//
+// Every mutation is appended as a checksummed record and fsynced before the
+// call returns. The in-memory index is rebuilt from the log on open, and the
+// log is rewritten without dead records once enough of them accumulate.
+type FileStore struct {
+	mu       sync.RWMutex
+	config   FileStoreConfig
+	file     logFile
+	memories map[string]*Memory
+	dead     int
+	closed   bool
+	// failed is set when a failed append could not be rolled back; the
+	// log may end in a torn record, so further writes are refused.
+	failed error
+}
+
+// logFile is the part of *os.File the store appends through.
+type logFile interface {
+	io.WriteSeeker
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// logRecord is a single entry in the FileStore log.
+type logRecord struct {
+	Op     string  `json:"op"`
+	ID     string  `json:"id,omitempty"`
+	Memory *Memory `json:"memory,omitempty"`
+}
+
+// NewFileStore opens or creates a file-backed store in cfg.Dir.
+func NewFileStore(cfg FileStoreConfig) (*FileStore, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("file store: dir is required")
+	}
+	if cfg.CompactThreshold == 0 {
+		cfg.CompactThreshold = defaultCompactThreshold
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create store dir: %w", err)
+	}
+
+	s := &FileStore{
+		config:   cfg,
+		memories: make(map[string]*Memory),
+	}
+
+	path := s.logPath()
+	// A leftover temp file means a compaction was interrupted before the
+	// rename; the original log is still authoritative.
+	_ = os.Remove(path + ".tmp")
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open log: %w", err)
+	}
+	if err := s.replay(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("seek log: %w", err)
+	}
+	s.file = f
+	return s, nil
+}
+
+func (s *FileStore) logPath() string {
+	return filepath.Join(s.config.Dir, fileStoreLogName)
+}
+
+// replay rebuilds the index from the log. A torn or corrupt record at the
+// tail is treated as an interrupted write and truncated; corruption followed
+// by valid records is reported as an error.
+func (s *FileStore) replay(f *os.File) error {
+	r := bufio.NewReader(f)
+	var offset, goodOffset int64
+	var corruptAt int64 = -1
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			complete := line[len(line)-1] == '\n'
+			rec, decodeErr := decodeRecord(line)
+			switch {
+			case !complete || decodeErr != nil:
+				if corruptAt < 0 {
+					corruptAt = offset
+				}
+			case corruptAt >= 0:
+				return fmt.Errorf("corrupt log record at offset %d", corruptAt)
+			default:
+				s.apply(rec)
+				goodOffset = offset + int64(len(line))
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read log: %w", err)
+		}
+	}
+
+	if corruptAt >= 0 {
+		if err := f.Truncate(goodOffset); err != nil {
+			return fmt.Errorf("truncate log: %w", err)
+		}
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("sync log: %w", err)
+		}
+	}
+	return nil
+}
+
+// apply updates the index with a record and tracks dead records.
+func (s *FileStore) apply(rec *logRecord) {
+	switch rec.Op {
+	case opPut:
+		if _, ok := s.memories[rec.Memory.ID]; ok {
+			s.dead++
+		}
+		s.memories[rec.Memory.ID] = rec.Memory
+	case opDelete:
+		if _, ok := s.memories[rec.ID]; ok {
+			delete(s.memories, rec.ID)
+			s.dead++
+		}
+		s.dead++
+	case opClear:
+		s.dead += len(s.memories) + 1
+		s.memories = make(map[string]*Memory)
+	}
+}
+
+func encodeRecord(rec *logRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("encode record: %w", err)
+	}
+	buf := make([]byte, 0, len(payload)+fileStoreRecordHeaderSize+1)
+	buf = fmt.Appendf(buf, "%08x ", crc32.ChecksumIEEE(payload))
+	buf = append(buf, payload...)
+	return append(buf, '\n'), nil
+}
+
+func decodeRecord(line []byte) (*logRecord, error) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) < fileStoreRecordHeaderSize || line[fileStoreRecordHeaderSize-1] != ' ' {
+		return nil, errors.New("malformed record")
+	}
+	var sum uint32
+	if _, err := fmt.Sscanf(string(line[:fileStoreRecordHeaderSize-1]), "%08x", &sum); err != nil {
+		return nil, fmt.Errorf("malformed checksum: %w", err)
+	}
+	payload := line[fileStoreRecordHeaderSize:]
+	if crc32.ChecksumIEEE(payload) != sum {
+		return nil, errors.New("checksum mismatch")
+	}
+	var rec logRecord
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return nil, fmt.Errorf("decode record: %w", err)
+	}
+	if rec.Op == opPut && (rec.Memory == nil || rec.Memory.ID == "") {
+		return nil, errors.New("put record without memory")
+	}
+	return &rec, nil
+}
+
+// append writes and fsyncs a record, then applies it to the index.
+// Callers must hold s.mu.
+func (s *FileStore) append(rec *logRecord) error {
+	if s.closed {
+		return fmt.Errorf("file store is closed")
+	}
+	if s.failed != nil {
+		return fmt.Errorf("file store failed: %w", s.failed)
+	}
+	buf, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	offset, err := s.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("seek log: %w", err)
+	}
+	if _, err := s.file.Write(buf); err != nil {
+		return s.rollback(offset, fmt.Errorf("write log: %w", err))
+	}
+	if err := s.file.Sync(); err != nil {
+		return s.rollback(offset, fmt.Errorf("sync log: %w", err))
+	}
+	s.apply(rec)
+
+	if s.config.CompactThreshold > 0 && s.dead >= s.config.CompactThreshold && s.dead > len(s.memories) {
+		s.autoCompact()
+	}
+	return nil
+}
+
+// autoCompact compacts after a successful write, reporting failure to
+// OnCompactError rather than to the writer. Callers must hold s.mu.
+func (s *FileStore) autoCompact() {
+	if err := s.compact(); err != nil && s.config.OnCompactError != nil {
+		s.config.OnCompactError(err)
+	}
+}
+
+// rollback truncates the log back to offset after a failed append, so
+// that a partly written record does not precede later ones. If that
+// fails too, the store refuses further writes.
+func (s *FileStore) rollback(offset int64, err error) error {
+	if terr := s.file.Truncate(offset); terr != nil {
+		s.failed = err
+		return err
+	}
+	if _, serr := s.file.Seek(offset, io.SeekStart); serr != nil {
+		s.failed = err
+		return err
+	}
+	if serr := s.file.Sync(); serr != nil {
+		s.failed = err
+	}
+	return err
+}
+
+// Save stores a memory.
+func (s *FileStore) Save(ctx context.Context, m *Memory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	now := time.Now()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	m.UpdatedAt = now
+
+	return s.append(&logRecord{Op: opPut, Memory: m.clone()})
+}
+
+// Get retrieves a memory by ID.
+func (s *FileStore) Get(ctx context.Context, id string) (*Memory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.memories[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return m.clone(), nil
+}
+
+// RecordAccess increments the memory's access count and last access time.
+func (s *FileStore) RecordAccess(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.memories[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	m = m.clone()
+	m.AccessCount++
+	m.LastAccessedAt = &at
+	return s.append(&logRecord{Op: opPut, Memory: m})
+}
+
+// Delete removes a memory.
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.memories[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return s.append(&logRecord{Op: opDelete, ID: id})
+}
+
+// List returns all memories matching the filter.
+func (s *FileStore) List(ctx context.Context, filter *Filter) ([]*Memory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make([]*Memory, 0, len(s.memories))
+	for _, m := range s.memories {
+		all = append(all, m)
+	}
+	result, err := applyFilter(all, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range result {
+		result[i] = m.clone()
+	}
+	return result, nil
+}
+
+// Clear removes all memories.
+func (s *FileStore) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(&logRecord{Op: opClear}); err != nil {
+		return err
+	}
+	// Nothing survives a clear, so the log can be reset immediately.
+	s.autoCompact()
+	return nil
+}
+
+// Compact rewrites the log so it holds only live memories.
+func (s *FileStore) Compact(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("file store is closed")
+	}
+	return s.compact()
+}
+
+// compact writes live memories to a temp file, fsyncs it and atomically
+// renames it over the log. Callers must hold s.mu.
+func (s *FileStore) compact() error {
+	path := s.logPath()
+	tmpPath := path + ".tmp"
+
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("create compacted log: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, m := range s.memories {
+		buf, err := encodeRecord(&logRecord{Op: opPut, Memory: m})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err := w.Write(buf); err != nil {
+			tmp.Close()
+			return fmt.Errorf("write compacted log: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write compacted log: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync compacted log: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		tmp.Close()
+		return fmt.Errorf("replace log: %w", err)
+	}
+
+	// The old log is unlinked now, so writes must go to the new one even
+	// if the rename cannot be made durable.
+	s.file.Close()
+	s.file = tmp
+	s.dead = 0
+	return syncDir(s.config.Dir)
+}
+
+// Close releases the underlying log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.file.Close()
+}
+
+// syncDir fsyncs a directory so a rename within it is durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open store dir: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync store dir: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestFileStore(t *testing.T, dir string) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(FileStoreConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestFileStore(t *testing.T) {
+	testStoreBasics(t, newTestFileStore(t, t.TempDir()))
+}
+
+func TestFileStoreFiltering(t *testing.T) {
+	testStoreFiltering(t, newTestFileStore(t, t.TempDir()))
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := newTestFileStore(t, dir)
+	keep := &Memory{Type: TypeSemantic, Content: "keep", Metadata: map[string]interface{}{"lang": "go"}}
+	gone := &Memory{Type: TypeEpisodic, Content: "gone"}
+	s.Save(ctx, keep)
+	s.Save(ctx, gone)
+	keep.Content = "keep v2"
+	s.Save(ctx, keep)
+	s.Delete(ctx, gone.ID)
+	s.Close()
+
+	s = newTestFileStore(t, dir)
+	got, err := s.Get(ctx, keep.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Content != "keep v2" {
+		t.Errorf("expected content 'keep v2', got %q", got.Content)
+	}
+	if got.Metadata["lang"] != "go" {
+		t.Errorf("expected metadata lang 'go', got %v", got.Metadata["lang"])
+	}
+	if _, err := s.Get(ctx, gone.ID); err == nil {
+		t.Error("expected deleted memory to stay deleted after reopen")
+	}
+}
+
+func TestFileStoreTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := newTestFileStore(t, dir)
+	m := &Memory{Content: "durable"}
+	s.Save(ctx, m)
+	s.Close()
+
+	// Simulate a crash halfway through appending a record.
+	f, err := os.OpenFile(filepath.Join(dir, fileStoreLogName), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.WriteString(`0badc0de {"op":"put","memory":{"id":"x"`)
+	f.Close()
+
+	s = newTestFileStore(t, dir)
+	memories, _ := s.List(ctx, nil)
+	if len(memories) != 1 || memories[0].ID != m.ID {
+		t.Fatalf("expected only the durable memory, got %d memories", len(memories))
+	}
+
+	// The torn tail is truncated so new writes remain readable.
+	s.Save(ctx, &Memory{Content: "after crash"})
+	s.Close()
+	s = newTestFileStore(t, dir)
+	memories, _ = s.List(ctx, nil)
+	if len(memories) != 2 {
+		t.Errorf("expected 2 memories after recovery, got %d", len(memories))
+	}
+}
+
+func TestFileStoreCorruptMiddle(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := newTestFileStore(t, dir)
+	s.Save(ctx, &Memory{Content: "first"})
+	s.Save(ctx, &Memory{Content: "second"})
+	s.Close()
+
+	path := filepath.Join(dir, fileStoreLogName)
+	data, _ := os.ReadFile(path)
+	data[0] ^= 0xff
+	os.WriteFile(path, data, 0o600)
+
+	if _, err := NewFileStore(FileStoreConfig{Dir: dir}); err == nil {
+		t.Error("expected error for corruption before valid records")
+	}
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s, err := NewFileStore(FileStoreConfig{Dir: dir, CompactThreshold: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := &Memory{Content: "v0"}
+	for i := 0; i < 20; i++ {
+		if err := s.Save(ctx, m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	s.Close()
+
+	data, _ := os.ReadFile(filepath.Join(dir, fileStoreLogName))
+	lines := 0
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+	if lines >= 20 {
+		t.Errorf("expected log to be compacted, got %d records", lines)
+	}
+
+	s = newTestFileStore(t, dir)
+	memories, _ := s.List(ctx, nil)
+	if len(memories) != 1 {
+		t.Errorf("expected 1 memory after compaction, got %d", len(memories))
+	}
+}
+
+// tornFile writes half of the next record and then fails, like a full disk.
+type tornFile struct {
+	*os.File
+	torn         bool
+	failTruncate bool
+}
+
+func (f *tornFile) Write(p []byte) (int, error) {
+	if f.torn {
+		return f.File.Write(p)
+	}
+	f.torn = true
+	n, _ := f.File.Write(p[:len(p)/2])
+	return n, errors.New("no space left on device")
+}
+
+func (f *tornFile) Truncate(size int64) error {
+	if f.failTruncate {
+		return errors.New("truncate failed")
+	}
+	return f.File.Truncate(size)
+}
+
+func TestFileStoreFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	s := newTestFileStore(t, dir)
+	s.Save(ctx, &Memory{Content: "before"})
+	s.file = &tornFile{File: s.file.(*os.File)}
+	if err := s.Save(ctx, &Memory{Content: "lost"}); err == nil {
+		t.Fatal("expected write error")
+	}
+
+	// The partial record is rolled back, so later records stay readable.
+	if err := s.Save(ctx, &Memory{Content: "after"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.Close()
+	s = newTestFileStore(t, dir)
+	memories, _ := s.List(ctx, nil)
+	if len(memories) != 2 {
+		t.Errorf("expected 2 memories after reopen, got %d", len(memories))
+	}
+}
+
+func TestFileStoreFailedRollback(t *testing.T) {
+	s := newTestFileStore(t, t.TempDir())
+	ctx := context.Background()
+
+	s.file = &tornFile{File: s.file.(*os.File), failTruncate: true}
+	if err := s.Save(ctx, &Memory{Content: "lost"}); err == nil {
+		t.Fatal("expected write error")
+	}
+	// The log may end in a torn record, so the store refuses to write more.
+	err := s.Save(ctx, &Memory{Content: "after"})
+	if err == nil || !strings.Contains(err.Error(), "file store failed") {
+		t.Errorf("expected failed store error, got %v", err)
+	}
+}
+
+func TestFileStoreCompactionError(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// A non-empty directory in the way of the temp file makes compaction
+	// fail.
+	blocker := filepath.Join(dir, fileStoreLogName+".tmp")
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	var compactErrs []error
+	s, err := NewFileStore(FileStoreConfig{Dir: dir, CompactThreshold: 2, OnCompactError: func(err error) {
+		compactErrs = append(compactErrs, err)
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := &Memory{Content: "v0"}
+	for i := 0; i < 5; i++ {
+		m.Content = fmt.Sprintf("v%d", i)
+		if err := s.Save(ctx, m); err != nil {
+			t.Fatalf("expected durable save to succeed, got %v", err)
+		}
+	}
+	if len(compactErrs) == 0 {
+		t.Error("expected compaction errors to be reported")
+	}
+	s.Close()
+
+	s = newTestFileStore(t, dir)
+	if got, err := s.Get(ctx, m.ID); err != nil || got.Content != "v4" {
+		t.Errorf("expected latest save after reopen, got %+v (%v)", got, err)
+	}
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Supported values for Filter.OrderBy.
+const (
+	OrderByCreatedAt = "created_at"
+	OrderByUpdatedAt = "updated_at"
+)
+
+// Validate checks that the filter only uses supported options.
+func (f *Filter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	switch f.OrderBy {
+	case "", OrderByCreatedAt, OrderByUpdatedAt:
+	default:
+		return fmt.Errorf("unsupported order_by: %q", f.OrderBy)
+	}
+	if f.Limit < 0 {
+		return fmt.Errorf("negative limit: %d", f.Limit)
+	}
+	if f.Offset < 0 {
+		return fmt.Errorf("negative offset: %d", f.Offset)
+	}
+	return nil
+}
+
+// matches reports whether m satisfies the filter's predicates at now.
+func (f *Filter) matches(m *Memory, now time.Time) bool {
+	if m.Expired(now) && (f == nil || !f.IncludeExpired) {
+		return false
+	}
+	if m.Archived && (f == nil || !f.IncludeArchived) {
+		return false
+	}
+	if f == nil {
+		return true
+	}
+	if f.Type != "" && m.Type != f.Type {
+		return false
+	}
+	if f.Since != nil && m.CreatedAt.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && m.CreatedAt.After(*f.Until) {
+		return false
+	}
+	for k, want := range f.Metadata {
+		got, ok := m.Metadata[k]
+		if !ok || !MetadataEqual(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// MetadataEqual reports whether two metadata values are equal after JSON
+// normalization, so 1 and 1.0 compare equal the same way they would after a
+// round trip through a persistent store.
+func MetadataEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return string(ja) == string(jb)
+}
+
+// less orders memories according to the filter. Without an explicit OrderBy
+// memories are returned newest first; ties are broken by ID so pagination is
+// stable.
+func (f *Filter) less(a, b *Memory) bool {
+	orderBy, desc := OrderByCreatedAt, true
+	if f != nil && f.OrderBy != "" {
+		orderBy, desc = f.OrderBy, f.OrderDesc
+	}
+	ta, tb := a.CreatedAt, b.CreatedAt
+	if orderBy == OrderByUpdatedAt {
+		ta, tb = a.UpdatedAt, b.UpdatedAt
+	}
+	if !ta.Equal(tb) {
+		if desc {
+			return ta.After(tb)
+		}
+		return ta.Before(tb)
+	}
+	return a.ID < b.ID
+}
+
+// applyFilter selects, orders and paginates memories according to filter.
+func applyFilter(memories []*Memory, filter *Filter) ([]*Memory, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var result []*Memory
+	for _, m := range memories {
+		if filter.matches(m, now) {
+			result = append(result, m)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return filter.less(result[i], result[j])
+	})
+
+	// Apply offset and limit
+	if filter != nil {
+		if filter.Offset > 0 && filter.Offset < len(result) {
+			result = result[filter.Offset:]
+		} else if filter.Offset >= len(result) {
+			return []*Memory{}, nil
+		}
+		if filter.Limit > 0 && filter.Limit < len(result) {
+			result = result[:filter.Limit]
+		}
+	}
+
+	return result, nil
+}
+
+// clone returns a deep copy of m so callers cannot mutate stored state.
+func (m *Memory) clone() *Memory {
+	c := *m
+	if m.Embedding != nil {
+		c.Embedding = append([]float64(nil), m.Embedding...)
+	}
+	if m.ExpiresAt != nil {
+		t := *m.ExpiresAt
+		c.ExpiresAt = &t
+	}
+	if m.LastAccessedAt != nil {
+		t := *m.LastAccessedAt
+		c.LastAccessedAt = &t
+	}
+	if m.Metadata != nil {
+		c.Metadata = make(map[string]interface{}, len(m.Metadata))
+		for k, v := range m.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return &c
+}
@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// FusionMethod selects how keyword and vector rankings are combined.
+type FusionMethod string
+
+const (
+	// FusionRRF combines rankings with reciprocal rank fusion:
+	// score = sum(weight / (k + rank)).
+	FusionRRF FusionMethod = "rrf"
+	// FusionWeighted min-max normalizes each signal's scores to [0, 1] and
+	// sums them by weight.
+	FusionWeighted FusionMethod = "weighted"
+)
+
+// DefaultRRFK is the rank offset from the original reciprocal rank fusion paper.
+const DefaultRRFK = 60
+
+// HybridConfig contains hybrid search configuration.
+type HybridConfig struct {
+	// Fusion defaults to FusionRRF.
+	Fusion FusionMethod `json:"fusion,omitempty"`
+	// RRFK is the RRF rank offset. Defaults to DefaultRRFK.
+	RRFK float64 `json:"rrf_k,omitempty"`
+	// VectorWeight and KeywordWeight scale each signal. Both default to 1
+	// when left at zero.
+	VectorWeight  float64 `json:"vector_weight,omitempty"`
+	KeywordWeight float64 `json:"keyword_weight,omitempty"`
+	// Candidates is how many results to pull from each signal before
+	// fusing. Defaults to four times the requested limit, at least 20.
+	Candidates int `json:"candidates,omitempty"`
+}
+
+// HybridResult is a fused search result with per-signal detail. Ranks are
+// 1-based; a rank of zero means the signal did not return the memory.
+type HybridResult struct {
+	Memory       *Memory `json:"memory"`
+	Score        float64 `json:"score"`
+	VectorScore  float64 `json:"vector_score"`
+	VectorRank   int     `json:"vector_rank,omitempty"`
+	KeywordScore float64 `json:"keyword_score"`
+	KeywordRank  int     `json:"keyword_rank,omitempty"`
+}
+
+// HybridSearcher fuses vector similarity and keyword relevance, so exact
+// identifiers such as function names and error codes are found even when
+// their embeddings are not close to the query's.
+type HybridSearcher struct {
+	vector  VectorStore
+	keyword KeywordSearcher
+	config  HybridConfig
+}
+
+// NewHybridSearcher creates a hybrid searcher. If keyword is nil and vector
+// implements KeywordSearcher, the vector store is used for both signals.
+func NewHybridSearcher(vector VectorStore, keyword KeywordSearcher, cfg HybridConfig) *HybridSearcher {
+	if keyword == nil {
+		keyword, _ = vector.(KeywordSearcher)
+	}
+	if cfg.Fusion == "" {
+		cfg.Fusion = FusionRRF
+	}
+	if cfg.RRFK == 0 {
+		cfg.RRFK = DefaultRRFK
+	}
+	if cfg.VectorWeight == 0 && cfg.KeywordWeight == 0 {
+		cfg.VectorWeight, cfg.KeywordWeight = 1, 1
+	}
+	return &HybridSearcher{vector: vector, keyword: keyword, config: cfg}
+}
+
+// Search runs both signals for query and returns up to limit fused results,
+// best first. Each result's Memory.Score is set to the fused score.
+func (h *HybridSearcher) Search(ctx context.Context, query string, limit int) ([]*HybridResult, error) {
+	if h.keyword == nil {
+		return nil, fmt.Errorf("hybrid search: no keyword searcher configured")
+	}
+	switch h.config.Fusion {
+	case FusionRRF, FusionWeighted:
+	default:
+		return nil, fmt.Errorf("hybrid search: unknown fusion method %q", h.config.Fusion)
+	}
+
+	candidates := h.config.Candidates
+	if candidates <= 0 {
+		candidates = 4 * limit
+		if candidates < 20 {
+			candidates = 20
+		}
+	}
+
+	vectorHits, err := h.vector.SearchByText(ctx, query, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("hybrid search: vector: %w", err)
+	}
+	keywordHits, err := h.keyword.SearchKeyword(ctx, query, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("hybrid search: keyword: %w", err)
+	}
+
+	byID := make(map[string]*HybridResult)
+	var order []*HybridResult
+	get := func(m *Memory) *HybridResult {
+		r, ok := byID[m.ID]
+		if !ok {
+			r = &HybridResult{Memory: m}
+			byID[m.ID] = r
+			order = append(order, r)
+		}
+		return r
+	}
+	for i, m := range vectorHits {
+		r := get(m)
+		r.VectorScore, r.VectorRank = m.Score, i+1
+	}
+	for i, m := range keywordHits {
+		r := get(m)
+		r.KeywordScore, r.KeywordRank = m.Score, i+1
+	}
+
+	switch h.config.Fusion {
+	case FusionRRF:
+		for _, r := range order {
+			if r.VectorRank > 0 {
+				r.Score += h.config.VectorWeight / (h.config.RRFK + float64(r.VectorRank))
+			}
+			if r.KeywordRank > 0 {
+				r.Score += h.config.KeywordWeight / (h.config.RRFK + float64(r.KeywordRank))
+			}
+		}
+	case FusionWeighted:
+		vmin, vmax := scoreRange(vectorHits)
+		kmin, kmax := scoreRange(keywordHits)
+		for _, r := range order {
+			if r.VectorRank > 0 {
+				r.Score += h.config.VectorWeight * normalize(r.VectorScore, vmin, vmax)
+			}
+			if r.KeywordRank > 0 {
+				r.Score += h.config.KeywordWeight * normalize(r.KeywordScore, kmin, kmax)
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].Score > order[j].Score
+	})
+	if limit > 0 && limit < len(order) {
+		order = order[:limit]
+	}
+	for _, r := range order {
+		r.Memory.Score = r.Score
+	}
+	return order, nil
+}
+
+func scoreRange(memories []*Memory) (lo, hi float64) {
+	for i, m := range memories {
+		if i == 0 || m.Score < lo {
+			lo = m.Score
+		}
+		if i == 0 || m.Score > hi {
+			hi = m.Score
+		}
+	}
+	return lo, hi
+}
+
+// normalize maps v into [0, 1]; a degenerate range maps to 1.
+func normalize(v, lo, hi float64) float64 {
+	if hi == lo {
+		return 1
+	}
+	return (v - lo) / (hi - lo)
+}
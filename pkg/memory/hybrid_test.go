@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"hello world", []string{"hello", "world"}},
+		{"parseHTTPRequest", []string{"parsehttprequest", "parse", "http", "request"}},
+		{"snake_case_name", []string{"snake_case_name", "snake", "case", "name"}},
+		{"ERR_TIMEOUT", []string{"err_timeout", "err", "timeout"}},
+		{"memory.Store.Save()", []string{"memory", "store", "save"}},
+		{"E1234 utf8Decode", []string{"e1234", "utf8decode", "utf8", "decode"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestBM25Index(t *testing.T) {
+	idx := NewBM25Index()
+	idx.Add(&Memory{ID: "a", Content: "call ParseConfig before starting the server"})
+	idx.Add(&Memory{ID: "b", Content: "the server returned ERR_TIMEOUT"})
+	idx.Add(&Memory{ID: "c", Content: "the config loader reads yaml config files"})
+
+	hits := idx.Search("ParseConfig", 10)
+	if len(hits) == 0 || hits[0].ID != "a" {
+		t.Fatalf("expected exact identifier match first, got %v", hits)
+	}
+
+	hits = idx.Search("timeout", 10)
+	if len(hits) != 1 || hits[0].ID != "b" {
+		t.Errorf("expected snake_case part to match, got %v", hits)
+	}
+
+	hits = idx.Search("config", 10)
+	if len(hits) != 2 || hits[0].ID != "c" {
+		t.Errorf("expected higher term frequency first, got %v", hits)
+	}
+
+	idx.Remove("c")
+	if hits = idx.Search("yaml", 10); len(hits) != 0 {
+		t.Errorf("expected no hits after remove, got %v", hits)
+	}
+	if idx.Len() != 2 {
+		t.Errorf("expected 2 documents, got %d", idx.Len())
+	}
+
+	// Re-adding replaces the previous content.
+	idx.Add(&Memory{ID: "a", Content: "nothing relevant"})
+	if hits = idx.Search("ParseConfig", 10); len(hits) != 0 {
+		t.Errorf("expected stale content to be removed, got %v", hits)
+	}
+}
+
+func TestHybridSearcher(t *testing.T) {
+	store := NewInMemoryVectorStore(newWordEmbedder())
+	ctx := context.Background()
+
+	// "fn" mentions the identifier but nothing in the embedder vocabulary,
+	// so only the keyword signal can find it.
+	store.Save(ctx, &Memory{ID: "fn", Content: "loadUserProfile panics on nil session"})
+	store.Save(ctx, &Memory{ID: "go", Content: "go test flakes in the database package"})
+	store.Save(ctx, &Memory{ID: "py", Content: "python deploy script"})
+
+	for _, fusion := range []FusionMethod{FusionRRF, FusionWeighted} {
+		t.Run(string(fusion), func(t *testing.T) {
+			h := NewHybridSearcher(store, nil, HybridConfig{Fusion: fusion})
+			results, err := h.Search(ctx, "go test loadUserProfile", 3)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			byID := make(map[string]*HybridResult)
+			for _, r := range results {
+				byID[r.Memory.ID] = r
+			}
+			fn, ok := byID["fn"]
+			if !ok {
+				t.Fatal("expected keyword-only match in results")
+			}
+			if fn.KeywordRank != 1 || fn.KeywordScore <= 0 {
+				t.Errorf("expected keyword rank 1 with positive score, got rank %d score %v", fn.KeywordRank, fn.KeywordScore)
+			}
+			if results[0].Memory.ID != "go" {
+				t.Errorf("expected memory matching both signals first, got %q", results[0].Memory.ID)
+			}
+			if g := byID["go"]; g.VectorRank != 1 || g.KeywordRank == 0 {
+				t.Errorf("expected 'go' in both signals, got vector rank %d keyword rank %d", g.VectorRank, g.KeywordRank)
+			}
+			for i := 1; i < len(results); i++ {
+				if results[i].Score > results[i-1].Score {
+					t.Error("expected results ordered by fused score")
+				}
+			}
+		})
+	}
+
+	h := NewHybridSearcher(store, nil, HybridConfig{Fusion: "bogus"})
+	if _, err := h.Search(ctx, "go", 1); err == nil {
+		t.Error("expected error for unknown fusion method")
+	}
+}
@@ -3,19 +3,44 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrNotFound is returned when a memory does not exist in a store.
+var ErrNotFound = errors.New("memory not found")
+
 // Memory represents a single memory item.
+//
+// Importance is a caller-assigned weight in [0, 1]. ExpiresAt, when set,
+// hides the memory from List and search once passed and lets an Expirer
+// delete it. AccessCount and LastAccessedAt are maintained by RecordAccess.
+// Archived memories are kept for provenance but hidden from List and search.
 type Memory struct {
-	ID        string                 `json:"id"`
-	Type      MemoryType             `json:"type"`
-	Content   string                 `json:"content"`
-	Embedding []float64              `json:"embedding,omitempty"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
-	CreatedAt time.Time              `json:"created_at"`
-	UpdatedAt time.Time              `json:"updated_at"`
-	Score     float64                `json:"score,omitempty"`
+	ID             string                 `json:"id"`
+	Type           MemoryType             `json:"type"`
+	Content        string                 `json:"content"`
+	Embedding      []float64              `json:"embedding,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+	Score          float64                `json:"score,omitempty"`
+	Importance     float64                `json:"importance,omitempty"`
+	ExpiresAt      *time.Time             `json:"expires_at,omitempty"`
+	AccessCount    int                    `json:"access_count,omitempty"`
+	LastAccessedAt *time.Time             `json:"last_accessed_at,omitempty"`
+	Archived       bool                   `json:"archived,omitempty"`
+}
+
+// ExpireAfter sets the memory to expire d from now.
+func (m *Memory) ExpireAfter(d time.Duration) {
+	t := time.Now().Add(d)
+	m.ExpiresAt = &t
+}
+
+// Expired reports whether the memory has expired at the given time.
+func (m *Memory) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(now)
 }
 
 // MemoryType represents the type of memory.
@@ -51,7 +76,21 @@ type VectorStore interface {
 	SearchByText(ctx context.Context, text string, limit int) ([]*Memory, error)
 }
 
+// KeywordSearcher is implemented by stores that support keyword search over
+// memory content. Results are ordered best match first with Score set to the
+// backend's relevance score, where higher is better.
+type KeywordSearcher interface {
+	// SearchKeyword finds memories whose content matches the query terms.
+	SearchKeyword(ctx context.Context, query string, limit int) ([]*Memory, error)
+}
+
 // Filter contains options for filtering memories.
+//
+// Metadata matches memories whose metadata contains every given key with an
+// equal value. Expired and archived memories are skipped unless
+// IncludeExpired or IncludeArchived is set. OrderBy accepts OrderByCreatedAt or OrderByUpdatedAt and is
+// sorted ascending unless OrderDesc is set; when OrderBy is empty memories
+// are returned newest first.
 type Filter struct {
 	Type      MemoryType             `json:"type,omitempty"`
 	Since     *time.Time             `json:"since,omitempty"`
@@ -61,6 +100,9 @@ type Filter struct {
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 	OrderBy   string                 `json:"order_by,omitempty"`
 	OrderDesc bool                   `json:"order_desc,omitempty"`
+
+	IncludeExpired  bool `json:"include_expired,omitempty"`
+	IncludeArchived bool `json:"include_archived,omitempty"`
 }
 
 // Embedder generates embeddings for text.
@@ -72,3 +114,19 @@ type Embedder interface {
 	// Dimension returns the embedding dimension.
 	Dimension() int
 }
+
+// ModelNamer is implemented by embedders that can name the model producing
+// their embeddings. Embeddings from different models are not comparable.
+type ModelNamer interface {
+	// Model returns the embedding model identifier.
+	Model() string
+}
+
+// EmbedderModel returns the model name of e, or "" if it does not implement
+// ModelNamer.
+func EmbedderModel(e Embedder) string {
+	if n, ok := e.(ModelNamer); ok {
+		return n.Model()
+	}
+	return ""
+}
@@ -7,7 +7,16 @@ import (
 )
 
 func TestInMemoryStore(t *testing.T) {
-	store := NewInMemoryStore()
+	testStoreBasics(t, NewInMemoryStore())
+}
+
+func TestInMemoryStoreFiltering(t *testing.T) {
+	testStoreFiltering(t, NewInMemoryStore())
+}
+
+// testStoreBasics exercises the Store contract shared by all backends.
+func testStoreBasics(t *testing.T, store Store) {
+	t.Helper()
 	ctx := context.Background()
 
 	// Test Save
@@ -80,8 +89,9 @@ func TestInMemoryStore(t *testing.T) {
 	}
 }
 
-func TestInMemoryStoreFiltering(t *testing.T) {
-	store := NewInMemoryStore()
+// testStoreFiltering exercises Filter handling shared by all backends.
+func testStoreFiltering(t *testing.T, store Store) {
+	t.Helper()
 	ctx := context.Background()
 
 	now := time.Now()
@@ -114,3 +124,32 @@ func TestInMemoryStoreFiltering(t *testing.T) {
 		t.Errorf("expected 2 memories with offset, got %d", len(memories))
 	}
 }
+
+func TestFilterMetadataAndOrder(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+
+	now := time.Now()
+	store.Save(ctx, &Memory{ID: "a", CreatedAt: now.Add(-time.Hour), Metadata: map[string]interface{}{"repo": "api", "n": 1}})
+	store.Save(ctx, &Memory{ID: "b", CreatedAt: now, Metadata: map[string]interface{}{"repo": "web", "n": 1.0}})
+
+	memories, _ := store.List(ctx, &Filter{Metadata: map[string]interface{}{"repo": "api"}})
+	if len(memories) != 1 || memories[0].ID != "a" {
+		t.Errorf("expected only 'a' for repo=api, got %d memories", len(memories))
+	}
+
+	// Numbers compare equal regardless of Go type.
+	memories, _ = store.List(ctx, &Filter{Metadata: map[string]interface{}{"n": 1}})
+	if len(memories) != 2 {
+		t.Errorf("expected 2 memories for n=1, got %d", len(memories))
+	}
+
+	memories, _ = store.List(ctx, &Filter{OrderBy: OrderByCreatedAt})
+	if len(memories) != 2 || memories[0].ID != "a" {
+		t.Error("expected oldest first for ascending created_at")
+	}
+
+	if _, err := store.List(ctx, &Filter{OrderBy: "content"}); err == nil {
+		t.Error("expected error for unsupported order_by")
+	}
+}
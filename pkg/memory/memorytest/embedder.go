@@ -0,0 +1,63 @@
+package memorytest
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+)
+
+// HashEmbedder is a deterministic memory.Embedder for tests. It hashes each
+// token produced by memory.Tokenize into one of Dim buckets and L2-normalizes
+// the counts, so texts sharing words are similar and unrelated texts are not.
+type HashEmbedder struct {
+	Dim int
+}
+
+// NewHashEmbedder creates a HashEmbedder with the given dimension.
+func NewHashEmbedder(dim int) *HashEmbedder {
+	return &HashEmbedder{Dim: dim}
+}
+
+// Embed generates an embedding for the given text.
+func (e *HashEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	v := make([]float64, e.Dim)
+	for _, tok := range memory.Tokenize(text) {
+		h := fnv.New32a()
+		h.Write([]byte(tok))
+		v[h.Sum32()%uint32(e.Dim)]++
+	}
+	var norm float64
+	for _, f := range v {
+		norm += f * f
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range v {
+			v[i] /= norm
+		}
+	}
+	return v, nil
+}
+
+// EmbedBatch generates embeddings for multiple texts.
+func (e *HashEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	out := make([][]float64, len(texts))
+	for i, t := range texts {
+		out[i], _ = e.Embed(ctx, t)
+	}
+	return out, nil
+}
+
+// Dimension returns the embedding dimension.
+func (e *HashEmbedder) Dimension() int {
+	return e.Dim
+}
+
+// Model identifies the embedder by its dimension, since embeddings of
+// different dimensions are not comparable.
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d", e.Dim)
+}
@@ -0,0 +1,657 @@
+// Package memorytest provides a conformance suite for memory.Store and
+// memory.VectorStore implementations.
+//
+// A backend proves it honours the contract by running the suite from its
+// own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		memorytest.TestStore(t, func(t *testing.T) memory.Store {
+//			return newEmptyStore(t)
+//		})
+//	}
+package memorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+)
+
+// StoreFactory returns a new, empty store. It is called once per subtest;
+// use t.Cleanup to release resources.
+type StoreFactory func(t *testing.T) memory.Store
+
+// VectorStoreFactory returns a new, empty vector store that uses embedder
+// for SearchByText and for memories saved without an embedding.
+type VectorStoreFactory func(t *testing.T, embedder memory.Embedder) memory.VectorStore
+
+// TestStore runs the memory.Store conformance suite.
+//
+// Beyond the interface itself, the suite expects that Save assigns missing
+// IDs and timestamps, that missing memories produce errors wrapping
+// memory.ErrNotFound, and that List honours every Filter field as
+// documented on memory.Filter.
+func TestStore(t *testing.T, newStore StoreFactory) {
+	t.Run("CRUD", func(t *testing.T) { testCRUD(t, newStore(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newStore(t)) })
+	t.Run("Timestamps", func(t *testing.T) { testTimestamps(t, newStore(t)) })
+	t.Run("Filter", func(t *testing.T) { testFilter(t, newStore(t)) })
+	t.Run("Order", func(t *testing.T) { testOrder(t, newStore(t)) })
+	t.Run("Pagination", func(t *testing.T) { testPagination(t, newStore(t)) })
+	t.Run("ConcurrentWriters", func(t *testing.T) { testConcurrentWriters(t, newStore(t)) })
+	t.Run("Clear", func(t *testing.T) { testClear(t, newStore(t)) })
+	t.Run("Retention", func(t *testing.T) { testRetention(t, newStore(t)) })
+	t.Run("RecordAccess", func(t *testing.T) { testRecordAccess(t, newStore(t)) })
+	t.Run("Archived", func(t *testing.T) { testArchived(t, newStore(t)) })
+}
+
+// TestVectorStore runs the memory.Store suite followed by search tests.
+func TestVectorStore(t *testing.T, newStore VectorStoreFactory) {
+	embedder := NewHashEmbedder(64)
+	TestStore(t, func(t *testing.T) memory.Store { return newStore(t, embedder) })
+	t.Run("SearchOrdering", func(t *testing.T) { testSearchOrdering(t, newStore(t, embedder), embedder) })
+	t.Run("SearchByText", func(t *testing.T) { testSearchByText(t, newStore(t, embedder)) })
+	t.Run("SearchEmpty", func(t *testing.T) { testSearchEmpty(t, newStore(t, embedder), embedder) })
+	t.Run("SearchSkipsExpired", func(t *testing.T) { testSearchSkipsExpired(t, newStore(t, embedder), embedder) })
+	t.Run("SearchSkipsArchived", func(t *testing.T) { testSearchSkipsArchived(t, newStore(t, embedder)) })
+}
+
+func testCRUD(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+
+	m := &memory.Memory{
+		Type:      memory.TypeSemantic,
+		Content:   "prefer table-driven tests",
+		Embedding: []float64{0.25, -0.5, 1},
+		Metadata:  map[string]interface{}{"lang": "go", "weight": 2, "reviewed": true},
+	}
+	if err := s.Save(ctx, m); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if m.ID == "" {
+		t.Fatal("Save did not assign an ID")
+	}
+
+	got, err := s.Get(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertMemory(t, got, m)
+
+	// Saving with an existing ID replaces the memory.
+	m.Content = "prefer table-driven tests with t.Run"
+	m.Metadata["lang"] = "golang"
+	if err := s.Save(ctx, m); err != nil {
+		t.Fatalf("Save (update): %v", err)
+	}
+	got, err = s.Get(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("Get after update: %v", err)
+	}
+	assertMemory(t, got, m)
+	if all := mustList(t, s, nil); len(all) != 1 {
+		t.Errorf("expected 1 memory after update, got %d", len(all))
+	}
+
+	// Caller-provided IDs are preserved.
+	fixed := &memory.Memory{ID: "fixed-id", Content: "fixed"}
+	if err := s.Save(ctx, fixed); err != nil {
+		t.Fatalf("Save with ID: %v", err)
+	}
+	if fixed.ID != "fixed-id" {
+		t.Errorf("expected ID to be preserved, got %q", fixed.ID)
+	}
+	if _, err := s.Get(ctx, "fixed-id"); err != nil {
+		t.Errorf("Get with caller ID: %v", err)
+	}
+
+	if err := s.Delete(ctx, m.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, m.ID); err == nil {
+		t.Error("expected error getting deleted memory")
+	}
+	if all := mustList(t, s, nil); len(all) != 1 {
+		t.Errorf("expected 1 memory after delete, got %d", len(all))
+	}
+}
+
+func testNotFound(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, memory.ErrNotFound) {
+		t.Errorf("Get missing: expected ErrNotFound, got %v", err)
+	}
+	if err := s.Delete(ctx, "missing"); !errors.Is(err, memory.ErrNotFound) {
+		t.Errorf("Delete missing: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testTimestamps(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+
+	before := time.Now()
+	m := &memory.Memory{Content: "auto timestamps"}
+	if err := s.Save(ctx, m); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if m.CreatedAt.Before(before) || m.CreatedAt.After(time.Now()) {
+		t.Errorf("expected CreatedAt to be set to now, got %v", m.CreatedAt)
+	}
+	if m.UpdatedAt.Before(m.CreatedAt) {
+		t.Errorf("expected UpdatedAt >= CreatedAt, got %v < %v", m.UpdatedAt, m.CreatedAt)
+	}
+
+	created := time.Date(2023, 4, 5, 6, 7, 8, 123456000, time.FixedZone("X", 3600))
+	old := &memory.Memory{Content: "backdated", CreatedAt: created}
+	if err := s.Save(ctx, old); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := s.Get(ctx, old.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !sameInstant(got.CreatedAt, created) {
+		t.Errorf("expected CreatedAt %v to be preserved, got %v", created, got.CreatedAt)
+	}
+	firstUpdate := got.UpdatedAt
+
+	time.Sleep(5 * time.Millisecond)
+	old.Content = "backdated, edited"
+	if err := s.Save(ctx, old); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err = s.Get(ctx, old.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !sameInstant(got.CreatedAt, created) {
+		t.Errorf("expected CreatedAt to survive update, got %v", got.CreatedAt)
+	}
+	if !got.UpdatedAt.After(firstUpdate) {
+		t.Errorf("expected UpdatedAt to advance on update, got %v then %v", firstUpdate, got.UpdatedAt)
+	}
+}
+
+func testFilter(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	fixtures := []*memory.Memory{
+		{ID: "e1", Type: memory.TypeEpisodic, CreatedAt: base, Metadata: map[string]interface{}{"repo": "api", "n": 1, "ok": true}},
+		{ID: "e2", Type: memory.TypeEpisodic, CreatedAt: base.Add(time.Hour), Metadata: map[string]interface{}{"repo": "web", "n": 2, "ok": false}},
+		{ID: "s1", Type: memory.TypeSemantic, CreatedAt: base.Add(2 * time.Hour), Metadata: map[string]interface{}{"repo": "api", "n": "1"}},
+		{ID: "w1", Type: memory.TypeWorking, CreatedAt: base.Add(3 * time.Hour)},
+	}
+	for _, m := range fixtures {
+		m.Content = "content of " + m.ID
+		if err := s.Save(ctx, m); err != nil {
+			t.Fatalf("Save %s: %v", m.ID, err)
+		}
+	}
+
+	at := func(d time.Duration) *time.Time {
+		v := base.Add(d)
+		return &v
+	}
+	tests := []struct {
+		name   string
+		filter *memory.Filter
+		want   []string
+	}{
+		{"nil", nil, []string{"w1", "s1", "e2", "e1"}},
+		{"empty", &memory.Filter{}, []string{"w1", "s1", "e2", "e1"}},
+		{"type", &memory.Filter{Type: memory.TypeEpisodic}, []string{"e2", "e1"}},
+		{"type no match", &memory.Filter{Type: memory.TypeProcedural}, nil},
+		{"since inclusive", &memory.Filter{Since: at(time.Hour)}, []string{"w1", "s1", "e2"}},
+		{"until inclusive", &memory.Filter{Until: at(time.Hour)}, []string{"e2", "e1"}},
+		{"since and until", &memory.Filter{Since: at(time.Hour), Until: at(2 * time.Hour)}, []string{"s1", "e2"}},
+		{"metadata string", &memory.Filter{Metadata: map[string]interface{}{"repo": "api"}}, []string{"s1", "e1"}},
+		{"metadata number", &memory.Filter{Metadata: map[string]interface{}{"n": 1}}, []string{"e1"}},
+		{"metadata float equals int", &memory.Filter{Metadata: map[string]interface{}{"n": 2.0}}, []string{"e2"}},
+		{"metadata bool", &memory.Filter{Metadata: map[string]interface{}{"ok": false}}, []string{"e2"}},
+		{"metadata missing key", &memory.Filter{Metadata: map[string]interface{}{"absent": "x"}}, nil},
+		{"metadata all keys", &memory.Filter{Metadata: map[string]interface{}{"repo": "api", "ok": true}}, []string{"e1"}},
+		{"combined", &memory.Filter{Type: memory.TypeEpisodic, Metadata: map[string]interface{}{"repo": "web"}, Since: at(0)}, []string{"e2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, mustList(t, s, tt.filter), tt.want)
+		})
+	}
+
+	if _, err := s.List(ctx, &memory.Filter{OrderBy: "content"}); err == nil {
+		t.Error("expected error for unsupported OrderBy")
+	}
+}
+
+func testOrder(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Created a < b < c, but a is updated last.
+	ms := map[string]*memory.Memory{}
+	for i, id := range []string{"a", "b", "c"} {
+		m := &memory.Memory{ID: id, Content: id, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if err := s.Save(ctx, m); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		ms[id] = m
+		time.Sleep(2 * time.Millisecond)
+	}
+	ms["a"].Content = "a2"
+	if err := s.Save(ctx, ms["a"]); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *memory.Filter
+		want   []string
+	}{
+		{"default newest first", nil, []string{"c", "b", "a"}},
+		{"created asc", &memory.Filter{OrderBy: memory.OrderByCreatedAt}, []string{"a", "b", "c"}},
+		{"created desc", &memory.Filter{OrderBy: memory.OrderByCreatedAt, OrderDesc: true}, []string{"c", "b", "a"}},
+		{"updated asc", &memory.Filter{OrderBy: memory.OrderByUpdatedAt}, []string{"b", "c", "a"}},
+		{"updated desc", &memory.Filter{OrderBy: memory.OrderByUpdatedAt, OrderDesc: true}, []string{"a", "c", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, mustList(t, s, tt.filter), tt.want)
+		})
+	}
+
+	// Ties are broken by ID so pages are stable.
+	same := base.Add(10 * time.Hour)
+	for _, id := range []string{"t2", "t1", "t3"} {
+		if err := s.Save(ctx, &memory.Memory{ID: id, Content: id, CreatedAt: same}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	assertIDs(t, mustList(t, s, &memory.Filter{Since: &same}), []string{"t1", "t2", "t3"})
+}
+
+func testPagination(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var want []string
+	for i := 0; i < 7; i++ {
+		id := fmt.Sprintf("m%d", i)
+		if err := s.Save(ctx, &memory.Memory{ID: id, Content: id, CreatedAt: base.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		want = append([]string{id}, want...)
+	}
+
+	tests := []struct {
+		name   string
+		filter *memory.Filter
+		want   []string
+	}{
+		{"zero limit returns all", &memory.Filter{}, want},
+		{"limit", &memory.Filter{Limit: 3}, want[:3]},
+		{"limit beyond size", &memory.Filter{Limit: 100}, want},
+		{"offset", &memory.Filter{Offset: 5}, want[5:]},
+		{"offset and limit", &memory.Filter{Offset: 2, Limit: 2}, want[2:4]},
+		{"last partial page", &memory.Filter{Offset: 6, Limit: 3}, want[6:]},
+		{"offset at end", &memory.Filter{Offset: 7}, nil},
+		{"offset past end", &memory.Filter{Offset: 50, Limit: 1}, nil},
+		{"offset with type filter", &memory.Filter{Type: memory.TypeSemantic, Offset: 1}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertIDs(t, mustList(t, s, tt.filter), tt.want)
+		})
+	}
+
+	// Walking pages visits every memory exactly once.
+	var walked []string
+	for offset := 0; ; offset += 3 {
+		page := mustList(t, s, &memory.Filter{Offset: offset, Limit: 3})
+		if len(page) == 0 {
+			break
+		}
+		for _, m := range page {
+			walked = append(walked, m.ID)
+		}
+	}
+	if fmt.Sprint(walked) != fmt.Sprint(want) {
+		t.Errorf("page walk: expected %v, got %v", want, walked)
+	}
+
+	for _, f := range []*memory.Filter{{Limit: -1}, {Offset: -1}} {
+		if _, err := s.List(ctx, f); err == nil {
+			t.Errorf("expected error for negative pagination %+v", *f)
+		}
+	}
+}
+
+func testConcurrentWriters(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+	const writers, perWriter = 8, 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				m := &memory.Memory{
+					ID:       fmt.Sprintf("w%d-%d", w, i),
+					Content:  fmt.Sprintf("writer %d item %d", w, i),
+					Metadata: map[string]interface{}{"writer": w},
+				}
+				if err := s.Save(ctx, m); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := s.Get(ctx, m.ID); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := s.List(ctx, &memory.Filter{Limit: 5}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent write: %v", err)
+	}
+
+	if all := mustList(t, s, nil); len(all) != writers*perWriter {
+		t.Errorf("expected %d memories, got %d", writers*perWriter, len(all))
+	}
+	if mine := mustList(t, s, &memory.Filter{Metadata: map[string]interface{}{"writer": 3}}); len(mine) != perWriter {
+		t.Errorf("expected %d memories for writer 3, got %d", perWriter, len(mine))
+	}
+}
+
+func testClear(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+
+	if err := s.Clear(ctx); err != nil {
+		t.Fatalf("Clear empty store: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := s.Save(ctx, &memory.Memory{Content: fmt.Sprintf("m%d", i)}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	if err := s.Clear(ctx); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if all := mustList(t, s, nil); len(all) != 0 {
+		t.Errorf("expected 0 memories after Clear, got %d", len(all))
+	}
+
+	// The store remains usable after Clear.
+	m := &memory.Memory{Content: "after clear"}
+	if err := s.Save(ctx, m); err != nil {
+		t.Fatalf("Save after Clear: %v", err)
+	}
+	if _, err := s.Get(ctx, m.ID); err != nil {
+		t.Errorf("Get after Clear: %v", err)
+	}
+}
+
+func testRetention(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	live := &memory.Memory{ID: "live", Content: "live", Importance: 0.75, ExpiresAt: &future, AccessCount: 3, LastAccessedAt: &past}
+	dead := &memory.Memory{ID: "dead", Content: "dead", ExpiresAt: &past}
+	forever := &memory.Memory{ID: "forever", Content: "forever"}
+	for _, m := range []*memory.Memory{live, dead, forever} {
+		if err := s.Save(ctx, m); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	got, err := s.Get(ctx, "live")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Importance != 0.75 || got.AccessCount != 3 {
+		t.Errorf("expected importance 0.75 and 3 accesses, got %v and %d", got.Importance, got.AccessCount)
+	}
+	if got.ExpiresAt == nil || !sameInstant(*got.ExpiresAt, future) {
+		t.Errorf("expected ExpiresAt %v, got %v", future, got.ExpiresAt)
+	}
+	if got.LastAccessedAt == nil || !sameInstant(*got.LastAccessedAt, past) {
+		t.Errorf("expected LastAccessedAt %v, got %v", past, got.LastAccessedAt)
+	}
+	if got, _ := s.Get(ctx, "forever"); got != nil && got.ExpiresAt != nil {
+		t.Errorf("expected no expiry, got %v", got.ExpiresAt)
+	}
+
+	// Expired memories are hidden from List unless requested.
+	assertIDs(t, mustList(t, s, &memory.Filter{OrderBy: memory.OrderByCreatedAt}), []string{"live", "forever"})
+	all := mustList(t, s, &memory.Filter{IncludeExpired: true})
+	if len(all) != 3 {
+		t.Errorf("expected 3 memories including expired, got %d", len(all))
+	}
+}
+
+func testRecordAccess(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+	m := &memory.Memory{Content: "accessed"}
+	if err := s.Save(ctx, m); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	at := time.Now().Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		if err := memory.RecordAccess(ctx, s, m.ID, at); err != nil {
+			t.Fatalf("RecordAccess: %v", err)
+		}
+	}
+	got, err := s.Get(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.AccessCount != 2 {
+		t.Errorf("expected 2 accesses, got %d", got.AccessCount)
+	}
+	if got.LastAccessedAt == nil || !sameInstant(*got.LastAccessedAt, at) {
+		t.Errorf("expected LastAccessedAt %v, got %v", at, got.LastAccessedAt)
+	}
+	if err := memory.RecordAccess(ctx, s, "missing", at); !errors.Is(err, memory.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testSearchSkipsExpired(t *testing.T, s memory.VectorStore, e memory.Embedder) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+	if err := s.Save(ctx, &memory.Memory{ID: "old", Content: "stale cache entry", ExpiresAt: &past}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := s.Save(ctx, &memory.Memory{ID: "new", Content: "fresh cache entry"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := s.SearchByText(ctx, "stale cache entry", 10)
+	if err != nil {
+		t.Fatalf("SearchByText: %v", err)
+	}
+	assertIDs(t, got, []string{"new"})
+}
+
+func testArchived(t *testing.T, s memory.Store) {
+	ctx := context.Background()
+	if err := s.Save(ctx, &memory.Memory{ID: "kept", Content: "kept"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := s.Save(ctx, &memory.Memory{ID: "archived", Content: "archived", Archived: true}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := s.Get(ctx, "archived")
+	if err != nil {
+		t.Fatalf("Get archived: %v", err)
+	}
+	if !got.Archived {
+		t.Error("expected Archived to round trip")
+	}
+	assertIDs(t, mustList(t, s, nil), []string{"kept"})
+	if all := mustList(t, s, &memory.Filter{IncludeArchived: true}); len(all) != 2 {
+		t.Errorf("expected 2 memories including archived, got %d", len(all))
+	}
+}
+
+func testSearchSkipsArchived(t *testing.T, s memory.VectorStore) {
+	ctx := context.Background()
+	if err := s.Save(ctx, &memory.Memory{ID: "old", Content: "flaky integration test", Archived: true}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := s.Save(ctx, &memory.Memory{ID: "new", Content: "flaky integration test again"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := s.SearchByText(ctx, "flaky integration test", 10)
+	if err != nil {
+		t.Fatalf("SearchByText: %v", err)
+	}
+	assertIDs(t, got, []string{"new"})
+}
+
+func testSearchOrdering(t *testing.T, s memory.VectorStore, e memory.Embedder) {
+	ctx := context.Background()
+
+	contents := map[string]string{
+		"exact":   "database migration failed on startup",
+		"partial": "database connection pool exhausted",
+		"other":   "frontend build uses vite",
+	}
+	for id, c := range contents {
+		if err := s.Save(ctx, &memory.Memory{ID: id, Content: c}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	// Memories without embeddings are ignored by Search.
+	if err := s.Save(ctx, &memory.Memory{ID: "blank", Embedding: nil}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	query, _ := e.Embed(ctx, contents["exact"])
+	got, err := s.Search(ctx, query, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(got) == 0 || got[0].ID != "exact" {
+		t.Fatalf("expected 'exact' first, got %v", ids(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Score > got[i-1].Score {
+			t.Errorf("expected non-increasing scores, got %v then %v", got[i-1].Score, got[i].Score)
+		}
+	}
+	if got[0].Score < 0.99 {
+		t.Errorf("expected near-1 similarity for identical text, got %v", got[0].Score)
+	}
+	pos := map[string]int{}
+	for i, m := range got {
+		pos[m.ID] = i
+	}
+	if pos["partial"] > pos["other"] {
+		t.Errorf("expected 'partial' before 'other', got %v", ids(got))
+	}
+
+	limited, err := s.Search(ctx, query, 2)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(limited) != 2 {
+		t.Errorf("expected limit of 2 results, got %d", len(limited))
+	}
+}
+
+func testSearchByText(t *testing.T, s memory.VectorStore) {
+	ctx := context.Background()
+	for _, c := range []string{"retry flaky network calls", "cache compiled templates"} {
+		if err := s.Save(ctx, &memory.Memory{Content: c}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	got, err := s.SearchByText(ctx, "flaky network", 1)
+	if err != nil {
+		t.Fatalf("SearchByText: %v", err)
+	}
+	if len(got) != 1 || got[0].Content != "retry flaky network calls" {
+		t.Errorf("expected network memory, got %v", ids(got))
+	}
+}
+
+func testSearchEmpty(t *testing.T, s memory.VectorStore, e memory.Embedder) {
+	query, _ := e.Embed(context.Background(), "anything")
+	got, err := s.Search(context.Background(), query, 5)
+	if err != nil {
+		t.Fatalf("Search empty store: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no results, got %d", len(got))
+	}
+}
+
+func mustList(t *testing.T, s memory.Store, f *memory.Filter) []*memory.Memory {
+	t.Helper()
+	got, err := s.List(context.Background(), f)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return got
+}
+
+func assertMemory(t *testing.T, got, want *memory.Memory) {
+	t.Helper()
+	if got.ID != want.ID || got.Type != want.Type || got.Content != want.Content {
+		t.Errorf("expected %s/%s/%q, got %s/%s/%q", want.ID, want.Type, want.Content, got.ID, got.Type, got.Content)
+	}
+	if len(got.Embedding) != len(want.Embedding) {
+		t.Errorf("expected embedding of length %d, got %d", len(want.Embedding), len(got.Embedding))
+	} else {
+		for i := range want.Embedding {
+			if got.Embedding[i] != want.Embedding[i] {
+				t.Errorf("embedding[%d]: expected %v, got %v", i, want.Embedding[i], got.Embedding[i])
+			}
+		}
+	}
+	if len(got.Metadata) != len(want.Metadata) {
+		t.Errorf("expected %d metadata keys, got %d", len(want.Metadata), len(got.Metadata))
+	}
+	for k, v := range want.Metadata {
+		if !memory.MetadataEqual(got.Metadata[k], v) {
+			t.Errorf("metadata %q: expected %v, got %v", k, v, got.Metadata[k])
+		}
+	}
+}
+
+func assertIDs(t *testing.T, got []*memory.Memory, want []string) {
+	t.Helper()
+	if fmt.Sprint(ids(got)) != fmt.Sprint(want) && !(len(got) == 0 && len(want) == 0) {
+		t.Errorf("expected %v, got %v", want, ids(got))
+	}
+}
+
+// sameInstant compares times at microsecond precision, the finest many
+// databases keep.
+func sameInstant(a, b time.Time) bool {
+	return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+}
+
+func ids(ms []*memory.Memory) []string {
+	out := make([]string, 0, len(ms))
+	for _, m := range ms {
+		out = append(out, m.ID)
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// DefaultMinHashPermutations is the default MinHash signature length.
+const DefaultMinHashPermutations = 128
+
+// minHashShingle is the number of consecutive words per shingle.
+const minHashShingle = 3
+
+// MinHashSignature computes a MinHash signature of n values over the word
+// shingles of text. Texts are compared with MinHashSimilarity, which
+// estimates the Jaccard similarity of their shingle sets.
+func MinHashSignature(text string, n int) []uint64 {
+	sig := make([]uint64, n)
+	for i := range sig {
+		sig[i] = math.MaxUint64
+	}
+	for _, sh := range shingles(NormalizeContent(text)) {
+		h := fnv.New64a()
+		h.Write([]byte(sh))
+		x := h.Sum64()
+		for i := range sig {
+			a, b := minHashParams(i)
+			if v := a*x + b; v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// MinHashSimilarity returns the fraction of matching positions in two
+// signatures of equal length.
+func MinHashSimilarity(a, b []uint64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// shingles returns overlapping word n-grams of text. Texts shorter than one
+// shingle yield a single shingle of all their words.
+func shingles(text string) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	if len(words) < minHashShingle {
+		return []string{strings.Join(words, " ")}
+	}
+	out := make([]string, 0, len(words)-minHashShingle+1)
+	for i := 0; i+minHashShingle <= len(words); i++ {
+		out = append(out, strings.Join(words[i:i+minHashShingle], " "))
+	}
+	return out
+}
+
+// minHashParams derives the i-th hash permutation's coefficients with
+// splitmix64, so signatures are stable across processes.
+func minHashParams(i int) (a, b uint64) {
+	next := func(x uint64) uint64 {
+		x += 0x9e3779b97f4a7c15
+		x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+		x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+		return x ^ (x >> 31)
+	}
+	a = next(uint64(2*i)) | 1
+	b = next(uint64(2*i + 1))
+	return a, b
+}
@@ -0,0 +1,394 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetaNamespace is the metadata key recording a memory's namespace. It is
+// reserved so that user metadata under other keys, including "namespace",
+// is stored and filtered as given.
+const MetaNamespace = "_openagent_namespace"
+
+// ErrQuotaExceeded is returned when a save would push a namespace past its
+// quota.
+var ErrQuotaExceeded = errors.New("memory quota exceeded")
+
+// Namespace identifies an isolated partition of a store. Tenant is
+// required; Agent and Project narrow it further and may be empty.
+type Namespace struct {
+	Tenant  string `json:"tenant"`
+	Agent   string `json:"agent,omitempty"`
+	Project string `json:"project,omitempty"`
+}
+
+// String returns the namespace as "tenant/agent/project".
+func (ns Namespace) String() string {
+	return ns.Tenant + "/" + ns.Agent + "/" + ns.Project
+}
+
+// Validate checks that the namespace has a tenant and that no component
+// contains a slash.
+func (ns Namespace) Validate() error {
+	if ns.Tenant == "" {
+		return fmt.Errorf("namespace: tenant is required")
+	}
+	for _, c := range []string{ns.Tenant, ns.Agent, ns.Project} {
+		if strings.Contains(c, "/") {
+			return fmt.Errorf("namespace: component %q contains '/'", c)
+		}
+	}
+	return nil
+}
+
+// ParseNamespace parses a namespace produced by Namespace.String.
+func ParseNamespace(s string) (Namespace, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return Namespace{}, fmt.Errorf("namespace: malformed %q", s)
+	}
+	ns := Namespace{Tenant: parts[0], Agent: parts[1], Project: parts[2]}
+	return ns, ns.Validate()
+}
+
+// NamespaceOf returns the namespace recorded on a memory returned by
+// SearchAcross.
+func NamespaceOf(m *Memory) (Namespace, bool) {
+	s, ok := m.Metadata[MetaNamespace].(string)
+	if !ok {
+		return Namespace{}, false
+	}
+	ns, err := ParseNamespace(s)
+	return ns, err == nil
+}
+
+// Quota limits the size of a namespace. Zero fields are unlimited.
+// Expired and archived memories count until they are deleted.
+type Quota struct {
+	MaxMemories int `json:"max_memories,omitempty"`
+	MaxBytes    int `json:"max_bytes,omitempty"`
+}
+
+// Namespaced partitions a single underlying store into isolated namespaces.
+// Memories are stored under IDs prefixed with their namespace and tagged
+// with MetaNamespace, so the same ID may be used in different namespaces.
+// Access goes through Scope handles; only SearchAcross reads more than one
+// namespace. Isolation holds as long as the underlying store is not also
+// written to directly.
+type Namespaced struct {
+	base Store
+
+	mu     sync.Mutex
+	quotas map[Namespace]Quota
+	// DefaultQuota applies to namespaces without their own quota.
+	DefaultQuota Quota
+}
+
+// NewNamespaced wraps base for namespaced access.
+func NewNamespaced(base Store) *Namespaced {
+	return &Namespaced{base: base, quotas: make(map[Namespace]Quota)}
+}
+
+// SetQuota sets the quota for a namespace, overriding DefaultQuota.
+func (n *Namespaced) SetQuota(ns Namespace, q Quota) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.quotas[ns] = q
+}
+
+// Scope returns a handle whose operations are confined to ns.
+func (n *Namespaced) Scope(ns Namespace) (*Scoped, error) {
+	if err := ns.Validate(); err != nil {
+		return nil, err
+	}
+	return &Scoped{parent: n, ns: ns, prefix: ns.String() + "/"}, nil
+}
+
+// Clear removes every memory in ns, leaving other namespaces untouched.
+func (n *Namespaced) Clear(ctx context.Context, ns Namespace) error {
+	s, err := n.Scope(ns)
+	if err != nil {
+		return err
+	}
+	return s.Clear(ctx)
+}
+
+// SearchAcross searches the listed namespaces together and returns the
+// best matches overall. Results keep their local IDs; use NamespaceOf to
+// tell where each came from. The underlying store must be a VectorStore.
+func (n *Namespaced) SearchAcross(ctx context.Context, text string, limit int, namespaces ...Namespace) ([]*Memory, error) {
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("search across namespaces: no namespaces given")
+	}
+	vs, ok := n.base.(VectorStore)
+	if !ok {
+		return nil, fmt.Errorf("search across namespaces: store does not support vector search")
+	}
+	scopes := make([]*Scoped, len(namespaces))
+	for i, ns := range namespaces {
+		s, err := n.Scope(ns)
+		if err != nil {
+			return nil, err
+		}
+		scopes[i] = s
+	}
+	return searchOwned(limit, func(k int) ([]*Memory, error) {
+		return vs.SearchByText(ctx, text, k)
+	}, func(m *Memory) *Memory {
+		for _, s := range scopes {
+			if s.owns(m) {
+				c := s.local(m)
+				if c.Metadata == nil {
+					c.Metadata = make(map[string]interface{})
+				}
+				c.Metadata[MetaNamespace] = s.ns.String()
+				return c
+			}
+		}
+		return nil
+	})
+}
+
+func (n *Namespaced) quota(ns Namespace) Quota {
+	if q, ok := n.quotas[ns]; ok {
+		return q
+	}
+	return n.DefaultQuota
+}
+
+// Scoped is a Store confined to one namespace. It also implements
+// VectorStore, KeywordSearcher and AccessRecorder, returning an error from
+// search methods the underlying store does not support.
+type Scoped struct {
+	parent *Namespaced
+	ns     Namespace
+	prefix string
+}
+
+// Namespace returns the handle's namespace.
+func (s *Scoped) Namespace() Namespace {
+	return s.ns
+}
+
+// Save stores a memory in the namespace, enforcing its quota. The caller's
+// memory receives the assigned ID, timestamps and embedding.
+func (s *Scoped) Save(ctx context.Context, m *Memory) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	stored := m.clone()
+	stored.ID = s.prefix + m.ID
+	if stored.Metadata == nil {
+		stored.Metadata = make(map[string]interface{})
+	}
+	stored.Metadata[MetaNamespace] = s.ns.String()
+
+	s.parent.mu.Lock()
+	defer s.parent.mu.Unlock()
+	if err := s.checkQuota(ctx, m.ID, len(m.Content)); err != nil {
+		return err
+	}
+	if err := s.parent.base.Save(ctx, stored); err != nil {
+		return err
+	}
+	m.CreatedAt = stored.CreatedAt
+	m.UpdatedAt = stored.UpdatedAt
+	if m.Embedding == nil {
+		m.Embedding = stored.Embedding
+	}
+	return nil
+}
+
+// checkQuota reports ErrQuotaExceeded if saving size bytes under the local
+// ID id would exceed the namespace quota. The caller holds parent.mu.
+func (s *Scoped) checkQuota(ctx context.Context, id string, size int) error {
+	q := s.parent.quota(s.ns)
+	if q.MaxMemories == 0 && q.MaxBytes == 0 {
+		return nil
+	}
+	all, err := s.list(ctx, &Filter{IncludeExpired: true, IncludeArchived: true})
+	if err != nil {
+		return fmt.Errorf("check quota: %w", err)
+	}
+	count := len(all) + 1
+	for _, existing := range all {
+		if existing.ID == id {
+			// Replacing an existing memory.
+			count--
+			continue
+		}
+		size += len(existing.Content)
+	}
+	if q.MaxMemories > 0 && count > q.MaxMemories {
+		return fmt.Errorf("%w: namespace %s holds at most %d memories", ErrQuotaExceeded, s.ns, q.MaxMemories)
+	}
+	if q.MaxBytes > 0 && size > q.MaxBytes {
+		return fmt.Errorf("%w: namespace %s holds at most %d bytes", ErrQuotaExceeded, s.ns, q.MaxBytes)
+	}
+	return nil
+}
+
+// Get retrieves a memory by its ID within the namespace.
+func (s *Scoped) Get(ctx context.Context, id string) (*Memory, error) {
+	m, err := s.parent.base.Get(ctx, s.prefix+id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, err
+	}
+	return s.local(m), nil
+}
+
+// Delete removes a memory from the namespace.
+func (s *Scoped) Delete(ctx context.Context, id string) error {
+	err := s.parent.base.Delete(ctx, s.prefix+id)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return err
+}
+
+// List returns the namespace's memories matching the filter.
+func (s *Scoped) List(ctx context.Context, filter *Filter) ([]*Memory, error) {
+	return s.list(ctx, filter)
+}
+
+// list filters the base store by namespace and returns local copies.
+func (s *Scoped) list(ctx context.Context, filter *Filter) ([]*Memory, error) {
+	f := Filter{}
+	if filter != nil {
+		f = *filter
+	}
+	meta := make(map[string]interface{}, len(f.Metadata)+1)
+	for k, v := range f.Metadata {
+		meta[k] = v
+	}
+	meta[MetaNamespace] = s.ns.String()
+	f.Metadata = meta
+
+	found, err := s.parent.base.List(ctx, &f)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Memory, 0, len(found))
+	for _, m := range found {
+		if s.owns(m) {
+			result = append(result, s.local(m))
+		}
+	}
+	return result, nil
+}
+
+// Clear removes every memory in the namespace.
+func (s *Scoped) Clear(ctx context.Context) error {
+	all, err := s.list(ctx, &Filter{IncludeExpired: true, IncludeArchived: true})
+	if err != nil {
+		return fmt.Errorf("clear namespace %s: %w", s.ns, err)
+	}
+	for _, m := range all {
+		if err := s.Delete(ctx, m.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("clear namespace %s: %w", s.ns, err)
+		}
+	}
+	return nil
+}
+
+// RecordAccess records a retrieval of a memory in the namespace.
+func (s *Scoped) RecordAccess(ctx context.Context, id string, at time.Time) error {
+	return RecordAccess(ctx, s.parent.base, s.prefix+id, at)
+}
+
+// Search finds the namespace's memories most similar to embedding.
+func (s *Scoped) Search(ctx context.Context, embedding []float64, limit int) ([]*Memory, error) {
+	vs, ok := s.parent.base.(VectorStore)
+	if !ok {
+		return nil, fmt.Errorf("search: store does not support vector search")
+	}
+	return s.search(limit, func(k int) ([]*Memory, error) {
+		return vs.Search(ctx, embedding, k)
+	})
+}
+
+// SearchByText finds the namespace's memories most similar to text.
+func (s *Scoped) SearchByText(ctx context.Context, text string, limit int) ([]*Memory, error) {
+	vs, ok := s.parent.base.(VectorStore)
+	if !ok {
+		return nil, fmt.Errorf("search by text: store does not support vector search")
+	}
+	return s.search(limit, func(k int) ([]*Memory, error) {
+		return vs.SearchByText(ctx, text, k)
+	})
+}
+
+// SearchKeyword ranks the namespace's memories against query.
+func (s *Scoped) SearchKeyword(ctx context.Context, query string, limit int) ([]*Memory, error) {
+	ks, ok := s.parent.base.(KeywordSearcher)
+	if !ok {
+		return nil, fmt.Errorf("search keyword: store does not support keyword search")
+	}
+	return s.search(limit, func(k int) ([]*Memory, error) {
+		return ks.SearchKeyword(ctx, query, k)
+	})
+}
+
+func (s *Scoped) search(limit int, search func(k int) ([]*Memory, error)) ([]*Memory, error) {
+	return searchOwned(limit, search, func(m *Memory) *Memory {
+		if !s.owns(m) {
+			return nil
+		}
+		return s.local(m)
+	})
+}
+
+// owns reports whether a stored memory belongs to the namespace.
+func (s *Scoped) owns(m *Memory) bool {
+	return strings.HasPrefix(m.ID, s.prefix) && m.Metadata[MetaNamespace] == s.ns.String()
+}
+
+// local returns a copy of a stored memory as the scoped handle presents
+// it: without the namespace prefix on its ID or the MetaNamespace tag.
+func (s *Scoped) local(m *Memory) *Memory {
+	c := m.clone()
+	c.ID = strings.TrimPrefix(m.ID, s.prefix)
+	delete(c.Metadata, MetaNamespace)
+	if len(c.Metadata) == 0 {
+		c.Metadata = nil
+	}
+	return c
+}
+
+// searchOwned runs search with a growing candidate count until it has limit
+// results that convert returns non-nil for, or the store runs out of
+// candidates. A non-positive limit searches everything once.
+func searchOwned(limit int, search func(k int) ([]*Memory, error), convert func(*Memory) *Memory) ([]*Memory, error) {
+	k := limit * 4
+	if limit <= 0 {
+		k = 0
+	}
+	for {
+		found, err := search(k)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]*Memory, 0, len(found))
+		for _, m := range found {
+			if c := convert(m); c != nil {
+				result = append(result, c)
+				if limit > 0 && len(result) == limit {
+					return result, nil
+				}
+			}
+		}
+		if k <= 0 || len(found) < k {
+			return result, nil
+		}
+		k *= 4
+	}
+}
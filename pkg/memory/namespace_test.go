@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func mustScope(t *testing.T, n *Namespaced, ns Namespace) *Scoped {
+	t.Helper()
+	s, err := n.Scope(ns)
+	if err != nil {
+		t.Fatalf("Scope(%v) failed: %v", ns, err)
+	}
+	return s
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	ctx := context.Background()
+	n := NewNamespaced(NewInMemoryVectorStore(newWordEmbedder()))
+	acme := mustScope(t, n, Namespace{Tenant: "acme", Project: "api"})
+	globex := mustScope(t, n, Namespace{Tenant: "globex", Project: "api"})
+
+	if err := acme.Save(ctx, &Memory{ID: "m1", Content: "go test"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := globex.Save(ctx, &Memory{ID: "m1", Content: "deploy config"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	got, err := acme.Get(ctx, "m1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Content != "go test" {
+		t.Errorf("expected acme's memory, got %q", got.Content)
+	}
+	if _, err := acme.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	results, err := globex.SearchByText(ctx, "go test", 10)
+	if err != nil {
+		t.Fatalf("SearchByText failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Content != "deploy config" {
+		t.Errorf("expected only globex's memory, got %v", results)
+	}
+
+	if err := n.Clear(ctx, acme.Namespace()); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if all, _ := acme.List(ctx, nil); len(all) != 0 {
+		t.Errorf("expected acme to be empty, got %d memories", len(all))
+	}
+	if all, _ := globex.List(ctx, nil); len(all) != 1 {
+		t.Errorf("expected globex to keep its memory, got %d", len(all))
+	}
+}
+
+func TestNamespaceUserMetadata(t *testing.T) {
+	ctx := context.Background()
+	n := NewNamespaced(NewInMemoryStore())
+	s := mustScope(t, n, Namespace{Tenant: "acme"})
+
+	s.Save(ctx, &Memory{ID: "a", Metadata: map[string]interface{}{"namespace": "kube-system", "other": 1}})
+	s.Save(ctx, &Memory{ID: "b", Metadata: map[string]interface{}{"namespace": "default"}})
+
+	got, err := s.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(got.Metadata) != 2 || got.Metadata["namespace"] != "kube-system" || got.Metadata["other"] != 1 {
+		t.Errorf("expected user metadata to be kept, got %v", got.Metadata)
+	}
+	found, err := s.List(ctx, &Filter{Metadata: map[string]interface{}{"namespace": "kube-system"}})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != "a" {
+		t.Errorf("expected only a to match, got %v", found)
+	}
+}
+
+func TestNamespaceValidation(t *testing.T) {
+	n := NewNamespaced(NewInMemoryStore())
+	for _, ns := range []Namespace{{}, {Tenant: "a/b"}, {Tenant: "a", Agent: "x/y"}} {
+		if _, err := n.Scope(ns); err == nil {
+			t.Errorf("expected error for namespace %+v", ns)
+		}
+	}
+
+	ns := Namespace{Tenant: "acme", Project: "api"}
+	parsed, err := ParseNamespace(ns.String())
+	if err != nil || parsed != ns {
+		t.Errorf("expected round trip of %+v, got %+v (%v)", ns, parsed, err)
+	}
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	ctx := context.Background()
+	n := NewNamespaced(NewInMemoryStore())
+	n.DefaultQuota = Quota{MaxMemories: 2}
+	small := Namespace{Tenant: "acme", Agent: "small"}
+	n.SetQuota(small, Quota{MaxBytes: 10})
+
+	s := mustScope(t, n, Namespace{Tenant: "acme"})
+	s.Save(ctx, &Memory{ID: "a", Content: "one"})
+	s.Save(ctx, &Memory{ID: "b", Content: "two"})
+	if err := s.Save(ctx, &Memory{ID: "c", Content: "three"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+	// Replacing an existing memory does not count against the quota.
+	if err := s.Save(ctx, &Memory{ID: "a", Content: "uno"}); err != nil {
+		t.Errorf("expected update within quota, got %v", err)
+	}
+
+	b := mustScope(t, n, small)
+	if err := b.Save(ctx, &Memory{Content: "0123456789"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := b.Save(ctx, &Memory{Content: "x"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected byte quota to be enforced, got %v", err)
+	}
+}
+
+func TestSearchAcross(t *testing.T) {
+	ctx := context.Background()
+	n := NewNamespaced(NewInMemoryVectorStore(newWordEmbedder()))
+	api := Namespace{Tenant: "acme", Project: "api"}
+	web := Namespace{Tenant: "acme", Project: "web"}
+	private := Namespace{Tenant: "globex"}
+
+	mustScope(t, n, api).Save(ctx, &Memory{ID: "m", Content: "go test"})
+	mustScope(t, n, web).Save(ctx, &Memory{ID: "m", Content: "go test deploy"})
+	mustScope(t, n, private).Save(ctx, &Memory{ID: "m", Content: "go test"})
+
+	if _, err := n.SearchAcross(ctx, "go test", 10); err == nil {
+		t.Error("expected error without namespaces")
+	}
+
+	results, err := n.SearchAcross(ctx, "go test", 10, api, web)
+	if err != nil {
+		t.Fatalf("SearchAcross failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if ns, ok := NamespaceOf(results[0]); !ok || ns != api {
+		t.Errorf("expected best match from %v, got %v", api, ns)
+	}
+	for _, m := range results {
+		if ns, _ := NamespaceOf(m); ns == private {
+			t.Error("expected unlisted namespace to be excluded")
+		}
+	}
+}
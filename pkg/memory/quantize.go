@@ -0,0 +1,329 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Quantization selects how embeddings are encoded in memory.
+type Quantization string
+
+const (
+	// QuantizeFloat64 keeps embeddings at full precision (8 bytes/dim).
+	QuantizeFloat64 Quantization = "float64"
+	// QuantizeFloat32 halves storage with negligible recall loss (4 bytes/dim).
+	QuantizeFloat32 Quantization = "float32"
+	// QuantizeInt8 stores one signed byte per dimension with a per-vector
+	// scale (1 byte/dim).
+	QuantizeInt8 Quantization = "int8"
+	// QuantizeBinary keeps only the sign of each dimension (1 bit/dim) and
+	// compares by Hamming distance. Pair it with rescoring.
+	QuantizeBinary Quantization = "binary"
+)
+
+// quantizedVector is an encoded embedding that can be scored against a
+// full-precision query.
+type quantizedVector interface {
+	// similarity approximates the cosine similarity to the query.
+	similarity(q *preparedQuery) float64
+	// decode returns an approximation of the original embedding.
+	decode() []float64
+	// size returns the encoded size in bytes.
+	size() int
+	// dim returns the embedding dimension.
+	dim() int
+}
+
+// preparedQuery holds per-query values shared across comparisons.
+type preparedQuery struct {
+	vec  []float64
+	norm float64
+	sign []uint64
+}
+
+func prepareQuery(q []float64) *preparedQuery {
+	return &preparedQuery{vec: q, norm: l2norm(q), sign: signBits(q)}
+}
+
+// quantize encodes v with method.
+func quantize(method Quantization, v []float64) (quantizedVector, error) {
+	switch method {
+	case QuantizeFloat64, "":
+		return float64Vector(copyEmbedding(v)), nil
+	case QuantizeFloat32:
+		f := make(float32Vector, len(v))
+		for i, x := range v {
+			f[i] = float32(x)
+		}
+		return f, nil
+	case QuantizeInt8:
+		return newInt8Vector(v), nil
+	case QuantizeBinary:
+		return binaryVector{bits: signBits(v), n: len(v)}, nil
+	default:
+		return nil, fmt.Errorf("unknown quantization %q", method)
+	}
+}
+
+// BytesPerVector returns the encoded size of a dim-dimensional embedding.
+func (q Quantization) BytesPerVector(dim int) int {
+	switch q {
+	case QuantizeFloat32:
+		return 4 * dim
+	case QuantizeInt8:
+		return dim + 8 // codes plus the float64 scale
+	case QuantizeBinary:
+		return 8 * ((dim + 63) / 64)
+	default:
+		return 8 * dim
+	}
+}
+
+type float64Vector []float64
+
+func (v float64Vector) similarity(q *preparedQuery) float64 { return CosineSimilarity(q.vec, v) }
+func (v float64Vector) decode() []float64                   { return copyEmbedding(v) }
+func (v float64Vector) size() int                           { return 8 * len(v) }
+func (v float64Vector) dim() int                            { return len(v) }
+
+type float32Vector []float32
+
+func (v float32Vector) similarity(q *preparedQuery) float64 {
+	if len(v) != len(q.vec) || q.norm == 0 {
+		return 0
+	}
+	var dot, norm float64
+	for i, x := range v {
+		f := float64(x)
+		dot += q.vec[i] * f
+		norm += f * f
+	}
+	if norm == 0 {
+		return 0
+	}
+	return dot / (q.norm * math.Sqrt(norm))
+}
+
+func (v float32Vector) decode() []float64 {
+	out := make([]float64, len(v))
+	for i, x := range v {
+		out[i] = float64(x)
+	}
+	return out
+}
+
+func (v float32Vector) size() int { return 4 * len(v) }
+func (v float32Vector) dim() int  { return len(v) }
+
+// int8Vector is symmetric scalar quantization: codes[i] * scale
+// approximates the original value. Queries stay at full precision.
+type int8Vector struct {
+	codes []int8
+	scale float64
+	norm  float64 // norm of the decoded vector
+}
+
+func newInt8Vector(v []float64) int8Vector {
+	var max float64
+	for _, x := range v {
+		if a := math.Abs(x); a > max {
+			max = a
+		}
+	}
+	out := int8Vector{codes: make([]int8, len(v))}
+	if max == 0 {
+		return out
+	}
+	out.scale = max / 127
+	var norm float64
+	for i, x := range v {
+		c := int8(math.Round(x / out.scale))
+		out.codes[i] = c
+		f := float64(c) * out.scale
+		norm += f * f
+	}
+	out.norm = math.Sqrt(norm)
+	return out
+}
+
+func (v int8Vector) similarity(q *preparedQuery) float64 {
+	if len(v.codes) != len(q.vec) || q.norm == 0 || v.norm == 0 {
+		return 0
+	}
+	var dot float64
+	for i, c := range v.codes {
+		dot += q.vec[i] * float64(c)
+	}
+	return dot * v.scale / (q.norm * v.norm)
+}
+
+func (v int8Vector) decode() []float64 {
+	out := make([]float64, len(v.codes))
+	for i, c := range v.codes {
+		out[i] = float64(c) * v.scale
+	}
+	return out
+}
+
+func (v int8Vector) size() int { return len(v.codes) + 8 }
+func (v int8Vector) dim() int  { return len(v.codes) }
+
+// binaryVector keeps one sign bit per dimension.
+type binaryVector struct {
+	bits []uint64
+	n    int
+}
+
+// similarity maps Hamming distance to [-1, 1]; identical signs score 1.
+func (v binaryVector) similarity(q *preparedQuery) float64 {
+	if v.n != len(q.vec) || v.n == 0 {
+		return 0
+	}
+	diff := 0
+	for i, w := range v.bits {
+		diff += bits.OnesCount64(w ^ q.sign[i])
+	}
+	return 1 - 2*float64(diff)/float64(v.n)
+}
+
+func (v binaryVector) decode() []float64 {
+	out := make([]float64, v.n)
+	scale := 1 / math.Sqrt(float64(v.n))
+	for i := range out {
+		if v.bits[i/64]&(1<<(uint(i)%64)) != 0 {
+			out[i] = scale
+		} else {
+			out[i] = -scale
+		}
+	}
+	return out
+}
+
+func (v binaryVector) size() int { return 8 * len(v.bits) }
+func (v binaryVector) dim() int  { return v.n }
+
+func signBits(v []float64) []uint64 {
+	out := make([]uint64, (len(v)+63)/64)
+	for i, x := range v {
+		if x > 0 {
+			out[i/64] |= 1 << (uint(i) % 64)
+		}
+	}
+	return out
+}
+
+func l2norm(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+// QuantizationReport describes the storage and recall of one method on a
+// sample of embeddings.
+type QuantizationReport struct {
+	Method         Quantization `json:"method"`
+	Dimension      int          `json:"dimension"`
+	BytesPerVector int          `json:"bytes_per_vector"`
+	// Compression is full-precision size divided by quantized size.
+	Compression float64 `json:"compression"`
+	// Recall is the fraction of the exact top-k found by quantized search.
+	Recall float64 `json:"recall"`
+	// RescoredRecall is Recall when the top k*oversample quantized
+	// candidates are rescored at full precision.
+	RescoredRecall float64 `json:"rescored_recall"`
+}
+
+// EvaluateQuantization measures recall@k of each method against exact
+// cosine search over vectors, for each query. Oversample sets the rescoring
+// candidate multiplier; zero uses DefaultOversample.
+func EvaluateQuantization(vectors, queries [][]float64, k, oversample int, methods ...Quantization) ([]QuantizationReport, error) {
+	if len(vectors) == 0 || len(queries) == 0 || k <= 0 {
+		return nil, fmt.Errorf("evaluate quantization: need vectors, queries and a positive k")
+	}
+	if oversample <= 0 {
+		oversample = DefaultOversample
+	}
+	if len(methods) == 0 {
+		methods = []Quantization{QuantizeFloat32, QuantizeInt8, QuantizeBinary}
+	}
+	dim := len(vectors[0])
+
+	exact := make([][]int, len(queries))
+	for qi, q := range queries {
+		pq := prepareQuery(q)
+		exact[qi] = topK(len(vectors), k, func(i int) float64 { return float64Vector(vectors[i]).similarity(pq) })
+	}
+
+	reports := make([]QuantizationReport, 0, len(methods))
+	for _, method := range methods {
+		codes := make([]quantizedVector, len(vectors))
+		for i, v := range vectors {
+			c, err := quantize(method, v)
+			if err != nil {
+				return nil, fmt.Errorf("evaluate quantization: %w", err)
+			}
+			codes[i] = c
+		}
+
+		var hits, rescoredHits int
+		for qi, q := range queries {
+			pq := prepareQuery(q)
+			approx := func(i int) float64 { return codes[i].similarity(pq) }
+			hits += overlap(exact[qi], topK(len(vectors), k, approx))
+
+			candidates := topK(len(vectors), k*oversample, approx)
+			sort.SliceStable(candidates, func(a, b int) bool {
+				return CosineSimilarity(q, vectors[candidates[a]]) > CosineSimilarity(q, vectors[candidates[b]])
+			})
+			if len(candidates) > k {
+				candidates = candidates[:k]
+			}
+			rescoredHits += overlap(exact[qi], candidates)
+		}
+
+		total := float64(len(queries) * len(exact[0]))
+		bytes := method.BytesPerVector(dim)
+		reports = append(reports, QuantizationReport{
+			Method:         method,
+			Dimension:      dim,
+			BytesPerVector: bytes,
+			Compression:    float64(QuantizeFloat64.BytesPerVector(dim)) / float64(bytes),
+			Recall:         float64(hits) / total,
+			RescoredRecall: float64(rescoredHits) / total,
+		})
+	}
+	return reports, nil
+}
+
+// topK returns the indexes of the k highest scores, best first.
+func topK(n, k int, score func(int) float64) []int {
+	idx := make([]int, n)
+	scores := make([]float64, n)
+	for i := range idx {
+		idx[i] = i
+		scores[i] = score(i)
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] > scores[idx[b]] })
+	if k < n {
+		idx = idx[:k]
+	}
+	return idx
+}
+
+func overlap(a, b []int) int {
+	set := make(map[int]bool, len(a))
+	for _, i := range a {
+		set[i] = true
+	}
+	n := 0
+	for _, i := range b {
+		if set[i] {
+			n++
+		}
+	}
+	return n
+}
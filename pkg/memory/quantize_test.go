@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomVectors(r *rand.Rand, n, dim int) [][]float64 {
+	out := make([][]float64, n)
+	for i := range out {
+		out[i] = make([]float64, dim)
+		for j := range out[i] {
+			out[i][j] = r.NormFloat64()
+		}
+	}
+	return out
+}
+
+func TestQuantizeDecode(t *testing.T) {
+	v := []float64{0.5, -0.25, 0.125, -1}
+	tests := []struct {
+		method Quantization
+		tol    float64
+	}{
+		{QuantizeFloat32, 1e-7},
+		{QuantizeInt8, 1.0 / 127},
+	}
+	for _, tt := range tests {
+		code, err := quantize(tt.method, v)
+		if err != nil {
+			t.Fatalf("quantize(%s) failed: %v", tt.method, err)
+		}
+		for i, x := range code.decode() {
+			if math.Abs(x-v[i]) > tt.tol {
+				t.Errorf("%s: dim %d decoded to %v, want %v", tt.method, i, x, v[i])
+			}
+		}
+		if sim := code.similarity(prepareQuery(v)); sim < 0.999 {
+			t.Errorf("%s: expected self-similarity near 1, got %v", tt.method, sim)
+		}
+	}
+
+	bin, _ := quantize(QuantizeBinary, v)
+	if sim := bin.similarity(prepareQuery(v)); sim != 1 {
+		t.Errorf("binary: expected self-similarity 1, got %v", sim)
+	}
+	if sim := bin.similarity(prepareQuery([]float64{-0.5, 0.25, -0.125, 1})); sim != -1 {
+		t.Errorf("binary: expected opposite similarity -1, got %v", sim)
+	}
+	if _, err := quantize("int4", v); err == nil {
+		t.Error("expected error for unknown method")
+	}
+}
+
+func TestQuantizedVectorStore(t *testing.T) {
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(1))
+	vectors := randomVectors(r, 200, 64)
+
+	for _, keep := range []bool{false, true} {
+		s, err := NewQuantizedVectorStore(nil, QuantizationConfig{Method: QuantizeBinary, KeepFullPrecision: keep})
+		if err != nil {
+			t.Fatalf("NewQuantizedVectorStore failed: %v", err)
+		}
+		for _, v := range vectors {
+			s.Save(ctx, &Memory{Embedding: v})
+		}
+
+		stats := s.Stats()
+		if stats.Count != 200 || stats.Dimension != 64 || stats.QuantizedBytes != 200*8 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+		if keep && stats.FullBytes != 200*64*8 {
+			t.Errorf("expected full precision bytes, got %+v", stats)
+		}
+
+		results, err := s.Search(ctx, vectors[7], 1)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if keep && math.Abs(results[0].Score-1) > 1e-9 {
+			t.Errorf("expected rescored exact match, got score %v", results[0].Score)
+		}
+		if !keep && len(results[0].Embedding) != 64 {
+			t.Errorf("expected decoded embedding, got %d dims", len(results[0].Embedding))
+		}
+	}
+
+	if _, err := NewQuantizedVectorStore(nil, QuantizationConfig{Method: "int4"}); err == nil {
+		t.Error("expected error for unknown method")
+	}
+}
+
+func TestEvaluateQuantization(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	vectors := randomVectors(r, 500, 128)
+	queries := randomVectors(r, 20, 128)
+
+	reports, err := EvaluateQuantization(vectors, queries, 10, 0)
+	if err != nil {
+		t.Fatalf("EvaluateQuantization failed: %v", err)
+	}
+	byMethod := make(map[Quantization]QuantizationReport)
+	for _, rep := range reports {
+		byMethod[rep.Method] = rep
+	}
+
+	if rep := byMethod[QuantizeFloat32]; rep.Recall < 0.99 || rep.Compression != 2 {
+		t.Errorf("unexpected float32 report %+v", rep)
+	}
+	if rep := byMethod[QuantizeInt8]; rep.Recall < 0.8 || rep.BytesPerVector != 136 {
+		t.Errorf("unexpected int8 report %+v", rep)
+	}
+	bin := byMethod[QuantizeBinary]
+	if bin.Compression != 64 {
+		t.Errorf("expected binary compression 64, got %v", bin.Compression)
+	}
+	if bin.RescoredRecall < bin.Recall {
+		t.Errorf("expected rescoring to help binary recall: %+v", bin)
+	}
+}
@@ -0,0 +1,279 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultOversample is the default rescoring candidate multiplier.
+const DefaultOversample = 4
+
+// QuantizationConfig contains QuantizedVectorStore configuration.
+type QuantizationConfig struct {
+	// Method defaults to QuantizeFloat32.
+	Method Quantization `json:"method,omitempty"`
+	// KeepFullPrecision also keeps the original embeddings, so searches
+	// rescore the best quantized candidates exactly and Get returns the
+	// original embedding. Without it Get returns a decoded approximation.
+	KeepFullPrecision bool `json:"keep_full_precision,omitempty"`
+	// Oversample is how many candidates per requested result are rescored
+	// when full precision is kept. Zero uses DefaultOversample.
+	Oversample int `json:"oversample,omitempty"`
+}
+
+// QuantizationStats reports the embedding storage of a QuantizedVectorStore.
+type QuantizationStats struct {
+	Method    Quantization `json:"method"`
+	Count     int          `json:"count"`
+	Dimension int          `json:"dimension"`
+	// QuantizedBytes is the size of the encoded embeddings.
+	QuantizedBytes int `json:"quantized_bytes"`
+	// FullBytes is the size of retained full-precision embeddings.
+	FullBytes int `json:"full_bytes"`
+	// Float64Bytes is what the embeddings would take unquantized.
+	Float64Bytes int `json:"float64_bytes"`
+}
+
+// QuantizedVectorStore is an in-memory VectorStore that keeps embeddings
+// quantized to cut their memory footprint. Searches score the quantized
+// vectors and, when full precision is kept, rescore the top candidates.
+type QuantizedVectorStore struct {
+	mu       sync.RWMutex
+	memories map[string]*Memory // stored without embeddings
+	codes    map[string]quantizedVector
+	full     map[string][]float64
+	embedder Embedder
+	config   QuantizationConfig
+}
+
+// NewQuantizedVectorStore creates a quantized in-memory vector store. The
+// embedder may be nil, as with NewInMemoryVectorStore.
+func NewQuantizedVectorStore(embedder Embedder, cfg QuantizationConfig) (*QuantizedVectorStore, error) {
+	if cfg.Method == "" {
+		cfg.Method = QuantizeFloat32
+	}
+	if _, err := quantize(cfg.Method, nil); err != nil {
+		return nil, err
+	}
+	if cfg.Oversample <= 0 {
+		cfg.Oversample = DefaultOversample
+	}
+	return &QuantizedVectorStore{
+		memories: make(map[string]*Memory),
+		codes:    make(map[string]quantizedVector),
+		full:     make(map[string][]float64),
+		embedder: embedder,
+		config:   cfg,
+	}, nil
+}
+
+// Save stores a memory, embedding its content first if it has no embedding
+// and an embedder is configured.
+func (s *QuantizedVectorStore) Save(ctx context.Context, m *Memory) error {
+	if m.Embedding == nil && s.embedder != nil && m.Content != "" {
+		emb, err := s.embedder.Embed(ctx, m.Content)
+		if err != nil {
+			return fmt.Errorf("embed memory: %w", err)
+		}
+		m.Embedding = emb
+	}
+
+	var code quantizedVector
+	if m.Embedding != nil {
+		var err error
+		if code, err = quantize(s.config.Method, m.Embedding); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	now := time.Now()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	m.UpdatedAt = now
+
+	stored := m.clone()
+	stored.Embedding = nil
+	s.memories[m.ID] = stored
+	delete(s.codes, m.ID)
+	delete(s.full, m.ID)
+	if code != nil {
+		s.codes[m.ID] = code
+		if s.config.KeepFullPrecision {
+			s.full[m.ID] = copyEmbedding(m.Embedding)
+		}
+	}
+	return nil
+}
+
+// Get retrieves a memory by ID.
+func (s *QuantizedVectorStore) Get(ctx context.Context, id string) (*Memory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.memories[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return s.restore(m), nil
+}
+
+// Delete removes a memory.
+func (s *QuantizedVectorStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.memories[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(s.memories, id)
+	delete(s.codes, id)
+	delete(s.full, id)
+	return nil
+}
+
+// List returns all memories matching the filter.
+func (s *QuantizedVectorStore) List(ctx context.Context, filter *Filter) ([]*Memory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make([]*Memory, 0, len(s.memories))
+	for _, m := range s.memories {
+		all = append(all, m)
+	}
+	matched, err := applyFilter(all, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range matched {
+		matched[i] = s.restore(m)
+	}
+	return matched, nil
+}
+
+// Clear removes all memories.
+func (s *QuantizedVectorStore) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.memories = make(map[string]*Memory)
+	s.codes = make(map[string]quantizedVector)
+	s.full = make(map[string][]float64)
+	return nil
+}
+
+// RecordAccess increments the memory's access count and last access time.
+func (s *QuantizedVectorStore) RecordAccess(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.memories[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	m.AccessCount++
+	m.LastAccessedAt = &at
+	return nil
+}
+
+// Search finds the memories most similar to embedding. Score is the exact
+// cosine similarity when full precision is kept and the quantized
+// approximation otherwise.
+func (s *QuantizedVectorStore) Search(ctx context.Context, embedding []float64, limit int) ([]*Memory, error) {
+	q := prepareQuery(embedding)
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type candidate struct {
+		id    string
+		score float64
+	}
+	candidates := make([]candidate, 0, len(s.codes))
+	for id, code := range s.codes {
+		m := s.memories[id]
+		if m.Expired(now) || m.Archived {
+			continue
+		}
+		candidates = append(candidates, candidate{id, code.similarity(q)})
+	}
+	byScore := func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].id < candidates[j].id
+	}
+	sort.Slice(candidates, byScore)
+
+	if s.config.KeepFullPrecision {
+		if n := limit * s.config.Oversample; limit > 0 && n < len(candidates) {
+			candidates = candidates[:n]
+		}
+		for i := range candidates {
+			candidates[i].score = CosineSimilarity(embedding, s.full[candidates[i].id])
+		}
+		sort.Slice(candidates, byScore)
+	}
+	if limit > 0 && limit < len(candidates) {
+		candidates = candidates[:limit]
+	}
+
+	result := make([]*Memory, len(candidates))
+	for i, c := range candidates {
+		result[i] = s.restore(s.memories[c.id])
+		result[i].Score = c.score
+	}
+	return result, nil
+}
+
+// SearchByText embeds text with the configured Embedder and searches by it.
+func (s *QuantizedVectorStore) SearchByText(ctx context.Context, text string, limit int) ([]*Memory, error) {
+	if s.embedder == nil {
+		return nil, fmt.Errorf("search by text: no embedder configured")
+	}
+	emb, err := s.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	return s.Search(ctx, emb, limit)
+}
+
+// Stats reports the store's embedding storage.
+func (s *QuantizedVectorStore) Stats() QuantizationStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := QuantizationStats{Method: s.config.Method, Count: len(s.codes)}
+	for id, code := range s.codes {
+		stats.QuantizedBytes += code.size()
+		if full, ok := s.full[id]; ok {
+			stats.FullBytes += 8 * len(full)
+		}
+		stats.Float64Bytes += 8 * code.dim()
+		stats.Dimension = code.dim()
+	}
+	return stats
+}
+
+// restore returns a copy of a stored memory with its embedding attached.
+// The caller holds mu.
+func (s *QuantizedVectorStore) restore(m *Memory) *Memory {
+	c := m.clone()
+	if full, ok := s.full[m.ID]; ok {
+		c.Embedding = copyEmbedding(full)
+	} else if code, ok := s.codes[m.ID]; ok {
+		c.Embedding = code.decode()
+	}
+	return c
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// periodic runs a function on a fixed interval in a background goroutine.
+type periodic struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// start begins calling fn every interval until ctx is done or stop is
+// called. Starting a running periodic has no effect.
+func (p *periodic) start(ctx context.Context, interval time.Duration, fn func(ctx context.Context, now time.Time)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return
+	}
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				fn(ctx, now)
+			}
+		}
+	}(p.done)
+}
+
+// stop halts the loop and waits for an in-flight call to finish.
+func (p *periodic) stop() {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,164 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+)
+
+// DefaultChangeRetention is the default number of change feed events kept.
+const DefaultChangeRetention = 10000
+
+// queryer is satisfied by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// getMemory reads one memory, returning sql.ErrNoRows if it does not exist.
+func getMemory(ctx context.Context, q queryer, id string) (*memory.Memory, error) {
+	return scanMemory(q.QueryRowContext(ctx, "SELECT "+selectColumns+" FROM memories WHERE id = ?", id))
+}
+
+// recordChange appends an event to the change feed within tx and prunes
+// events beyond the retention limit.
+func (s *Store) recordChange(ctx context.Context, tx *sql.Tx, typ memory.EventType, id string, before, after *memory.Memory) error {
+	encode := func(m *memory.Memory) (sql.NullString, error) {
+		if m == nil {
+			return sql.NullString{}, nil
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return sql.NullString{}, fmt.Errorf("encode change: %w", err)
+		}
+		return sql.NullString{String: string(data), Valid: true}, nil
+	}
+	b, err := encode(before)
+	if err != nil {
+		return err
+	}
+	a, err := encode(after)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO changes (type, id, before, after, at) VALUES (?, ?, ?, ?, ?)",
+		string(typ), id, b, a, formatTime(time.Now()))
+	if err != nil {
+		return fmt.Errorf("record change: %w", err)
+	}
+	if s.retention < 0 {
+		return nil
+	}
+	seq, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("record change: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM changes WHERE seq <= ?", seq-int64(s.retention)); err != nil {
+		return fmt.Errorf("prune changes: %w", err)
+	}
+	return nil
+}
+
+// Watch subscribes to the store's change feed. Events are retained in the
+// database, so a subscriber can resume from its cursor after a restart as
+// long as the events have not been pruned. Only writes made through this
+// Store wake subscribers; writes from other processes are delivered on the
+// next local write.
+func (s *Store) Watch(ctx context.Context, opts memory.WatchOptions) (*memory.Subscription, error) {
+	return s.feed.Subscribe(ctx, opts)
+}
+
+// Head returns the sequence number of the latest change.
+func (s *Store) Head(ctx context.Context) (uint64, error) {
+	var head int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'changes'), 0)").Scan(&head)
+	if err != nil {
+		return 0, fmt.Errorf("read change head: %w", err)
+	}
+	return uint64(head), nil
+}
+
+// Oldest returns the sequence number of the oldest retained change.
+func (s *Store) Oldest(ctx context.Context) (uint64, error) {
+	var oldest int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MIN(seq), 0) FROM changes").Scan(&oldest); err != nil {
+		return 0, fmt.Errorf("read oldest change: %w", err)
+	}
+	return uint64(oldest), nil
+}
+
+// Events returns up to limit changes after since, oldest first.
+func (s *Store) Events(ctx context.Context, since uint64, limit int) ([]memory.Event, error) {
+	head, err := s.Head(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if since >= head {
+		return nil, nil
+	}
+	oldest, err := s.Oldest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if oldest == 0 || since+1 < oldest {
+		return nil, fmt.Errorf("%w: %d", memory.ErrCursorExpired, since)
+	}
+
+	query := "SELECT seq, type, id, before, after, at FROM changes WHERE seq > ? ORDER BY seq"
+	args := []interface{}{int64(since)}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("read changes: %w", err)
+	}
+	defer rows.Close()
+
+	var events []memory.Event
+	for rows.Next() {
+		var (
+			ev            memory.Event
+			seq           int64
+			typ, at       string
+			before, after sql.NullString
+		)
+		if err := rows.Scan(&seq, &typ, &ev.ID, &before, &after, &at); err != nil {
+			return nil, fmt.Errorf("read changes: %w", err)
+		}
+		ev.Seq = uint64(seq)
+		ev.Type = memory.EventType(typ)
+		if ev.Time, err = time.Parse(timeLayout, at); err != nil {
+			return nil, fmt.Errorf("decode change time: %w", err)
+		}
+		if ev.Before, err = decodeSnapshot(before); err != nil {
+			return nil, err
+		}
+		if ev.After, err = decodeSnapshot(after); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read changes: %w", err)
+	}
+	return events, nil
+}
+
+func decodeSnapshot(s sql.NullString) (*memory.Memory, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	var m memory.Memory
+	if err := json.Unmarshal([]byte(s.String), &m); err != nil {
+		return nil, fmt.Errorf("decode change snapshot: %w", err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations holds the schema history. Entry i upgrades a database from
+// user_version i to i+1; released entries must never be edited, only
+// appended to.
+var migrations = []string{
+	// 1: memories table with external-content FTS5 index on content.
+	`
+CREATE TABLE memories (
+	seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+	id         TEXT NOT NULL UNIQUE,
+	type       TEXT NOT NULL DEFAULT '',
+	content    TEXT NOT NULL DEFAULT '',
+	embedding  BLOB,
+	metadata   TEXT,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE INDEX memories_created_at ON memories (created_at);
+CREATE INDEX memories_type_created_at ON memories (type, created_at);
+
+CREATE VIRTUAL TABLE memories_fts USING fts5(
+	content,
+	content='memories',
+	content_rowid='seq'
+);
+
+CREATE TRIGGER memories_ai AFTER INSERT ON memories BEGIN
+	INSERT INTO memories_fts (rowid, content) VALUES (new.seq, new.content);
+END;
+CREATE TRIGGER memories_ad AFTER DELETE ON memories BEGIN
+	INSERT INTO memories_fts (memories_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+END;
+CREATE TRIGGER memories_au AFTER UPDATE OF content ON memories BEGIN
+	INSERT INTO memories_fts (memories_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+	INSERT INTO memories_fts (rowid, content) VALUES (new.seq, new.content);
+END;
+`,
+	// 2: retention fields for TTL, importance and access tracking.
+	`
+ALTER TABLE memories ADD COLUMN importance REAL NOT NULL DEFAULT 0;
+ALTER TABLE memories ADD COLUMN expires_at TEXT;
+ALTER TABLE memories ADD COLUMN access_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE memories ADD COLUMN last_accessed_at TEXT;
+CREATE INDEX memories_expires_at ON memories (expires_at) WHERE expires_at IS NOT NULL;
+`,
+	// 3: archived flag for consolidated episodes.
+	`
+ALTER TABLE memories ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
+`,
+	// 4: change feed with before/after snapshots as memory JSON.
+	`
+CREATE TABLE changes (
+	seq    INTEGER PRIMARY KEY AUTOINCREMENT,
+	type   TEXT NOT NULL,
+	id     TEXT NOT NULL DEFAULT '',
+	before TEXT,
+	after  TEXT,
+	at     TEXT NOT NULL
+);
+`,
+}
+
+// SchemaVersion returns the schema version this package migrates to.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// migrate applies any pending migrations, each in its own transaction.
+func migrate(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,577 @@
+// Package sqlite implements a memory.VectorStore on a single SQLite file
+// using a pure-Go driver, with FTS5 keyword search over memory content.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+)
+
+// timeLayout is a fixed-width UTC layout so timestamps sort lexically and
+// stay readable from the sqlite3 shell.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Config contains SQLite store configuration.
+type Config struct {
+	// Path is the database file. Use ":memory:" for a private in-memory database.
+	Path string `json:"path"`
+	// Embedder, if set, embeds memories saved without an embedding and
+	// backs SearchByText.
+	Embedder memory.Embedder `json:"-"`
+	// ChangeRetention is the number of change feed events kept for
+	// resuming subscribers. Zero uses DefaultChangeRetention; a negative
+	// value keeps every event.
+	ChangeRetention int `json:"change_retention,omitempty"`
+}
+
+// Store implements memory.VectorStore and memory.Watcher backed by SQLite.
+type Store struct {
+	db        *sql.DB
+	embedder  memory.Embedder
+	retention int
+	feed      *memory.Feed
+}
+
+// NewStore opens or creates the database at cfg.Path and migrates it to the
+// current schema.
+func NewStore(cfg Config) (*Store, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("sqlite store: path is required")
+	}
+	db, err := sql.Open("sqlite", cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	// SQLite serializes writers anyway, and a single connection keeps
+	// ":memory:" databases from splitting across connections.
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	for _, pragma := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA synchronous = FULL",
+		"PRAGMA busy_timeout = 5000",
+	} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("configure database: %w", err)
+		}
+	}
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if cfg.ChangeRetention == 0 {
+		cfg.ChangeRetention = DefaultChangeRetention
+	}
+	s := &Store{db: db, embedder: cfg.Embedder, retention: cfg.ChangeRetention}
+	s.feed = memory.NewFeed(s)
+	return s, nil
+}
+
+// DB returns the underlying database handle.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Close ends change feed subscriptions and closes the database.
+func (s *Store) Close() error {
+	s.feed.Close()
+	return s.db.Close()
+}
+
+// Save stores a memory, replacing any existing memory with the same ID.
+func (s *Store) Save(ctx context.Context, m *memory.Memory) error {
+	if m.Embedding == nil && s.embedder != nil && m.Content != "" {
+		emb, err := s.embedder.Embed(ctx, m.Content)
+		if err != nil {
+			return fmt.Errorf("embed memory: %w", err)
+		}
+		m.Embedding = emb
+	}
+
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	now := time.Now()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	m.UpdatedAt = now
+
+	var metadata sql.NullString
+	if m.Metadata != nil {
+		data, err := json.Marshal(m.Metadata)
+		if err != nil {
+			return fmt.Errorf("encode metadata: %w", err)
+		}
+		metadata = sql.NullString{String: string(data), Valid: true}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("save memory: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getMemory(ctx, tx, m.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("save memory: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO memories (id, type, content, embedding, metadata, created_at, updated_at,
+	importance, expires_at, access_count, last_accessed_at, archived)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	type = excluded.type,
+	content = excluded.content,
+	embedding = excluded.embedding,
+	metadata = excluded.metadata,
+	created_at = excluded.created_at,
+	updated_at = excluded.updated_at,
+	importance = excluded.importance,
+	expires_at = excluded.expires_at,
+	access_count = excluded.access_count,
+	last_accessed_at = excluded.last_accessed_at,
+	archived = excluded.archived`,
+		m.ID, string(m.Type), m.Content, encodeEmbedding(m.Embedding), metadata,
+		formatTime(m.CreatedAt), formatTime(m.UpdatedAt),
+		m.Importance, formatTimePtr(m.ExpiresAt), m.AccessCount, formatTimePtr(m.LastAccessedAt), m.Archived)
+	if err != nil {
+		return fmt.Errorf("save memory: %w", err)
+	}
+
+	typ := memory.EventSave
+	if before != nil {
+		typ = memory.EventUpdate
+	}
+	if err := s.recordChange(ctx, tx, typ, m.ID, before, m); err != nil {
+		return fmt.Errorf("save memory: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("save memory: %w", err)
+	}
+	s.feed.Notify()
+	return nil
+}
+
+const selectColumns = "id, type, content, embedding, metadata, created_at, updated_at, " +
+	"importance, expires_at, access_count, last_accessed_at, archived"
+
+// notExpired matches rows without an expiry or expiring after the bound time.
+const notExpired = "(expires_at IS NULL OR expires_at > ?)"
+
+// notArchived matches rows that have not been archived.
+const notArchived = "archived = 0"
+
+// Get retrieves a memory by ID.
+func (s *Store) Get(ctx context.Context, id string) (*memory.Memory, error) {
+	m, err := getMemory(ctx, s.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", memory.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get memory: %w", err)
+	}
+	return m, nil
+}
+
+// RecordAccess increments the memory's access count and last access time.
+func (s *Store) RecordAccess(ctx context.Context, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE memories SET access_count = access_count + 1, last_accessed_at = ? WHERE id = ?",
+		formatTime(at), id)
+	if err != nil {
+		return fmt.Errorf("record access: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("record access: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", memory.ErrNotFound, id)
+	}
+	return nil
+}
+
+// Delete removes a memory.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete memory: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getMemory(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", memory.ErrNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("delete memory: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM memories WHERE id = ?", id); err != nil {
+		return fmt.Errorf("delete memory: %w", err)
+	}
+	if err := s.recordChange(ctx, tx, memory.EventDelete, id, before, nil); err != nil {
+		return fmt.Errorf("delete memory: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete memory: %w", err)
+	}
+	s.feed.Notify()
+	return nil
+}
+
+// List returns all memories matching the filter.
+func (s *Store) List(ctx context.Context, filter *memory.Filter) ([]*memory.Memory, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	where, args, err := buildWhere(filter, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + selectColumns + " FROM memories" + where + buildOrder(filter)
+	if filter != nil && (filter.Limit > 0 || filter.Offset > 0) {
+		limit := -1
+		if filter.Limit > 0 {
+			limit = filter.Limit
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, filter.Offset)
+	}
+	return s.query(ctx, query, args...)
+}
+
+// Clear removes all memories.
+func (s *Store) Clear(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("clear memories: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM memories"); err != nil {
+		return fmt.Errorf("clear memories: %w", err)
+	}
+	if err := s.recordChange(ctx, tx, memory.EventClear, "", nil, nil); err != nil {
+		return fmt.Errorf("clear memories: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("clear memories: %w", err)
+	}
+	s.feed.Notify()
+	return nil
+}
+
+// Search finds the memories whose embeddings are most similar to embedding.
+// Score is set to the cosine similarity.
+func (s *Store) Search(ctx context.Context, embedding []float64, limit int) ([]*memory.Memory, error) {
+	all, err := s.query(ctx, "SELECT "+selectColumns+" FROM memories WHERE embedding IS NOT NULL AND "+notArchived+" AND "+notExpired,
+		formatTime(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range all {
+		m.Score = memory.CosineSimilarity(embedding, m.Embedding)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Score > all[j].Score
+	})
+	if limit > 0 && limit < len(all) {
+		all = all[:limit]
+	}
+	return all, nil
+}
+
+// SearchByText embeds text with the configured Embedder and searches by it.
+func (s *Store) SearchByText(ctx context.Context, text string, limit int) ([]*memory.Memory, error) {
+	if s.embedder == nil {
+		return nil, fmt.Errorf("search by text: no embedder configured")
+	}
+	emb, err := s.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	return s.Search(ctx, emb, limit)
+}
+
+// SearchKeyword runs a full-text query over memory content, best match
+// first. Each whitespace-separated term is matched literally and any term
+// may match. Score is set to the negated FTS5 bm25 rank, so higher is better.
+func (s *Store) SearchKeyword(ctx context.Context, query string, limit int) ([]*memory.Memory, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return []*memory.Memory{}, nil
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, `
+SELECT m.id, m.type, m.content, m.embedding, m.metadata, m.created_at, m.updated_at,
+	m.importance, m.expires_at, m.access_count, m.last_accessed_at, m.archived, -bm25(memories_fts)
+FROM memories_fts JOIN memories m ON m.seq = memories_fts.rowid
+WHERE memories_fts MATCH ? AND m.archived = 0 AND (m.expires_at IS NULL OR m.expires_at > ?)
+ORDER BY bm25(memories_fts)
+LIMIT ?`, match, formatTime(time.Now()), limit)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search: %w", err)
+	}
+	defer rows.Close()
+
+	result := []*memory.Memory{}
+	for rows.Next() {
+		var score float64
+		m, err := scanMemory(rows, &score)
+		if err != nil {
+			return nil, fmt.Errorf("keyword search: %w", err)
+		}
+		m.Score = score
+		result = append(result, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("keyword search: %w", err)
+	}
+	return result, nil
+}
+
+// ftsQuery quotes each term so user input cannot inject FTS5 syntax.
+func ftsQuery(text string) string {
+	terms := strings.Fields(text)
+	for i, t := range terms {
+		terms[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+	return strings.Join(terms, " OR ")
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) ([]*memory.Memory, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list memories: %w", err)
+	}
+	defer rows.Close()
+
+	result := []*memory.Memory{}
+	for rows.Next() {
+		m, err := scanMemory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list memories: %w", err)
+		}
+		result = append(result, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list memories: %w", err)
+	}
+	return result, nil
+}
+
+// buildWhere translates a filter into a WHERE clause evaluated at now.
+// Metadata values are compared by JSON type as well as value, matching
+// memory.MetadataEqual.
+func buildWhere(filter *memory.Filter, now time.Time) (string, []interface{}, error) {
+	var conds []string
+	var args []interface{}
+
+	if filter == nil || !filter.IncludeExpired {
+		conds = append(conds, notExpired)
+		args = append(args, formatTime(now))
+	}
+	if filter == nil || !filter.IncludeArchived {
+		conds = append(conds, notArchived)
+	}
+	if filter == nil {
+		return " WHERE " + strings.Join(conds, " AND "), args, nil
+	}
+
+	if filter.Type != "" {
+		conds = append(conds, "type = ?")
+		args = append(args, string(filter.Type))
+	}
+	if filter.Since != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, formatTime(*filter.Since))
+	}
+	if filter.Until != nil {
+		conds = append(conds, "created_at <= ?")
+		args = append(args, formatTime(*filter.Until))
+	}
+
+	keys := make([]string, 0, len(filter.Metadata))
+	for k := range filter.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cond, condArgs, err := metadataCondition(k, filter.Metadata[k])
+		if err != nil {
+			return "", nil, err
+		}
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+func metadataCondition(key string, value interface{}) (string, []interface{}, error) {
+	path := `$."` + strings.ReplaceAll(key, `"`, `\"`) + `"`
+
+	switch v := value.(type) {
+	case nil:
+		return "json_type(metadata, ?) = 'null'", []interface{}{path}, nil
+	case bool:
+		want := "false"
+		if v {
+			want = "true"
+		}
+		return "json_type(metadata, ?) = ?", []interface{}{path, want}, nil
+	case string:
+		return "(json_type(metadata, ?) = 'text' AND json_extract(metadata, ?) = ?)",
+			[]interface{}{path, path, v}, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		f, _ := toFloat(v)
+		return "(json_type(metadata, ?) IN ('integer', 'real') AND json_extract(metadata, ?) = ?)",
+			[]interface{}{path, path, f}, nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", nil, fmt.Errorf("encode metadata filter %q: %w", key, err)
+		}
+		return "(json_type(metadata, ?) IN ('object', 'array') AND json(json_extract(metadata, ?)) = json(?))",
+			[]interface{}{path, path, string(data)}, nil
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, false
+	}
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+func buildOrder(filter *memory.Filter) string {
+	column, dir := memory.OrderByCreatedAt, "DESC"
+	if filter != nil && filter.OrderBy != "" {
+		column, dir = filter.OrderBy, "ASC"
+		if filter.OrderDesc {
+			dir = "DESC"
+		}
+	}
+	// column is one of the constants accepted by Filter.Validate.
+	return fmt.Sprintf(" ORDER BY %s %s, id ASC", column, dir)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMemory(row scanner, extra ...interface{}) (*memory.Memory, error) {
+	var (
+		m                    memory.Memory
+		typ                  string
+		embedding            []byte
+		metadata             sql.NullString
+		createdAt, updatedAt string
+		expiresAt, accessed  sql.NullString
+	)
+	dest := append([]interface{}{&m.ID, &typ, &m.Content, &embedding, &metadata, &createdAt, &updatedAt,
+		&m.Importance, &expiresAt, &m.AccessCount, &accessed, &m.Archived}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	m.Type = memory.MemoryType(typ)
+
+	var err error
+	if m.Embedding, err = decodeEmbedding(embedding); err != nil {
+		return nil, err
+	}
+	if metadata.Valid {
+		if err := json.Unmarshal([]byte(metadata.String), &m.Metadata); err != nil {
+			return nil, fmt.Errorf("decode metadata: %w", err)
+		}
+	}
+	if m.CreatedAt, err = time.Parse(timeLayout, createdAt); err != nil {
+		return nil, fmt.Errorf("decode created_at: %w", err)
+	}
+	if m.UpdatedAt, err = time.Parse(timeLayout, updatedAt); err != nil {
+		return nil, fmt.Errorf("decode updated_at: %w", err)
+	}
+	if m.ExpiresAt, err = parseTimePtr(expiresAt); err != nil {
+		return nil, fmt.Errorf("decode expires_at: %w", err)
+	}
+	if m.LastAccessedAt, err = parseTimePtr(accessed); err != nil {
+		return nil, fmt.Errorf("decode last_accessed_at: %w", err)
+	}
+	return &m, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func formatTimePtr(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(*t), Valid: true}
+}
+
+func parseTimePtr(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(timeLayout, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// encodeEmbedding packs a vector as little-endian float64s.
+func encodeEmbedding(v []float64) []byte {
+	if v == nil {
+		return nil
+	}
+	buf := make([]byte, 8*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint64(buf[8*i:], math.Float64bits(f))
+	}
+	return buf
+}
+
+func decodeEmbedding(buf []byte) ([]float64, error) {
+	if buf == nil {
+		return nil, nil
+	}
+	if len(buf)%8 != 0 {
+		return nil, fmt.Errorf("decode embedding: invalid length %d", len(buf))
+	}
+	v := make([]float64, len(buf)/8)
+	for i := range v {
+		v[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf[8*i:]))
+	}
+	return v, nil
+}
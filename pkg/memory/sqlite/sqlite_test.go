@@ -0,0 +1,311 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/memory/memorytest"
+)
+
+func newTestStore(t *testing.T, cfg Config) *Store {
+	t.Helper()
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(t.TempDir(), "memory.db")
+	}
+	s, err := NewStore(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStoreCRUD(t *testing.T) {
+	s := newTestStore(t, Config{})
+	ctx := context.Background()
+
+	m := &memory.Memory{
+		Type:      memory.TypeSemantic,
+		Content:   "Go uses gofmt",
+		Embedding: []float64{0.5, -1.25},
+		Metadata:  map[string]interface{}{"lang": "go", "stars": 3},
+	}
+	if err := s.Save(ctx, m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := s.Get(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Content != m.Content || got.Type != m.Type {
+		t.Errorf("expected %q/%s, got %q/%s", m.Content, m.Type, got.Content, got.Type)
+	}
+	if len(got.Embedding) != 2 || got.Embedding[1] != -1.25 {
+		t.Errorf("expected embedding to round trip, got %v", got.Embedding)
+	}
+	if got.Metadata["lang"] != "go" {
+		t.Errorf("expected metadata lang 'go', got %v", got.Metadata["lang"])
+	}
+	if !got.CreatedAt.Equal(m.CreatedAt) {
+		t.Errorf("expected created_at %v, got %v", m.CreatedAt, got.CreatedAt)
+	}
+
+	m.Content = "Go uses gofmt and go vet"
+	s.Save(ctx, m)
+	got, _ = s.Get(ctx, m.ID)
+	if got.Content != m.Content {
+		t.Errorf("expected updated content, got %q", got.Content)
+	}
+
+	if err := s.Delete(ctx, m.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Get(ctx, m.ID); err == nil {
+		t.Error("expected error for deleted memory")
+	}
+	if err := s.Delete(ctx, m.ID); err == nil {
+		t.Error("expected error deleting missing memory")
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	s := newTestStore(t, Config{})
+	ctx := context.Background()
+
+	now := time.Now()
+	s.Save(ctx, &memory.Memory{ID: "a", Content: "m1", Type: memory.TypeEpisodic, CreatedAt: now.Add(-2 * time.Hour),
+		Metadata: map[string]interface{}{"repo": "api", "ok": true, "n": 1}})
+	s.Save(ctx, &memory.Memory{ID: "b", Content: "m2", Type: memory.TypeSemantic, CreatedAt: now.Add(-1 * time.Hour),
+		Metadata: map[string]interface{}{"repo": "web", "ok": false, "n": "1"}})
+	s.Save(ctx, &memory.Memory{ID: "c", Content: "m3", Type: memory.TypeEpisodic, CreatedAt: now,
+		Metadata: map[string]interface{}{"repo": "api", "tags": []string{"x"}}})
+
+	tests := []struct {
+		name   string
+		filter *memory.Filter
+		want   []string
+	}{
+		{"nil filter newest first", nil, []string{"c", "b", "a"}},
+		{"type", &memory.Filter{Type: memory.TypeEpisodic}, []string{"c", "a"}},
+		{"since", &memory.Filter{Since: timePtr(now.Add(-90 * time.Minute))}, []string{"c", "b"}},
+		{"until", &memory.Filter{Until: timePtr(now.Add(-90 * time.Minute))}, []string{"a"}},
+		{"metadata string", &memory.Filter{Metadata: map[string]interface{}{"repo": "api"}}, []string{"c", "a"}},
+		{"metadata bool", &memory.Filter{Metadata: map[string]interface{}{"ok": false}}, []string{"b"}},
+		{"metadata number is not string", &memory.Filter{Metadata: map[string]interface{}{"n": 1.0}}, []string{"a"}},
+		{"metadata array", &memory.Filter{Metadata: map[string]interface{}{"tags": []interface{}{"x"}}}, []string{"c"}},
+		{"order asc", &memory.Filter{OrderBy: memory.OrderByCreatedAt}, []string{"a", "b", "c"}},
+		{"limit offset", &memory.Filter{Limit: 1, Offset: 1}, []string{"b"}},
+		{"offset only", &memory.Filter{Offset: 2}, []string{"a"}},
+		{"offset past end", &memory.Filter{Offset: 5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ids := memoryIDs(got); strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, ids)
+			}
+		})
+	}
+
+	if _, err := s.List(ctx, &memory.Filter{OrderBy: "content; DROP TABLE memories"}); err == nil {
+		t.Error("expected error for unsupported order_by")
+	}
+}
+
+func TestStoreSearch(t *testing.T) {
+	s := newTestStore(t, Config{Embedder: memorytest.NewHashEmbedder(64)})
+	ctx := context.Background()
+
+	s.Save(ctx, &memory.Memory{ID: "go", Content: "go test go"})
+	s.Save(ctx, &memory.Memory{ID: "rust", Content: "rust test"})
+	s.Save(ctx, &memory.Memory{ID: "deploy", Content: "deploy deploy"})
+
+	got, err := s.SearchByText(ctx, "go", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != "go" {
+		t.Fatalf("expected 'go' first of 2 results, got %v", memoryIDs(got))
+	}
+	if got[0].Score <= got[1].Score {
+		t.Errorf("expected descending scores, got %v then %v", got[0].Score, got[1].Score)
+	}
+
+	noEmbedder := newTestStore(t, Config{})
+	if _, err := noEmbedder.SearchByText(ctx, "go", 1); err == nil {
+		t.Error("expected error without embedder")
+	}
+}
+
+func TestStoreSearchKeyword(t *testing.T) {
+	s := newTestStore(t, Config{})
+	ctx := context.Background()
+
+	s.Save(ctx, &memory.Memory{ID: "a", Content: "ParseConfig failed with ENOENT"})
+	s.Save(ctx, &memory.Memory{ID: "b", Content: "retry the deploy"})
+	s.Save(ctx, &memory.Memory{ID: "c", Content: "ENOENT again, ENOENT everywhere"})
+
+	got, err := s.SearchKeyword(ctx, "ENOENT", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := memoryIDs(got); strings.Join(ids, ",") != "c,a" {
+		t.Errorf("expected [c a], got %v", ids)
+	}
+
+	// FTS syntax in user input is treated literally.
+	if _, err := s.SearchKeyword(ctx, `"unbalanced AND (`, 10); err != nil {
+		t.Errorf("unexpected error for special characters: %v", err)
+	}
+
+	// The index follows updates and deletes.
+	s.Save(ctx, &memory.Memory{ID: "b", Content: "ENOENT during deploy"})
+	s.Delete(ctx, "a")
+	got, _ = s.SearchKeyword(ctx, "ENOENT", 10)
+	if len(got) != 2 {
+		t.Errorf("expected 2 results after update and delete, got %v", memoryIDs(got))
+	}
+	s.Clear(ctx)
+	got, _ = s.SearchKeyword(ctx, "ENOENT", 10)
+	if len(got) != 0 {
+		t.Errorf("expected no results after clear, got %v", memoryIDs(got))
+	}
+}
+
+func TestStoreMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	s := newTestStore(t, Config{Path: path})
+	ctx := context.Background()
+	s.Save(ctx, &memory.Memory{ID: "persisted", Content: "still here"})
+	s.Close()
+
+	// Reopening must not re-run migrations or lose data.
+	s = newTestStore(t, Config{Path: path})
+	var version int
+	s.DB().QueryRow("PRAGMA user_version").Scan(&version)
+	if version != SchemaVersion() {
+		t.Errorf("expected schema version %d, got %d", SchemaVersion(), version)
+	}
+	if _, err := s.Get(ctx, "persisted"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s.DB().Exec("PRAGMA user_version = 999")
+	s.Close()
+	if _, err := NewStore(Config{Path: path}); err == nil {
+		t.Error("expected error for newer schema version")
+	}
+}
+
+func memoryIDs(memories []*memory.Memory) []string {
+	var ids []string
+	for _, m := range memories {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestStoreConformance(t *testing.T) {
+	memorytest.TestVectorStore(t, func(t *testing.T, e memory.Embedder) memory.VectorStore {
+		return newTestStore(t, Config{Embedder: e})
+	})
+}
+
+func TestStoreUpgradeFromV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	db.Exec(migrations[0])
+	db.Exec("PRAGMA user_version = 1")
+	db.Exec(`INSERT INTO memories (id, content, created_at, updated_at) VALUES ('v1', 'from v1', ?, ?)`,
+		formatTime(time.Now()), formatTime(time.Now()))
+	db.Close()
+
+	s := newTestStore(t, Config{Path: path})
+	got, err := s.Get(context.Background(), "v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Content != "from v1" || got.ExpiresAt != nil || got.AccessCount != 0 {
+		t.Errorf("unexpected upgraded memory: %+v", got)
+	}
+}
+
+func TestStoreWatch(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "memory.db")
+
+	s, err := NewStore(Config{Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sub, err := s.Watch(ctx, memory.WatchOptions{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	s.Save(ctx, &memory.Memory{ID: "a", Content: "first"})
+	s.Save(ctx, &memory.Memory{ID: "a", Content: "second"})
+
+	var ev memory.Event
+	for i := 0; i < 2; i++ {
+		select {
+		case ev = <-sub.Events():
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+	if ev.Type != memory.EventUpdate || ev.Before.Content != "first" || ev.After.Content != "second" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	cursor := sub.Cursor()
+	sub.Close()
+
+	s.Delete(ctx, "a")
+	s.Close()
+
+	// The cursor survives a restart.
+	s = newTestStore(t, Config{Path: path})
+	resumed, err := s.Watch(ctx, memory.WatchOptions{Since: cursor})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer resumed.Close()
+	select {
+	case ev = <-resumed.Events():
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	if ev.Type != memory.EventDelete || ev.ID != "a" || ev.Before.Content != "second" {
+		t.Errorf("unexpected resumed event %+v", ev)
+	}
+}
+
+func TestStoreChangeRetention(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t, Config{ChangeRetention: 2})
+	for i := 0; i < 5; i++ {
+		s.Save(ctx, &memory.Memory{})
+	}
+	if oldest, _ := s.Oldest(ctx); oldest != 4 {
+		t.Errorf("expected oldest retained change 4, got %d", oldest)
+	}
+	if _, err := s.Watch(ctx, memory.WatchOptions{Since: 1}); !errors.Is(err, memory.ErrCursorExpired) {
+		t.Errorf("expected ErrCursorExpired, got %v", err)
+	}
+}
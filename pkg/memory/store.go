@@ -3,7 +3,6 @@ package memory
 import (
 	"context"
 	"fmt"
-	"sort"
 	"sync"
 	"time"
 
@@ -48,7 +47,7 @@ func (s *InMemoryStore) Get(ctx context.Context, id string) (*Memory, error) {
 
 	m, ok := s.memories[id]
 	if !ok {
-		return nil, fmt.Errorf("memory not found: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
 	}
 	return m, nil
 }
@@ -59,7 +58,7 @@ func (s *InMemoryStore) Delete(ctx context.Context, id string) error {
 	defer s.mu.Unlock()
 
 	if _, ok := s.memories[id]; !ok {
-		return fmt.Errorf("memory not found: %s", id)
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
 	}
 	delete(s.memories, id)
 	return nil
@@ -70,40 +69,11 @@ func (s *InMemoryStore) List(ctx context.Context, filter *Filter) ([]*Memory, er
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var result []*Memory
+	all := make([]*Memory, 0, len(s.memories))
 	for _, m := range s.memories {
-		if filter != nil {
-			if filter.Type != "" && m.Type != filter.Type {
-				continue
-			}
-			if filter.Since != nil && m.CreatedAt.Before(*filter.Since) {
-				continue
-			}
-			if filter.Until != nil && m.CreatedAt.After(*filter.Until) {
-				continue
-			}
-		}
-		result = append(result, m)
+		all = append(all, m)
 	}
-
-	// Sort by created time (newest first by default)
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].CreatedAt.After(result[j].CreatedAt)
-	})
-
-	// Apply offset and limit
-	if filter != nil {
-		if filter.Offset > 0 && filter.Offset < len(result) {
-			result = result[filter.Offset:]
-		} else if filter.Offset >= len(result) {
-			return []*Memory{}, nil
-		}
-		if filter.Limit > 0 && filter.Limit < len(result) {
-			result = result[:filter.Limit]
-		}
-	}
-
-	return result, nil
+	return applyFilter(all, filter)
 }
 
 // Clear removes all memories.
@@ -113,3 +83,17 @@ func (s *InMemoryStore) Clear(ctx context.Context) error {
 	s.memories = make(map[string]*Memory)
 	return nil
 }
+
+// RecordAccess increments the memory's access count and last access time.
+func (s *InMemoryStore) RecordAccess(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.memories[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	m.AccessCount++
+	m.LastAccessedAt = &at
+	return nil
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits text into lowercase search terms with awareness of code
+// identifiers. Words are split on anything other than letters, digits and
+// underscores; identifiers are further split on snake_case and camelCase
+// boundaries, and the whole identifier is kept as a term as well so exact
+// matches on names like ParseConfig or ERR_TIMEOUT rank highest.
+//
+//	Tokenize("parseHTTPRequest failed") // [parsehttprequest parse http request failed]
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	var tokens []string
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) > 1 {
+			tokens = append(tokens, strings.ToLower(word))
+		}
+		tokens = append(tokens, parts...)
+	}
+	return tokens
+}
+
+// splitIdentifier splits a word on underscores and case changes and
+// lowercases the parts. Runs of capitals are kept together except for the
+// last one when it starts a new word, so "HTTPServer" yields http, server.
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, chunk := range strings.Split(word, "_") {
+		runes := []rune(chunk)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			boundary := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+				unicode.IsDigit(prev) && unicode.IsUpper(cur) ||
+				unicode.IsUpper(prev) && unicode.IsUpper(cur) &&
+					i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if boundary {
+				parts = append(parts, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, strings.ToLower(string(runes[start:])))
+		}
+	}
+	return parts
+}
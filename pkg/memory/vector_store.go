@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// InMemoryVectorStore implements VectorStore and KeywordSearcher on top of
+// InMemoryStore using brute-force cosine similarity and a BM25 index.
+type InMemoryVectorStore struct {
+	*InMemoryStore
+	embedder Embedder
+	keywords *BM25Index
+}
+
+// NewInMemoryVectorStore creates a new in-memory vector store. The embedder
+// may be nil, in which case memories must be saved with embeddings and
+// SearchByText is unavailable.
+func NewInMemoryVectorStore(embedder Embedder) *InMemoryVectorStore {
+	return &InMemoryVectorStore{
+		InMemoryStore: NewInMemoryStore(),
+		embedder:      embedder,
+		keywords:      NewBM25Index(),
+	}
+}
+
+// Save stores a memory, embedding its content first if it has no embedding
+// and an embedder is configured.
+func (s *InMemoryVectorStore) Save(ctx context.Context, m *Memory) error {
+	if m.Embedding == nil && s.embedder != nil && m.Content != "" {
+		emb, err := s.embedder.Embed(ctx, m.Content)
+		if err != nil {
+			return fmt.Errorf("embed memory: %w", err)
+		}
+		m.Embedding = emb
+	}
+	if err := s.InMemoryStore.Save(ctx, m); err != nil {
+		return err
+	}
+	s.keywords.Add(m)
+	return nil
+}
+
+// Delete removes a memory.
+func (s *InMemoryVectorStore) Delete(ctx context.Context, id string) error {
+	if err := s.InMemoryStore.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.keywords.Remove(id)
+	return nil
+}
+
+// Clear removes all memories.
+func (s *InMemoryVectorStore) Clear(ctx context.Context) error {
+	if err := s.InMemoryStore.Clear(ctx); err != nil {
+		return err
+	}
+	s.keywords.Clear()
+	return nil
+}
+
+// Search finds the memories whose embeddings are most similar to embedding.
+// Results are copies with Score set to the cosine similarity.
+func (s *InMemoryVectorStore) Search(ctx context.Context, embedding []float64, limit int) ([]*Memory, error) {
+	now := time.Now()
+	s.mu.RLock()
+	result := make([]*Memory, 0, len(s.memories))
+	for _, m := range s.memories {
+		if m.Embedding == nil || m.Expired(now) || m.Archived {
+			continue
+		}
+		c := m.clone()
+		c.Score = CosineSimilarity(embedding, m.Embedding)
+		result = append(result, c)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].ID < result[j].ID
+	})
+	if limit > 0 && limit < len(result) {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// SearchByText embeds text with the configured Embedder and searches by it.
+func (s *InMemoryVectorStore) SearchByText(ctx context.Context, text string, limit int) ([]*Memory, error) {
+	if s.embedder == nil {
+		return nil, fmt.Errorf("search by text: no embedder configured")
+	}
+	emb, err := s.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	return s.Search(ctx, emb, limit)
+}
+
+// SearchKeyword ranks memories against query with BM25. Results are copies
+// with Score set to the BM25 score.
+func (s *InMemoryVectorStore) SearchKeyword(ctx context.Context, query string, limit int) ([]*Memory, error) {
+	hits := s.keywords.Search(query, 0)
+
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]*Memory, 0, len(hits))
+	for _, h := range hits {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		m, ok := s.memories[h.ID]
+		if !ok || m.Expired(now) || m.Archived {
+			continue
+		}
+		c := m.clone()
+		c.Score = h.Score
+		result = append(result, c)
+	}
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// wordEmbedder embeds text as counts of a fixed vocabulary.
+type wordEmbedder struct {
+	vocab []string
+	calls int
+}
+
+func (e *wordEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	e.calls++
+	v := make([]float64, len(e.vocab))
+	for _, w := range Tokenize(text) {
+		for i, word := range e.vocab {
+			if w == word {
+				v[i]++
+			}
+		}
+	}
+	return v, nil
+}
+
+func (e *wordEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	out := make([][]float64, len(texts))
+	for i, t := range texts {
+		out[i], _ = e.Embed(ctx, t)
+	}
+	return out, nil
+}
+
+func (e *wordEmbedder) Dimension() int { return len(e.vocab) }
+
+func newWordEmbedder() *wordEmbedder {
+	return &wordEmbedder{vocab: strings.Fields("go rust python test deploy config error database")}
+}
+
+func TestInMemoryVectorStore(t *testing.T) {
+	testStoreBasics(t, NewInMemoryVectorStore(nil))
+	testStoreFiltering(t, NewInMemoryVectorStore(nil))
+}
+
+func TestInMemoryVectorStoreSearch(t *testing.T) {
+	store := NewInMemoryVectorStore(newWordEmbedder())
+	ctx := context.Background()
+
+	store.Save(ctx, &Memory{ID: "go", Content: "go test go"})
+	store.Save(ctx, &Memory{ID: "rust", Content: "rust test"})
+	store.Save(ctx, &Memory{ID: "deploy", Content: "deploy deploy"})
+
+	got, err := store.SearchByText(ctx, "go", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != "go" {
+		t.Fatalf("expected 'go' first of 2 results, got %d results", len(got))
+	}
+	if got[0].Score <= got[1].Score {
+		t.Errorf("expected descending scores, got %v then %v", got[0].Score, got[1].Score)
+	}
+
+	// Search results are copies; scoring must not leak into stored memories.
+	stored, _ := store.Get(ctx, "go")
+	if stored.Score != 0 {
+		t.Errorf("expected stored score 0, got %v", stored.Score)
+	}
+
+	if _, err := NewInMemoryVectorStore(nil).SearchByText(ctx, "go", 1); err == nil {
+		t.Error("expected error without embedder")
+	}
+}
@@ -0,0 +1,301 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of change an Event describes.
+type EventType string
+
+const (
+	// EventSave records a new memory; Before is nil.
+	EventSave EventType = "save"
+	// EventUpdate records a memory replaced by Save; both snapshots are set.
+	EventUpdate EventType = "update"
+	// EventDelete records a deleted memory; After is nil.
+	EventDelete EventType = "delete"
+	// EventClear records a Clear. It carries no snapshots; consumers should
+	// drop everything they hold.
+	EventClear EventType = "clear"
+	// EventGap is delivered to subscriptions using SlowSkip when events were
+	// lost. Consumers should resynchronize with List.
+	EventGap EventType = "gap"
+)
+
+// ErrCursorExpired is returned when a resume cursor points at events that
+// are no longer retained.
+var ErrCursorExpired = errors.New("change feed cursor expired")
+
+// ErrSlowConsumer ends a subscription using SlowDisconnect that fell so far
+// behind that unread events were discarded.
+var ErrSlowConsumer = errors.New("change feed consumer too slow")
+
+// Event describes a change to a store. Seq increases monotonically within
+// a feed and is the cursor for resuming. Snapshots are shared between
+// subscribers and must not be modified.
+type Event struct {
+	Seq    uint64    `json:"seq"`
+	Type   EventType `json:"type"`
+	ID     string    `json:"id,omitempty"`
+	Before *Memory   `json:"before,omitempty"`
+	After  *Memory   `json:"after,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// SlowConsumerPolicy decides what happens to a subscription that falls
+// behind the events its feed retains.
+type SlowConsumerPolicy string
+
+const (
+	// SlowDisconnect closes the subscription with ErrSlowConsumer. The
+	// consumer can resynchronize and watch again.
+	SlowDisconnect SlowConsumerPolicy = "disconnect"
+	// SlowSkip delivers an EventGap and continues from the oldest retained
+	// event.
+	SlowSkip SlowConsumerPolicy = "skip"
+)
+
+// WatchOptions controls a subscription.
+type WatchOptions struct {
+	// Buffer is the channel capacity. Defaults to 64.
+	Buffer int
+	// Since resumes after the event with this sequence number, as returned
+	// by Subscription.Cursor. Zero starts with events published after Watch
+	// returns.
+	Since uint64
+	// SlowConsumer defaults to SlowDisconnect.
+	SlowConsumer SlowConsumerPolicy
+}
+
+// Watcher is implemented by stores that publish a change feed.
+type Watcher interface {
+	// Watch subscribes to changes until ctx is done or the subscription is
+	// closed.
+	Watch(ctx context.Context, opts WatchOptions) (*Subscription, error)
+}
+
+// EventSource is the retained history a Feed reads from.
+type EventSource interface {
+	// Head returns the sequence number of the latest event, or zero.
+	Head(ctx context.Context) (uint64, error)
+	// Oldest returns the sequence number of the oldest retained event, or
+	// zero if none are retained.
+	Oldest(ctx context.Context) (uint64, error)
+	// Events returns up to limit events with Seq greater than since, oldest
+	// first. It returns ErrCursorExpired if events after since have been
+	// discarded.
+	Events(ctx context.Context, since uint64, limit int) ([]Event, error)
+}
+
+// feedBatch is how many events a subscription reads from its source at once.
+const feedBatch = 256
+
+// Feed fans events out from an EventSource to subscriptions. Each
+// subscription pulls from the source at its own pace, so a slow consumer
+// never blocks writers; it only falls behind until the source no longer
+// retains its position.
+type Feed struct {
+	source EventSource
+
+	mu     sync.Mutex
+	wake   chan struct{}
+	closed bool
+}
+
+// NewFeed creates a feed over source.
+func NewFeed(source EventSource) *Feed {
+	return &Feed{source: source, wake: make(chan struct{})}
+}
+
+// Notify wakes subscriptions after new events are available in the source.
+func (f *Feed) Notify() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	close(f.wake)
+	f.wake = make(chan struct{})
+}
+
+// Close ends all subscriptions.
+func (f *Feed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.closed = true
+		close(f.wake)
+	}
+}
+
+func (f *Feed) waitChan() (<-chan struct{}, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.wake, f.closed
+}
+
+// Subscribe starts a subscription.
+func (f *Feed) Subscribe(ctx context.Context, opts WatchOptions) (*Subscription, error) {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 64
+	}
+	switch opts.SlowConsumer {
+	case "":
+		opts.SlowConsumer = SlowDisconnect
+	case SlowDisconnect, SlowSkip:
+	default:
+		return nil, fmt.Errorf("watch: unknown slow consumer policy %q", opts.SlowConsumer)
+	}
+	cursor := opts.Since
+	if cursor == 0 {
+		head, err := f.source.Head(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("watch: %w", err)
+		}
+		cursor = head
+	} else if _, err := f.source.Events(ctx, cursor, 1); err != nil {
+		return nil, fmt.Errorf("watch: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{
+		ch:     make(chan Event, opts.Buffer),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		cursor: cursor,
+	}
+	go s.run(ctx, f, opts.SlowConsumer)
+	return s, nil
+}
+
+// Subscription is a stream of events from a Feed.
+type Subscription struct {
+	ch     chan Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	cursor uint64
+	err    error
+}
+
+// Events returns the event channel. It is closed when the subscription
+// ends; Err then reports why.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Cursor returns the sequence number of the last event sent to the channel.
+// Passing it as WatchOptions.Since resumes after that event.
+func (s *Subscription) Cursor() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursor
+}
+
+// Err returns the error that ended the subscription, or nil if it is still
+// running or was closed normally.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription and waits for its channel to close.
+func (s *Subscription) Close() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *Subscription) run(ctx context.Context, f *Feed, policy SlowConsumerPolicy) {
+	defer close(s.done)
+	defer close(s.ch)
+	defer s.cancel()
+
+	for {
+		// Take the wake channel before reading so a Notify between the read
+		// and the wait is not missed.
+		wake, closed := f.waitChan()
+		events, err := f.source.Events(ctx, s.Cursor(), feedBatch)
+		if errors.Is(err, ErrCursorExpired) && policy == SlowSkip {
+			if err = s.skip(ctx, f.source); err == nil {
+				continue
+			}
+		} else if errors.Is(err, ErrCursorExpired) {
+			err = ErrSlowConsumer
+		}
+		if err != nil {
+			s.fail(ctx, err)
+			return
+		}
+
+		for _, ev := range events {
+			if !s.send(ctx, ev, true) {
+				return
+			}
+		}
+		if len(events) == feedBatch {
+			continue
+		}
+		if closed {
+			return
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// skip sends an EventGap and moves the cursor to just before the oldest
+// retained event, or to the head if nothing is retained.
+func (s *Subscription) skip(ctx context.Context, source EventSource) error {
+	oldest, err := source.Oldest(ctx)
+	if err != nil {
+		return err
+	}
+	next := oldest - 1
+	if oldest == 0 {
+		if next, err = source.Head(ctx); err != nil {
+			return err
+		}
+	}
+	if !s.send(ctx, Event{Seq: s.Cursor(), Type: EventGap, Time: time.Now()}, false) {
+		return ctx.Err()
+	}
+	s.mu.Lock()
+	s.cursor = next
+	s.mu.Unlock()
+	return nil
+}
+
+// send delivers ev, blocking until the consumer reads it or the
+// subscription ends. advance moves the cursor to ev.Seq.
+func (s *Subscription) send(ctx context.Context, ev Event, advance bool) bool {
+	select {
+	case s.ch <- ev:
+		if advance {
+			s.mu.Lock()
+			s.cursor = ev.Seq
+			s.mu.Unlock()
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// fail records err unless the subscription was closed by its consumer.
+func (s *Subscription) fail(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
@@ -0,0 +1,178 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultWatchHistory is the default number of events a WatchStore retains
+// for lagging and resuming subscribers.
+const DefaultWatchHistory = 1024
+
+// eventRing is an in-memory EventSource retaining the most recent events.
+type eventRing struct {
+	mu     sync.RWMutex
+	events []Event // oldest first
+	size   int
+	head   uint64
+}
+
+func (r *eventRing) append(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.head++
+	ev.Seq = r.head
+	if len(r.events) == r.size {
+		copy(r.events, r.events[1:])
+		r.events = r.events[:len(r.events)-1]
+	}
+	r.events = append(r.events, ev)
+}
+
+func (r *eventRing) Head(ctx context.Context) (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.head, nil
+}
+
+func (r *eventRing) Oldest(ctx context.Context) (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.events) == 0 {
+		return 0, nil
+	}
+	return r.events[0].Seq, nil
+}
+
+func (r *eventRing) Events(ctx context.Context, since uint64, limit int) ([]Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if since >= r.head {
+		return nil, nil
+	}
+	if len(r.events) == 0 || since+1 < r.events[0].Seq {
+		return nil, fmt.Errorf("%w: %d", ErrCursorExpired, since)
+	}
+	start := int(since + 1 - r.events[0].Seq)
+	end := len(r.events)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return append([]Event(nil), r.events[start:end]...), nil
+}
+
+// WatchStore wraps a Store and publishes its changes to subscribers. Recent
+// events are kept in memory, so cursors survive slow consumers and
+// reconnects but not a restart; use a backend with a durable change feed
+// for that. Changes made to the wrapped store directly are not observed.
+// Search methods pass through and return an error when the wrapped store
+// does not support them.
+type WatchStore struct {
+	Store
+
+	mu   sync.Mutex
+	ring *eventRing
+	feed *Feed
+}
+
+// NewWatchStore wraps s. history is the number of events retained; zero
+// uses DefaultWatchHistory.
+func NewWatchStore(s Store, history int) *WatchStore {
+	if history <= 0 {
+		history = DefaultWatchHistory
+	}
+	ring := &eventRing{size: history}
+	return &WatchStore{Store: s, ring: ring, feed: NewFeed(ring)}
+}
+
+// Watch subscribes to changes.
+func (s *WatchStore) Watch(ctx context.Context, opts WatchOptions) (*Subscription, error) {
+	return s.feed.Subscribe(ctx, opts)
+}
+
+// Close ends all subscriptions. The wrapped store is left open.
+func (s *WatchStore) Close() {
+	s.feed.Close()
+}
+
+func (s *WatchStore) publish(ev Event) {
+	ev.Time = time.Now()
+	s.ring.append(ev)
+	s.feed.Notify()
+}
+
+// Save stores a memory and publishes EventSave or EventUpdate.
+func (s *WatchStore) Save(ctx context.Context, m *Memory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var before *Memory
+	if m.ID != "" {
+		prev, err := s.Store.Get(ctx, m.ID)
+		switch {
+		case err == nil:
+			before = prev.clone()
+		case !errors.Is(err, ErrNotFound):
+			return err
+		}
+	}
+	if err := s.Store.Save(ctx, m); err != nil {
+		return err
+	}
+	ev := Event{Type: EventSave, ID: m.ID, Before: before, After: m.clone()}
+	if before != nil {
+		ev.Type = EventUpdate
+	}
+	s.publish(ev)
+	return nil
+}
+
+// Delete removes a memory and publishes EventDelete.
+func (s *WatchStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, err := s.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	before := prev.clone()
+	if err := s.Store.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.publish(Event{Type: EventDelete, ID: id, Before: before})
+	return nil
+}
+
+// Clear removes all memories and publishes EventClear.
+func (s *WatchStore) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.Store.Clear(ctx); err != nil {
+		return err
+	}
+	s.publish(Event{Type: EventClear})
+	return nil
+}
+
+// Search finds similar memories using the wrapped VectorStore.
+func (s *WatchStore) Search(ctx context.Context, embedding []float64, limit int) ([]*Memory, error) {
+	vs, ok := s.Store.(VectorStore)
+	if !ok {
+		return nil, fmt.Errorf("search: store does not support vector search")
+	}
+	return vs.Search(ctx, embedding, limit)
+}
+
+// SearchByText finds similar memories using the wrapped VectorStore.
+func (s *WatchStore) SearchByText(ctx context.Context, text string, limit int) ([]*Memory, error) {
+	vs, ok := s.Store.(VectorStore)
+	if !ok {
+		return nil, fmt.Errorf("search by text: store does not support vector search")
+	}
+	return vs.SearchByText(ctx, text, limit)
+}
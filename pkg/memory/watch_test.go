@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case ev, ok := <-sub.Events():
+		if !ok {
+			t.Fatalf("subscription ended: %v", sub.Err())
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return Event{}
+}
+
+func TestWatchStoreEvents(t *testing.T) {
+	ctx := context.Background()
+	s := NewWatchStore(NewInMemoryStore(), 0)
+	defer s.Close()
+
+	sub, err := s.Watch(ctx, WatchOptions{})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer sub.Close()
+
+	s.Save(ctx, &Memory{ID: "a", Content: "first"})
+	s.Save(ctx, &Memory{ID: "a", Content: "second"})
+	s.Delete(ctx, "a")
+	s.Clear(ctx)
+
+	ev := nextEvent(t, sub)
+	if ev.Type != EventSave || ev.Before != nil || ev.After.Content != "first" {
+		t.Errorf("unexpected save event %+v", ev)
+	}
+	ev = nextEvent(t, sub)
+	if ev.Type != EventUpdate || ev.Before.Content != "first" || ev.After.Content != "second" {
+		t.Errorf("unexpected update event %+v", ev)
+	}
+	ev = nextEvent(t, sub)
+	if ev.Type != EventDelete || ev.ID != "a" || ev.Before.Content != "second" || ev.After != nil {
+		t.Errorf("unexpected delete event %+v", ev)
+	}
+	ev = nextEvent(t, sub)
+	if ev.Type != EventClear || ev.Seq != 4 {
+		t.Errorf("unexpected clear event %+v", ev)
+	}
+	if sub.Cursor() != 4 {
+		t.Errorf("expected cursor 4, got %d", sub.Cursor())
+	}
+}
+
+func TestWatchStoreResume(t *testing.T) {
+	ctx := context.Background()
+	s := NewWatchStore(NewInMemoryStore(), 0)
+	defer s.Close()
+
+	sub, _ := s.Watch(ctx, WatchOptions{})
+	s.Save(ctx, &Memory{ID: "a"})
+	nextEvent(t, sub)
+	cursor := sub.Cursor()
+	sub.Close()
+
+	s.Save(ctx, &Memory{ID: "b"})
+	s.Save(ctx, &Memory{ID: "c"})
+
+	resumed, err := s.Watch(ctx, WatchOptions{Since: cursor})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer resumed.Close()
+	if ev := nextEvent(t, resumed); ev.ID != "b" {
+		t.Errorf("expected to resume at b, got %s", ev.ID)
+	}
+	if ev := nextEvent(t, resumed); ev.ID != "c" {
+		t.Errorf("expected c, got %s", ev.ID)
+	}
+}
+
+func TestWatchSlowConsumer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Disconnect", func(t *testing.T) {
+		s := NewWatchStore(NewInMemoryStore(), 4)
+		defer s.Close()
+		sub, _ := s.Watch(ctx, WatchOptions{Buffer: 1})
+		defer sub.Close()
+
+		// The subscription can hold one event in its buffer and one in
+		// flight; the rest fall out of the retained history.
+		for i := 0; i < 20; i++ {
+			s.Save(ctx, &Memory{})
+		}
+		for range sub.Events() {
+		}
+		if !errors.Is(sub.Err(), ErrSlowConsumer) {
+			t.Errorf("expected ErrSlowConsumer, got %v", sub.Err())
+		}
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		s := NewWatchStore(NewInMemoryStore(), 4)
+		defer s.Close()
+		sub, _ := s.Watch(ctx, WatchOptions{Buffer: 1, SlowConsumer: SlowSkip})
+		defer sub.Close()
+
+		for i := 0; i < 20; i++ {
+			s.Save(ctx, &Memory{})
+		}
+		gap := false
+		for {
+			ev := nextEvent(t, sub)
+			if ev.Type == EventGap {
+				gap = true
+			}
+			if ev.Seq == 20 && ev.Type != EventGap {
+				break
+			}
+		}
+		if !gap {
+			t.Error("expected a gap event")
+		}
+	})
+
+	t.Run("ExpiredCursor", func(t *testing.T) {
+		s := NewWatchStore(NewInMemoryStore(), 2)
+		defer s.Close()
+		for i := 0; i < 5; i++ {
+			s.Save(ctx, &Memory{})
+		}
+		if _, err := s.Watch(ctx, WatchOptions{Since: 1}); !errors.Is(err, ErrCursorExpired) {
+			t.Errorf("expected ErrCursorExpired, got %v", err)
+		}
+	})
+}
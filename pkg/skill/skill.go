@@ -0,0 +1,460 @@
+// Package skill stores reusable procedures as procedural memories.
+//
+// A Skill records how a kind of task was solved before: a trigger
+// describing when it applies, and either ordered steps or a reference to a
+// workflow definition. Outcomes reported after each use reinforce skills
+// that work and demote those that do not, so agents reuse proven solutions.
+package skill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+// Metadata keys used on skill memories.
+const (
+	MetaSkillName   = "skill_name"
+	MetaTrigger     = "skill_trigger"
+	MetaSteps       = "skill_steps"
+	MetaWorkflow    = "skill_workflow"
+	MetaSuccesses   = "skill_successes"
+	MetaFailures    = "skill_failures"
+	MetaLastOutcome = "skill_last_outcome"
+	MetaLastUsedAt  = "skill_last_used_at"
+)
+
+// ErrNotFound is returned when a skill does not exist.
+var ErrNotFound = errors.New("skill not found")
+
+// Outcome is the result of applying a skill.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Skill is a reusable procedure. Either Steps or Workflow describes how to
+// perform it; both may be set.
+type Skill struct {
+	Name string `json:"name"`
+	// Trigger describes the tasks the skill applies to. It is what matching
+	// compares tasks against.
+	Trigger string `json:"trigger"`
+	// Steps are ordered natural-language instructions.
+	Steps []string `json:"steps,omitempty"`
+	// Workflow is the path of a workflow YAML definition.
+	Workflow     string     `json:"workflow,omitempty"`
+	SuccessCount int        `json:"success_count"`
+	FailureCount int        `json:"failure_count"`
+	LastOutcome  Outcome    `json:"last_outcome,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Uses returns how many outcomes have been recorded.
+func (s *Skill) Uses() int {
+	return s.SuccessCount + s.FailureCount
+}
+
+// Reliability estimates the skill's success rate with add-one smoothing,
+// so an unused skill scores 0.5.
+func (s *Skill) Reliability() float64 {
+	return float64(s.SuccessCount+1) / float64(s.Uses()+2)
+}
+
+// LoadWorkflow parses the skill's workflow definition with p.
+func (s *Skill) LoadWorkflow(p workflow.Parser) (*workflow.Workflow, error) {
+	if s.Workflow == "" {
+		return nil, fmt.Errorf("skill %s: no workflow", s.Name)
+	}
+	w, err := p.ParseFile(s.Workflow)
+	if err != nil {
+		return nil, fmt.Errorf("skill %s: %w", s.Name, err)
+	}
+	return w, nil
+}
+
+// Config contains Library configuration.
+type Config struct {
+	// ReliabilityWeight is how much reliability affects match ranking, in
+	// [0, 1]. Zero ranks by relevance alone. Defaults to 0.3.
+	ReliabilityWeight float64
+	// MinReliability demotes skills whose reliability falls below it, once
+	// they have MinUses recorded outcomes: they are left out of matches.
+	// Zero disables demotion.
+	MinReliability float64
+	// MinUses is how many outcomes a skill needs before it can be demoted.
+	// Defaults to 3.
+	MinUses int
+}
+
+// Match is a skill matched to a task.
+type Match struct {
+	Skill *Skill `json:"skill"`
+	// Relevance is how well the trigger matches the task, in [0, 1].
+	Relevance float64 `json:"relevance"`
+	// Score combines relevance and reliability; matches are ordered by it.
+	Score float64 `json:"score"`
+}
+
+// Library stores skills in a memory.Store as procedural memories. Matching
+// uses vector search when the store is a memory.VectorStore and keyword
+// search otherwise. Writes through one Library are serialized, so
+// concurrent outcomes are all counted.
+type Library struct {
+	store  memory.Store
+	config Config
+
+	mu sync.Mutex // serializes writes
+}
+
+// NewLibrary creates a skill library backed by store.
+func NewLibrary(store memory.Store, cfg Config) *Library {
+	if cfg.ReliabilityWeight == 0 {
+		cfg.ReliabilityWeight = 0.3
+	}
+	if cfg.MinUses == 0 {
+		cfg.MinUses = 3
+	}
+	return &Library{store: store, config: cfg}
+}
+
+// memoryID returns the memory ID of a skill. Names are unique, so saving a
+// skill with an existing name replaces it.
+func memoryID(name string) string {
+	return "skill:" + name
+}
+
+// Save stores a skill, replacing any skill with the same name. The
+// caller's skill receives the stored timestamps.
+func (l *Library) Save(ctx context.Context, s *Skill) error {
+	if s.Name == "" {
+		return fmt.Errorf("save skill: name is required")
+	}
+	if s.Trigger == "" {
+		return fmt.Errorf("save skill %s: trigger is required", s.Name)
+	}
+	if len(s.Steps) == 0 && s.Workflow == "" {
+		return fmt.Errorf("save skill %s: steps or workflow is required", s.Name)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	existing, err := l.store.Get(ctx, memoryID(s.Name))
+	if err != nil && !errors.Is(err, memory.ErrNotFound) {
+		return fmt.Errorf("save skill %s: %w", s.Name, err)
+	}
+	return l.save(ctx, s, existing)
+}
+
+// save stores s over existing, which is nil for a new skill. The embedding
+// is kept when the content is unchanged, so recording outcomes does not
+// re-embed. The caller holds mu.
+func (l *Library) save(ctx context.Context, s *Skill, existing *memory.Memory) error {
+	m := toMemory(s)
+	if existing != nil {
+		m.CreatedAt = existing.CreatedAt
+		if existing.Content == m.Content {
+			m.Embedding = existing.Embedding
+		}
+	}
+	if err := l.store.Save(ctx, m); err != nil {
+		return fmt.Errorf("save skill %s: %w", s.Name, err)
+	}
+	s.CreatedAt = m.CreatedAt
+	s.UpdatedAt = m.UpdatedAt
+	return nil
+}
+
+// Get returns the skill with the given name.
+func (l *Library) Get(ctx context.Context, name string) (*Skill, error) {
+	m, err := l.store.Get(ctx, memoryID(name))
+	if errors.Is(err, memory.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get skill %s: %w", name, err)
+	}
+	return fromMemory(m), nil
+}
+
+// Delete removes a skill.
+func (l *Library) Delete(ctx context.Context, name string) error {
+	err := l.store.Delete(ctx, memoryID(name))
+	if errors.Is(err, memory.ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return err
+}
+
+// List returns all skills ordered by name.
+func (l *Library) List(ctx context.Context) ([]*Skill, error) {
+	memories, err := l.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	skills := make([]*Skill, len(memories))
+	for i, m := range memories {
+		skills[i] = fromMemory(m)
+	}
+	sort.Slice(skills, func(i, j int) bool { return skills[i].Name < skills[j].Name })
+	return skills, nil
+}
+
+func (l *Library) list(ctx context.Context) ([]*memory.Memory, error) {
+	all, err := l.store.List(ctx, &memory.Filter{Type: memory.TypeProcedural})
+	if err != nil {
+		return nil, fmt.Errorf("list skills: %w", err)
+	}
+	memories := all[:0]
+	for _, m := range all {
+		if isSkill(m) {
+			memories = append(memories, m)
+		}
+	}
+	return memories, nil
+}
+
+// RecordOutcome records the result of applying a skill and returns the
+// updated skill. Successes raise its reliability and ranking; failures
+// lower them and may demote it.
+func (l *Library) RecordOutcome(ctx context.Context, name string, outcome Outcome) (*Skill, error) {
+	switch outcome {
+	case OutcomeSuccess, OutcomeFailure:
+	default:
+		return nil, fmt.Errorf("record outcome: unknown outcome %q", outcome)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, err := l.store.Get(ctx, memoryID(name))
+	if errors.Is(err, memory.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("record outcome %s: %w", name, err)
+	}
+	s := fromMemory(m)
+	if outcome == OutcomeSuccess {
+		s.SuccessCount++
+	} else {
+		s.FailureCount++
+	}
+	now := time.Now()
+	s.LastOutcome = outcome
+	s.LastUsedAt = &now
+	if err := l.save(ctx, s, m); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Demoted reports whether s is excluded from matches by its track record.
+func (l *Library) Demoted(s *Skill) bool {
+	return l.config.MinReliability > 0 &&
+		s.Uses() >= l.config.MinUses &&
+		s.Reliability() < l.config.MinReliability
+}
+
+// Match returns up to limit skills relevant to task, best first. Demoted
+// skills are left out.
+func (l *Library) Match(ctx context.Context, task string, limit int) ([]*Match, error) {
+	var (
+		candidates []*memory.Memory
+		err        error
+	)
+	if vs, ok := l.store.(memory.VectorStore); ok {
+		candidates, err = l.searchVector(ctx, vs, task, limit)
+	} else {
+		candidates, err = l.searchKeyword(ctx, task)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("match skills: %w", err)
+	}
+
+	w := l.config.ReliabilityWeight
+	matches := make([]*Match, 0, len(candidates))
+	for _, m := range candidates {
+		s := fromMemory(m)
+		if l.Demoted(s) || m.Score <= 0 {
+			continue
+		}
+		matches = append(matches, &Match{
+			Skill:     s,
+			Relevance: m.Score,
+			Score:     m.Score * (1 - w + w*s.Reliability()),
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if limit > 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// searchVector over-fetches, since the store holds other memories and
+// reliability reorders the results.
+func (l *Library) searchVector(ctx context.Context, vs memory.VectorStore, task string, limit int) ([]*memory.Memory, error) {
+	n := 4 * limit
+	if n < 20 {
+		n = 20
+	}
+	found, err := vs.SearchByText(ctx, task, n)
+	if err != nil {
+		return nil, err
+	}
+	skills := found[:0]
+	for _, m := range found {
+		if m.Type == memory.TypeProcedural && isSkill(m) {
+			skills = append(skills, m)
+		}
+	}
+	return skills, nil
+}
+
+// searchKeyword ranks all skills by BM25, normalized so the best match
+// scores 1.
+func (l *Library) searchKeyword(ctx context.Context, task string) ([]*memory.Memory, error) {
+	memories, err := l.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx := memory.NewBM25Index()
+	byID := make(map[string]*memory.Memory, len(memories))
+	for _, m := range memories {
+		idx.Add(m)
+		byID[m.ID] = m
+	}
+	hits := idx.Search(task, 0)
+	if len(hits) == 0 {
+		return nil, nil
+	}
+	top := hits[0].Score
+	result := make([]*memory.Memory, len(hits))
+	for i, h := range hits {
+		m := *byID[h.ID]
+		m.Score = h.Score / top
+		result[i] = &m
+	}
+	return result, nil
+}
+
+// Prompt formats matches as a block for an agent's context.
+func Prompt(matches []*Match) string {
+	if len(matches) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Skills that solved similar tasks before:")
+	for _, m := range matches {
+		s := m.Skill
+		fmt.Fprintf(&b, "\n\n%s (%s; %d/%d successful)", s.Name, s.Trigger, s.SuccessCount, s.Uses())
+		for i, step := range s.Steps {
+			fmt.Fprintf(&b, "\n%d. %s", i+1, step)
+		}
+		if s.Workflow != "" {
+			fmt.Fprintf(&b, "\nWorkflow: %s", s.Workflow)
+		}
+	}
+	return b.String()
+}
+
+func isSkill(m *memory.Memory) bool {
+	_, ok := m.Metadata[MetaSkillName].(string)
+	return ok
+}
+
+// toMemory encodes a skill. Content holds the text matching runs against;
+// the structured fields live in metadata.
+func toMemory(s *Skill) *memory.Memory {
+	meta := map[string]interface{}{
+		MetaSkillName: s.Name,
+		MetaTrigger:   s.Trigger,
+		MetaSuccesses: s.SuccessCount,
+		MetaFailures:  s.FailureCount,
+	}
+	if len(s.Steps) > 0 {
+		steps := make([]interface{}, len(s.Steps))
+		for i, step := range s.Steps {
+			steps[i] = step
+		}
+		meta[MetaSteps] = steps
+	}
+	if s.Workflow != "" {
+		meta[MetaWorkflow] = s.Workflow
+	}
+	if s.LastOutcome != "" {
+		meta[MetaLastOutcome] = string(s.LastOutcome)
+	}
+	if s.LastUsedAt != nil {
+		meta[MetaLastUsedAt] = s.LastUsedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	content := s.Name + ": " + s.Trigger
+	if len(s.Steps) > 0 {
+		content += "\n" + strings.Join(s.Steps, "\n")
+	}
+	return &memory.Memory{
+		ID:         memoryID(s.Name),
+		Type:       memory.TypeProcedural,
+		Content:    content,
+		Metadata:   meta,
+		Importance: s.Reliability(),
+		CreatedAt:  s.CreatedAt,
+	}
+}
+
+// fromMemory decodes a skill, tolerating the numeric and slice types
+// produced by JSON-backed stores.
+func fromMemory(m *memory.Memory) *Skill {
+	s := &Skill{
+		Name:         metaString(m, MetaSkillName),
+		Trigger:      metaString(m, MetaTrigger),
+		Workflow:     metaString(m, MetaWorkflow),
+		SuccessCount: metaInt(m, MetaSuccesses),
+		FailureCount: metaInt(m, MetaFailures),
+		LastOutcome:  Outcome(metaString(m, MetaLastOutcome)),
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+	}
+	switch steps := m.Metadata[MetaSteps].(type) {
+	case []interface{}:
+		for _, step := range steps {
+			if str, ok := step.(string); ok {
+				s.Steps = append(s.Steps, str)
+			}
+		}
+	case []string:
+		s.Steps = append(s.Steps, steps...)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, metaString(m, MetaLastUsedAt)); err == nil {
+		s.LastUsedAt = &t
+	}
+	return s
+}
+
+func metaString(m *memory.Memory, key string) string {
+	s, _ := m.Metadata[key].(string)
+	return s
+}
+
+func metaInt(m *memory.Memory, key string) int {
+	switch v := m.Metadata[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(math.Round(v))
+	}
+	return 0
+}
@@ -0,0 +1,260 @@
+package skill
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/memory/memorytest"
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+func seed(t *testing.T, l *Library) {
+	t.Helper()
+	ctx := context.Background()
+	skills := []*Skill{
+		{
+			Name:    "fix-flaky-test",
+			Trigger: "a go test fails intermittently",
+			Steps:   []string{"run the test with -count=100", "look for shared state", "add synchronization"},
+		},
+		{
+			Name:     "deploy-service",
+			Trigger:  "deploy a service to staging",
+			Workflow: "workflows/deploy.yaml",
+		},
+		{
+			Name:    "rotate-credentials",
+			Trigger: "rotate database credentials",
+			Steps:   []string{"generate new credentials", "update the secret", "restart the database clients"},
+		},
+	}
+	for _, s := range skills {
+		if err := l.Save(ctx, s); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+func TestLibraryRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewFileStore(memory.FileStoreConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer store.Close()
+
+	// Unrelated memories in the same store are ignored.
+	store.Save(ctx, &memory.Memory{Type: memory.TypeProcedural, Content: "not a skill"})
+	store.Save(ctx, &memory.Memory{Type: memory.TypeEpisodic, Content: "deploy went fine"})
+
+	l := NewLibrary(store, Config{})
+	seed(t, l)
+
+	skills, err := l.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(skills) != 3 || skills[0].Name != "deploy-service" {
+		t.Fatalf("unexpected skills %v", skills)
+	}
+
+	got, err := l.Get(ctx, "fix-flaky-test")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(got.Steps) != 3 || got.Steps[1] != "look for shared state" || got.CreatedAt.IsZero() {
+		t.Errorf("unexpected skill %+v", got)
+	}
+
+	if _, err := l.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := l.Save(ctx, &Skill{Name: "empty", Trigger: "never"}); err == nil {
+		t.Error("expected error for skill without steps or workflow")
+	}
+	if err := l.Delete(ctx, "deploy-service"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := l.Delete(ctx, "deploy-service"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestLibraryRecordOutcome(t *testing.T) {
+	ctx := context.Background()
+	store, _ := memory.NewFileStore(memory.FileStoreConfig{Dir: t.TempDir()})
+	defer store.Close()
+	l := NewLibrary(store, Config{})
+	seed(t, l)
+
+	before, _ := l.Get(ctx, "fix-flaky-test")
+	l.RecordOutcome(ctx, "fix-flaky-test", OutcomeSuccess)
+	s, err := l.RecordOutcome(ctx, "fix-flaky-test", OutcomeFailure)
+	if err != nil {
+		t.Fatalf("RecordOutcome failed: %v", err)
+	}
+	if s.SuccessCount != 1 || s.FailureCount != 1 || s.LastOutcome != OutcomeFailure || s.LastUsedAt == nil {
+		t.Errorf("unexpected skill after outcomes %+v", s)
+	}
+
+	got, _ := l.Get(ctx, "fix-flaky-test")
+	if got.SuccessCount != 1 || got.FailureCount != 1 || got.LastUsedAt == nil {
+		t.Errorf("outcomes not persisted: %+v", got)
+	}
+	if !got.CreatedAt.Equal(before.CreatedAt) {
+		t.Error("expected CreatedAt to be preserved")
+	}
+
+	if _, err := l.RecordOutcome(ctx, "fix-flaky-test", "maybe"); err == nil {
+		t.Error("expected error for unknown outcome")
+	}
+	if _, err := l.RecordOutcome(ctx, "missing", OutcomeSuccess); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// slowStore delays saves to widen the window for lost updates.
+type slowStore struct {
+	memory.VectorStore
+}
+
+func (s slowStore) Save(ctx context.Context, m *memory.Memory) error {
+	time.Sleep(time.Millisecond)
+	return s.VectorStore.Save(ctx, m)
+}
+
+// countingEmbedder counts the texts it embeds.
+type countingEmbedder struct {
+	memory.Embedder
+	calls atomic.Int32
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	e.calls.Add(1)
+	return e.Embedder.Embed(ctx, text)
+}
+
+func TestLibraryRecordOutcomeConcurrent(t *testing.T) {
+	ctx := context.Background()
+	e := &countingEmbedder{Embedder: memorytest.NewHashEmbedder(64)}
+	l := NewLibrary(slowStore{memory.NewInMemoryVectorStore(e)}, Config{})
+	if err := l.Save(ctx, &Skill{Name: "deploy", Trigger: "deploy a service", Steps: []string{"run the pipeline"}}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := l.RecordOutcome(ctx, "deploy", OutcomeSuccess); err != nil {
+				t.Errorf("RecordOutcome failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s, err := l.Get(ctx, "deploy")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if s.SuccessCount != 50 {
+		t.Errorf("expected 50 successes, got %d", s.SuccessCount)
+	}
+	if n := e.calls.Load(); n != 1 {
+		t.Errorf("expected the skill to be embedded once, got %d", n)
+	}
+}
+
+func TestLibraryMatchKeyword(t *testing.T) {
+	ctx := context.Background()
+	l := NewLibrary(memory.NewInMemoryStore(), Config{MinReliability: 0.4, MinUses: 3})
+	seed(t, l)
+
+	matches, err := l.Match(ctx, "the go test for the parser fails intermittently", 2)
+	if err != nil {
+		t.Fatalf("Match failed: %v", err)
+	}
+	if len(matches) == 0 || matches[0].Skill.Name != "fix-flaky-test" || matches[0].Relevance != 1 {
+		t.Fatalf("unexpected matches %+v", matches)
+	}
+
+	// Scores are set on copies, not on the store's memories.
+	stored, _ := l.store.List(ctx, nil)
+	for _, m := range stored {
+		if m.Score != 0 {
+			t.Errorf("expected stored memory %s to keep score 0, got %v", m.ID, m.Score)
+		}
+	}
+
+	// Repeated failures demote a skill out of matches.
+	for i := 0; i < 3; i++ {
+		l.RecordOutcome(ctx, "fix-flaky-test", OutcomeFailure)
+	}
+	matches, _ = l.Match(ctx, "the go test for the parser fails intermittently", 2)
+	for _, m := range matches {
+		if m.Skill.Name == "fix-flaky-test" {
+			t.Error("expected demoted skill to be excluded")
+		}
+	}
+}
+
+func TestLibraryMatchVector(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewInMemoryVectorStore(memorytest.NewHashEmbedder(64))
+	store.Save(ctx, &memory.Memory{Type: memory.TypeEpisodic, Content: "rotate database credentials"})
+	l := NewLibrary(store, Config{ReliabilityWeight: 1})
+	seed(t, l)
+
+	// Two equally relevant skills are ordered by reliability.
+	l.Save(ctx, &Skill{Name: "rotate-credentials-v2", Trigger: "rotate database credentials", Steps: []string{"use the rotation job"}})
+	l.RecordOutcome(ctx, "rotate-credentials-v2", OutcomeSuccess)
+	l.RecordOutcome(ctx, "rotate-credentials", OutcomeFailure)
+
+	matches, err := l.Match(ctx, "rotate database credentials", 3)
+	if err != nil {
+		t.Fatalf("Match failed: %v", err)
+	}
+	if len(matches) < 2 {
+		t.Fatalf("expected at least 2 matches, got %d", len(matches))
+	}
+	if matches[0].Skill.Name != "rotate-credentials-v2" {
+		t.Errorf("expected reinforced skill first, got %s", matches[0].Skill.Name)
+	}
+	for _, m := range matches {
+		if m.Skill.Name == "" {
+			t.Error("expected only skill memories")
+		}
+	}
+
+	prompt := Prompt(matches[:1])
+	if !strings.Contains(prompt, "rotate-credentials-v2") || !strings.Contains(prompt, "1. use the rotation job") {
+		t.Errorf("unexpected prompt %q", prompt)
+	}
+}
+
+func TestSkillLoadWorkflow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.yaml")
+	os.WriteFile(path, []byte("name: deploy\nversion: \"1\"\nsteps:\n  - id: build\n    name: Build\n    type: task\n    action: shell.exec\n"), 0o644)
+
+	s := &Skill{Name: "deploy", Trigger: "deploy", Workflow: path}
+	w, err := s.LoadWorkflow(workflow.NewParser())
+	if err != nil {
+		t.Fatalf("LoadWorkflow failed: %v", err)
+	}
+	if w.Name != "deploy" || len(w.Steps) != 1 {
+		t.Errorf("unexpected workflow %+v", w)
+	}
+
+	if _, err := (&Skill{Name: "none"}).LoadWorkflow(workflow.NewParser()); err == nil {
+		t.Error("expected error for skill without workflow")
+	}
+}
@@ -0,0 +1,370 @@
+// Package actions provides the built-in workflow actions: LLM completion
+// and agent runs, shell commands, HTTP requests, file access, JSON queries,
+// memory and git.
+package actions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ferg-cod3s/openagent/pkg/agent"
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/provider"
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+// Config configures the built-in actions. Actions whose dependencies are
+// missing, such as llm.complete without a provider, are still registered
+// and fail when run.
+type Config struct {
+	// Providers are the LLM providers by name, as chosen by the provider
+	// input of llm.complete and agent.run.
+	Providers map[string]provider.Provider
+	// DefaultProvider names the provider used when a step does not choose
+	// one. Empty uses the only provider, if there is exactly one.
+	DefaultProvider string
+	// Model is the default model.
+	Model string
+	// Agents are named agent configurations for agent.run.
+	Agents map[string]agent.Config
+	// Memory backs memory.save and memory.search.
+	Memory memory.Store
+	// Root is the directory file, shell and git actions work in; paths
+	// may not lead outside it, including through symbolic links. Empty
+	// uses the current directory.
+	Root string
+	// Shell configures shell.exec.
+	Shell ShellConfig
+	// HTTPClient sends http.request requests. Nil uses http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// HasAgent reports whether Agents defines name, so that a Config can be
+// used as a workflow.AgentRegistry.
+func (c Config) HasAgent(name string) bool {
+	_, ok := c.Agents[name]
+	return ok
+}
+
+// Schema documents an action's inputs and outputs.
+type Schema struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Inputs      []Field `json:"inputs"`
+	Outputs     []Field `json:"outputs"`
+}
+
+// Field is one input or output of an action. Type is a JSON Schema type:
+// string, integer, number, boolean, object or array, or any.
+type Field struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description"`
+}
+
+// builtin is a built-in action and its schema.
+type builtin struct {
+	schema Schema
+	run    func(l *library, ctx context.Context, in args) (map[string]interface{}, error)
+}
+
+var builtins = []builtin{
+	{llmCompleteSchema, (*library).llmComplete},
+	{agentRunSchema, (*library).agentRun},
+	{shellExecSchema, (*library).shellExec},
+	{httpRequestSchema, (*library).httpRequest},
+	{fileReadSchema, (*library).fileRead},
+	{fileWriteSchema, (*library).fileWrite},
+	{fileGlobSchema, (*library).fileGlob},
+	{jsonQuerySchema, (*library).jsonQuery},
+	{memorySaveSchema, (*library).memorySave},
+	{memorySearchSchema, (*library).memorySearch},
+	{gitStatusSchema, (*library).gitStatus},
+	{gitDiffSchema, (*library).gitDiff},
+	{gitCommitSchema, (*library).gitCommit},
+}
+
+// Schemas returns the schemas of the built-in actions, sorted by name.
+func Schemas() []Schema {
+	schemas := make([]Schema, len(builtins))
+	for i, b := range builtins {
+		schemas[i] = b.schema
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas
+}
+
+// Lookup returns the schema of the named built-in action.
+func Lookup(name string) (Schema, bool) {
+	for _, b := range builtins {
+		if b.schema.Name == name {
+			return b.schema, true
+		}
+	}
+	return Schema{}, false
+}
+
+// library holds the resolved configuration shared by the actions.
+type library struct {
+	cfg  Config
+	root string
+}
+
+// Register registers the built-in actions on e.
+func Register(e workflow.Engine, cfg Config) error {
+	root := cfg.Root
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("resolve root: %w", err)
+	}
+	l := &library{cfg: cfg, root: root}
+	for _, b := range builtins {
+		b := b
+		e.RegisterAction(b.schema.Name, func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+			for _, f := range b.schema.Inputs {
+				if f.Required && inputs[f.Name] == nil {
+					return nil, workflow.Classify(fmt.Errorf("%s: missing required input %q", b.schema.Name, f.Name), workflow.ErrorClassPermanent)
+				}
+			}
+			return b.run(l, ctx, args(inputs))
+		})
+	}
+	return nil
+}
+
+// path resolves p against the root and checks that it stays inside it,
+// both as written and with the symbolic links along it followed.
+func (l *library) path(p string) (string, error) {
+	if p == "" {
+		return l.root, nil
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(l.root, p)
+	}
+	p = filepath.Clean(p)
+	outside := workflow.Classify(fmt.Errorf("path %q is outside %s", p, l.root), workflow.ErrorClassPermanent)
+	if !within(l.root, p) {
+		return "", outside
+	}
+	root, err := realPath(l.root)
+	if err != nil {
+		return "", fmt.Errorf("resolve root: %w", err)
+	}
+	real, err := realPath(p)
+	if err != nil {
+		return "", workflow.Classify(fmt.Errorf("resolve %q: %w", p, err), workflow.ErrorClassPermanent)
+	}
+	if !within(root, real) {
+		return "", outside
+	}
+	return p, nil
+}
+
+// within reports whether p is dir or lies under it.
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realPath follows the symbolic links of the longest existing prefix of p
+// and appends the rest, which is yet to be created. A link that points
+// nowhere is an error, since writing through it would create its target.
+func realPath(p string) (string, error) {
+	existing, rest := p, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(real, rest), nil
+}
+
+// rel returns p relative to the root, with forward slashes.
+func (l *library) rel(p string) string {
+	rel, err := filepath.Rel(l.root, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// env returns the process environment with the step's environment and
+// extra variables applied.
+func env(ctx context.Context, base []string, extra map[string]string) []string {
+	out := append([]string(nil), base...)
+	for _, vars := range []map[string]string{workflow.EnvFromContext(ctx), extra} {
+		keys := make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, k+"="+vars[k])
+		}
+	}
+	return out
+}
+
+// hostEnv returns the values of the named host variables.
+func hostEnv(names []string) []string {
+	var out []string
+	for _, name := range names {
+		if v, ok := os.LookupEnv(name); ok {
+			out = append(out, name+"="+v)
+		}
+	}
+	return out
+}
+
+// args are an action's inputs, with typed accessors. Missing inputs yield
+// the zero value or the given default; inputs of the wrong type are
+// permanent errors.
+type args map[string]interface{}
+
+func (a args) invalid(name, want string) error {
+	return workflow.Classify(fmt.Errorf("input %s: expected %s, got %T", name, want, a[name]), workflow.ErrorClassPermanent)
+}
+
+func (a args) string(name string) (string, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case int, int64, float64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", a.invalid(name, "a string")
+}
+
+func (a args) int(name string, def int) (int, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return def, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n, nil
+		}
+	}
+	return 0, a.invalid(name, "an integer")
+}
+
+func (a args) float(name string, def float64) (float64, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return def, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, a.invalid(name, "a number")
+}
+
+func (a args) bool(name string) (bool, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+	return false, a.invalid(name, "a boolean")
+}
+
+// strings accepts a list of scalars or a single string.
+func (a args) strings(name string) ([]string, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		out := make([]string, len(v))
+		for i, e := range v {
+			switch s := e.(type) {
+			case string:
+				out[i] = s
+			case int, int64, float64, bool:
+				out[i] = fmt.Sprint(s)
+			default:
+				return nil, a.invalid(name, "a list of strings")
+			}
+		}
+		return out, nil
+	}
+	return nil, a.invalid(name, "a list of strings")
+}
+
+// stringMap accepts an object of scalars.
+func (a args) stringMap(name string) (map[string]string, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return nil, nil
+	case map[string]string:
+		return v, nil
+	case map[string]interface{}:
+		out := make(map[string]string, len(v))
+		for k, e := range v {
+			switch s := e.(type) {
+			case string:
+				out[k] = s
+			case int, int64, float64, bool:
+				out[k] = fmt.Sprint(s)
+			default:
+				return nil, a.invalid(name, "an object of strings")
+			}
+		}
+		return out, nil
+	}
+	return nil, a.invalid(name, "an object of strings")
+}
+
+func (a args) object(name string) (map[string]interface{}, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return v, nil
+	}
+	return nil, a.invalid(name, "an object")
+}
@@ -0,0 +1,415 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ferg-cod3s/openagent/pkg/agent"
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/memory/memorytest"
+	"github.com/ferg-cod3s/openagent/pkg/provider"
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+// mockProvider answers every request with a fixed response and records
+// the requests.
+type mockProvider struct {
+	mu       sync.Mutex
+	response string
+	requests []*provider.CompletionRequest
+}
+
+func (m *mockProvider) Name() string { return "mock" }
+
+func (m *mockProvider) Complete(ctx context.Context, req *provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
+	return &provider.CompletionResponse{
+		Content: m.response,
+		Model:   req.Model,
+		Usage:   provider.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (m *mockProvider) Stream(ctx context.Context, req *provider.CompletionRequest, handler provider.StreamHandler) error {
+	return errors.New("not implemented")
+}
+
+func (m *mockProvider) Models(ctx context.Context) ([]string, error) {
+	return []string{"mock-1"}, nil
+}
+
+func newEngine(t *testing.T, cfg Config) *workflow.DefaultEngine {
+	t.Helper()
+	e := workflow.NewEngine()
+	if err := Register(e, cfg); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	return e
+}
+
+// run executes one step with the given action and inputs.
+func run(t *testing.T, e *workflow.DefaultEngine, action string, with map[string]interface{}) (map[string]interface{}, error) {
+	t.Helper()
+	res, err := e.ExecuteStep(context.Background(), &workflow.Step{ID: "s", Action: action, With: with}, nil)
+	if res == nil {
+		t.Fatalf("%s: no result", action)
+	}
+	return res.Output, err
+}
+
+func TestSchemas(t *testing.T) {
+	want := []string{
+		"agent.run", "file.glob", "file.read", "file.write", "git.commit", "git.diff", "git.status",
+		"http.request", "json.query", "llm.complete", "memory.save", "memory.search", "shell.exec",
+	}
+	schemas := Schemas()
+	if len(schemas) != len(want) {
+		t.Fatalf("expected %d schemas, got %d", len(want), len(schemas))
+	}
+	for i, s := range schemas {
+		if s.Name != want[i] {
+			t.Errorf("schema %d: expected %s, got %s", i, want[i], s.Name)
+		}
+		if s.Description == "" || len(s.Inputs) == 0 || len(s.Outputs) == 0 {
+			t.Errorf("%s: incomplete schema", s.Name)
+		}
+		for _, f := range append(s.Inputs, s.Outputs...) {
+			if f.Name == "" || f.Type == "" || f.Description == "" {
+				t.Errorf("%s: incomplete field %+v", s.Name, f)
+			}
+		}
+	}
+	if _, ok := Lookup("file.read"); !ok {
+		t.Error("expected to find file.read")
+	}
+
+	// Required inputs are checked before running.
+	e := newEngine(t, Config{Root: t.TempDir()})
+	_, err := run(t, e, "file.read", nil)
+	if err == nil || !strings.Contains(err.Error(), `missing required input "path"`) || workflow.ErrorClass(err) != workflow.ErrorClassPermanent {
+		t.Errorf("expected permanent missing input error, got %v", err)
+	}
+	_, err = run(t, e, "llm.complete", map[string]interface{}{"prompt": "hi", "max_tokens": "many"})
+	if err == nil || !strings.Contains(err.Error(), "input max_tokens: expected an integer") {
+		t.Errorf("expected input type error, got %v", err)
+	}
+}
+
+func TestLLMActions(t *testing.T) {
+	p := &mockProvider{response: "looks good"}
+	e := newEngine(t, Config{
+		Providers: map[string]provider.Provider{"mock": p},
+		Model:     "mock-1",
+		Agents:    map[string]agent.Config{"reviewer": {SystemPrompt: "You review code.", Model: "mock-2"}},
+	})
+
+	out, err := run(t, e, "llm.complete", map[string]interface{}{"prompt": "review", "system": "be brief", "max_tokens": 100})
+	if err != nil {
+		t.Fatalf("llm.complete failed: %v", err)
+	}
+	if out["content"] != "looks good" || out["model"] != "mock-1" || out["usage"].(map[string]interface{})["total_tokens"] != 15 {
+		t.Errorf("unexpected output %v", out)
+	}
+	req := p.requests[0]
+	if len(req.Messages) != 2 || req.Messages[0].Content != "be brief" || req.MaxTokens != 100 {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	out, err = run(t, e, "agent.run", map[string]interface{}{"agent": "reviewer", "prompt": "review main.go"})
+	if err != nil {
+		t.Fatalf("agent.run failed: %v", err)
+	}
+	if out["output"] != "looks good" || len(out["messages"].([]interface{})) != 2 {
+		t.Errorf("unexpected output %v", out)
+	}
+	req = p.requests[1]
+	if req.Model != "mock-2" || req.Messages[0].Content != "You review code." {
+		t.Errorf("expected the named agent's config, got %+v", req)
+	}
+
+	if _, err := run(t, e, "agent.run", map[string]interface{}{"agent": "nobody", "prompt": "x"}); err == nil || !strings.Contains(err.Error(), "agent not found") {
+		t.Errorf("expected unknown agent error, got %v", err)
+	}
+	if _, err := run(t, e, "llm.complete", map[string]interface{}{"prompt": "x", "provider": "other"}); err == nil || !strings.Contains(err.Error(), "provider not found: other") {
+		t.Errorf("expected unknown provider error, got %v", err)
+	}
+}
+
+func TestAgentSessions(t *testing.T) {
+	p := &mockProvider{response: "done"}
+	e := newEngine(t, Config{Providers: map[string]provider.Provider{"mock": p}})
+	w, err := workflow.NewParser().Parse([]byte(`
+name: pair
+version: "1"
+steps:
+  - id: first
+    name: First
+    type: agent
+    agent:
+      system_prompt: You pair program.
+      timeout: 1m
+      policy: {deny: [shell.exec]}
+    prompt: Write the test.
+    session: pair
+  - id: second
+    name: Second
+    type: agent
+    depends_on: [first]
+    prompt: "Now make it pass; you said ${{ steps.first.output.output }}."
+    session: pair
+  - id: alone
+    name: Alone
+    type: agent
+    depends_on: [second]
+    prompt: Start over.
+`))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	result, err := e.Execute(context.Background(), w)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	// The second step continues the first step's conversation, with its
+	// agent; the third starts a new one.
+	if n := len(result.Steps[1].Output["messages"].([]interface{})); n != 4 {
+		t.Errorf("expected the session's 4 messages, got %d", n)
+	}
+	if n := len(result.Steps[2].Output["messages"].([]interface{})); n != 2 {
+		t.Errorf("expected a new conversation, got %d messages", n)
+	}
+	req := p.requests[1]
+	if len(req.Messages) != 4 || req.Messages[0].Content != "You pair program." || req.Messages[3].Content != "Now make it pass; you said done." {
+		t.Errorf("unexpected second request %+v", req.Messages)
+	}
+	if req := p.requests[2]; len(req.Messages) != 1 {
+		t.Errorf("expected no history in the third request, got %+v", req.Messages)
+	}
+
+	for _, with := range []map[string]interface{}{
+		{"prompt": "x", "timeout": "soon"},
+		{"prompt": "x", "policy": map[string]interface{}{"restrictive": true, "deny": []interface{}{"a"}}},
+		{"prompt": "x", "policy": "strict"},
+	} {
+		if _, err := run(t, e, "agent.run", with); err == nil || workflow.ErrorClass(err) != workflow.ErrorClassPermanent {
+			t.Errorf("%v: expected permanent error, got %v", with, err)
+		}
+	}
+}
+
+func TestMemoryActions(t *testing.T) {
+	stores := map[string]memory.Store{
+		"keyword": memory.NewInMemoryStore(),
+		"vector":  memory.NewInMemoryVectorStore(memorytest.NewHashEmbedder(64)),
+	}
+	for name, store := range stores {
+		e := newEngine(t, Config{Memory: store})
+		for _, content := range []string{"retry flaky llm calls with backoff", "the build uses go 1.21", "deploys happen on fridays"} {
+			if _, err := run(t, e, "memory.save", map[string]interface{}{"content": content, "tags": []interface{}{"notes"}, "type": "semantic"}); err != nil {
+				t.Fatalf("%s: memory.save failed: %v", name, err)
+			}
+		}
+		out, err := run(t, e, "memory.save", map[string]interface{}{"id": "fixed", "content": "go build tags"})
+		if err != nil || out["id"] != "fixed" {
+			t.Fatalf("%s: expected id fixed, got %v, %v", name, out, err)
+		}
+
+		out, err = run(t, e, "memory.search", map[string]interface{}{"query": "flaky llm backoff", "limit": 2, "type": "semantic"})
+		if err != nil {
+			t.Fatalf("%s: memory.search failed: %v", name, err)
+		}
+		memories := out["memories"].([]interface{})
+		if len(memories) == 0 || out["count"] != len(memories) || len(memories) > 2 {
+			t.Fatalf("%s: unexpected results %v", name, out)
+		}
+		first := memories[0].(map[string]interface{})
+		if first["content"] != "retry flaky llm calls with backoff" || first["type"] != "semantic" {
+			t.Errorf("%s: unexpected best match %v", name, first)
+		}
+		if tags := first["metadata"].(map[string]interface{})[MetaTags].([]interface{}); tags[0] != "notes" {
+			t.Errorf("%s: expected tags, got %v", name, tags)
+		}
+	}
+
+	e := newEngine(t, Config{})
+	if _, err := run(t, e, "memory.search", map[string]interface{}{"query": "x"}); err == nil || !strings.Contains(err.Error(), "no memory store") {
+		t.Errorf("expected missing store error, got %v", err)
+	}
+}
+
+func TestJSONQuery(t *testing.T) {
+	e := newEngine(t, Config{})
+	data := `{"items": [{"name": "a", "tags": ["x"]}, {"name": "b"}], "total": 2}`
+	tests := []struct {
+		data  interface{}
+		query string
+		want  string
+		found bool
+	}{
+		{data, "total", "2", true},
+		{data, ".items[1].name", "b", true},
+		{data, "items[-1].name", "b", true},
+		{data, "items[*].name", "[a b]", true},
+		{data, "items[0].tags[0]", "x", true},
+		{data, "items[5]", "<nil>", false},
+		{data, "missing.field", "<nil>", false},
+		{map[string]interface{}{"files": []string{"a.go"}}, "files[0]", "a.go", true},
+	}
+	for _, tt := range tests {
+		out, err := run(t, e, "json.query", map[string]interface{}{"data": tt.data, "query": tt.query})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if got := fmt.Sprint(out["result"]); got != tt.want || out["found"] != tt.found {
+			t.Errorf("%s: expected %s (%v), got %s (%v)", tt.query, tt.want, tt.found, got, out["found"])
+		}
+	}
+	if _, err := run(t, e, "json.query", map[string]interface{}{"data": "{not json", "query": "a"}); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestHTTPRequest(t *testing.T) {
+	var gotBody, gotQuery, gotHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/busy" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		buf := new(strings.Builder)
+		_, _ = io.Copy(buf, r.Body)
+		gotBody, gotQuery, gotHeader = buf.String(), r.URL.Query().Get("q"), r.Header.Get("X-Token")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+
+	e := newEngine(t, Config{})
+	out, err := run(t, e, "http.request", map[string]interface{}{
+		"url": srv.URL + "/api", "method": "post",
+		"headers": map[string]interface{}{"X-Token": "secret"},
+		"query":   map[string]interface{}{"q": "go"},
+		"body":    map[string]interface{}{"n": 1},
+	})
+	if err != nil {
+		t.Fatalf("http.request failed: %v", err)
+	}
+	if out["status"] != 200 || out["json"].(map[string]interface{})["ok"] != true {
+		t.Errorf("unexpected output %v", out)
+	}
+	if gotBody != `{"n":1}` || gotQuery != "go" || gotHeader != "secret" {
+		t.Errorf("unexpected request body %q, query %q, header %q", gotBody, gotQuery, gotHeader)
+	}
+
+	out, err = run(t, e, "http.request", map[string]interface{}{"url": srv.URL + "/busy"})
+	if err == nil || workflow.ErrorClass(err) != workflow.ErrorClassTransient || out["status"] != 503 {
+		t.Errorf("expected transient error with output, got %v, %v", out, err)
+	}
+	if _, err := run(t, e, "http.request", map[string]interface{}{"url": srv.URL + "/busy", "allow_failure": true}); err != nil {
+		t.Errorf("expected allow_failure to succeed, got %v", err)
+	}
+}
+
+// TestWorkflowYAML runs the README example workflow with the built-in
+// actions.
+func TestWorkflowYAML(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"main.go": "package main\n", "pkg/util.go": "package pkg\n", "README.md": "# demo\n"} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := workflow.NewParser().Parse([]byte(`
+name: review
+version: "1"
+steps:
+  - id: files
+    name: Find Go files
+    action: file.glob
+    with:
+      pattern: "**/*.go"
+  - id: review
+    name: Review each file
+    type: loop
+    depends_on: [files]
+    for_each: ${{ steps.files.output.files }}
+    as: file
+    steps:
+      - id: read
+        name: Read
+        action: file.read
+        with:
+          path: ${{ loop.file }}
+      - id: ask
+        name: Ask
+        action: llm.complete
+        depends_on: [read]
+        with:
+          system: You are a careful code reviewer.
+          prompt: "Review ${{ loop.file }}:\n${{ steps.read.output.content }}"
+  - id: report
+    name: Write report
+    action: file.write
+    depends_on: [review]
+    with:
+      path: review.json
+      content: ${{ steps.review.output.iterations }}
+  - id: count
+    name: Count lines
+    action: shell.exec
+    depends_on: [report]
+    with:
+      command: wc -l < review.json
+  - id: remember
+    name: Remember
+    action: memory.save
+    depends_on: [review]
+    with:
+      content: "Reviewed ${{ steps.files.output.count }} files"
+      tags: [review]
+`))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if err := workflow.NewValidator().Validate(w); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+
+	store := memory.NewInMemoryStore()
+	e := newEngine(t, Config{Root: root, Memory: store, Providers: map[string]provider.Provider{"mock": &mockProvider{response: "LGTM"}}})
+	result, err := e.Execute(context.Background(), w)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got := result.Steps[1].Output["count"]; got != 2 {
+		t.Errorf("expected 2 reviews, got %v", got)
+	}
+	report, err := os.ReadFile(filepath.Join(root, "review.json"))
+	if err != nil || !strings.Contains(string(report), "LGTM") {
+		t.Errorf("expected report with reviews, got %q, %v", report, err)
+	}
+	if lines := strings.TrimSpace(result.Steps[3].Output["stdout"].(string)); lines == "0" {
+		t.Error("expected line count of the report")
+	}
+	saved, _ := store.List(context.Background(), nil)
+	if len(saved) != 1 || saved[0].Content != "Reviewed 2 files" {
+		t.Errorf("unexpected memories %v", saved)
+	}
+}
@@ -0,0 +1,177 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+var fileReadSchema = Schema{
+	Name:        "file.read",
+	Description: "Reads a file under the workflow root.",
+	Inputs: []Field{
+		{Name: "path", Type: "string", Required: true, Description: "File path, relative to the root."},
+	},
+	Outputs: []Field{
+		{Name: "content", Type: "string", Description: "File content."},
+		{Name: "path", Type: "string", Description: "Path relative to the root."},
+		{Name: "size", Type: "integer", Description: "Size in bytes."},
+	},
+}
+
+var fileWriteSchema = Schema{
+	Name:        "file.write",
+	Description: "Writes a file under the workflow root, creating parent directories.",
+	Inputs: []Field{
+		{Name: "path", Type: "string", Required: true, Description: "File path, relative to the root."},
+		{Name: "content", Type: "any", Required: true, Description: "Content; values other than strings are written as indented JSON."},
+		{Name: "append", Type: "boolean", Description: "Append instead of replacing the file."},
+	},
+	Outputs: []Field{
+		{Name: "path", Type: "string", Description: "Path relative to the root."},
+		{Name: "bytes", Type: "integer", Description: "Number of bytes written."},
+	},
+}
+
+var fileGlobSchema = Schema{
+	Name:        "file.glob",
+	Description: "Lists files under the workflow root matching a pattern. ** matches any number of directories; .git is skipped.",
+	Inputs: []Field{
+		{Name: "pattern", Type: "string", Required: true, Description: "Slash-separated pattern, such as pkg/**/*.go."},
+	},
+	Outputs: []Field{
+		{Name: "files", Type: "array", Description: "Matching file paths relative to the root, sorted."},
+		{Name: "count", Type: "integer", Description: "Number of matches."},
+	},
+}
+
+func (l *library) fileRead(ctx context.Context, in args) (map[string]interface{}, error) {
+	p, err := in.string("path")
+	if err != nil {
+		return nil, err
+	}
+	if p, err = l.path(p); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, workflow.Classify(fmt.Errorf("file.read: %w", err), workflow.ErrorClassPermanent)
+	}
+	return map[string]interface{}{"content": string(data), "path": l.rel(p), "size": len(data)}, nil
+}
+
+func (l *library) fileWrite(ctx context.Context, in args) (map[string]interface{}, error) {
+	p, err := in.string("path")
+	if err != nil {
+		return nil, err
+	}
+	if p, err = l.path(p); err != nil {
+		return nil, err
+	}
+	appendMode, err := in.bool("append")
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	switch c := in["content"].(type) {
+	case string:
+		data = []byte(c)
+	default:
+		if data, err = json.MarshalIndent(c, "", "  "); err != nil {
+			return nil, workflow.Classify(fmt.Errorf("input content: %w", err), workflow.ErrorClassPermanent)
+		}
+		data = append(data, '\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, fmt.Errorf("file.write: %w", err)
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(p, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("file.write: %w", err)
+	}
+	n, err := f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file.write: %w", err)
+	}
+	return map[string]interface{}{"path": l.rel(p), "bytes": n}, nil
+}
+
+func (l *library) fileGlob(ctx context.Context, in args) (map[string]interface{}, error) {
+	pattern, err := in.string("pattern")
+	if err != nil {
+		return nil, err
+	}
+	pattern = strings.TrimPrefix(path.Clean(filepath.ToSlash(pattern)), "./")
+	if strings.HasPrefix(pattern, "../") || path.IsAbs(pattern) {
+		return nil, workflow.Classify(fmt.Errorf("file.glob: pattern %q must be relative to the root", pattern), workflow.ErrorClassPermanent)
+	}
+	segments := strings.Split(pattern, "/")
+	for _, s := range segments {
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, workflow.Classify(fmt.Errorf("file.glob: %w", err), workflow.ErrorClassPermanent)
+		}
+	}
+
+	files := []interface{}{}
+	err = filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel := l.rel(p)
+		if globMatch(segments, strings.Split(rel, "/")) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("file.glob: %w", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].(string) < files[j].(string) })
+	return map[string]interface{}{"files": files, "count": len(files)}, nil
+}
+
+// globMatch matches path segments against pattern segments, where a **
+// segment matches any number of path segments.
+func globMatch(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if globMatch(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && globMatch(pattern[1:], name[1:])
+}
@@ -0,0 +1,130 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileActions(t *testing.T) {
+	root := t.TempDir()
+	e := newEngine(t, Config{Root: root})
+
+	out, err := run(t, e, "file.write", map[string]interface{}{"path": "out/notes.txt", "content": "first\n"})
+	if err != nil {
+		t.Fatalf("file.write failed: %v", err)
+	}
+	if out["path"] != "out/notes.txt" || out["bytes"] != 6 {
+		t.Errorf("unexpected output %v", out)
+	}
+	if _, err := run(t, e, "file.write", map[string]interface{}{"path": "out/notes.txt", "content": "second\n", "append": true}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	out, err = run(t, e, "file.read", map[string]interface{}{"path": filepath.Join(root, "out/notes.txt")})
+	if err != nil {
+		t.Fatalf("file.read failed: %v", err)
+	}
+	if out["content"] != "first\nsecond\n" || out["size"] != 13 || out["path"] != "out/notes.txt" {
+		t.Errorf("unexpected output %v", out)
+	}
+
+	// Non-string content is written as JSON.
+	if _, err := run(t, e, "file.write", map[string]interface{}{"path": "data.json", "content": map[string]interface{}{"ok": true}}); err != nil {
+		t.Fatalf("file.write failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "data.json")); string(data) != "{\n  \"ok\": true\n}\n" {
+		t.Errorf("unexpected JSON file %q", data)
+	}
+
+	for _, p := range []string{"../escape.txt", "/etc/passwd", "out/../../x"} {
+		if _, err := run(t, e, "file.read", map[string]interface{}{"path": p}); err == nil || !strings.Contains(err.Error(), "is outside") {
+			t.Errorf("%s: expected outside root error, got %v", p, err)
+		}
+	}
+	if _, err := run(t, e, "file.read", map[string]interface{}{"path": "missing.txt"}); err == nil {
+		t.Error("expected missing file error")
+	}
+}
+
+func TestFileSymlinks(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "real"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"escape":   outside,
+		"dangling": filepath.Join(outside, "new.txt"),
+		"inside":   filepath.Join(root, "real"),
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+	e := newEngine(t, Config{Root: root})
+
+	// Links may not lead outside the root, for reads or writes.
+	if _, err := run(t, e, "file.read", map[string]interface{}{"path": "escape/secret.txt"}); err == nil || !strings.Contains(err.Error(), "is outside") {
+		t.Errorf("expected outside root error, got %v", err)
+	}
+	for _, p := range []string{"escape/new.txt", "escape/sub/new.txt", "dangling"} {
+		if _, err := run(t, e, "file.write", map[string]interface{}{"path": p, "content": "x"}); err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 1 {
+		t.Errorf("expected nothing written outside the root, got %d entries", len(entries))
+	}
+
+	// Links inside the root are followed.
+	if _, err := run(t, e, "file.write", map[string]interface{}{"path": "inside/notes.txt", "content": "x"}); err != nil {
+		t.Fatalf("file.write failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "real", "notes.txt")); string(data) != "x" {
+		t.Errorf("unexpected file %q", data)
+	}
+}
+
+func TestFileGlob(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"main.go", "main_test.go", "pkg/a/a.go", "pkg/a/b/b.go", "pkg/readme.md", ".git/config.go"} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e := newEngine(t, Config{Root: root})
+
+	tests := []struct {
+		pattern string
+		want    []interface{}
+	}{
+		{"*.go", []interface{}{"main.go", "main_test.go"}},
+		{"**/*.go", []interface{}{"main.go", "main_test.go", "pkg/a/a.go", "pkg/a/b/b.go"}},
+		{"pkg/**", []interface{}{"pkg/a/a.go", "pkg/a/b/b.go", "pkg/readme.md"}},
+		{"./pkg/*/*.go", []interface{}{"pkg/a/a.go"}},
+		{"*.rs", []interface{}{}},
+	}
+	for _, tt := range tests {
+		out, err := run(t, e, "file.glob", map[string]interface{}{"pattern": tt.pattern})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.pattern, err)
+		}
+		if !reflect.DeepEqual(out["files"], tt.want) || out["count"] != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.pattern, tt.want, out["files"])
+		}
+	}
+	if _, err := run(t, e, "file.glob", map[string]interface{}{"pattern": "../*"}); err == nil {
+		t.Error("expected error for a pattern outside the root")
+	}
+	if _, err := run(t, e, "file.glob", map[string]interface{}{"pattern": "[a"}); err == nil {
+		t.Error("expected error for a malformed pattern")
+	}
+}
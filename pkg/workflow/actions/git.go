@@ -0,0 +1,195 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+var gitStatusSchema = Schema{
+	Name:        "git.status",
+	Description: "Reports the branch and changed files of a git working tree.",
+	Inputs: []Field{
+		{Name: "dir", Type: "string", Description: "Repository directory, relative to the root."},
+	},
+	Outputs: []Field{
+		{Name: "branch", Type: "string", Description: "Current branch."},
+		{Name: "clean", Type: "boolean", Description: "Whether there are no changes."},
+		{Name: "files", Type: "array", Description: "Changed files as objects with path and status, the two-letter porcelain code."},
+	},
+}
+
+var gitDiffSchema = Schema{
+	Name:        "git.diff",
+	Description: "Shows changes in a git working tree.",
+	Inputs: []Field{
+		{Name: "dir", Type: "string", Description: "Repository directory, relative to the root."},
+		{Name: "staged", Type: "boolean", Description: "Diff the index instead of the working tree."},
+		{Name: "ref", Type: "string", Description: "Commit or range to diff against, such as main or HEAD~1."},
+		{Name: "paths", Type: "array", Description: "Limit the diff to these paths."},
+	},
+	Outputs: []Field{
+		{Name: "diff", Type: "string", Description: "Unified diff."},
+		{Name: "files", Type: "array", Description: "Changed file paths."},
+	},
+}
+
+var gitCommitSchema = Schema{
+	Name:        "git.commit",
+	Description: "Stages changes and commits them.",
+	Inputs: []Field{
+		{Name: "message", Type: "string", Required: true, Description: "Commit message."},
+		{Name: "dir", Type: "string", Description: "Repository directory, relative to the root."},
+		{Name: "paths", Type: "array", Description: "Paths to stage; all changes if empty."},
+		{Name: "allow_empty", Type: "boolean", Description: "Commit even if nothing changed."},
+	},
+	Outputs: []Field{
+		{Name: "commit", Type: "string", Description: "Hash of the new commit."},
+		{Name: "output", Type: "string", Description: "Output of git commit."},
+	},
+}
+
+// git runs a git command in the repository directory given by the dir
+// input and returns its standard output.
+func (l *library) git(ctx context.Context, in args, gitArgs ...string) (string, error) {
+	d, err := in.string("dir")
+	if err != nil {
+		return "", err
+	}
+	dir, err := l.path(d)
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", gitArgs...)
+	cmd.Dir = dir
+	cmd.Env = env(ctx, os.Environ(), nil)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		return "", fmt.Errorf("git %s: %w: %s", gitArgs[0], err, msg)
+	}
+	return stdout.String(), nil
+}
+
+func (l *library) gitStatus(ctx context.Context, in args) (map[string]interface{}, error) {
+	out, err := l.git(ctx, in, "status", "--porcelain=v1", "--branch")
+	if err != nil {
+		return nil, err
+	}
+	branch := ""
+	files := []interface{}{}
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "## "):
+			branch = strings.TrimPrefix(line, "## ")
+			branch = strings.TrimPrefix(branch, "No commits yet on ")
+			if i := strings.Index(branch, "..."); i >= 0 {
+				branch = branch[:i]
+			}
+			if i := strings.IndexByte(branch, ' '); i >= 0 {
+				branch = branch[:i]
+			}
+		case len(line) > 3:
+			path := line[3:]
+			if i := strings.Index(path, " -> "); i >= 0 {
+				path = path[i+4:]
+			}
+			files = append(files, map[string]interface{}{"path": path, "status": line[:2]})
+		}
+	}
+	return map[string]interface{}{"branch": branch, "clean": len(files) == 0, "files": files}, nil
+}
+
+func (l *library) gitDiff(ctx context.Context, in args) (map[string]interface{}, error) {
+	staged, err := in.bool("staged")
+	if err != nil {
+		return nil, err
+	}
+	ref, err := in.string("ref")
+	if err != nil {
+		return nil, err
+	}
+	// git would read such a ref as an option, which could write outside
+	// the root (--output) or change what runs.
+	if strings.HasPrefix(ref, "-") {
+		return nil, workflow.Classify(fmt.Errorf("input ref: %q is not a ref", ref), workflow.ErrorClassPermanent)
+	}
+	paths, err := in.strings("paths")
+	if err != nil {
+		return nil, err
+	}
+	diffArgs := func(extra ...string) []string {
+		a := append([]string{"diff"}, extra...)
+		if staged {
+			a = append(a, "--cached")
+		}
+		if ref != "" {
+			a = append(a, ref)
+		}
+		return append(append(a, "--"), paths...)
+	}
+	diff, err := l.git(ctx, in, diffArgs()...)
+	if err != nil {
+		return nil, err
+	}
+	names, err := l.git(ctx, in, diffArgs("--name-only")...)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"diff": diff, "files": lines(names)}, nil
+}
+
+func (l *library) gitCommit(ctx context.Context, in args) (map[string]interface{}, error) {
+	message, err := in.string("message")
+	if err != nil {
+		return nil, err
+	}
+	paths, err := in.strings("paths")
+	if err != nil {
+		return nil, err
+	}
+	allowEmpty, err := in.bool("allow_empty")
+	if err != nil {
+		return nil, err
+	}
+	add := []string{"add", "-A"}
+	if len(paths) > 0 {
+		add = append(append(add, "--"), paths...)
+	}
+	if _, err := l.git(ctx, in, add...); err != nil {
+		return nil, err
+	}
+	commit := []string{"commit", "-m", message}
+	if allowEmpty {
+		commit = append(commit, "--allow-empty")
+	}
+	out, err := l.git(ctx, in, commit...)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := l.git(ctx, in, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"commit": strings.TrimSpace(hash), "output": out}, nil
+}
+
+// lines splits output into its non-empty lines.
+func lines(s string) []interface{} {
+	out := []interface{}{}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
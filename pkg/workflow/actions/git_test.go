@@ -0,0 +1,91 @@
+package actions
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitActions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	if out, err := exec.Command("git", "init", "-q", "-b", "main", root).CombinedOutput(); err != nil {
+		t.Skipf("git init failed: %s", out)
+	}
+	e := newEngine(t, Config{Root: root})
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("a.txt", "one\n")
+	out, err := run(t, e, "git.status", nil)
+	if err != nil {
+		t.Fatalf("git.status failed: %v", err)
+	}
+	files := out["files"].([]interface{})
+	if out["branch"] != "main" || out["clean"] != false || len(files) != 1 {
+		t.Fatalf("unexpected status %v", out)
+	}
+	if f := files[0].(map[string]interface{}); f["path"] != "a.txt" || f["status"] != "??" {
+		t.Errorf("unexpected file %v", f)
+	}
+
+	out, err = run(t, e, "git.commit", map[string]interface{}{"message": "Add a"})
+	if err != nil {
+		t.Fatalf("git.commit failed: %v", err)
+	}
+	if len(out["commit"].(string)) != 40 {
+		t.Errorf("expected a commit hash, got %v", out["commit"])
+	}
+	if out, _ := run(t, e, "git.status", nil); out["clean"] != true {
+		t.Errorf("expected clean tree, got %v", out)
+	}
+
+	write("a.txt", "one\ntwo\n")
+	write("b.txt", "b\n")
+	out, err = run(t, e, "git.diff", nil)
+	if err != nil {
+		t.Fatalf("git.diff failed: %v", err)
+	}
+	if !strings.Contains(out["diff"].(string), "+two") || len(out["files"].([]interface{})) != 1 {
+		t.Errorf("unexpected diff %v", out)
+	}
+
+	// Only the given paths are committed.
+	if _, err := run(t, e, "git.commit", map[string]interface{}{"message": "Update a", "paths": []interface{}{"a.txt"}}); err != nil {
+		t.Fatalf("git.commit failed: %v", err)
+	}
+	out, _ = run(t, e, "git.diff", map[string]interface{}{"ref": "HEAD~1", "paths": []interface{}{"a.txt"}})
+	if !strings.Contains(out["diff"].(string), "+two") {
+		t.Errorf("expected diff against the previous commit, got %v", out)
+	}
+	out, _ = run(t, e, "git.status", nil)
+	if files := out["files"].([]interface{}); len(files) != 1 || files[0].(map[string]interface{})["path"] != "b.txt" {
+		t.Errorf("expected b.txt to stay uncommitted, got %v", out)
+	}
+
+	if _, err := run(t, e, "git.commit", map[string]interface{}{"message": "Nothing", "paths": []interface{}{"a.txt"}}); err == nil || !strings.Contains(err.Error(), "git commit") {
+		t.Errorf("expected commit error with nothing staged, got %v", err)
+	}
+
+	// A ref cannot pass options to git, such as one writing outside the
+	// root.
+	outside := filepath.Join(t.TempDir(), "out.diff")
+	_, err = run(t, e, "git.diff", map[string]interface{}{"ref": "--output=" + outside})
+	if err == nil || !strings.Contains(err.Error(), "is not a ref") {
+		t.Errorf("expected ref error, got %v", err)
+	}
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Errorf("expected no file outside the root, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+// MaxResponseBytes caps the response body read by http.request.
+const MaxResponseBytes = 10 << 20
+
+var httpRequestSchema = Schema{
+	Name:        "http.request",
+	Description: "Sends an HTTP request. Responses with status 400 or above fail the step unless allow_failure is set; 429 and 5xx are retryable.",
+	Inputs: []Field{
+		{Name: "url", Type: "string", Required: true, Description: "Request URL."},
+		{Name: "method", Type: "string", Description: "HTTP method, GET by default."},
+		{Name: "headers", Type: "object", Description: "Request headers."},
+		{Name: "query", Type: "object", Description: "Query parameters added to the URL."},
+		{Name: "body", Type: "any", Description: "Request body; values other than strings are sent as JSON."},
+		{Name: "timeout", Type: "string", Description: "Duration bounding the request, such as 30s."},
+		{Name: "allow_failure", Type: "boolean", Description: "Succeed whatever the response status."},
+	},
+	Outputs: []Field{
+		{Name: "status", Type: "integer", Description: "Response status code."},
+		{Name: "headers", Type: "object", Description: "Response headers, first value of each."},
+		{Name: "body", Type: "string", Description: "Response body."},
+		{Name: "json", Type: "any", Description: "Response body parsed as JSON, if the response is JSON."},
+	},
+}
+
+func (l *library) httpRequest(ctx context.Context, in args) (map[string]interface{}, error) {
+	rawURL, err := in.string("url")
+	if err != nil {
+		return nil, err
+	}
+	method, err := in.string("method")
+	if err != nil {
+		return nil, err
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	headers, err := in.stringMap("headers")
+	if err != nil {
+		return nil, err
+	}
+	query, err := in.stringMap("query")
+	if err != nil {
+		return nil, err
+	}
+	allowFailure, err := in.bool("allow_failure")
+	if err != nil {
+		return nil, err
+	}
+	if t, err := in.string("timeout"); err != nil {
+		return nil, err
+	} else if t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, workflow.Classify(fmt.Errorf("input timeout: %w", err), workflow.ErrorClassPermanent)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, workflow.Classify(fmt.Errorf("input url: %w", err), workflow.ErrorClassPermanent)
+	}
+	if len(query) > 0 {
+		q := u.Query()
+		for k, v := range query {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch b := in["body"].(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, workflow.Classify(fmt.Errorf("input body: %w", err), workflow.ErrorClassPermanent)
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), u.String(), body)
+	if err != nil {
+		return nil, workflow.Classify(fmt.Errorf("http.request: %w", err), workflow.ErrorClassPermanent)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := l.cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http.request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("http.request: read response: %w", err)
+	}
+
+	respHeaders := make(map[string]interface{}, len(resp.Header))
+	for k := range resp.Header {
+		respHeaders[k] = resp.Header.Get(k)
+	}
+	out := map[string]interface{}{
+		"status":  resp.StatusCode,
+		"headers": respHeaders,
+		"body":    string(data),
+	}
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt == "application/json" || strings.HasSuffix(mt, "+json") {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err == nil {
+			out["json"] = v
+		}
+	}
+	if resp.StatusCode >= 400 && !allowFailure {
+		// The status format lets workflow.ErrorClass classify the error.
+		return out, fmt.Errorf("http error (status %d): %s", resp.StatusCode, truncate(string(data), 200))
+	}
+	return out, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
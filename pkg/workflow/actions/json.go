@@ -0,0 +1,136 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+var jsonQuerySchema = Schema{
+	Name:        "json.query",
+	Description: "Extracts a value from JSON data with a path such as items[0].name; [*] maps the rest of the path over a list.",
+	Inputs: []Field{
+		{Name: "data", Type: "any", Required: true, Description: "Data to query; a string is parsed as JSON."},
+		{Name: "query", Type: "string", Description: "Path into the data; empty or . selects all of it."},
+	},
+	Outputs: []Field{
+		{Name: "result", Type: "any", Description: "Selected value, or null if not found."},
+		{Name: "found", Type: "boolean", Description: "Whether the path exists."},
+	},
+}
+
+func (l *library) jsonQuery(ctx context.Context, in args) (map[string]interface{}, error) {
+	query, err := in.string("query")
+	if err != nil {
+		return nil, err
+	}
+	data := in["data"]
+	if s, ok := data.(string); ok {
+		if err := json.Unmarshal([]byte(s), &data); err != nil {
+			return nil, workflow.Classify(fmt.Errorf("json.query: parse data: %w", err), workflow.ErrorClassPermanent)
+		}
+	} else if data, err = normalizeJSON(data); err != nil {
+		return nil, workflow.Classify(fmt.Errorf("json.query: %w", err), workflow.ErrorClassPermanent)
+	}
+	path, err := parseQuery(query)
+	if err != nil {
+		return nil, workflow.Classify(fmt.Errorf("json.query: %w", err), workflow.ErrorClassPermanent)
+	}
+	result, found := queryPath(data, path)
+	return map[string]interface{}{"result": result, "found": found}, nil
+}
+
+// normalizeJSON round-trips v through JSON so typed values, such as
+// []string from another action, can be queried.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+// wildcard is the [*] path element.
+type wildcard struct{}
+
+// parseQuery splits a query into field names, list indices and wildcards.
+func parseQuery(q string) ([]interface{}, error) {
+	q = strings.TrimPrefix(strings.TrimSpace(q), ".")
+	var path []interface{}
+	for q != "" {
+		switch {
+		case q[0] == '[':
+			end := strings.IndexByte(q, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("query: unterminated [")
+			}
+			key := q[1:end]
+			if key == "*" || key == "" {
+				path = append(path, wildcard{})
+			} else if n, err := strconv.Atoi(key); err == nil {
+				path = append(path, n)
+			} else {
+				path = append(path, strings.Trim(key, `"'`))
+			}
+			q = strings.TrimPrefix(q[end+1:], ".")
+		default:
+			end := strings.IndexAny(q, ".[")
+			if end < 0 {
+				end = len(q)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("query: empty field name")
+			}
+			path = append(path, q[:end])
+			q = strings.TrimPrefix(q[end:], ".")
+		}
+	}
+	return path, nil
+}
+
+// queryPath follows path through v.
+func queryPath(v interface{}, path []interface{}) (interface{}, bool) {
+	for i, key := range path {
+		switch k := key.(type) {
+		case wildcard:
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			out := make([]interface{}, 0, len(list))
+			for _, e := range list {
+				if r, ok := queryPath(e, path[i+1:]); ok {
+					out = append(out, r)
+				}
+			}
+			return out, true
+		case int:
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			if k < 0 {
+				k += len(list)
+			}
+			if k < 0 || k >= len(list) {
+				return nil, false
+			}
+			v = list[k]
+		case string:
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[k]; !ok {
+				return nil, false
+			}
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,300 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/agent"
+	"github.com/ferg-cod3s/openagent/pkg/provider"
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+var llmCompleteSchema = Schema{
+	Name:        "llm.complete",
+	Description: "Sends a prompt to an LLM provider and returns the completion.",
+	Inputs: []Field{
+		{Name: "prompt", Type: "string", Required: true, Description: "User message."},
+		{Name: "system", Type: "string", Description: "System prompt."},
+		{Name: "provider", Type: "string", Description: "Provider name; the configured default if empty."},
+		{Name: "model", Type: "string", Description: "Model; the configured default if empty."},
+		{Name: "max_tokens", Type: "integer", Description: "Completion token limit."},
+		{Name: "temperature", Type: "number", Description: "Sampling temperature."},
+	},
+	Outputs: []Field{
+		{Name: "content", Type: "string", Description: "Completion text."},
+		{Name: "model", Type: "string", Description: "Model that answered."},
+		{Name: "usage", Type: "object", Description: "Token usage: prompt_tokens, completion_tokens and total_tokens."},
+	},
+}
+
+var agentRunSchema = Schema{
+	Name:        "agent.run",
+	Description: "Runs an agent, named in the configuration or defined by the inputs, on a prompt.",
+	Inputs: []Field{
+		{Name: "prompt", Type: "string", Required: true, Description: "Input to the agent."},
+		{Name: "agent", Type: "string", Description: "Name of a configured agent."},
+		{Name: "system_prompt", Type: "string", Description: "System prompt, overriding the agent's."},
+		{Name: "provider", Type: "string", Description: "Provider name, overriding the agent's."},
+		{Name: "model", Type: "string", Description: "Model, overriding the agent's."},
+		{Name: "max_tokens", Type: "integer", Description: "Completion token limit, overriding the agent's."},
+		{Name: "temperature", Type: "number", Description: "Sampling temperature, overriding the agent's."},
+		{Name: "name", Type: "string", Description: "Agent name, overriding the agent's."},
+		{Name: "timeout", Type: "string", Description: "Duration bounding the run, such as 2m."},
+		{Name: "policy", Type: "object", Description: "Action policy: allow and deny lists of action types, and restrictive to deny the rest."},
+		{Name: "session", Type: "string", Description: "Conversation to continue; runs in the same session of a workflow run share their messages."},
+	},
+	Outputs: []Field{
+		{Name: "output", Type: "string", Description: "Agent response."},
+		{Name: "usage", Type: "object", Description: "Token usage: prompt_tokens, completion_tokens and total_tokens."},
+		{Name: "messages", Type: "array", Description: "Conversation as objects with role and content."},
+	},
+}
+
+// provider returns the named provider, or the default one.
+func (l *library) provider(name string) (provider.Provider, error) {
+	if name == "" {
+		name = l.cfg.DefaultProvider
+	}
+	if name == "" {
+		if len(l.cfg.Providers) == 1 {
+			for _, p := range l.cfg.Providers {
+				return p, nil
+			}
+		}
+		return nil, workflow.Classify(errors.New("no provider configured"), workflow.ErrorClassPermanent)
+	}
+	p, ok := l.cfg.Providers[name]
+	if !ok {
+		return nil, workflow.Classify(fmt.Errorf("provider not found: %s", name), workflow.ErrorClassPermanent)
+	}
+	return p, nil
+}
+
+func (l *library) llmComplete(ctx context.Context, in args) (map[string]interface{}, error) {
+	prompt, err := in.string("prompt")
+	if err != nil {
+		return nil, err
+	}
+	system, err := in.string("system")
+	if err != nil {
+		return nil, err
+	}
+	name, err := in.string("provider")
+	if err != nil {
+		return nil, err
+	}
+	req := &provider.CompletionRequest{Model: l.cfg.Model}
+	if model, err := in.string("model"); err != nil {
+		return nil, err
+	} else if model != "" {
+		req.Model = model
+	}
+	if req.MaxTokens, err = in.int("max_tokens", 0); err != nil {
+		return nil, err
+	}
+	if req.Temperature, err = in.float("temperature", 0); err != nil {
+		return nil, err
+	}
+	if system != "" {
+		req.Messages = append(req.Messages, provider.Message{Role: "system", Content: system})
+	}
+	req.Messages = append(req.Messages, provider.Message{Role: "user", Content: prompt})
+
+	p, err := l.provider(name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"content": resp.Content,
+		"model":   resp.Model,
+		"usage":   usageOutput(&resp.Usage),
+	}, nil
+}
+
+func (l *library) agentRun(ctx context.Context, in args) (map[string]interface{}, error) {
+	prompt, err := in.string("prompt")
+	if err != nil {
+		return nil, err
+	}
+	session, err := in.string("session")
+	if err != nil {
+		return nil, err
+	}
+	sessions := workflow.SessionsFromContext(ctx)
+	if session == "" || sessions == nil {
+		a, err := l.newAgent(in)
+		if err != nil {
+			return nil, err
+		}
+		return runAgent(ctx, a, prompt)
+	}
+
+	// The first run of a session creates its agent; later runs continue
+	// its conversation, one at a time.
+	s, ok := sessions.Get(session, func() interface{} { return &agentSession{} }).(*agentSession)
+	if !ok {
+		return nil, workflow.Classify(fmt.Errorf("session %s is not an agent session", session), workflow.ErrorClassPermanent)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.agent == nil {
+		if s.agent, err = l.newAgent(in); err != nil {
+			return nil, err
+		}
+	}
+	return runAgent(ctx, s.agent, prompt)
+}
+
+// agentSession is the agent of a shared session.
+type agentSession struct {
+	mu    sync.Mutex
+	agent *agent.Agent
+}
+
+// newAgent creates the agent described by the inputs.
+func (l *library) newAgent(in args) (*agent.Agent, error) {
+	cfg, err := l.agentConfig(in)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := agentPolicy(in)
+	if err != nil {
+		return nil, err
+	}
+	p, err := l.provider(cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	a := agent.New(cfg, p)
+	if policy != nil {
+		a.SetPolicy(policy)
+	}
+	return a, nil
+}
+
+func runAgent(ctx context.Context, a *agent.Agent, prompt string) (map[string]interface{}, error) {
+	res, err := a.Run(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return agentOutput(res), nil
+}
+
+// agentPolicy builds the policy input, or returns nil without one.
+func agentPolicy(in args) (agent.Policy, error) {
+	obj, err := in.object("policy")
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	p := args(obj)
+	restrictive, err := p.bool("restrictive")
+	if err != nil {
+		return nil, err
+	}
+	allow, err := p.strings("allow")
+	if err != nil {
+		return nil, err
+	}
+	deny, err := p.strings("deny")
+	if err != nil {
+		return nil, err
+	}
+	if restrictive {
+		if len(deny) > 0 {
+			return nil, workflow.Classify(errors.New("input policy: deny is not used by restrictive policies"), workflow.ErrorClassPermanent)
+		}
+		rp := agent.NewRestrictivePolicy()
+		for _, a := range allow {
+			rp.AllowAction(a)
+		}
+		return rp, nil
+	}
+	dp := agent.NewDefaultPolicy()
+	for _, a := range allow {
+		dp.AllowAction(a)
+	}
+	for _, a := range deny {
+		dp.DenyAction(a)
+	}
+	return dp, nil
+}
+
+// agentConfig returns the named agent's configuration with the inline
+// inputs applied.
+func (l *library) agentConfig(in args) (agent.Config, error) {
+	var cfg agent.Config
+	name, err := in.string("agent")
+	if err != nil {
+		return cfg, err
+	}
+	if name != "" {
+		c, ok := l.cfg.Agents[name]
+		if !ok {
+			return cfg, workflow.Classify(fmt.Errorf("agent not found: %s", name), workflow.ErrorClassPermanent)
+		}
+		cfg = c
+		if cfg.Name == "" {
+			cfg.Name = name
+		}
+	}
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{{"name", &cfg.Name}, {"system_prompt", &cfg.SystemPrompt}, {"provider", &cfg.Provider}, {"model", &cfg.Model}} {
+		v, err := in.string(f.name)
+		if err != nil {
+			return cfg, err
+		}
+		if v != "" {
+			*f.dst = v
+		}
+	}
+	if cfg.MaxTokens, err = in.int("max_tokens", cfg.MaxTokens); err != nil {
+		return cfg, err
+	}
+	if cfg.Temperature, err = in.float("temperature", cfg.Temperature); err != nil {
+		return cfg, err
+	}
+	if t, err := in.string("timeout"); err != nil {
+		return cfg, err
+	} else if t != "" {
+		if cfg.Timeout, err = time.ParseDuration(t); err != nil {
+			return cfg, workflow.Classify(fmt.Errorf("input timeout: %w", err), workflow.ErrorClassPermanent)
+		}
+	}
+	if cfg.Model == "" {
+		cfg.Model = l.cfg.Model
+	}
+	return cfg, nil
+}
+
+// agentOutput converts an agent result to action output.
+func agentOutput(res *agent.Result) map[string]interface{} {
+	messages := make([]interface{}, len(res.Messages))
+	for i, m := range res.Messages {
+		messages[i] = map[string]interface{}{"role": m.Role, "content": m.Content}
+	}
+	return map[string]interface{}{
+		"output":   res.Output,
+		"usage":    usageOutput(res.Usage),
+		"messages": messages,
+	}
+}
+
+func usageOutput(u *provider.Usage) map[string]interface{} {
+	if u == nil {
+		u = &provider.Usage{}
+	}
+	return map[string]interface{}{
+		"prompt_tokens":     u.PromptTokens,
+		"completion_tokens": u.CompletionTokens,
+		"total_tokens":      u.TotalTokens,
+	}
+}
@@ -0,0 +1,193 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ferg-cod3s/openagent/pkg/memory"
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+// MetaTags is the metadata key holding the tags given to memory.save.
+const MetaTags = "tags"
+
+var memorySaveSchema = Schema{
+	Name:        "memory.save",
+	Description: "Saves a memory to the configured store.",
+	Inputs: []Field{
+		{Name: "content", Type: "string", Required: true, Description: "Memory content."},
+		{Name: "id", Type: "string", Description: "Memory ID; a new one if empty. Saving an existing ID replaces it."},
+		{Name: "type", Type: "string", Description: "episodic (default), semantic, procedural or working."},
+		{Name: "tags", Type: "array", Description: "Tags, stored under the tags metadata key."},
+		{Name: "metadata", Type: "object", Description: "Metadata."},
+		{Name: "importance", Type: "number", Description: "Importance between 0 and 1."},
+		{Name: "ttl", Type: "string", Description: "Duration after which the memory expires, such as 24h."},
+	},
+	Outputs: []Field{
+		{Name: "id", Type: "string", Description: "ID of the saved memory."},
+	},
+}
+
+var memorySearchSchema = Schema{
+	Name:        "memory.search",
+	Description: "Searches the configured store: by similarity for vector stores, otherwise by keyword.",
+	Inputs: []Field{
+		{Name: "query", Type: "string", Required: true, Description: "Search text."},
+		{Name: "limit", Type: "integer", Description: "Maximum results, 5 by default."},
+		{Name: "type", Type: "string", Description: "Only return memories of this type."},
+	},
+	Outputs: []Field{
+		{Name: "memories", Type: "array", Description: "Matches, best first, as objects with id, type, content, score and metadata."},
+		{Name: "count", Type: "integer", Description: "Number of matches."},
+	},
+}
+
+func (l *library) store() (memory.Store, error) {
+	if l.cfg.Memory == nil {
+		return nil, workflow.Classify(errors.New("no memory store configured"), workflow.ErrorClassPermanent)
+	}
+	return l.cfg.Memory, nil
+}
+
+func (l *library) memorySave(ctx context.Context, in args) (map[string]interface{}, error) {
+	store, err := l.store()
+	if err != nil {
+		return nil, err
+	}
+	m := &memory.Memory{Type: memory.TypeEpisodic}
+	if m.Content, err = in.string("content"); err != nil {
+		return nil, err
+	}
+	if m.ID, err = in.string("id"); err != nil {
+		return nil, err
+	}
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	if t, err := in.string("type"); err != nil {
+		return nil, err
+	} else if t != "" {
+		m.Type = memory.MemoryType(t)
+	}
+	if m.Metadata, err = in.object("metadata"); err != nil {
+		return nil, err
+	}
+	tags, err := in.strings("tags")
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		meta := make(map[string]interface{}, len(m.Metadata)+1)
+		for k, v := range m.Metadata {
+			meta[k] = v
+		}
+		list := make([]interface{}, len(tags))
+		for i, t := range tags {
+			list[i] = t
+		}
+		meta[MetaTags] = list
+		m.Metadata = meta
+	}
+	if m.Importance, err = in.float("importance", 0); err != nil {
+		return nil, err
+	}
+	if ttl, err := in.string("ttl"); err != nil {
+		return nil, err
+	} else if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return nil, workflow.Classify(fmt.Errorf("input ttl: %w", err), workflow.ErrorClassPermanent)
+		}
+		m.ExpireAfter(d)
+	}
+	now := time.Now()
+	m.CreatedAt, m.UpdatedAt = now, now
+	if existing, err := store.Get(ctx, m.ID); err == nil {
+		m.CreatedAt = existing.CreatedAt
+	}
+	if err := store.Save(ctx, m); err != nil {
+		return nil, fmt.Errorf("memory.save: %w", err)
+	}
+	return map[string]interface{}{"id": m.ID}, nil
+}
+
+func (l *library) memorySearch(ctx context.Context, in args) (map[string]interface{}, error) {
+	store, err := l.store()
+	if err != nil {
+		return nil, err
+	}
+	query, err := in.string("query")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := in.int("limit", 5)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := in.string("type")
+	if err != nil {
+		return nil, err
+	}
+
+	// Over-fetch when filtering by type, since the backends cannot.
+	n := limit
+	if typ != "" {
+		n = limit * 4
+	}
+	var found []*memory.Memory
+	switch s := store.(type) {
+	case memory.VectorStore:
+		found, err = s.SearchByText(ctx, query, n)
+	case memory.KeywordSearcher:
+		found, err = s.SearchKeyword(ctx, query, n)
+	default:
+		found, err = searchBM25(ctx, store, query, n)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memory.search: %w", err)
+	}
+
+	memories := []interface{}{}
+	for _, m := range found {
+		if typ != "" && string(m.Type) != typ {
+			continue
+		}
+		if len(memories) == limit {
+			break
+		}
+		memories = append(memories, map[string]interface{}{
+			"id":       m.ID,
+			"type":     string(m.Type),
+			"content":  m.Content,
+			"score":    m.Score,
+			"metadata": m.Metadata,
+		})
+	}
+	return map[string]interface{}{"memories": memories, "count": len(memories)}, nil
+}
+
+// searchBM25 ranks every memory in store by BM25.
+func searchBM25(ctx context.Context, store memory.Store, query string, limit int) ([]*memory.Memory, error) {
+	all, err := store.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	idx := memory.NewBM25Index()
+	byID := make(map[string]*memory.Memory, len(all))
+	for _, m := range all {
+		idx.Add(m)
+		byID[m.ID] = m
+	}
+	hits := idx.Search(query, limit)
+	out := make([]*memory.Memory, len(hits))
+	for i, h := range hits {
+		m := *byID[h.ID]
+		m.Score = h.Score
+		out[i] = &m
+	}
+	return out, nil
+}
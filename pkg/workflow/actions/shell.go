@@ -0,0 +1,318 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+// Shell defaults.
+const (
+	DefaultShellTimeout   = 10 * time.Minute
+	DefaultMaxOutputBytes = 1 << 20
+)
+
+// waitDelay bounds the wait for output after a command is killed.
+const waitDelay = 100 * time.Millisecond
+
+// ShellConfig configures shell.exec.
+type ShellConfig struct {
+	// Disabled makes shell.exec fail without running anything.
+	Disabled bool
+	// Sandbox, if set, restricts what commands may do.
+	Sandbox *Sandbox
+	// Timeout bounds each command. Zero uses DefaultShellTimeout.
+	Timeout time.Duration
+	// MaxOutputBytes caps the captured stdout and stderr, each. Zero uses
+	// DefaultMaxOutputBytes.
+	MaxOutputBytes int
+}
+
+// Sandbox restricts shell.exec. Sandboxed commands run directly rather
+// than through a shell, so pipes, redirection, globs and variable
+// expansion are unavailable; the command line is split into arguments
+// on whitespace, honoring single and double quotes. Resource limits are
+// not enforced.
+type Sandbox struct {
+	// AllowedCommands lists the programs that may run, by name. It is
+	// required: an empty list allows nothing.
+	AllowedCommands []string
+	// AllowedDirs lists the directories commands may run in, including
+	// their subdirectories. Empty allows the root and below.
+	AllowedDirs []string
+	// Env names the host variables passed to commands, besides PATH and
+	// HOME. Others are dropped. The step's env and the env input are
+	// passed too, except for variables that change what a program loads
+	// or runs, such as LD_PRELOAD, BASH_ENV and GIT_CONFIG_*, which fail
+	// the step unless named here.
+	Env []string
+}
+
+var shellExecSchema = Schema{
+	Name:        "shell.exec",
+	Description: "Runs a command in the workflow root. Without a sandbox the command line runs through sh -c.",
+	Inputs: []Field{
+		{Name: "command", Type: "string", Description: "Command line; one of command and args is required."},
+		{Name: "args", Type: "array", Description: "Program and arguments, run without a shell."},
+		{Name: "dir", Type: "string", Description: "Working directory, relative to the root."},
+		{Name: "env", Type: "object", Description: "Extra environment variables."},
+		{Name: "stdin", Type: "string", Description: "Standard input."},
+		{Name: "timeout", Type: "string", Description: "Duration bounding the command, such as 30s."},
+		{Name: "allow_failure", Type: "boolean", Description: "Succeed even if the command exits non-zero."},
+	},
+	Outputs: []Field{
+		{Name: "stdout", Type: "string", Description: "Standard output."},
+		{Name: "stderr", Type: "string", Description: "Standard error."},
+		{Name: "exit_code", Type: "integer", Description: "Exit status."},
+		{Name: "truncated", Type: "boolean", Description: "Whether output was cut at the size limit."},
+	},
+}
+
+func (l *library) shellExec(ctx context.Context, in args) (map[string]interface{}, error) {
+	cfg := l.cfg.Shell
+	if cfg.Disabled {
+		return nil, workflow.Classify(errors.New("shell.exec is disabled"), workflow.ErrorClassPermanent)
+	}
+	command, err := in.string("command")
+	if err != nil {
+		return nil, err
+	}
+	argv, err := in.strings("args")
+	if err != nil {
+		return nil, err
+	}
+	if (command == "") == (len(argv) == 0) {
+		return nil, workflow.Classify(errors.New("shell.exec: exactly one of command and args is required"), workflow.ErrorClassPermanent)
+	}
+	dirInput, err := in.string("dir")
+	if err != nil {
+		return nil, err
+	}
+	dir, err := l.path(dirInput)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := in.stringMap("env")
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := in.string("stdin")
+	if err != nil {
+		return nil, err
+	}
+	allowFailure, err := in.bool("allow_failure")
+	if err != nil {
+		return nil, err
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultShellTimeout
+	}
+	if t, err := in.string("timeout"); err != nil {
+		return nil, err
+	} else if t != "" {
+		if timeout, err = time.ParseDuration(t); err != nil {
+			return nil, workflow.Classify(fmt.Errorf("input timeout: %w", err), workflow.ErrorClassPermanent)
+		}
+	}
+
+	base := os.Environ()
+	if sb := cfg.Sandbox; sb != nil {
+		if len(argv) == 0 {
+			if argv, err = splitCommand(command); err != nil {
+				return nil, workflow.Classify(err, workflow.ErrorClassPermanent)
+			}
+		}
+		if err := l.checkSandbox(sb, argv[0], dir); err != nil {
+			return nil, workflow.Classify(err, workflow.ErrorClassPermanent)
+		}
+		for _, vars := range []map[string]string{workflow.EnvFromContext(ctx), extra} {
+			if err := checkSandboxEnv(sb, vars); err != nil {
+				return nil, workflow.Classify(err, workflow.ErrorClassPermanent)
+			}
+		}
+		base = hostEnv(append([]string{"PATH", "HOME"}, sb.Env...))
+	} else if len(argv) == 0 {
+		argv = []string{"sh", "-c", command}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	limit := cfg.MaxOutputBytes
+	if limit == 0 {
+		limit = DefaultMaxOutputBytes
+	}
+	stdout, stderr := &cappedBuffer{max: limit}, &cappedBuffer{max: limit}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = env(ctx, base, extra)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// Children of a killed shell may hold the output pipes open.
+	cmd.WaitDelay = waitDelay
+
+	err = cmd.Run()
+	code := -1
+	if cmd.ProcessState != nil {
+		code = cmd.ProcessState.ExitCode()
+	}
+	out := map[string]interface{}{
+		"stdout":    stdout.String(),
+		"stderr":    stderr.String(),
+		"exit_code": code,
+		"truncated": stdout.truncated || stderr.truncated,
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return out, fmt.Errorf("shell.exec: %w", ctx.Err())
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return out, fmt.Errorf("shell.exec: %w", err)
+		}
+		if !allowFailure {
+			return out, fmt.Errorf("shell.exec: exit status %d: %s", exitErr.ExitCode(), lastLine(stderr.String()))
+		}
+	}
+	return out, nil
+}
+
+// checkSandbox checks that a sandbox allows running program in dir.
+func (l *library) checkSandbox(sb *Sandbox, program, dir string) error {
+	allowed := false
+	for _, c := range sb.AllowedCommands {
+		allowed = allowed || c == program
+	}
+	if !allowed {
+		return fmt.Errorf("shell.exec: command %q is not allowed by the sandbox", program)
+	}
+	if len(sb.AllowedDirs) == 0 {
+		return nil
+	}
+	for _, d := range sb.AllowedDirs {
+		allowedDir, err := l.path(d)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(allowedDir, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("shell.exec: directory %q is not allowed by the sandbox", l.rel(dir))
+}
+
+// sandboxDeniedEnv lists the variables a sandboxed command may only get
+// from Sandbox.Env: those making the dynamic loader, shells, interpreters
+// or git load or run other code. A trailing * matches any suffix.
+var sandboxDeniedEnv = []string{
+	"PATH", "HOME", "LD_*", "DYLD_*",
+	"BASH_ENV", "ENV", "BASH_FUNC_*", "SHELLOPTS", "BASHOPTS", "PS4", "PROMPT_COMMAND", "IFS", "CDPATH",
+	"GIT_*", "EDITOR", "VISUAL", "PAGER", "LESSOPEN", "LESSCLOSE", "SSH_ASKPASS",
+	"PYTHONPATH", "PYTHONHOME", "PYTHONSTARTUP", "PERL5OPT", "PERL5LIB", "PERLLIB", "RUBYOPT", "RUBYLIB",
+	"NODE_OPTIONS", "NODE_PATH", "JAVA_TOOL_OPTIONS", "_JAVA_OPTIONS",
+}
+
+// checkSandboxEnv checks that a sandbox allows setting vars.
+func checkSandboxEnv(sb *Sandbox, vars map[string]string) error {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		allowed := false
+		for _, e := range sb.Env {
+			allowed = allowed || e == name
+		}
+		if allowed {
+			continue
+		}
+		for _, pattern := range sandboxDeniedEnv {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) || name == pattern {
+				return fmt.Errorf("shell.exec: env %s is not allowed by the sandbox", name)
+			}
+		}
+	}
+	return nil
+}
+
+// splitCommand splits a command line into arguments on whitespace,
+// honoring single and double quotes.
+func splitCommand(s string) ([]string, error) {
+	var (
+		out   []string
+		cur   strings.Builder
+		quote rune
+		inArg bool
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				out = append(out, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("shell.exec: unterminated quote in %q", s)
+	}
+	if inArg {
+		out = append(out, cur.String())
+	}
+	if len(out) == 0 {
+		return nil, errors.New("shell.exec: empty command")
+	}
+	return out, nil
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+// cappedBuffer keeps the first max bytes written to it. It does not
+// embed bytes.Buffer, whose ReadFrom would bypass the cap.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) String() string { return b.buf.String() }
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room < 0 {
+			room = 0
+		}
+		p = p[:room]
+	}
+	b.buf.Write(p)
+	return n, nil
+}
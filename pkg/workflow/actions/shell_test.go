@@ -0,0 +1,142 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ferg-cod3s/openagent/pkg/workflow"
+)
+
+func TestShellExec(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e := newEngine(t, Config{Root: root, Shell: ShellConfig{MaxOutputBytes: 8}})
+
+	out, err := run(t, e, "shell.exec", map[string]interface{}{"command": "pwd; echo $GREETING >&2", "dir": "sub", "env": map[string]interface{}{"GREETING": "hi"}})
+	if err != nil {
+		t.Fatalf("shell.exec failed: %v", err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(out["stdout"].(string)), "sub") && out["truncated"] != true {
+		t.Errorf("expected to run in sub, got %v", out)
+	}
+	if out["stderr"] != "hi\n" || out["exit_code"] != 0 {
+		t.Errorf("unexpected output %v", out)
+	}
+
+	out, err = run(t, e, "shell.exec", map[string]interface{}{"args": []interface{}{"cat"}, "stdin": "0123456789"})
+	if err != nil || out["stdout"] != "01234567" || out["truncated"] != true {
+		t.Errorf("expected truncated stdin echo, got %v, %v", out, err)
+	}
+
+	out, err = run(t, e, "shell.exec", map[string]interface{}{"command": "echo oops >&2; exit 3"})
+	if err == nil || !strings.Contains(err.Error(), "exit status 3: oops") || out["exit_code"] != 3 {
+		t.Errorf("expected exit error, got %v, %v", out, err)
+	}
+	if _, err := run(t, e, "shell.exec", map[string]interface{}{"command": "exit 3", "allow_failure": true}); err != nil {
+		t.Errorf("expected allow_failure to succeed, got %v", err)
+	}
+
+	_, err = run(t, e, "shell.exec", map[string]interface{}{"command": "sleep 5", "timeout": "20ms"})
+	if !errors.Is(err, context.DeadlineExceeded) || workflow.ErrorClass(err) != workflow.ErrorClassTimeout {
+		t.Errorf("expected timeout, got %v", err)
+	}
+
+	// The step's environment is passed to commands.
+	res, err := e.ExecuteStep(workflow.WithEnv(context.Background(), map[string]string{"NAME": "ok"}),
+		&workflow.Step{ID: "s", Action: "shell.exec", With: map[string]interface{}{"command": "echo $NAME"}}, nil)
+	if err != nil || res.Output["stdout"] != "ok\n" {
+		t.Errorf("expected step env, got %v, %v", res.Output, err)
+	}
+
+	e = newEngine(t, Config{Shell: ShellConfig{Disabled: true}})
+	if _, err := run(t, e, "shell.exec", map[string]interface{}{"command": "true"}); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("expected disabled error, got %v", err)
+	}
+}
+
+func TestShellSandbox(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"work", "secret"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("OPENAGENT_TEST_SECRET", "leaked")
+	e := newEngine(t, Config{Root: root, Shell: ShellConfig{Sandbox: &Sandbox{
+		AllowedCommands: []string{"echo", "env"},
+		AllowedDirs:     []string{"work"},
+	}}})
+
+	// Commands run without a shell.
+	out, err := run(t, e, "shell.exec", map[string]interface{}{"command": `echo "a  b" $HOME; ls`, "dir": "work"})
+	if err != nil {
+		t.Fatalf("shell.exec failed: %v", err)
+	}
+	if out["stdout"] != "a  b $HOME; ls\n" {
+		t.Errorf("expected literal arguments, got %q", out["stdout"])
+	}
+
+	out, err = run(t, e, "shell.exec", map[string]interface{}{"args": []interface{}{"env"}, "dir": "work"})
+	if err != nil {
+		t.Fatalf("shell.exec failed: %v", err)
+	}
+	if strings.Contains(out["stdout"].(string), "OPENAGENT_TEST_SECRET") {
+		t.Error("expected host environment to be dropped")
+	}
+
+	// The step may set variables, but not ones that make the program
+	// load other code, unless the sandbox names them.
+	out, err = run(t, e, "shell.exec", map[string]interface{}{"args": []interface{}{"env"}, "dir": "work", "env": map[string]interface{}{"GREETING": "hi"}})
+	if err != nil || !strings.Contains(out["stdout"].(string), "GREETING=hi") {
+		t.Errorf("expected the env input to be passed, got %v, %v", out, err)
+	}
+	step := &workflow.Step{ID: "s", Action: "shell.exec", With: map[string]interface{}{"args": []interface{}{"env"}, "dir": "work"}}
+	ctx := workflow.WithEnv(context.Background(), map[string]string{"BASH_ENV": "/tmp/evil.sh"})
+	if _, err := e.ExecuteStep(ctx, step, nil); err == nil || !strings.Contains(err.Error(), "env BASH_ENV is not allowed") {
+		t.Errorf("expected the step env to be checked, got %v", err)
+	}
+	opened := newEngine(t, Config{Root: root, Shell: ShellConfig{Sandbox: &Sandbox{AllowedCommands: []string{"env"}, Env: []string{"LD_LIBRARY_PATH"}}}})
+	out, err = run(t, opened, "shell.exec", map[string]interface{}{"args": []interface{}{"env"}, "env": map[string]interface{}{"LD_LIBRARY_PATH": "/opt/lib"}})
+	if err != nil || !strings.Contains(out["stdout"].(string), "LD_LIBRARY_PATH=/opt/lib") {
+		t.Errorf("expected a variable named by the sandbox to be passed, got %v, %v", out, err)
+	}
+
+	tests := []struct {
+		with map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"args": []interface{}{"env"}, "dir": "work", "env": map[string]interface{}{"LD_PRELOAD": "/tmp/evil.so"}}, "env LD_PRELOAD is not allowed"},
+		{map[string]interface{}{"args": []interface{}{"env"}, "dir": "work", "env": map[string]interface{}{"GIT_CONFIG_COUNT": "1"}}, "env GIT_CONFIG_COUNT is not allowed"},
+		{map[string]interface{}{"args": []interface{}{"env"}, "dir": "work", "env": map[string]interface{}{"PATH": "/tmp"}}, "env PATH is not allowed"},
+		{map[string]interface{}{"command": "rm -rf .", "dir": "work"}, `command "rm" is not allowed`},
+		{map[string]interface{}{"command": "echo hi", "dir": "secret"}, `directory "secret" is not allowed`},
+		{map[string]interface{}{"command": "echo 'unterminated", "dir": "work"}, "unterminated quote"},
+	}
+	for _, tt := range tests {
+		_, err := run(t, e, "shell.exec", tt.with)
+		if err == nil || !strings.Contains(err.Error(), tt.want) || workflow.ErrorClass(err) != workflow.ErrorClassPermanent {
+			t.Errorf("%v: expected permanent error containing %q, got %v", tt.with, tt.want, err)
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	got, err := splitCommand(`go test  -run 'Test A' "./pkg/..."`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"go", "test", "-run", "Test A", "./pkg/..."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if _, err := splitCommand("   "); err == nil {
+		t.Error("expected empty command error")
+	}
+}
@@ -0,0 +1,266 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AgentAction is the action agent steps run unless they set their own.
+// The pkg/workflow/actions library registers it.
+const AgentAction = "agent.run"
+
+// AgentRef is the agent an agent step runs: either the name of an agent
+// the action knows about or an inline definition. In YAML it is a string
+// or a mapping:
+//
+//	agent: reviewer
+//
+//	agent:
+//	  provider: openai
+//	  model: gpt-4o
+//	  system_prompt: You review Go code.
+type AgentRef struct {
+	Name   string
+	Inline *AgentConfig
+}
+
+// AgentConfig defines an agent inline.
+type AgentConfig struct {
+	Name         string  `yaml:"name,omitempty" json:"name,omitempty"`
+	Provider     string  `yaml:"provider,omitempty" json:"provider,omitempty"`
+	Model        string  `yaml:"model,omitempty" json:"model,omitempty"`
+	SystemPrompt string  `yaml:"system_prompt,omitempty" json:"system_prompt,omitempty"`
+	MaxTokens    int     `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"`
+	Temperature  float64 `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	// Timeout bounds each run of the agent.
+	Timeout string       `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Policy  *AgentPolicy `yaml:"policy,omitempty" json:"policy,omitempty"`
+}
+
+// AgentPolicy lists the action types an agent may and may not take. A
+// restrictive policy denies every action it does not allow.
+type AgentPolicy struct {
+	Restrictive bool     `yaml:"restrictive,omitempty" json:"restrictive,omitempty"`
+	Allow       []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+	Deny        []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// UnmarshalYAML reads an agent name or an inline definition.
+func (r *AgentRef) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*r = AgentRef{}
+		return node.Decode(&r.Name)
+	}
+	var c AgentConfig
+	if err := node.Decode(&c); err != nil {
+		return err
+	}
+	*r = AgentRef{Inline: &c}
+	return nil
+}
+
+// MarshalYAML writes the agent name, or the inline definition.
+func (r AgentRef) MarshalYAML() (interface{}, error) {
+	if r.Inline != nil {
+		return r.Inline, nil
+	}
+	return r.Name, nil
+}
+
+// UnmarshalJSON reads an agent name or an inline definition.
+func (r *AgentRef) UnmarshalJSON(data []byte) error {
+	*r = AgentRef{}
+	if err := json.Unmarshal(data, &r.Name); err == nil {
+		return nil
+	}
+	r.Inline = &AgentConfig{}
+	return json.Unmarshal(data, r.Inline)
+}
+
+// MarshalJSON writes the agent name, or the inline definition.
+func (r AgentRef) MarshalJSON() ([]byte, error) {
+	if r.Inline != nil {
+		return json.Marshal(r.Inline)
+	}
+	return json.Marshal(r.Name)
+}
+
+// agentStep returns the action step an agent step runs: its agent,
+// prompt and session become inputs of the agent action, and With values
+// override the agent's settings.
+func agentStep(step *Step) *Step {
+	s := *step
+	if s.Action == "" {
+		s.Action = AgentAction
+	}
+	with := make(map[string]interface{}, len(step.With)+3)
+	if a := step.Agent; a != nil {
+		if a.Name != "" {
+			with["agent"] = a.Name
+		}
+		if c := a.Inline; c != nil {
+			for k, v := range map[string]interface{}{
+				"name": c.Name, "provider": c.Provider, "model": c.Model,
+				"system_prompt": c.SystemPrompt, "timeout": c.Timeout,
+			} {
+				if v != "" {
+					with[k] = v
+				}
+			}
+			if c.MaxTokens != 0 {
+				with["max_tokens"] = c.MaxTokens
+			}
+			if c.Temperature != 0 {
+				with["temperature"] = c.Temperature
+			}
+			if p := c.Policy; p != nil {
+				with["policy"] = map[string]interface{}{
+					"restrictive": p.Restrictive,
+					"allow":       stringList(p.Allow),
+					"deny":        stringList(p.Deny),
+				}
+			}
+		}
+	}
+	for k, v := range step.With {
+		with[k] = v
+	}
+	if step.Prompt != "" {
+		with["prompt"] = step.Prompt
+	}
+	if step.Session != "" {
+		with["session"] = step.Session
+	}
+	s.With = with
+	return &s
+}
+
+func stringList(items []string) []interface{} {
+	out := make([]interface{}, len(items))
+	for i, v := range items {
+		out[i] = v
+	}
+	return out
+}
+
+// renderAgent renders the prompt and session templates of an agent step.
+func renderAgent(step *Step, ctx *ExprContext) error {
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{{"prompt", &step.Prompt}, {"session", &step.Session}} {
+		if *f.dst == "" {
+			continue
+		}
+		v, err := renderString(*f.dst, ctx)
+		if err != nil {
+			return fmt.Errorf("%s %w", f.name, err)
+		}
+		if *f.dst, err = stringify(v); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// checkAgent checks the agent fields of a step.
+func checkAgent(d *diagnostics, step *Step, p string, sc *refScope) {
+	if step.Type != StepTypeAgent {
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{{"agent", step.Agent != nil}, {"prompt", step.Prompt != ""}, {"session", step.Session != ""}} {
+			if f.set {
+				d.add(p+"."+f.name, "only allowed in agent steps")
+			}
+		}
+		return
+	}
+
+	if step.Prompt == "" && step.With["prompt"] == nil {
+		d.add(p+".prompt", "required for agent steps")
+	}
+	exprs := make(map[string][]*Expr)
+	for _, f := range []struct{ name, value string }{{"prompt", step.Prompt}, {"session", step.Session}} {
+		found, err := templateExprs(p+"."+f.name, f.value)
+		if err != nil {
+			d.addErr(p+"."+f.name, err)
+		}
+		for field, e := range found {
+			exprs[field] = e
+		}
+	}
+	sc.checkAll(d, exprs)
+
+	if step.Agent == nil {
+		return
+	}
+	if (step.Agent.Name == "") == (step.Agent.Inline == nil) {
+		d.add(p+".agent", "needs exactly one of a name and an inline definition")
+	}
+	if c := step.Agent.Inline; c != nil {
+		if c.MaxTokens < 0 {
+			d.add(p+".agent.max_tokens", "must not be negative")
+		}
+		if c.Temperature < 0 {
+			d.add(p+".agent.temperature", "must not be negative")
+		}
+		if c.Timeout != "" {
+			if t, err := time.ParseDuration(c.Timeout); err != nil || t <= 0 {
+				d.add(p+".agent.timeout", fmt.Sprintf("invalid duration %q", c.Timeout))
+			}
+		}
+	}
+}
+
+// Sessions holds the agent conversations of a run, keyed by session
+// name, so that agent steps naming the same session continue one
+// conversation. The agent action decides what a session holds.
+type Sessions struct {
+	mu sync.Mutex
+	m  map[string]interface{}
+}
+
+// NewSessions creates an empty session store.
+func NewSessions() *Sessions {
+	return &Sessions{m: make(map[string]interface{})}
+}
+
+// Get returns the named session, calling create to start it if needed.
+func (s *Sessions) Get(name string, create func() interface{}) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[name]
+	if !ok {
+		v = create()
+		s.m[name] = v
+	}
+	return v
+}
+
+type sessionsKey struct{}
+
+// WithSessions returns a context carrying a session store. Runs started
+// with such a context share its sessions; otherwise each run has its own.
+func WithSessions(ctx context.Context, s *Sessions) context.Context {
+	return context.WithValue(ctx, sessionsKey{}, s)
+}
+
+// SessionsFromContext returns the session store of the run, or nil.
+func SessionsFromContext(ctx context.Context) *Sessions {
+	s, _ := ctx.Value(sessionsKey{}).(*Sessions)
+	return s
+}
+
+// withSessions gives ctx a session store if it has none.
+func withSessions(ctx context.Context) context.Context {
+	if SessionsFromContext(ctx) != nil {
+		return ctx
+	}
+	return WithSessions(ctx, NewSessions())
+}
@@ -0,0 +1,180 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestAgentSteps(t *testing.T) {
+	w, err := NewParser().Parse([]byte(`
+name: agents
+version: "1"
+steps:
+  - id: plan
+    name: Plan
+    type: agent
+    agent: planner
+    prompt: "Plan ${{ inputs.task }}"
+    session: work
+  - id: build
+    name: Build
+    type: agent
+    depends_on: [plan]
+    agent:
+      provider: mock
+      model: mock-2
+      system_prompt: You write Go.
+      max_tokens: 500
+      policy:
+        restrictive: true
+        allow: [file.write]
+    prompt: "Follow ${{ steps.plan.output.output }}"
+    session: work-${{ inputs.task }}
+    with:
+      temperature: 0.2
+`))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var calls []map[string]interface{}
+	e := NewEngine()
+	e.RegisterAction(AgentAction, func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		if SessionsFromContext(ctx) == nil {
+			t.Error("expected a session store in the context")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, inputs)
+		return map[string]interface{}{"output": "the plan"}, nil
+	})
+	if _, err := e.ExecuteWithInputs(context.Background(), w, map[string]interface{}{"task": "parser"}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 agent runs, got %d", len(calls))
+	}
+	if got := calls[0]; got["agent"] != "planner" || got["prompt"] != "Plan parser" || got["session"] != "work" {
+		t.Errorf("unexpected inputs %v", got)
+	}
+	got := calls[1]
+	want := map[string]interface{}{
+		"provider": "mock", "model": "mock-2", "system_prompt": "You write Go.", "max_tokens": 500,
+		"temperature": 0.2, "prompt": "Follow the plan", "session": "work-parser", "task": "parser",
+		"policy": map[string]interface{}{"restrictive": true, "allow": []interface{}{"file.write"}, "deny": []interface{}{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected inputs %v, got %v", want, got)
+	}
+
+	// A step may run another action implementing the agent protocol.
+	e.RegisterAction("custom", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"output": "custom " + inputs["prompt"].(string)}, nil
+	})
+	res, err := e.ExecuteStep(context.Background(), &Step{ID: "s", Type: StepTypeAgent, Action: "custom", Prompt: "hi"}, nil)
+	if err != nil || res.Output["output"] != "custom hi" {
+		t.Errorf("expected custom action output, got %v, %v", res.Output, err)
+	}
+}
+
+func TestAgentRefEncoding(t *testing.T) {
+	var s Step
+	if err := yaml.Unmarshal([]byte("agent: reviewer"), &s); err != nil || s.Agent.Name != "reviewer" || s.Agent.Inline != nil {
+		t.Fatalf("expected named agent, got %+v, %v", s.Agent, err)
+	}
+	if err := yaml.Unmarshal([]byte("agent: {model: m, policy: {deny: [shell]}}"), &s); err != nil || s.Agent.Inline == nil || s.Agent.Inline.Model != "m" {
+		t.Fatalf("expected inline agent, got %+v, %v", s.Agent, err)
+	}
+
+	for _, ref := range []*AgentRef{{Name: "reviewer"}, {Inline: &AgentConfig{Model: "m", Policy: &AgentPolicy{Deny: []string{"shell"}}}}} {
+		data, err := json.Marshal(&Step{ID: "s", Agent: ref})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Step
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back.Agent, ref) {
+			t.Errorf("JSON round trip: expected %+v, got %+v", ref, back.Agent)
+		}
+		out, err := yaml.Marshal(&Step{ID: "s", Agent: ref})
+		if err != nil {
+			t.Fatal(err)
+		}
+		back = Step{}
+		if err := yaml.Unmarshal(out, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back.Agent, ref) {
+			t.Errorf("YAML round trip: expected %+v, got %+v", ref, back.Agent)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	s := NewSessions()
+	created := 0
+	create := func() interface{} { created++; return created }
+	if s.Get("a", create) != 1 || s.Get("a", create) != 1 || s.Get("b", create) != 2 {
+		t.Error("expected one value per session")
+	}
+
+	// Runs share the sessions of their context.
+	e := NewEngine()
+	var seen []*Sessions
+	e.RegisterAction(AgentAction, func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		seen = append(seen, SessionsFromContext(ctx))
+		return nil, nil
+	})
+	step := &Step{ID: "s", Type: StepTypeAgent, Prompt: "hi"}
+	ctx := WithSessions(context.Background(), s)
+	for _, c := range []context.Context{ctx, ctx, context.Background()} {
+		if _, err := e.ExecuteStep(c, step, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if seen[0] != s || seen[1] != s || seen[2] == s || seen[2] == nil {
+		t.Errorf("unexpected session stores %v", seen)
+	}
+}
+
+func TestValidatorAgentSteps(t *testing.T) {
+	tests := []struct {
+		name string
+		step Step
+		want string
+	}{
+		{"missing prompt", Step{Type: StepTypeAgent, Agent: &AgentRef{Name: "a"}}, "steps[0].prompt: required for agent steps"},
+		{"prompt outside agent step", Step{Action: "echo", Prompt: "hi"}, "steps[0].prompt: only allowed in agent steps"},
+		{"session outside agent step", Step{Action: "echo", Session: "s"}, "steps[0].session: only allowed in agent steps"},
+		{"unknown ref", Step{Type: StepTypeAgent, Prompt: "${{ steps.other.output }}"}, `steps[0].prompt: unknown step "other"`},
+		{"bad session template", Step{Type: StepTypeAgent, Prompt: "hi", Session: "${{ "}, "steps[0].session"},
+		{"name and inline", Step{Type: StepTypeAgent, Prompt: "hi", Agent: &AgentRef{Name: "a", Inline: &AgentConfig{}}}, "exactly one"},
+		{"bad timeout", Step{Type: StepTypeAgent, Prompt: "hi", Agent: &AgentRef{Inline: &AgentConfig{Timeout: "soon"}}}, `steps[0].agent.timeout: invalid duration "soon"`},
+		{"negative max tokens", Step{Type: StepTypeAgent, Prompt: "hi", Agent: &AgentRef{Inline: &AgentConfig{MaxTokens: -1}}}, "steps[0].agent.max_tokens"},
+	}
+	for _, tt := range tests {
+		tt.step.ID, tt.step.Name = "s", "S"
+		err := NewValidator().Validate(&Workflow{Name: "test", Steps: []Step{tt.step}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	// The prompt may come from With instead.
+	w := &Workflow{Name: "test", Steps: []Step{{ID: "s", Name: "S", Type: StepTypeAgent, With: map[string]interface{}{"prompt": "hi"}}}}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package workflow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrWorkflowChanged is returned when resuming a run of a workflow whose
+// definition differs from the one the run started with.
+var ErrWorkflowChanged = errors.New("workflow definition changed since the run started")
+
+// RunState is the checkpointed state of a workflow run.
+type RunState struct {
+	RunID        string `json:"run_id"`
+	WorkflowName string `json:"workflow_name"`
+	// WorkflowHash identifies the definition the run started with (see
+	// HashWorkflow).
+	WorkflowHash string                 `json:"workflow_hash"`
+	Status       StepStatus             `json:"status"`
+	Error        string                 `json:"error,omitempty"`
+	Inputs       map[string]interface{} `json:"inputs,omitempty"`
+	// Steps holds the state of each top-level step by ID.
+	Steps     map[string]*StepState `json:"steps"`
+	StartTime time.Time             `json:"start_time"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// StepState is the checkpointed state of a top-level step.
+type StepState struct {
+	Status    StepStatus             `json:"status"`
+	Output    map[string]interface{} `json:"output,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Attempts  []Attempt              `json:"attempts,omitempty"`
+	StartTime time.Time              `json:"start_time,omitempty"`
+	EndTime   time.Time              `json:"end_time,omitempty"`
+}
+
+// HashWorkflow returns a digest of a workflow definition. Definitions
+// that encode to the same JSON have the same hash.
+func HashWorkflow(w *Workflow) (string, error) {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return "", fmt.Errorf("hash workflow: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// newRunState returns the state of a new run of w, with every step
+// pending.
+func newRunState(w *Workflow, inputs map[string]interface{}) (*RunState, error) {
+	hash, err := HashWorkflow(w)
+	if err != nil {
+		return nil, err
+	}
+	state := &RunState{
+		RunID:        uuid.NewString(),
+		WorkflowName: w.Name,
+		WorkflowHash: hash,
+		Status:       StatusPending,
+		Inputs:       inputs,
+		Steps:        make(map[string]*StepState, len(w.Steps)),
+		StartTime:    time.Now(),
+	}
+	for _, step := range w.Steps {
+		state.Steps[step.ID] = &StepState{Status: StatusPending}
+	}
+	return state, nil
+}
+
+// completed returns the results of the steps that completed, which a
+// resumed run does not run again.
+func (s *RunState) completed() map[string]*StepResult {
+	done := make(map[string]*StepResult)
+	for id, st := range s.Steps {
+		if st.Status == StatusCompleted {
+			done[id] = st.result(id)
+		}
+	}
+	return done
+}
+
+func stepState(r *StepResult) *StepState {
+	st := &StepState{
+		Status:    r.Status,
+		Output:    r.Output,
+		Attempts:  r.Attempts,
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+	}
+	if r.Error != nil {
+		st.Error = r.Error.Error()
+	}
+	return st
+}
+
+func (st *StepState) result(id string) *StepResult {
+	return &StepResult{
+		StepID:    id,
+		Status:    st.Status,
+		Output:    st.Output,
+		Attempts:  st.Attempts,
+		StartTime: st.StartTime,
+		EndTime:   st.EndTime,
+		Duration:  st.EndTime.Sub(st.StartTime),
+	}
+}
+
+// checkpointer saves a run's state to the engine's store as its steps
+// finish. A nil checkpointer saves nothing.
+type checkpointer struct {
+	ctx   context.Context
+	store RunStore
+	state *RunState
+	// err is the first failed save.
+	err error
+}
+
+func (e *DefaultEngine) checkpointer(ctx context.Context, state *RunState) *checkpointer {
+	if e.Store == nil {
+		return nil
+	}
+	// Checkpoints are still saved once the run is cancelled.
+	return &checkpointer{ctx: context.WithoutCancel(ctx), store: e.Store, state: state}
+}
+
+func (c *checkpointer) save() error {
+	if c == nil {
+		return nil
+	}
+	c.state.UpdatedAt = time.Now()
+	if err := c.store.Save(c.ctx, c.state); err != nil {
+		err = fmt.Errorf("checkpoint run %s: %w", c.state.RunID, err)
+		if c.err == nil {
+			c.err = err
+		}
+		return err
+	}
+	return nil
+}
+
+// record saves the result of a top-level step.
+func (c *checkpointer) record(r *StepResult) {
+	if c == nil {
+		return
+	}
+	c.state.Steps[r.StepID] = stepState(r)
+	_ = c.save()
+}
+
+// finish saves the final state of the run and returns the first failed
+// save, if any.
+func (c *checkpointer) finish(result *WorkflowResult) error {
+	if c == nil {
+		return nil
+	}
+	for _, r := range result.Steps {
+		c.state.Steps[r.StepID] = stepState(r)
+	}
+	c.state.Status = result.Status
+	c.state.Error = ""
+	if result.Error != nil {
+		c.state.Error = result.Error.Error()
+	}
+	_ = c.save()
+	return c.err
+}
+
+// Resume continues a run checkpointed in the engine's store. Steps that
+// completed keep their results and are not run again; failed, skipped
+// and pending steps run as they would in a new run, which sees the
+// original inputs. Resuming fails with ErrWorkflowChanged if w is not the
+// definition the run started with.
+func (e *DefaultEngine) Resume(ctx context.Context, w *Workflow, runID string) (*WorkflowResult, error) {
+	if e.Store == nil {
+		return nil, fmt.Errorf("resume run %s: no run store", runID)
+	}
+	state, err := e.Store.Load(ctx, runID)
+	if err != nil {
+		return nil, fmt.Errorf("resume run %s: %w", runID, err)
+	}
+	hash, err := HashWorkflow(w)
+	if err != nil {
+		return nil, err
+	}
+	if hash != state.WorkflowHash {
+		return nil, fmt.Errorf("resume run %s: %w", runID, ErrWorkflowChanged)
+	}
+	for _, step := range w.Steps {
+		if state.Steps[step.ID] == nil {
+			state.Steps[step.ID] = &StepState{Status: StatusPending}
+		}
+	}
+	return e.run(ctx, w, state)
+}
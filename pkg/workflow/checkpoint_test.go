@@ -0,0 +1,190 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestCheckpointAndResume(t *testing.T) {
+	w, err := NewParser().Parse([]byte(`
+name: build
+version: "1"
+steps:
+  - id: fetch
+    name: Fetch
+    action: count
+    with:
+      value: ${{ inputs.repo }}
+  - id: compile
+    name: Compile
+    action: maybe
+    depends_on: [fetch]
+    with:
+      value: ${{ steps.fetch.output.value }}
+  - id: ship
+    name: Ship
+    action: count
+    depends_on: [compile]
+    with:
+      value: ${{ steps.compile.output.value }}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	broken := true
+	e := NewEngine()
+	e.Store = NewMemoryRunStore()
+	e.RegisterAction("count", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[inputs["value"].(string)]++
+		return map[string]interface{}{"value": inputs["value"]}, nil
+	})
+	e.RegisterAction("maybe", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if broken {
+			return nil, errors.New("compiler crashed")
+		}
+		return map[string]interface{}{"value": inputs["value"].(string) + ".bin"}, nil
+	})
+
+	result, err := e.ExecuteWithInputs(context.Background(), w, map[string]interface{}{"repo": "openagent"})
+	if err == nil || result.RunID == "" {
+		t.Fatalf("expected a failed run with an ID, got %v", err)
+	}
+	state, err := e.Store.Load(context.Background(), result.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != StatusFailed || state.Inputs["repo"] != "openagent" || state.WorkflowName != "build" {
+		t.Errorf("unexpected run state %+v", state)
+	}
+	if st := state.Steps["fetch"]; st.Status != StatusCompleted || st.Output["value"] != "openagent" || len(st.Attempts) != 1 {
+		t.Errorf("unexpected fetch state %+v", st)
+	}
+	if st := state.Steps["compile"]; st.Status != StatusFailed || st.Error != "compiler crashed" {
+		t.Errorf("unexpected compile state %+v", st)
+	}
+	if st := state.Steps["ship"]; st.Status != StatusSkipped {
+		t.Errorf("unexpected ship state %+v", st)
+	}
+
+	// A changed definition cannot be resumed.
+	changed := *w
+	changed.Steps = append([]Step(nil), w.Steps...)
+	changed.Steps[2].Timeout = "1m"
+	if _, err := e.Resume(context.Background(), &changed, result.RunID); !errors.Is(err, ErrWorkflowChanged) {
+		t.Errorf("expected ErrWorkflowChanged, got %v", err)
+	}
+	if _, err := e.Resume(context.Background(), w, "missing"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("expected ErrRunNotFound, got %v", err)
+	}
+
+	broken = false
+	resumed, err := e.Resume(context.Background(), w, result.RunID)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if resumed.RunID != result.RunID || resumed.Status != StatusCompleted {
+		t.Errorf("unexpected resumed result %+v", resumed)
+	}
+	// The completed step kept its output and did not run again.
+	if calls["openagent"] != 1 || calls["openagent.bin"] != 1 {
+		t.Errorf("unexpected calls %v", calls)
+	}
+	if got := resumed.Steps[2].Output["value"]; got != "openagent.bin" {
+		t.Errorf("expected output from the resumed chain, got %v", got)
+	}
+	state, _ = e.Store.Load(context.Background(), result.RunID)
+	for id, st := range state.Steps {
+		if st.Status != StatusCompleted {
+			t.Errorf("%s: expected completed, got %s", id, st.Status)
+		}
+	}
+	if state.Status != StatusCompleted {
+		t.Errorf("expected completed run, got %s", state.Status)
+	}
+}
+
+func TestCheckpointOnCancel(t *testing.T) {
+	e := NewEngine()
+	e.Store = NewMemoryRunStore()
+	started := make(chan struct{})
+	e.RegisterAction("block", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	e.RegisterAction("echo", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"ok": true}, nil
+	})
+	w := &Workflow{Name: "test", Steps: []Step{
+		{ID: "a", Name: "A", Action: "echo"},
+		{ID: "b", Name: "B", Action: "block", DependsOn: []string{"a"}},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	result, err := e.Execute(ctx, w)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	state, err := e.Store.Load(context.Background(), result.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Steps["a"].Status != StatusCompleted || state.Steps["b"].Status != StatusFailed {
+		t.Errorf("unexpected state after cancel: a=%s b=%s", state.Steps["a"].Status, state.Steps["b"].Status)
+	}
+}
+
+// failingStore fails every save.
+type failingStore struct{ *MemoryRunStore }
+
+func (failingStore) Save(ctx context.Context, state *RunState) error {
+	return errors.New("disk full")
+}
+
+func TestCheckpointErrors(t *testing.T) {
+	e := newControlEngine()
+	e.Store = failingStore{NewMemoryRunStore()}
+	w := &Workflow{Name: "test", Steps: []Step{{ID: "a", Name: "A", Action: "echo"}}}
+	if _, err := e.Execute(context.Background(), w); err == nil {
+		t.Error("expected checkpoint error")
+	}
+
+	e = newControlEngine()
+	if _, err := e.Resume(context.Background(), w, "x"); err == nil {
+		t.Error("expected error without a store")
+	}
+	// Runs without a store still have IDs.
+	result, err := e.Execute(context.Background(), w)
+	if err != nil || result.RunID == "" {
+		t.Errorf("expected a run ID, got %v, %v", result, err)
+	}
+}
+
+func TestHashWorkflow(t *testing.T) {
+	w := &Workflow{Name: "test", Steps: []Step{{ID: "a", Name: "A", Action: "echo", With: map[string]interface{}{"x": 1, "y": []interface{}{"a"}}}}}
+	h1, err := HashWorkflow(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, _ := HashWorkflow(&Workflow{Name: "test", Steps: []Step{{ID: "a", Name: "A", Action: "echo", With: map[string]interface{}{"y": []interface{}{"a"}, "x": 1}}}})
+	if h1 != h2 {
+		t.Error("expected equal definitions to hash equally")
+	}
+	w.Steps[0].With["x"] = 2
+	if h3, _ := HashWorkflow(w); h3 == h1 {
+		t.Error("expected a changed definition to hash differently")
+	}
+}
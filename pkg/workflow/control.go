@@ -0,0 +1,575 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultMaxIterations bounds while loops that do not set MaxIterations.
+const DefaultMaxIterations = 100
+
+// DefaultLoopVar names the loop variable when Step.As is empty.
+const DefaultLoopVar = "item"
+
+// isControl reports whether steps of type t run nested steps rather than
+// an action.
+func isControl(t StepType) bool {
+	switch t {
+	case StepTypeParallel, StepTypeSequence, StepTypeLoop, StepTypeDecision:
+		return true
+	}
+	return false
+}
+
+// executeControl runs a control step's nested steps and aggregates their
+// outputs. The output of parallel, sequence and decision steps maps each
+// child's ID to its output under "steps"; decisions add the chosen
+// "branch". Loops output "count" and one such map per iteration under
+// "iterations". A failing child fails the control step.
+func (e *DefaultEngine) executeControl(ctx context.Context, step *Step, sc *scope) (map[string]interface{}, []*StepResult, error) {
+	switch step.Type {
+	case StepTypeParallel:
+		limit := step.MaxParallel
+		if limit == 0 {
+			limit = sc.limit
+		}
+		results, err := e.runSteps(ctx, step.Steps, sc, limit)
+		return aggregate(results), results, err
+	case StepTypeSequence:
+		results, err := e.runSteps(ctx, sequential(step.Steps), sc, 1)
+		return aggregate(results), results, err
+	case StepTypeLoop:
+		return e.executeLoop(ctx, step, sc)
+	case StepTypeDecision:
+		return e.executeDecision(ctx, step, sc)
+	}
+	return nil, nil, fmt.Errorf("step type %q has no nested steps", step.Type)
+}
+
+// aggregate maps each step's ID to its output.
+func aggregate(results []*StepResult) map[string]interface{} {
+	outputs := make(map[string]interface{}, len(results))
+	for _, r := range results {
+		outputs[r.StepID] = r.Output
+	}
+	return map[string]interface{}{"steps": outputs}
+}
+
+// sequential returns a copy of steps where each depends on the one
+// before it. A step without an ID, which validation reports, is not
+// depended on.
+func sequential(steps []Step) []Step {
+	out := make([]Step, len(steps))
+	copy(out, steps)
+	for i := 1; i < len(out); i++ {
+		prev := out[i-1].ID
+		deps := append([]string(nil), out[i].DependsOn...)
+		found := false
+		for _, d := range deps {
+			found = found || d == prev
+		}
+		if !found && prev != "" {
+			deps = append(deps, prev)
+		}
+		out[i].DependsOn = deps
+	}
+	return out
+}
+
+func (sc *scope) exprContext() *ExprContext {
+	return &ExprContext{Env: sc.env, Inputs: sc.inputs, Steps: sc.steps, Loop: sc.loop}
+}
+
+// iterationScope returns the scope of one loop iteration.
+func (sc *scope) iterationScope(vars map[string]interface{}) *scope {
+	c := *sc
+	c.loop = make(map[string]interface{}, len(sc.loop)+len(vars))
+	for k, v := range sc.loop {
+		c.loop[k] = v
+	}
+	for k, v := range vars {
+		c.loop[k] = v
+	}
+	return &c
+}
+
+// executeLoop runs a loop step's body once per for_each item, or while its
+// condition holds.
+func (e *DefaultEngine) executeLoop(ctx context.Context, step *Step, sc *scope) (map[string]interface{}, []*StepResult, error) {
+	var (
+		iterations []*StepResult
+		err        error
+	)
+	if step.ForEach != "" {
+		iterations, err = e.forEach(ctx, step, sc)
+	} else {
+		iterations, err = e.while(ctx, step, sc)
+	}
+	outputs := make([]interface{}, len(iterations))
+	for i, it := range iterations {
+		outputs[i] = it.Output
+	}
+	return map[string]interface{}{"count": len(iterations), "iterations": outputs}, iterations, err
+}
+
+func (e *DefaultEngine) forEach(ctx context.Context, step *Step, sc *scope) ([]*StepResult, error) {
+	v, err := Render(step.ForEach, sc.exprContext())
+	if err != nil {
+		return nil, fmt.Errorf("for_each: %w", err)
+	}
+	items, ok := toList(v)
+	if !ok {
+		return nil, fmt.Errorf("for_each: expected a list, got %T", v)
+	}
+	if step.MaxIterations > 0 && len(items) > step.MaxIterations {
+		return nil, fmt.Errorf("for_each: %d items exceed max_iterations %d", len(items), step.MaxIterations)
+	}
+	name := step.As
+	if name == "" {
+		name = DefaultLoopVar
+	}
+	limit := step.MaxParallel
+	if limit == 0 {
+		limit = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	iterations := make([]*StepResult, len(items))
+	sem := make(chan struct{}, limit)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		mu.Lock()
+		stop := firstErr != nil || ctx.Err() != nil
+		mu.Unlock()
+		if stop {
+			break
+		}
+		wg.Add(1)
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			it, err := e.iteration(ctx, step, sc.iterationScope(map[string]interface{}{name: item, "index": i}), i)
+			iterations[i] = it
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	// Iterations not started after a failure are left out.
+	ran := iterations[:0]
+	for _, it := range iterations {
+		if it != nil {
+			ran = append(ran, it)
+		}
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return ran, firstErr
+}
+
+func (e *DefaultEngine) while(ctx context.Context, step *Step, sc *scope) ([]*StepResult, error) {
+	cond, err := CompileExpr(step.While)
+	if err != nil {
+		return nil, fmt.Errorf("while: %w", err)
+	}
+	max := step.MaxIterations
+	if max == 0 {
+		max = DefaultMaxIterations
+	}
+
+	var iterations []*StepResult
+	last := sc.steps
+	for i := 0; ; i++ {
+		it := sc.iterationScope(map[string]interface{}{"index": i})
+		exprCtx := it.exprContext()
+		exprCtx.Steps = last
+		ok, err := cond.EvalBool(exprCtx)
+		if err != nil {
+			return iterations, fmt.Errorf("while: %w", err)
+		}
+		if !ok {
+			return iterations, nil
+		}
+		if i >= max {
+			return iterations, fmt.Errorf("while: still true after max_iterations %d", max)
+		}
+		if err := ctx.Err(); err != nil {
+			return iterations, err
+		}
+
+		r, err := e.iteration(ctx, step, it, i)
+		iterations = append(iterations, r)
+		if err != nil {
+			return iterations, err
+		}
+		// The condition sees the steps of the latest iteration.
+		last = make(map[string]*StepResult, len(sc.steps)+len(r.Children))
+		for id, sr := range sc.steps {
+			last[id] = sr
+		}
+		for _, c := range r.Children {
+			last[c.StepID] = c
+		}
+	}
+}
+
+// iteration runs a loop body once and records it as a child result.
+func (e *DefaultEngine) iteration(ctx context.Context, step *Step, sc *scope, i int) (*StepResult, error) {
+	r := &StepResult{StepID: fmt.Sprintf("%s[%d]", step.ID, i), Status: StatusRunning, StartTime: time.Now()}
+	children, err := e.runSteps(ctx, step.Steps, sc.at(fmt.Sprintf("%s[%d]", sc.path, i)), sc.limit)
+	r.Children = children
+	r.Output = aggregate(children)
+	r.EndTime = time.Now()
+	r.Duration = r.EndTime.Sub(r.StartTime)
+	if err != nil {
+		r.Status = StatusFailed
+		r.Error = err
+		return r, fmt.Errorf("iteration %d: %w", i, err)
+	}
+	r.Status = StatusCompleted
+	return r, nil
+}
+
+// toList converts any slice or array to []interface{}.
+func toList(v interface{}) ([]interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	if l, ok := v.([]interface{}); ok {
+		return l, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out, true
+}
+
+// executeDecision runs the first branch whose condition holds.
+func (e *DefaultEngine) executeDecision(ctx context.Context, step *Step, sc *scope) (map[string]interface{}, []*StepResult, error) {
+	for i, b := range step.Branches {
+		if b.If != "" {
+			cond, err := CompileExpr(b.If)
+			if err != nil {
+				return nil, nil, fmt.Errorf("branch %s: %w", branchName(b, i), err)
+			}
+			ok, err := cond.EvalBool(sc.exprContext())
+			if err != nil {
+				return nil, nil, fmt.Errorf("branch %s: %w", branchName(b, i), err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		results, err := e.runSteps(ctx, b.Steps, sc, sc.limit)
+		out := aggregate(results)
+		out["branch"] = branchName(b, i)
+		return out, results, err
+	}
+	return map[string]interface{}{"branch": nil, "steps": map[string]interface{}{}}, nil, nil
+}
+
+// branchName returns the branch's name, or its index if unnamed.
+func branchName(b Branch, i int) string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return strconv.Itoa(i)
+}
+
+// checkRefs compiles the conditions and templates of w and checks that
+// every step they reference is in scope: a dependency of the referencing
+// step, or visible to an enclosing control step. It also checks the
+// structure of control steps and error handling.
+func checkRefs(w *Workflow) error {
+	d := &diagnostics{}
+	checkSteps(w, d)
+	return d.err()
+}
+
+// checkSteps records the problems checkRefs reports in d.
+func checkSteps(w *Workflow, d *diagnostics) {
+	wenv, err := templateExprs("env", w.Env)
+	if err != nil {
+		d.addErr("env", err)
+	}
+	for _, field := range sortedFields(wenv) {
+		for _, expr := range wenv[field] {
+			if len(expr.StepRefs()) > 0 {
+				d.add(field, "workflow env cannot reference steps")
+			}
+			if expr.usesLoop() {
+				d.add(field, "loop variables are only available inside loop steps")
+			}
+		}
+	}
+	if w.OnError != nil {
+		// The workflow handler may handle any step, so its step cannot
+		// reference particular ones.
+		checkHandler(d, w.OnError, "workflow", "on_error", nil, false)
+	}
+	checkLevel(d, w.Steps, "", nil, false)
+}
+
+// checkLevel checks one level of steps. outer holds the IDs of steps
+// visible from enclosing levels, and inLoop whether loop variables are in
+// scope.
+func checkLevel(d *diagnostics, steps []Step, prefix string, outer map[string]bool, inLoop bool) {
+	for i, step := range steps {
+		if step.ID == "" {
+			d.add(fmt.Sprintf("%ssteps[%d].id", prefix, i), "required")
+		}
+	}
+	g, errs := buildGraph(steps)
+	for _, verr := range errs {
+		d.add(prefix+verr.Field, verr.Message)
+	}
+	level := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		level[step.ID] = true
+	}
+
+	for i := range steps {
+		visible := make(map[string]bool, len(outer))
+		for id := range outer {
+			visible[id] = true
+		}
+		for j, id := range g.ids {
+			if g.reaches(i, j) {
+				visible[id] = true
+			}
+		}
+		checkStep(d, &steps[i], fmt.Sprintf("%ssteps[%d]", prefix, i), &refScope{visible: visible, level: level, inLoop: inLoop})
+	}
+}
+
+// checkStep checks a step and its nested steps. p is the step's field
+// path and sc what its expressions may reference.
+func checkStep(d *diagnostics, step *Step, p string, sc *refScope) {
+	switch step.Type {
+	case "", StepTypeTask, StepTypeAgent, StepTypeParallel, StepTypeSequence, StepTypeLoop, StepTypeDecision:
+	default:
+		d.add(p+".type", fmt.Sprintf("unknown step type %q", step.Type))
+	}
+	if step.Timeout != "" {
+		if t, err := time.ParseDuration(step.Timeout); err != nil || t <= 0 {
+			d.add(p+".timeout", fmt.Sprintf("invalid duration %q", step.Timeout))
+		}
+	}
+
+	exprs := make(map[string][]*Expr)
+	if step.If != "" {
+		if expr, err := CompileExpr(step.If); err != nil {
+			d.add(p+".if", err.Error())
+		} else {
+			exprs[p+".if"] = []*Expr{expr}
+			if v, ok := constant(expr); ok && !v {
+				d.warn(p+".if", "always false, so the step never runs")
+			}
+		}
+	}
+	for _, v := range []struct {
+		name  string
+		value interface{}
+	}{{"env", step.Env}, {"with", map[string]interface{}(step.With)}} {
+		found, err := templateExprs(p+"."+v.name, v.value)
+		if err != nil {
+			d.addErr(p+"."+v.name, err)
+		}
+		for field, e := range found {
+			exprs[field] = e
+		}
+	}
+	sc.checkAll(d, exprs)
+	checkControl(d, step, p, sc)
+	checkAgent(d, step, p, sc)
+	checkErrorHandling(d, step, p, sc)
+}
+
+// checkNames checks that nested steps are named, as top-level steps are.
+func checkNames(d *diagnostics, steps []Step, prefix string) {
+	for i, step := range steps {
+		p := fmt.Sprintf("%ssteps[%d]", prefix, i)
+		if step.Name == "" {
+			d.add(p+".name", "required")
+		}
+		checkNames(d, step.Steps, p+".")
+		for j, b := range step.Branches {
+			checkNames(d, b.Steps, fmt.Sprintf("%s.branches[%d].", p, j))
+		}
+	}
+}
+
+// constant returns the value of a condition that reads nothing from its
+// context, so always evaluates the same. ok is false for other
+// conditions.
+func constant(cond *Expr) (value, ok bool) {
+	if !cond.constant() {
+		return false, false
+	}
+	v, err := cond.EvalBool(nil)
+	return v, err == nil
+}
+
+// refScope is what expressions at one point of a workflow may reference.
+type refScope struct {
+	visible map[string]bool
+	level   map[string]bool
+	inLoop  bool
+}
+
+func (sc *refScope) check(d *diagnostics, field string, expr *Expr) {
+	for _, ref := range expr.StepRefs() {
+		if sc.visible[ref] {
+			continue
+		}
+		if sc.level[ref] {
+			d.add(field, fmt.Sprintf("step %q is not a dependency", ref))
+		} else {
+			d.add(field, fmt.Sprintf("unknown step %q", ref))
+		}
+	}
+	if expr.usesLoop() && !sc.inLoop {
+		d.add(field, "loop variables are only available inside loop steps")
+	}
+}
+
+func (sc *refScope) checkAll(d *diagnostics, exprs map[string][]*Expr) {
+	for _, field := range sortedFields(exprs) {
+		for _, expr := range exprs[field] {
+			sc.check(d, field, expr)
+		}
+	}
+}
+
+// sortedFields returns the fields of exprs in order.
+func sortedFields(exprs map[string][]*Expr) []string {
+	fields := make([]string, 0, len(exprs))
+	for field := range exprs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// checkControl checks the nested steps of a control step, or that other
+// steps have none.
+func checkControl(d *diagnostics, step *Step, p string, sc *refScope) {
+	if step.MaxParallel < 0 {
+		d.add(p+".max_parallel", "must not be negative")
+	}
+	if step.MaxIterations < 0 {
+		d.add(p+".max_iterations", "must not be negative")
+	}
+
+	switch step.Type {
+	case StepTypeParallel, StepTypeSequence:
+		if len(step.Steps) == 0 {
+			d.add(p+".steps", fmt.Sprintf("required for %s steps", step.Type))
+		}
+		children := step.Steps
+		if step.Type == StepTypeSequence {
+			children = sequential(children)
+		}
+		checkLevel(d, children, p+".", sc.visible, sc.inLoop)
+
+	case StepTypeLoop:
+		if (step.ForEach == "") == (step.While == "") {
+			d.add(p, "loop steps need exactly one of for_each and while")
+		}
+		if len(step.Steps) == 0 {
+			d.add(p+".steps", "required for loop steps")
+		}
+		if step.ForEach != "" {
+			parts, err := parseTemplate(step.ForEach)
+			switch {
+			case err != nil:
+				d.add(p+".for_each", err.Error())
+			case len(parts) != 1 || parts[0].expr == nil:
+				d.add(p+".for_each", "must be a single ${{ }} expression")
+			default:
+				sc.check(d, p+".for_each", parts[0].expr)
+			}
+		} else if step.While != "" {
+			if cond, err := CompileExpr(step.While); err != nil {
+				d.add(p+".while", err.Error())
+			} else {
+				// The condition may read the previous iteration's steps.
+				body := &refScope{visible: make(map[string]bool), level: sc.level, inLoop: true}
+				for id := range sc.visible {
+					body.visible[id] = true
+				}
+				for _, child := range step.Steps {
+					body.visible[child.ID] = true
+				}
+				body.check(d, p+".while", cond)
+				if v, ok := constant(cond); ok && !v {
+					d.warn(p+".while", "always false, so the loop body never runs")
+				}
+			}
+		}
+		checkLevel(d, step.Steps, p+".", sc.visible, true)
+
+	case StepTypeDecision:
+		if len(step.Branches) == 0 {
+			d.add(p+".branches", "required for decision steps")
+		}
+		taken := ""
+		for i, b := range step.Branches {
+			bp := fmt.Sprintf("%s.branches[%d]", p, i)
+			if taken != "" {
+				d.warn(bp, fmt.Sprintf("unreachable: branch %s always runs first", taken))
+			}
+			if b.If == "" && i != len(step.Branches)-1 {
+				d.add(bp+".if", "only the last branch may omit if")
+			}
+			if b.If != "" {
+				if cond, err := CompileExpr(b.If); err != nil {
+					d.add(bp+".if", err.Error())
+				} else {
+					sc.check(d, bp+".if", cond)
+					if v, ok := constant(cond); ok && !v {
+						d.warn(bp+".if", "always false, so the branch never runs")
+					} else if ok && taken == "" {
+						taken = branchName(b, i)
+					}
+				}
+			}
+			if len(b.Steps) == 0 {
+				d.add(bp+".steps", "required")
+			}
+			checkLevel(d, b.Steps, bp+".", sc.visible, sc.inLoop)
+		}
+
+	default:
+		if len(step.Steps) > 0 || len(step.Branches) > 0 {
+			d.add(p+".steps", fmt.Sprintf("nested steps are not allowed in %q steps", step.Type))
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newControlEngine() *DefaultEngine {
+	e := NewEngine()
+	e.RegisterAction("echo", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"value": inputs["value"]}, nil
+	})
+	e.RegisterAction("fail", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	e.RegisterAction("sleep", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			return nil, nil
+		}
+	})
+	return e
+}
+
+func TestControlSteps(t *testing.T) {
+	w, err := NewParser().Parse([]byte(`
+name: review-files
+version: "1"
+steps:
+  - id: plan
+    name: Plan
+    type: task
+    action: echo
+    with:
+      value: [a.go, b.go, c.go]
+  - id: checks
+    name: Checks
+    type: parallel
+    depends_on: [plan]
+    steps:
+      - id: lint
+        name: Lint
+        action: echo
+        with:
+          value: lint-ok
+      - id: test
+        name: Test
+        action: echo
+        with:
+          value: test-ok
+  - id: review
+    name: Review each file
+    type: loop
+    depends_on: [plan]
+    for_each: ${{ steps.plan.output.value }}
+    as: file
+    max_parallel: 2
+    steps:
+      - id: read
+        name: Read
+        action: echo
+        with:
+          value: ${{ loop.file }}
+      - id: comment
+        name: Comment
+        action: echo
+        depends_on: [read]
+        with:
+          value: "#${{ loop.index }} ${{ steps.read.output.value }}"
+  - id: route
+    name: Route
+    type: decision
+    depends_on: [checks]
+    branches:
+      - name: broken
+        if: steps.checks.output.steps.lint.value != 'lint-ok'
+        steps:
+          - id: fix
+            name: Fix
+            action: fail
+      - name: ok
+        steps:
+          - id: ship
+            name: Ship
+            type: sequence
+            steps:
+              - id: tag
+                name: Tag
+                action: echo
+                with:
+                  value: v1
+              - id: publish
+                name: Publish
+                action: echo
+                with:
+                  value: published ${{ steps.tag.output.value }}
+`))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+
+	result, err := newControlEngine().Execute(context.Background(), w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checks := result.Steps[1]
+	if len(checks.Children) != 2 || checks.Output["steps"].(map[string]interface{})["test"].(map[string]interface{})["value"] != "test-ok" {
+		t.Errorf("unexpected parallel output %v", checks.Output)
+	}
+
+	review := result.Steps[2]
+	if review.Output["count"] != 3 || len(review.Children) != 3 {
+		t.Fatalf("expected 3 iterations, got %v", review.Output)
+	}
+	last := review.Children[2]
+	if last.StepID != "review[2]" || len(last.Children) != 2 {
+		t.Fatalf("unexpected iteration %+v", last)
+	}
+	if got := last.Children[1].Output["value"]; got != "#2 c.go" {
+		t.Errorf("expected '#2 c.go', got %v", got)
+	}
+
+	route := result.Steps[3]
+	if route.Output["branch"] != "ok" {
+		t.Errorf("expected ok branch, got %v", route.Output["branch"])
+	}
+	ship := route.Children[0]
+	if got := ship.Children[1].Output["value"]; got != "published v1" {
+		t.Errorf("expected sequence to pass outputs, got %v", got)
+	}
+}
+
+func TestSequential(t *testing.T) {
+	steps := sequential([]Step{{ID: "a"}, {ID: "b", DependsOn: []string{"a"}}, {}, {ID: "d"}})
+	for i, want := range []string{"", "a", "b", ""} {
+		if got := strings.Join(steps[i].DependsOn, ","); got != want {
+			t.Errorf("step %d: expected dependencies %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestWhileLoop(t *testing.T) {
+	e := newControlEngine()
+	var mu sync.Mutex
+	calls := 0
+	e.RegisterAction("poll", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return map[string]interface{}{"done": calls >= 3}, nil
+	})
+
+	w := &Workflow{Name: "poll", Steps: []Step{{
+		ID: "wait", Name: "Wait", Type: StepTypeLoop,
+		While: "steps.check.output.done != true",
+		Steps: []Step{{ID: "check", Name: "Check", Action: "poll"}},
+	}}}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	result, err := e.Execute(context.Background(), w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Steps[0].Output["count"] != 3 {
+		t.Errorf("expected 3 iterations, got %v", result.Steps[0].Output["count"])
+	}
+
+	// The guard stops a loop that never ends.
+	w.Steps[0].While = "true"
+	w.Steps[0].MaxIterations = 5
+	result, err = e.Execute(context.Background(), w)
+	if err == nil || !strings.Contains(err.Error(), "max_iterations 5") {
+		t.Errorf("expected max iterations error, got %v", err)
+	}
+	if result.Steps[0].Status != StatusFailed || len(result.Steps[0].Children) != 5 {
+		t.Errorf("expected 5 iterations before failing, got %d", len(result.Steps[0].Children))
+	}
+}
+
+func TestControlErrorsAndTimeouts(t *testing.T) {
+	e := newControlEngine()
+
+	// A failing child fails the parallel step and cancels its siblings.
+	w := &Workflow{Name: "test", Steps: []Step{{
+		ID: "group", Name: "Group", Type: StepTypeParallel,
+		Steps: []Step{
+			{ID: "bad", Name: "Bad", Action: "fail"},
+			{ID: "slow", Name: "Slow", Action: "sleep"},
+		},
+	}}}
+	start := time.Now()
+	result, err := e.Execute(context.Background(), w)
+	if err == nil || !strings.Contains(err.Error(), "step group: step bad: boom") {
+		t.Errorf("expected nested error, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("expected siblings to be cancelled")
+	}
+	if result.Steps[0].Children[1].Status != StatusFailed {
+		t.Errorf("expected cancelled sibling to fail, got %s", result.Steps[0].Children[1].Status)
+	}
+
+	// A control step's timeout bounds its children.
+	w.Steps[0] = Step{
+		ID: "loop", Name: "Loop", Type: StepTypeLoop, Timeout: "20ms",
+		ForEach: "${{ inputs.items }}",
+		Steps:   []Step{{ID: "slow", Name: "Slow", Action: "sleep"}},
+	}
+	start = time.Now()
+	_, err = e.ExecuteWithInputs(context.Background(), w, map[string]interface{}{"items": []int{1, 2, 3}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("expected loop to stop at its timeout")
+	}
+
+	// for_each must yield a list.
+	_, err = e.ExecuteWithInputs(context.Background(), w, map[string]interface{}{"items": "abc"})
+	if err == nil || !strings.Contains(err.Error(), "expected a list") {
+		t.Errorf("expected list error, got %v", err)
+	}
+}
+
+func TestValidatorControlSteps(t *testing.T) {
+	body := []Step{{ID: "x", Name: "X", Action: "echo"}}
+	tests := []struct {
+		name string
+		step Step
+		want string
+	}{
+		{"parallel without steps", Step{Type: StepTypeParallel}, "steps[0].steps: required"},
+		{"loop without kind", Step{Type: StepTypeLoop, Steps: body}, "exactly one of for_each and while"},
+		{"loop with both", Step{Type: StepTypeLoop, ForEach: "${{ inputs.a }}", While: "true", Steps: body}, "exactly one"},
+		{"for_each literal", Step{Type: StepTypeLoop, ForEach: "a,b", Steps: body}, "single ${{ }} expression"},
+		{"decision default first", Step{Type: StepTypeDecision, Branches: []Branch{{Steps: body}, {If: "true", Steps: body}}}, "only the last branch"},
+		{"nested in task", Step{Type: StepTypeTask, Steps: body}, "nested steps are not allowed"},
+		{"loop var outside loop", Step{Type: StepTypeTask, If: "loop.item == 1"}, "only available inside loop steps"},
+		{"nested unknown dep", Step{Type: StepTypeParallel, Steps: []Step{{ID: "x", Name: "X", DependsOn: []string{"y"}}}}, `steps[0].steps[0].depends_on: unknown step "y"`},
+		{"nested ref to non-dependency", Step{Type: StepTypeParallel, Steps: []Step{
+			{ID: "x", Name: "X"},
+			{ID: "y", Name: "Y", If: "steps.x.status == 'completed'"},
+		}}, `step "x" is not a dependency`},
+	}
+	for _, tt := range tests {
+		tt.step.ID, tt.step.Name = "s", "S"
+		err := NewValidator().Validate(&Workflow{Name: "test", Steps: []Step{tt.step}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	// Children may reference steps their container depends on.
+	w := &Workflow{Name: "test", Steps: []Step{
+		{ID: "a", Name: "A", Action: "echo"},
+		{ID: "b", Name: "B", Type: StepTypeSequence, DependsOn: []string{"a"}, Steps: []Step{
+			{ID: "c", Name: "C", If: "steps.a.status == 'completed'"},
+			{ID: "d", Name: "D", With: map[string]any{"v": "${{ steps.c.output.value }}"}},
+		}},
+	}}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
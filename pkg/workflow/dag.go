@@ -0,0 +1,188 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// graph is the dependency graph of a workflow's steps, indexed by position
+// in Workflow.Steps.
+type graph struct {
+	ids        []string
+	deps       [][]int // steps each step depends on
+	dependents [][]int // steps that depend on each step
+}
+
+// newGraph builds the dependency graph of steps. It reports duplicate IDs,
+// self-references, unknown dependencies and cycles as ValidationErrors.
+func newGraph(steps []Step) (*graph, error) {
+	for i, step := range steps {
+		if step.ID == "" {
+			return nil, &ValidationError{Field: fmt.Sprintf("steps[%d].id", i), Message: "required"}
+		}
+	}
+	g, errs := buildGraph(steps)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return g, nil
+}
+
+// buildGraph builds the dependency graph of steps and returns every
+// problem newGraph reports. The graph leaves out the dependencies in
+// error. Steps without an ID are left for the caller to report.
+func buildGraph(steps []Step) (*graph, []*ValidationError) {
+	g := &graph{
+		ids:        make([]string, len(steps)),
+		deps:       make([][]int, len(steps)),
+		dependents: make([][]int, len(steps)),
+	}
+	var errs []*ValidationError
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		g.ids[i] = step.ID
+		if step.ID == "" {
+			continue
+		}
+		if _, dup := index[step.ID]; dup {
+			errs = append(errs, &ValidationError{Field: fmt.Sprintf("steps[%d].id", i), Message: fmt.Sprintf("duplicate step id %q", step.ID)})
+			continue
+		}
+		index[step.ID] = i
+	}
+	for i, step := range steps {
+		for _, dep := range step.DependsOn {
+			field := fmt.Sprintf("steps[%d].depends_on", i)
+			if dep == step.ID {
+				errs = append(errs, &ValidationError{Field: field, Message: fmt.Sprintf("step %q depends on itself", step.ID)})
+				continue
+			}
+			j, ok := index[dep]
+			if !ok {
+				errs = append(errs, &ValidationError{Field: field, Message: fmt.Sprintf("unknown step %q", dep)})
+				continue
+			}
+			g.deps[i] = append(g.deps[i], j)
+			g.dependents[j] = append(g.dependents[j], i)
+		}
+	}
+	if cycle := g.findCycle(); cycle != nil {
+		path := make([]string, len(cycle))
+		for k, i := range cycle {
+			path[k] = g.ids[i]
+		}
+		errs = append(errs, &ValidationError{Field: "steps", Message: "dependency cycle: " + strings.Join(path, " -> ")})
+	}
+	return g, errs
+}
+
+// findCycle returns the steps of a dependency cycle, starting and ending
+// with the same step, or nil if the graph is acyclic.
+func (g *graph) findCycle() []int {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(g.ids))
+	var stack []int
+	var visit func(i int) []int
+	visit = func(i int) []int {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, d := range g.deps[i] {
+			switch state[d] {
+			case visiting:
+				for k, s := range stack {
+					if s == d {
+						return append(append([]int(nil), stack[k:]...), d)
+					}
+				}
+			case unvisited:
+				if cycle := visit(d); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+		return nil
+	}
+	for i := range g.ids {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// reaches reports whether step i depends on step j, directly or
+// transitively.
+func (g *graph) reaches(i, j int) bool {
+	seen := make([]bool, len(g.ids))
+	var visit func(k int) bool
+	visit = func(k int) bool {
+		for _, d := range g.deps[k] {
+			if d == j {
+				return true
+			}
+			if !seen[d] {
+				seen[d] = true
+				if visit(d) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return visit(i)
+}
+
+// TopologicalOrder returns the step IDs in an order where every step comes
+// after its dependencies. Among steps whose dependencies are met, earlier
+// definitions come first.
+func TopologicalOrder(steps []Step) ([]string, error) {
+	g, err := newGraph(steps)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]int, len(steps))
+	for i := range steps {
+		pending[i] = len(g.deps[i])
+	}
+	var ready, order []int
+	for i, n := range pending {
+		if n == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, d := range g.dependents[i] {
+			if pending[d]--; pending[d] == 0 {
+				ready = insertSorted(ready, d)
+			}
+		}
+	}
+	ids := make([]string, len(order))
+	for k, i := range order {
+		ids[k] = g.ids[i]
+	}
+	return ids, nil
+}
+
+// insertSorted inserts i into the ascending slice s.
+func insertSorted(s []int, i int) []int {
+	k := 0
+	for k < len(s) && s[k] < i {
+		k++
+	}
+	s = append(s, 0)
+	copy(s[k+1:], s[k:])
+	s[k] = i
+	return s
+}
@@ -4,14 +4,32 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
-// DefaultEngine implements the Engine interface.
+// DefaultMaxParallel is the default limit on concurrently running steps.
+const DefaultMaxParallel = 4
+
+// DefaultEngine implements the Engine interface. Steps run as a DAG: each
+// step starts once the steps it depends on have completed, and independent
+// steps run concurrently.
 type DefaultEngine struct {
-	actions map[string]ActionHandler
+	mu        sync.RWMutex
+	actions   map[string]ActionHandler
+	observers []Observer
+
+	// MaxParallel limits concurrently running steps for workflows that do
+	// not set their own limit. Zero uses DefaultMaxParallel.
+	MaxParallel int
+	// FailurePolicy applies to workflows that do not set their own. Empty
+	// uses FailureCancel.
+	FailurePolicy FailurePolicy
+	// Store checkpoints runs as their top-level steps finish, so they can
+	// be resumed (see Resume). Nil disables checkpointing.
+	Store RunStore
 }
 
 // NewEngine creates a new workflow engine.
@@ -23,19 +41,65 @@ func NewEngine() *DefaultEngine {
 
 // RegisterAction registers an action handler.
 func (e *DefaultEngine) RegisterAction(name string, handler ActionHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.actions[name] = handler
 }
 
-// Execute runs a workflow.
+// HasAction reports whether an action is registered under name.
+func (e *DefaultEngine) HasAction(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	_, ok := e.actions[name]
+	return ok
+}
+
+// Execute runs a workflow without inputs.
 func (e *DefaultEngine) Execute(ctx context.Context, w *Workflow) (*WorkflowResult, error) {
+	return e.ExecuteWithInputs(ctx, w, nil)
+}
+
+// ExecuteWithInputs runs a workflow. Steps are scheduled from their
+// DependsOn dependencies, up to the parallelism limit at a time. A step
+// whose If condition is false is skipped; its dependents still run and can
+// check steps.<id>.status. When a step fails, the failure policy decides
+// which other steps still run, and steps that do not run are reported as
+// skipped. Step results are in definition order. With a Store, the run is
+// checkpointed under the result's RunID.
+func (e *DefaultEngine) ExecuteWithInputs(ctx context.Context, w *Workflow, inputs map[string]interface{}) (*WorkflowResult, error) {
+	state, err := newRunState(w, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return e.run(ctx, w, state)
+}
+
+// run executes a new or resumed run of w.
+func (e *DefaultEngine) run(ctx context.Context, w *Workflow, state *RunState) (*WorkflowResult, error) {
 	start := time.Now()
 	result := &WorkflowResult{
+		RunID:        state.RunID,
 		WorkflowName: w.Name,
 		Status:       StatusRunning,
-		Steps:        make([]*StepResult, 0, len(w.Steps)),
 		StartTime:    start,
 	}
 
+	if _, err := newGraph(w.Steps); err != nil {
+		return nil, fmt.Errorf("invalid workflow: %w", err)
+	}
+	if err := checkRefs(w); err != nil {
+		return nil, fmt.Errorf("invalid workflow: %w", err)
+	}
+	inputs := state.Inputs
+	env, err := renderEnv(w.Env, &ExprContext{Env: w.Env, Inputs: inputs})
+	if err != nil {
+		return nil, fmt.Errorf("workflow %w", err)
+	}
+	limit, policy, err := e.schedule(w)
+	if err != nil {
+		return nil, err
+	}
+
 	// Apply workflow timeout
 	if w.Timeout != "" {
 		d, err := time.ParseDuration(w.Timeout)
@@ -47,50 +111,421 @@ func (e *DefaultEngine) Execute(ctx context.Context, w *Workflow) (*WorkflowResu
 		defer cancel()
 	}
 
-	// Execute steps sequentially for now
-	for _, step := range w.Steps {
-		stepResult, err := e.ExecuteStep(ctx, &step, nil)
-		result.Steps = append(result.Steps, stepResult)
+	cp := e.checkpointer(ctx, state)
+	state.Status = StatusRunning
+	if err := cp.save(); err != nil {
+		return nil, err
+	}
+	events := e.emitter(state.RunID, w.Name)
+	sc := &scope{env: env, inputs: inputs, limit: limit, policy: policy, onError: w.OnError, events: events}
+	if cp != nil {
+		sc.resumed, sc.record = state.completed(), cp.record
+	}
+	events.emit(Event{Type: EventRunStarted, Status: StatusRunning, Inputs: inputs})
+	steps, err := e.runSteps(withSessions(ctx), w.Steps, sc, limit)
+	result.Steps = steps
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	result.Status = StatusCompleted
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err
+	}
+	if cpErr := cp.finish(result); cpErr != nil && err == nil {
+		err = cpErr
+		result.Status = StatusFailed
+		result.Error = err
+	}
+	finished := Event{Type: EventRunFinished, Status: result.Status, Duration: result.Duration}
+	if err != nil {
+		finished.Error = err.Error()
+	}
+	events.emit(finished)
+	return result, err
+}
+
+// scope is what a level of steps can see: the resolved environment, the
+// workflow inputs, results of steps outside the level and loop variables.
+type scope struct {
+	env    map[string]string
+	inputs map[string]interface{}
+	steps  map[string]*StepResult
+	loop   map[string]interface{}
+	limit  int
+	policy FailurePolicy
+	// onError handles failures of steps without their own handler.
+	onError *ErrorHandler
+	// resumed holds the results of top-level steps completed before the
+	// run was resumed; they are not run again.
+	resumed map[string]*StepResult
+	// record, if set, is called with each top-level result as its step
+	// finishes.
+	record func(*StepResult)
+	// events reports progress to the engine's observers, and path is the
+	// path of the level's parent step in events.
+	events *emitter
+	path   string
+}
+
+// stepPath returns the event path of a step of the scope's level.
+func (sc *scope) stepPath(id string) string {
+	if sc.path == "" {
+		return id
+	}
+	return sc.path + "/" + id
+}
+
+// at returns a copy of the scope for the children of the step at path.
+func (sc *scope) at(path string) *scope {
+	c := *sc
+	c.path = path
+	return &c
+}
+
+// with returns a copy of the scope for a nested level.
+func (sc *scope) with(env map[string]string, steps map[string]*StepResult) *scope {
+	c := *sc
+	c.env = env
+	c.steps = steps
+	c.resumed, c.record = nil, nil
+	return &c
+}
+
+// runSteps runs one level of steps as a DAG, at most limit at a time, and
+// returns their results in definition order along with the first failure.
+func (e *DefaultEngine) runSteps(ctx context.Context, steps []Step, sc *scope, limit int) ([]*StepResult, error) {
+	g, err := newGraph(steps)
+	if err != nil {
+		return nil, err
+	}
+	conds, err := compileConditions(steps)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type completion struct {
+		index  int
+		result *StepResult
+		err    error
+	}
+	n := len(steps)
+	results := make([]*StepResult, n)
+	visible := make(map[string]*StepResult, len(sc.steps)+n)
+	for id, r := range sc.steps {
+		visible[id] = r
+	}
+	pending := make([]int, n)
+	skipped := make([]bool, n)
+	var ready []int
+	queue := func(i int) {
+		ready = insertSorted(ready, i)
+		sc.events.emit(Event{Type: EventStepQueued, StepID: steps[i].ID, Path: sc.stepPath(steps[i].ID), Status: StatusPending})
+	}
+	for i := range steps {
+		if pending[i] = len(g.deps[i]); pending[i] == 0 {
+			queue(i)
+		}
+	}
 
+	stopped := false
+	var firstErr error
+	finish := func(c completion) {
+		results[c.index] = c.result
+		visible[c.result.StepID] = c.result
+		if sc.record != nil {
+			sc.record(c.result)
+		}
+		if c.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("step %s: %w", c.result.StepID, c.err)
+			}
+			if sc.policy == FailureCancel {
+				stopped = true
+				cancel()
+			} else {
+				g.skipDependents(c.index, skipped)
+			}
+			return
+		}
+		for _, d := range g.dependents[c.index] {
+			if pending[d]--; pending[d] == 0 && !skipped[d] {
+				queue(d)
+			}
+		}
+	}
+
+	done := make(chan completion)
+	running := 0
+	for {
+		for len(ready) > 0 && running < limit && !stopped {
+			i := ready[0]
+			ready = ready[1:]
+			path := sc.stepPath(steps[i].ID)
+			if r := sc.resumed[steps[i].ID]; r != nil {
+				sc.events.skipped(path, r, SkipResumed)
+				finish(completion{i, r, nil})
+				continue
+			}
+			exprCtx := &ExprContext{Env: sc.env, Inputs: sc.inputs, Steps: visible, Loop: sc.loop}
+			step, env, run, err := prepareStep(&steps[i], conds[i], exprCtx)
+			if err != nil || !run {
+				r := unstartedResult(&steps[i], err)
+				if err != nil {
+					sc.events.finished(path, r)
+				} else {
+					sc.events.skipped(path, r, SkipCondition)
+				}
+				finish(completion{i, r, err})
+				continue
+			}
+			// Nested levels see a snapshot, since visible changes as
+			// steps finish.
+			snapshot := make(map[string]*StepResult, len(visible))
+			for id, r := range visible {
+				snapshot[id] = r
+			}
+			running++
+			go func(i int, child *scope) {
+				res, err := e.executeStep(WithEnv(ctx, child.env), step, child)
+				done <- completion{i, res, err}
+			}(i, sc.with(env, snapshot))
+		}
+		if running == 0 {
+			break
+		}
+		c := <-done
+		running--
+		finish(c)
+	}
+
+	for i, r := range results {
+		if r == nil {
+			results[i] = &StepResult{StepID: steps[i].ID, Status: StatusSkipped}
+			reason := SkipStopped
+			if skipped[i] {
+				reason = SkipDependency
+			}
+			sc.events.skipped(sc.stepPath(steps[i].ID), results[i], reason)
+		}
+	}
+	return results, firstErr
+}
+
+// compileConditions compiles each step's If expression, leaving nil for
+// steps without one.
+func compileConditions(steps []Step) ([]*Expr, error) {
+	conds := make([]*Expr, len(steps))
+	for i, step := range steps {
+		if step.If == "" {
+			continue
+		}
+		expr, err := CompileExpr(step.If)
 		if err != nil {
-			result.Status = StatusFailed
-			result.Error = err
-			result.EndTime = time.Now()
-			result.Duration = result.EndTime.Sub(result.StartTime)
-			return result, err
+			return nil, &ValidationError{Field: fmt.Sprintf("steps[%d].if", i), Message: err.Error()}
 		}
+		conds[i] = expr
 	}
+	return conds, nil
+}
 
-	result.Status = StatusCompleted
-	result.EndTime = time.Now()
-	result.Duration = result.EndTime.Sub(result.StartTime)
-	return result, nil
+// prepareStep resolves a step's environment, evaluates its condition and
+// renders its With templates, and an agent step's prompt and session,
+// against the run so far. It returns a copy of
+// the step ready to execute, its environment, and whether it should run.
+func prepareStep(step *Step, cond *Expr, run *ExprContext) (*Step, map[string]string, bool, error) {
+	stepEnv, err := renderEnv(step.Env, run)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	env := make(map[string]string, len(run.Env)+len(stepEnv))
+	for k, v := range run.Env {
+		env[k] = v
+	}
+	for k, v := range stepEnv {
+		env[k] = v
+	}
+	ctx := &ExprContext{Env: env, Inputs: run.Inputs, Steps: run.Steps, Loop: run.Loop}
+
+	if cond != nil {
+		ok, err := cond.EvalBool(ctx)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("evaluate condition: %w", err)
+		}
+		if !ok {
+			return nil, nil, false, nil
+		}
+	}
+
+	resolved := *step
+	if step.With != nil {
+		with, err := Render(map[string]interface{}(step.With), ctx)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("with %w", err)
+		}
+		resolved.With = with.(map[string]interface{})
+	}
+	if err := renderAgent(&resolved, ctx); err != nil {
+		return nil, nil, false, err
+	}
+	return &resolved, env, true, nil
 }
 
-// ExecuteStep runs a single step.
+// unstartedResult records a step whose condition was false, or that
+// failed before starting when err is set.
+func unstartedResult(step *Step, err error) *StepResult {
+	now := time.Now()
+	r := &StepResult{StepID: step.ID, Status: StatusSkipped, StartTime: now, EndTime: now}
+	if err != nil {
+		r.Status = StatusFailed
+		r.Error = err
+	}
+	return r
+}
+
+// schedule returns the parallelism limit and failure policy for w.
+func (e *DefaultEngine) schedule(w *Workflow) (int, FailurePolicy, error) {
+	limit := w.MaxParallel
+	if limit == 0 {
+		limit = e.MaxParallel
+	}
+	if limit == 0 {
+		limit = DefaultMaxParallel
+	}
+	if limit < 0 {
+		return 0, "", fmt.Errorf("invalid max_parallel: %d", limit)
+	}
+
+	policy := w.FailurePolicy
+	if policy == "" {
+		policy = e.FailurePolicy
+	}
+	switch policy {
+	case "":
+		policy = FailureCancel
+	case FailureCancel, FailureContinue:
+	default:
+		return 0, "", fmt.Errorf("unknown failure policy: %q", policy)
+	}
+	return limit, policy, nil
+}
+
+// skipDependents marks every step depending on step i, transitively.
+func (g *graph) skipDependents(i int, skipped []bool) {
+	for _, d := range g.dependents[i] {
+		if !skipped[d] {
+			skipped[d] = true
+			g.skipDependents(d, skipped)
+		}
+	}
+}
+
+// ExecuteStep runs a single step. Its environment is taken from ctx (see
+// WithEnv), as are its agent sessions (see WithSessions); With values are
+// used as given, without template rendering.
 func (e *DefaultEngine) ExecuteStep(ctx context.Context, step *Step, inputs map[string]interface{}) (*StepResult, error) {
+	limit, policy, _ := e.schedule(&Workflow{})
+	sc := &scope{env: EnvFromContext(ctx), inputs: inputs, limit: limit, policy: policy, events: e.emitter("", "")}
+	return e.executeStep(withSessions(ctx), step, sc)
+}
+
+// executeStep runs a step within a scope, reporting its start and
+// outcome to the scope's observers.
+func (e *DefaultEngine) executeStep(ctx context.Context, step *Step, sc *scope) (*StepResult, error) {
+	path := sc.stepPath(step.ID)
+	sc.events.emit(Event{Type: EventStepStarted, StepID: step.ID, Path: path, Status: StatusRunning})
+	result, err := e.runStep(ctx, step, sc)
+	sc.events.finished(path, result)
+	return result, err
+}
+
+// runStep runs an action or control-flow step within a scope. A
+// failed attempt is retried, after a backoff, while the step's retry
+// policy allows; the step timeout bounds each attempt. If the step still
+// fails, its error handler, or the workflow's, decides the outcome.
+func (e *DefaultEngine) runStep(ctx context.Context, step *Step, sc *scope) (*StepResult, error) {
 	start := time.Now()
 	result := &StepResult{
 		StepID:    step.ID,
 		Status:    StatusRunning,
 		StartTime: start,
 	}
+	fail := func(err error) (*StepResult, error) {
+		result.Status = StatusFailed
+		result.Error = err
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime)
+		return result, err
+	}
 
-	// Apply step timeout
+	var timeout time.Duration
 	if step.Timeout != "" {
 		d, err := time.ParseDuration(step.Timeout)
 		if err != nil {
-			result.Status = StatusFailed
-			result.Error = fmt.Errorf("invalid timeout: %w", err)
-			result.EndTime = time.Now()
-			result.Duration = result.EndTime.Sub(result.StartTime)
-			return result, result.Error
+			return fail(fmt.Errorf("invalid timeout: %w", err))
+		}
+		timeout = d
+	}
+	handler := step.OnError
+	if handler == nil {
+		handler = sc.onError
+	}
+	retry, err := newRetrier(step, handler)
+	if err != nil {
+		return fail(err)
+	}
+
+	for n := 1; ; n++ {
+		a := Attempt{Number: n, Status: StatusCompleted, StartTime: time.Now()}
+		result.Output, result.Children, err = e.attempt(ctx, step, sc, timeout)
+		a.EndTime = time.Now()
+		a.Duration = a.EndTime.Sub(a.StartTime)
+		if err == nil {
+			result.Attempts = append(result.Attempts, a)
+			break
+		}
+		a.Status = StatusFailed
+		a.Error = err.Error()
+		a.Class = ErrorClass(err)
+		if n > retry.retries || !retry.retryable(a.Class) || ctx.Err() != nil {
+			result.Attempts = append(result.Attempts, a)
+			break
+		}
+		a.Backoff = retry.delay(n)
+		result.Attempts = append(result.Attempts, a)
+		sc.events.emit(Event{
+			Type: EventStepRetrying, StepID: step.ID, Path: sc.stepPath(step.ID), Status: StatusRunning,
+			Attempt: n, Backoff: a.Backoff, Error: a.Error,
+		})
+		timer := time.NewTimer(a.Backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
 		}
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, d)
-		defer cancel()
 	}
 
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err
+		if err = e.handleError(ctx, step, sc, handler, result); err != nil {
+			return fail(err)
+		}
+	} else {
+		result.Status = StatusCompleted
+	}
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	return result, nil
+}
+
+// executeAction runs a step's action handler with the inputs merged with
+// the step's With values.
+func (e *DefaultEngine) executeAction(ctx context.Context, step *Step, inputs map[string]interface{}) (map[string]interface{}, error) {
 	// Merge inputs with step.With
 	allInputs := make(map[string]interface{})
 	for k, v := range inputs {
@@ -100,30 +535,13 @@ func (e *DefaultEngine) ExecuteStep(ctx context.Context, step *Step, inputs map[
 		allInputs[k] = v
 	}
 
-	// Execute action
+	e.mu.RLock()
 	handler, ok := e.actions[step.Action]
+	e.mu.RUnlock()
 	if !ok {
-		result.Status = StatusFailed
-		result.Error = fmt.Errorf("unknown action: %s", step.Action)
-		result.EndTime = time.Now()
-		result.Duration = result.EndTime.Sub(result.StartTime)
-		return result, result.Error
-	}
-
-	output, err := handler(ctx, allInputs)
-	if err != nil {
-		result.Status = StatusFailed
-		result.Error = err
-		result.EndTime = time.Now()
-		result.Duration = result.EndTime.Sub(result.StartTime)
-		return result, err
+		return nil, fmt.Errorf("unknown action: %s", step.Action)
 	}
-
-	result.Status = StatusCompleted
-	result.Output = output
-	result.EndTime = time.Now()
-	result.Duration = result.EndTime.Sub(result.StartTime)
-	return result, nil
+	return handler(ctx, allInputs)
 }
 
 // YAMLParser implements the Parser interface.
@@ -134,12 +552,20 @@ func NewParser() *YAMLParser {
 	return &YAMLParser{}
 }
 
-// Parse parses a workflow from YAML bytes.
+// Parse parses a workflow from YAML bytes. The workflow keeps the
+// positions of its fields for the validator to report.
 func (p *YAMLParser) Parse(data []byte) (*Workflow, error) {
-	var w Workflow
-	if err := yaml.Unmarshal(data, &w); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("parse workflow: %w", err)
 	}
+	var w Workflow
+	if len(doc.Content) > 0 {
+		if err := doc.Decode(&w); err != nil {
+			return nil, fmt.Errorf("parse workflow: %w", err)
+		}
+		w.node = doc.Content[0]
+	}
 	return &w, nil
 }
 
@@ -151,30 +577,3 @@ func (p *YAMLParser) ParseFile(path string) (*Workflow, error) {
 	}
 	return p.Parse(data)
 }
-
-// DefaultValidator implements the Validator interface.
-type DefaultValidator struct{}
-
-// NewValidator creates a new validator.
-func NewValidator() *DefaultValidator {
-	return &DefaultValidator{}
-}
-
-// Validate checks if a workflow is valid.
-func (v *DefaultValidator) Validate(w *Workflow) error {
-	if w.Name == "" {
-		return &ValidationError{Field: "name", Message: "required"}
-	}
-	if len(w.Steps) == 0 {
-		return &ValidationError{Field: "steps", Message: "at least one step required"}
-	}
-	for i, step := range w.Steps {
-		if step.ID == "" {
-			return &ValidationError{Field: fmt.Sprintf("steps[%d].id", i), Message: "required"}
-		}
-		if step.Name == "" {
-			return &ValidationError{Field: fmt.Sprintf("steps[%d].name", i), Message: "required"}
-		}
-	}
-	return nil
-}
@@ -0,0 +1,170 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventType identifies what an Event reports.
+type EventType string
+
+const (
+	EventRunStarted    EventType = "run.started"
+	EventRunFinished   EventType = "run.finished"
+	EventStepQueued    EventType = "step.queued"
+	EventStepStarted   EventType = "step.started"
+	EventStepRetrying  EventType = "step.retrying"
+	EventStepSkipped   EventType = "step.skipped"
+	EventStepCompleted EventType = "step.completed"
+	EventStepFailed    EventType = "step.failed"
+)
+
+// Event reports the progress of a workflow run. Step events carry the
+// step's ID and its Path, which prefixes nested steps with their parents,
+// as in "review[2]/ask" for step ask in the third iteration of loop
+// review.
+type Event struct {
+	Type     EventType  `json:"type"`
+	Time     time.Time  `json:"time"`
+	RunID    string     `json:"run_id,omitempty"`
+	Workflow string     `json:"workflow,omitempty"`
+	StepID   string     `json:"step_id,omitempty"`
+	Path     string     `json:"path,omitempty"`
+	Status   StepStatus `json:"status,omitempty"`
+	// Attempt is the number of the failed attempt of a retrying event and
+	// the attempt count of a finished step.
+	Attempt int `json:"attempt,omitempty"`
+	// Backoff is the delay before a retry.
+	Backoff  time.Duration `json:"backoff,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	// Inputs are the inputs of a started run.
+	Inputs map[string]interface{} `json:"inputs,omitempty"`
+	Output map[string]interface{} `json:"output,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+	// Reason explains why a step was skipped.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Observer receives the events of workflow runs. The engine calls
+// observers synchronously, one event at a time, so they should return
+// quickly.
+type Observer interface {
+	OnEvent(ev Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(ev Event)
+
+// OnEvent calls f.
+func (f ObserverFunc) OnEvent(ev Event) { f(ev) }
+
+// Reasons given by step.skipped events.
+const (
+	SkipCondition  = "condition is false"
+	SkipDependency = "a dependency failed"
+	SkipStopped    = "the run stopped"
+	SkipResumed    = "completed before the run was resumed"
+)
+
+// AddObserver registers an observer of the engine's runs.
+func (e *DefaultEngine) AddObserver(o Observer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.observers = append(e.observers, o)
+}
+
+// emitter delivers the events of one run to the engine's observers. A
+// nil emitter delivers nothing.
+type emitter struct {
+	mu        sync.Mutex
+	observers []Observer
+	runID     string
+	workflow  string
+}
+
+func (e *DefaultEngine) emitter(runID, workflow string) *emitter {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.observers) == 0 {
+		return nil
+	}
+	return &emitter{observers: append([]Observer(nil), e.observers...), runID: runID, workflow: workflow}
+}
+
+func (em *emitter) emit(ev Event) {
+	if em == nil {
+		return
+	}
+	ev.Time = time.Now()
+	ev.RunID, ev.Workflow = em.runID, em.workflow
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	for _, o := range em.observers {
+		o.OnEvent(ev)
+	}
+}
+
+// stepEvent returns an event about a step at path.
+func stepEvent(t EventType, path string, r *StepResult) Event {
+	ev := Event{Type: t, StepID: r.StepID, Path: path, Status: r.Status, Output: r.Output, Duration: r.Duration, Attempt: len(r.Attempts)}
+	if r.Error != nil {
+		ev.Error = r.Error.Error()
+	}
+	return ev
+}
+
+// finished emits the event for a step that finished with r.
+func (em *emitter) finished(path string, r *StepResult) {
+	t := EventStepCompleted
+	if r.Status == StatusFailed {
+		t = EventStepFailed
+	}
+	em.emit(stepEvent(t, path, r))
+}
+
+// skipped emits the event for a step that did not run.
+func (em *emitter) skipped(path string, r *StepResult, reason string) {
+	ev := stepEvent(EventStepSkipped, path, r)
+	ev.Reason = reason
+	em.emit(ev)
+}
+
+// EventLog is an Observer writing events to w as JSON lines.
+type EventLog struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewEventLog creates an event log writing to w.
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{w: w}
+}
+
+// OnEvent writes ev as a line of JSON.
+func (l *EventLog) OnEvent(ev Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		l.err = fmt.Errorf("encode event: %w", err)
+		return
+	}
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		l.err = fmt.Errorf("write event: %w", err)
+	}
+}
+
+// Err returns the first error writing the log. Events after it are
+// dropped.
+func (l *EventLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
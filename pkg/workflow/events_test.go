@@ -0,0 +1,231 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recorder is an Observer collecting events.
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) OnEvent(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+// find returns the events of a type at a path.
+func (r *recorder) find(t EventType, path string) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Event
+	for _, ev := range r.events {
+		if ev.Type == t && ev.Path == path {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func TestEvents(t *testing.T) {
+	w, err := NewParser().Parse([]byte(`
+name: events
+version: "1"
+failure_policy: continue
+steps:
+  - id: fetch
+    name: Fetch
+    action: echo
+    with:
+      value: ${{ inputs.repo }}
+  - id: flaky
+    name: Flaky
+    action: flaky
+    retries: 1
+    retry: {backoff: 1ms}
+  - id: never
+    name: Never
+    action: echo
+    if: inputs.repo == 'other'
+  - id: each
+    name: Each
+    type: loop
+    depends_on: [fetch]
+    for_each: ${{ inputs.items }}
+    steps:
+      - id: say
+        name: Say
+        action: echo
+        with:
+          value: ${{ loop.item }}
+  - id: broken
+    name: Broken
+    action: fail
+  - id: after
+    name: After
+    action: echo
+    depends_on: [broken]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newControlEngine()
+	handler, _ := flaky(1, errors.New("connection reset"))
+	e.RegisterAction("flaky", handler)
+	rec := &recorder{}
+	e.AddObserver(rec)
+
+	result, err := e.ExecuteWithInputs(context.Background(), w, map[string]interface{}{"repo": "openagent", "items": []interface{}{"x", "y"}})
+	if err == nil {
+		t.Fatal("expected the broken step to fail the run")
+	}
+
+	first, last := rec.events[0], rec.events[len(rec.events)-1]
+	if first.Type != EventRunStarted || first.Inputs["repo"] != "openagent" || first.RunID != result.RunID || first.Workflow != "events" {
+		t.Errorf("unexpected first event %+v", first)
+	}
+	if last.Type != EventRunFinished || last.Status != StatusFailed || !strings.Contains(last.Error, "boom") {
+		t.Errorf("unexpected last event %+v", last)
+	}
+	for i := 1; i < len(rec.events); i++ {
+		if rec.events[i].Time.Before(rec.events[i-1].Time) {
+			t.Fatalf("event %d is earlier than the one before", i)
+		}
+	}
+
+	// A completed step is queued, started and completed, in order, with
+	// its output.
+	var order []EventType
+	for _, ev := range rec.events {
+		if ev.Path == "fetch" {
+			order = append(order, ev.Type)
+		}
+	}
+	if want := []EventType{EventStepQueued, EventStepStarted, EventStepCompleted}; !equalTypes(order, want) {
+		t.Errorf("expected %v for fetch, got %v", want, order)
+	}
+	if ev := rec.find(EventStepCompleted, "fetch"); len(ev) != 1 || ev[0].Output["value"] != "openagent" || ev[0].Attempt != 1 {
+		t.Errorf("unexpected completion %+v", ev)
+	}
+
+	retries := rec.find(EventStepRetrying, "flaky")
+	if len(retries) != 1 || retries[0].Attempt != 1 || retries[0].Error != "connection reset" || retries[0].Backoff == 0 {
+		t.Errorf("unexpected retry events %+v", retries)
+	}
+	if ev := rec.find(EventStepCompleted, "flaky"); len(ev) != 1 || ev[0].Attempt != 2 {
+		t.Errorf("expected flaky to complete after 2 attempts, got %+v", ev)
+	}
+
+	if ev := rec.find(EventStepSkipped, "never"); len(ev) != 1 || ev[0].Reason != SkipCondition {
+		t.Errorf("unexpected skip events %+v", ev)
+	}
+	if ev := rec.find(EventStepSkipped, "after"); len(ev) != 1 || ev[0].Reason != SkipDependency {
+		t.Errorf("unexpected skip events %+v", ev)
+	}
+	if ev := rec.find(EventStepFailed, "broken"); len(ev) != 1 || ev[0].Error != "boom" || ev[0].Status != StatusFailed {
+		t.Errorf("unexpected failure events %+v", ev)
+	}
+
+	// Nested steps carry their parents in the path.
+	for i, item := range []string{"x", "y"} {
+		path := fmt.Sprintf("each[%d]/say", i)
+		ev := rec.find(EventStepCompleted, path)
+		if len(ev) != 1 || ev[0].StepID != "say" || ev[0].Output["value"] != item {
+			t.Errorf("%s: unexpected events %+v", path, ev)
+		}
+	}
+	if ev := rec.find(EventStepCompleted, "each"); len(ev) != 1 || ev[0].Output["count"] != 2 {
+		t.Errorf("unexpected loop completion %+v", ev)
+	}
+}
+
+func equalTypes(a, b []EventType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEventsOnResumeAndCancel(t *testing.T) {
+	e := newControlEngine()
+	e.Store = NewMemoryRunStore()
+	rec := &recorder{}
+	e.AddObserver(rec)
+	w := &Workflow{Name: "test", Steps: []Step{
+		{ID: "a", Name: "A", Action: "echo"},
+		{ID: "b", Name: "B", Action: "fail", DependsOn: []string{"a"}},
+		{ID: "c", Name: "C", Action: "echo"},
+	}, MaxParallel: 1}
+
+	result, _ := e.Execute(context.Background(), w)
+	// With the cancel policy, steps not started when b fails are skipped.
+	if ev := rec.find(EventStepSkipped, "c"); len(ev) != 1 || ev[0].Reason != SkipStopped {
+		t.Errorf("expected c to be skipped as the run stopped, got %+v", ev)
+	}
+
+	rec.events = nil
+	if _, err := e.Resume(context.Background(), w, result.RunID); err == nil {
+		t.Fatal("expected b to fail again")
+	}
+	if ev := rec.find(EventStepSkipped, "a"); len(ev) != 1 || ev[0].Reason != SkipResumed || ev[0].Status != StatusCompleted {
+		t.Errorf("expected a to be skipped as resumed, got %+v", ev)
+	}
+	if rec.events[0].RunID != result.RunID {
+		t.Errorf("expected the resumed run's ID, got %s", rec.events[0].RunID)
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewEventLog(&buf)
+	e := newControlEngine()
+	e.AddObserver(log)
+	e.AddObserver(ObserverFunc(func(ev Event) {}))
+	w := &Workflow{Name: "test", Steps: []Step{{ID: "a", Name: "A", Action: "echo", With: map[string]interface{}{"value": 1}}}}
+	if _, err := e.Execute(context.Background(), w); err != nil {
+		t.Fatal(err)
+	}
+	if log.Err() != nil {
+		t.Fatal(log.Err())
+	}
+
+	var types []EventType
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var ev Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+		if ev.Time.IsZero() {
+			t.Errorf("expected a timestamp in %q", line)
+		}
+		types = append(types, ev.Type)
+	}
+	want := []EventType{EventRunStarted, EventStepQueued, EventStepStarted, EventStepCompleted, EventRunFinished}
+	if !equalTypes(types, want) {
+		t.Errorf("expected %v, got %v", want, types)
+	}
+
+	log = NewEventLog(failWriter{})
+	log.OnEvent(Event{Type: EventRunStarted})
+	if err := log.Err(); err == nil || !strings.Contains(err.Error(), "write event") {
+		t.Errorf("expected write error, got %v", err)
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
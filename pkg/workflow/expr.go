@@ -0,0 +1,751 @@
+package workflow
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a compiled condition expression, as used by Step.If.
+//
+// The language has literals (numbers, 'strings' or "strings", true, false,
+// null), references rooted at env, inputs, steps and, inside loop steps,
+// loop (steps.review.output.approved, steps.build.status, inputs.files[0],
+// loop.item, loop.index), comparisons (== != < <=
+// > >=), boolean logic (&& || !), parentheses, and the functions
+// contains(haystack, needle), startsWith(s, prefix) and endsWith(s,
+// suffix). Steps expose output, status, error and attempts. References to
+// missing values evaluate to null rather than
+// failing; null equals only null and orders against nothing. An expression
+// may be wrapped in ${{ }}.
+type Expr struct {
+	src  string
+	root node
+}
+
+// ExprError is a syntax error in an expression.
+type ExprError struct {
+	Expr    string
+	Pos     int
+	Message string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("expression %q at offset %d: %s", e.Expr, e.Pos, e.Message)
+}
+
+// ExprContext holds the values an expression can reference.
+type ExprContext struct {
+	Env    map[string]string
+	Inputs map[string]interface{}
+	Steps  map[string]*StepResult
+	// Loop holds the loop variables of the enclosing loop steps.
+	Loop map[string]interface{}
+	// Strict makes references to missing values fail with a RefError
+	// instead of evaluating to null.
+	Strict bool
+}
+
+// exprRoots are the names a reference may start with.
+var exprRoots = map[string]bool{"env": true, "inputs": true, "steps": true, "loop": true}
+
+// exprFuncs maps function names to their arity.
+var exprFuncs = map[string]int{"contains": 2, "startsWith": 2, "endsWith": 2}
+
+// CompileExpr parses an expression.
+func CompileExpr(src string) (*Expr, error) {
+	body := strings.TrimSpace(src)
+	if strings.HasPrefix(body, "${{") && strings.HasSuffix(body, "}}") {
+		body = body[3 : len(body)-2]
+	}
+	p := &exprParser{src: src, body: body, base: strings.Index(src, body)}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 1 {
+		return nil, p.errorf(0, "empty expression")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t.pos, "unexpected %q", t.text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the expression source.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression. Number literals evaluate to float64;
+// references evaluate to the referenced value unchanged.
+func (e *Expr) Eval(ctx *ExprContext) (interface{}, error) {
+	if ctx == nil {
+		ctx = &ExprContext{}
+	}
+	return e.root.eval(ctx)
+}
+
+// EvalBool evaluates the expression and reports whether the result is
+// truthy: anything but false, null, zero and the empty string.
+func (e *Expr) EvalBool(ctx *ExprContext) (bool, error) {
+	v, err := e.Eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// StepRefs returns the IDs of the steps the expression references.
+func (e *Expr) StepRefs() []string {
+	var refs []string
+	seen := make(map[string]bool)
+	walkNodes(e.root, func(n node) {
+		if r, ok := n.(*refNode); ok && r.path[0] == "steps" && len(r.path) > 1 {
+			if id, ok := r.path[1].(string); ok && !seen[id] {
+				seen[id] = true
+				refs = append(refs, id)
+			}
+		}
+	})
+	return refs
+}
+
+// usesLoop reports whether the expression references loop variables.
+func (e *Expr) usesLoop() bool {
+	found := false
+	walkNodes(e.root, func(n node) {
+		if r, ok := n.(*refNode); ok && r.path[0] == "loop" {
+			found = true
+		}
+	})
+	return found
+}
+
+// constant reports whether the expression references nothing, so it
+// always evaluates the same.
+func (e *Expr) constant() bool {
+	found := false
+	walkNodes(e.root, func(n node) {
+		if _, ok := n.(*refNode); ok {
+			found = true
+		}
+	})
+	return !found
+}
+
+// Tokens
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	num  float64
+}
+
+type exprParser struct {
+	src    string
+	body   string
+	base   int // offset of body in src
+	tokens []token
+	next   int
+}
+
+func (p *exprParser) errorf(pos int, format string, args ...interface{}) error {
+	return &ExprError{Expr: p.src, Pos: p.base + pos, Message: fmt.Sprintf(format, args...)}
+}
+
+var exprOps = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ".", ","}
+
+func (p *exprParser) lex() error {
+	s := p.body
+	i := 0
+	for i < len(s) {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && rune(s[j]) != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return p.errorf(i, "unterminated string")
+			}
+			p.tokens = append(p.tokens, token{kind: tokString, text: b.String(), pos: i})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i + 1
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(s[i:j], 64)
+			if err != nil {
+				return p.errorf(i, "invalid number %q", s[i:j])
+			}
+			p.tokens = append(p.tokens, token{kind: tokNumber, text: s[i:j], pos: i, num: n})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '-' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokIdent, text: s[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, o := range exprOps {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return p.errorf(i, "unexpected character %q", c)
+			}
+			p.tokens = append(p.tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokEOF, text: "end of expression", pos: len(s)})
+	return nil
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *exprParser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *exprParser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return p.errorf(t.pos, "expected %q, got %q", op, t.text)
+	}
+	return nil
+}
+
+// Grammar, lowest precedence first:
+//
+//	or      = and { "||" and }
+//	and     = not { "&&" not }
+//	not     = "!" not | compare
+//	compare = primary [ op primary ]
+//	primary = literal | call | reference | "(" or ")"
+
+func (p *exprParser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokOp {
+		switch t.text {
+		case "==", "!=", "<", "<=", ">", ">=":
+			p.next++
+			right, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return &compareNode{op: t.text, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parsePrimary() (node, error) {
+	t := p.take()
+	switch t.kind {
+	case tokNumber:
+		return &literalNode{t.num}, nil
+	case tokString:
+		return &literalNode{t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		}
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "null":
+			return &literalNode{nil}, nil
+		}
+		if p.accept("(") {
+			return p.parseCall(t)
+		}
+		return p.parseRef(t)
+	}
+	return nil, p.errorf(t.pos, "unexpected %q", t.text)
+}
+
+func (p *exprParser) parseCall(name token) (node, error) {
+	arity, ok := exprFuncs[name.text]
+	if !ok {
+		return nil, p.errorf(name.pos, "unknown function %q", name.text)
+	}
+	var args []node
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(args) != arity {
+		return nil, p.errorf(name.pos, "%s takes %d arguments, got %d", name.text, arity, len(args))
+	}
+	return &callNode{name: name.text, args: args}, nil
+}
+
+func (p *exprParser) parseRef(root token) (node, error) {
+	if !exprRoots[root.text] {
+		return nil, p.errorf(root.pos, "unknown name %q (expected env, inputs, steps or loop)", root.text)
+	}
+	ref := &refNode{path: []interface{}{root.text}}
+	for {
+		switch {
+		case p.accept("."):
+			t := p.take()
+			if t.kind != tokIdent {
+				return nil, p.errorf(t.pos, "expected a field name after '.', got %q", t.text)
+			}
+			ref.path = append(ref.path, t.text)
+		case p.accept("["):
+			t := p.take()
+			switch t.kind {
+			case tokNumber:
+				ref.path = append(ref.path, int(t.num))
+			case tokString:
+				ref.path = append(ref.path, t.text)
+			default:
+				return nil, p.errorf(t.pos, "expected an index or quoted key, got %q", t.text)
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		default:
+			if root.text == "steps" && len(ref.path) == 1 {
+				return nil, p.errorf(root.pos, "steps must be followed by a step id")
+			}
+			return ref, nil
+		}
+	}
+}
+
+// Nodes
+
+type node interface {
+	eval(ctx *ExprContext) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+func (n *literalNode) eval(*ExprContext) (interface{}, error) { return n.value, nil }
+
+type refNode struct{ path []interface{} }
+
+func (n *refNode) eval(ctx *ExprContext) (interface{}, error) {
+	v, err := resolvePath(ctx, n.path)
+	if err != nil && !ctx.Strict {
+		return nil, nil
+	}
+	return v, err
+}
+
+// RefError reports a reference to a value that does not exist, when
+// evaluating strictly.
+type RefError struct {
+	Ref     string
+	Message string
+}
+
+func (e *RefError) Error() string {
+	return fmt.Sprintf("reference %s: %s", e.Ref, e.Message)
+}
+
+// resolvePath looks up a reference path, returning a RefError for missing
+// values. Values present but null resolve to nil without error.
+func resolvePath(ctx *ExprContext, path []interface{}) (interface{}, error) {
+	ref := formatPath(path)
+	var cur interface{}
+	start := 1
+	switch path[0] {
+	case "env":
+		env := make(map[string]interface{}, len(ctx.Env))
+		for k, v := range ctx.Env {
+			env[k] = v
+		}
+		cur = env
+	case "inputs":
+		cur = ctx.Inputs
+	case "loop":
+		cur = ctx.Loop
+	case "steps":
+		id, _ := path[1].(string)
+		r, ok := ctx.Steps[id]
+		if !ok || r == nil {
+			return nil, &RefError{Ref: ref, Message: fmt.Sprintf("step %q has not run", id)}
+		}
+		if len(path) > 2 && path[2] == "output" && r.Status != StatusCompleted {
+			return nil, &RefError{Ref: ref, Message: fmt.Sprintf("step %q is %s", id, r.Status)}
+		}
+		errMsg := ""
+		if r.Error != nil {
+			errMsg = r.Error.Error()
+		}
+		cur = map[string]interface{}{"output": r.Output, "status": string(r.Status), "error": errMsg, "attempts": len(r.Attempts)}
+		start = 2
+	}
+	for k, key := range path[start:] {
+		next, ok := index(cur, key)
+		if !ok {
+			return nil, &RefError{Ref: ref, Message: fmt.Sprintf("%s has no %s", formatPath(path[:start+k]), formatKey(key))}
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// formatPath renders a reference path as written in an expression.
+func formatPath(path []interface{}) string {
+	var b strings.Builder
+	for i, key := range path {
+		switch k := key.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", k)
+		case string:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(k)
+		}
+	}
+	return b.String()
+}
+
+func formatKey(key interface{}) string {
+	if i, ok := key.(int); ok {
+		return fmt.Sprintf("index %d", i)
+	}
+	return fmt.Sprintf("field %q", key)
+}
+
+// index returns v[key] for maps and slices of any element type, and
+// whether it exists.
+func index(v interface{}, key interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		k, ok := key.(string)
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		e := rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()))
+		if !e.IsValid() {
+			return nil, false
+		}
+		return e.Interface(), true
+	case reflect.Slice, reflect.Array:
+		i, ok := key.(int)
+		if !ok || i < 0 || i >= rv.Len() {
+			return nil, false
+		}
+		return rv.Index(i).Interface(), true
+	}
+	return nil, false
+}
+
+// normalize converts numeric types to float64 so they compare uniformly.
+func normalize(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+	return v
+}
+
+type notNode struct{ operand node }
+
+func (n *notNode) eval(ctx *ExprContext) (interface{}, error) {
+	v, err := n.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(v), nil
+}
+
+type logicNode struct {
+	and         bool
+	left, right node
+}
+
+// eval short-circuits and yields a boolean.
+func (n *logicNode) eval(ctx *ExprContext) (interface{}, error) {
+	l, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(l) != n.and {
+		return !n.and, nil
+	}
+	r, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(r), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(ctx *ExprContext) (interface{}, error) {
+	l, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l, r = normalize(l), normalize(r)
+	switch n.op {
+	case "==":
+		return valuesEqual(l, r), nil
+	case "!=":
+		return !valuesEqual(l, r), nil
+	}
+
+	// Ordering is defined between two numbers or two strings only.
+	var c int
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return false, nil
+		}
+		switch {
+		case lv < rv:
+			c = -1
+		case lv > rv:
+			c = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return false, nil
+		}
+		c = strings.Compare(lv, rv)
+	default:
+		return false, nil
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) eval(ctx *ExprContext) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = normalize(v)
+	}
+	switch n.name {
+	case "contains":
+		return contains(args[0], args[1]), nil
+	case "startsWith":
+		s, ok1 := args[0].(string)
+		prefix, ok2 := args[1].(string)
+		return ok1 && ok2 && strings.HasPrefix(s, prefix), nil
+	case "endsWith":
+		s, ok1 := args[0].(string)
+		suffix, ok2 := args[1].(string)
+		return ok1 && ok2 && strings.HasSuffix(s, suffix), nil
+	}
+	return nil, fmt.Errorf("unknown function %q", n.name)
+}
+
+// contains reports whether a string contains a substring, a list contains
+// an element, or a map contains a key.
+func contains(haystack, needle interface{}) bool {
+	if s, ok := haystack.(string); ok {
+		sub, ok := needle.(string)
+		return ok && strings.Contains(s, sub)
+	}
+	if haystack == nil {
+		return false
+	}
+	rv := reflect.ValueOf(haystack)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if valuesEqual(normalize(rv.Index(i).Interface()), needle) {
+				return true
+			}
+		}
+	case reflect.Map:
+		key, ok := needle.(string)
+		if !ok {
+			return false
+		}
+		_, found := index(haystack, key)
+		return found
+	}
+	return false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func truthy(v interface{}) bool {
+	switch t := normalize(v).(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0
+	case string:
+		return t != ""
+	}
+	return true
+}
+
+func walkNodes(n node, fn func(node)) {
+	fn(n)
+	switch t := n.(type) {
+	case *notNode:
+		walkNodes(t.operand, fn)
+	case *logicNode:
+		walkNodes(t.left, fn)
+		walkNodes(t.right, fn)
+	case *compareNode:
+		walkNodes(t.left, fn)
+		walkNodes(t.right, fn)
+	case *callNode:
+		for _, a := range t.args {
+			walkNodes(a, fn)
+		}
+	}
+}
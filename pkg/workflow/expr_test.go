@@ -0,0 +1,144 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExprEval(t *testing.T) {
+	ctx := &ExprContext{
+		Env:    map[string]string{"BRANCH": "release/1.2", "MODE": "fast"},
+		Inputs: map[string]interface{}{"files": []string{"a.go", "b.go"}, "count": 3, "labels": map[string]interface{}{"bug": true}},
+		Steps: map[string]*StepResult{
+			"review": {StepID: "review", Status: StatusCompleted, Output: map[string]interface{}{"approved": true, "score": 0.9, "comments": nil}},
+		},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"steps.review.output.approved == true", true},
+		{"${{ steps.review.output.approved }}", true},
+		{"steps.review.status == 'completed'", true},
+		{"steps.review.output.score >= 0.8 && inputs.count > 2", true},
+		{"steps.review.output.score < 0.5 || env.MODE == \"slow\"", false},
+		{"!(inputs.count == 3)", false},
+		{"startsWith(env.BRANCH, 'release/')", true},
+		{"endsWith(env.BRANCH, '1.2')", true},
+		{"contains(inputs.files, 'b.go')", true},
+		{"contains(inputs.files, 'c.go')", false},
+		{"contains(inputs.labels, 'bug')", true},
+		{"contains(env.MODE, 'as')", true},
+		{"inputs.files[0] == 'a.go'", true},
+		{"inputs.files[5] == null", true},
+		{"steps.review.output.comments == null", true},
+		{"steps.missing.output.x == null", true},
+		{"steps.missing.output.x", false},
+		{"env.UNSET != null", false},
+		{"env.UNSET < 1", false},
+		{"inputs.count == 3.0", true},
+		{"inputs.count == '3'", false},
+		{"-1 < 0", true},
+	}
+	for _, tt := range tests {
+		expr, err := CompileExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%s: compile failed: %v", tt.expr, err)
+			continue
+		}
+		got, err := expr.EvalBool(ctx)
+		if err != nil {
+			t.Errorf("%s: eval failed: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestCompileExprErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "empty expression"},
+		{"foo == 1", `unknown name "foo"`},
+		{"steps == 1", "step id"},
+		{"len(inputs.files)", `unknown function "len"`},
+		{"contains(env.A)", "takes 2 arguments"},
+		{"env.A == 'x", "unterminated string"},
+		{"(env.A == 1", `expected ")"`},
+		{"env.A == 1 env.B", "unexpected"},
+		{"env.A = 1", "unexpected character"},
+	}
+	for _, tt := range tests {
+		_, err := CompileExpr(tt.expr)
+		var exprErr *ExprError
+		if !errors.As(err, &exprErr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.expr, tt.want, err)
+		}
+	}
+
+	expr, _ := CompileExpr("steps.a.output.x && contains(steps.b.status, 'ok') || steps.a.status")
+	if got := strings.Join(expr.StepRefs(), ","); got != "a,b" {
+		t.Errorf("expected refs a,b, got %s", got)
+	}
+}
+
+func TestEngineConditions(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAction("review", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"approved": inputs["approve"]}, nil
+	})
+	e.RegisterAction("noop", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{}, nil
+	})
+
+	w := &Workflow{
+		Name: "test",
+		Env:  map[string]string{"TARGET": "staging"},
+		Steps: []Step{
+			{ID: "review", Name: "Review", Action: "review"},
+			{ID: "merge", Name: "Merge", Action: "noop", DependsOn: []string{"review"}, If: "steps.review.output.approved == true"},
+			{ID: "notify", Name: "Notify", Action: "noop", DependsOn: []string{"merge"}, If: "steps.merge.status == 'skipped'"},
+			{ID: "deploy", Name: "Deploy", Action: "noop", If: "env.TARGET == 'production' && inputs.force"},
+		},
+	}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	result, err := e.ExecuteWithInputs(context.Background(), w, map[string]interface{}{"approve": false})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []StepStatus{StatusCompleted, StatusSkipped, StatusCompleted, StatusSkipped}
+	for i, r := range result.Steps {
+		if r.Status != want[i] {
+			t.Errorf("%s: expected %s, got %s", r.StepID, want[i], r.Status)
+		}
+	}
+}
+
+func TestValidatorConditions(t *testing.T) {
+	v := NewValidator()
+	tests := []struct {
+		name string
+		step Step
+		want string
+	}{
+		{"syntax", Step{ID: "b", Name: "B", If: "steps.a.output.x ==", DependsOn: []string{"a"}}, "steps[1].if"},
+		{"unknown step", Step{ID: "b", Name: "B", If: "steps.zzz.status == 'completed'"}, `unknown step "zzz"`},
+		{"not a dependency", Step{ID: "b", Name: "B", If: "steps.a.status == 'completed'"}, `step "a" is not a dependency`},
+	}
+	for _, tt := range tests {
+		w := &Workflow{Name: "test", Steps: []Step{{ID: "a", Name: "A"}, tt.step}}
+		err := v.Validate(w)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Retry defaults.
+const (
+	// DefaultRetries is the number of retries of the retry action when
+	// neither the step nor the handler sets one.
+	DefaultRetries    = 3
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Error classes, as reported by ErrorClass and listed in RetryPolicy.On.
+const (
+	// ErrorClassTimeout is a deadline exceeded, such as a step timeout.
+	ErrorClassTimeout = "timeout"
+	// ErrorClassRateLimit is a rate limit or quota error (HTTP 429).
+	ErrorClassRateLimit = "rate_limit"
+	// ErrorClassTransient is a temporary failure, such as a server error
+	// (HTTP 5xx) or a network error.
+	ErrorClassTransient = "transient"
+	// ErrorClassPermanent is an error that retrying cannot fix. It is
+	// only retried if listed explicitly.
+	ErrorClassPermanent = "permanent"
+	// ErrorClassError is any other error.
+	ErrorClassError = "error"
+)
+
+var errorClasses = map[string]bool{
+	ErrorClassTimeout:   true,
+	ErrorClassRateLimit: true,
+	ErrorClassTransient: true,
+	ErrorClassPermanent: true,
+	ErrorClassError:     true,
+}
+
+// classifiedError attaches an error class to an error.
+type classifiedError struct {
+	err   error
+	class string
+}
+
+func (e *classifiedError) Error() string      { return e.err.Error() }
+func (e *classifiedError) Unwrap() error      { return e.err }
+func (e *classifiedError) ErrorClass() string { return e.class }
+
+// Classify marks err with an error class, so actions can say whether
+// their failures are worth retrying.
+func Classify(err error, class string) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, class: class}
+}
+
+// statusPattern matches the HTTP status in provider errors, which read
+// "<provider> error (status 429): ...".
+var statusPattern = regexp.MustCompile(`\(status (\d{3})\)`)
+
+// ErrorClass classifies err. Errors marked with Classify, or implementing
+// ErrorClass() string, report their own class. Otherwise deadlines are
+// timeouts, HTTP 429 is a rate limit, and HTTP 5xx, network errors and
+// errors with Temporary() true are transient.
+func ErrorClass(err error) string {
+	var classified interface{ ErrorClass() string }
+	if errors.As(err, &classified) {
+		return classified.ErrorClass()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassTransient
+	}
+	var temp interface{ Temporary() bool }
+	if errors.As(err, &temp) && temp.Temporary() {
+		return ErrorClassTransient
+	}
+	if m := statusPattern.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		switch {
+		case code == 429:
+			return ErrorClassRateLimit
+		case code >= 500:
+			return ErrorClassTransient
+		case code >= 400:
+			return ErrorClassPermanent
+		}
+	}
+	return ErrorClassError
+}
+
+// retrier is a resolved retry policy.
+type retrier struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	multiplier float64
+	jitter     float64
+	on         map[string]bool
+}
+
+// newRetrier resolves the retry policy of a step failing into handler h.
+// The step's Retries and Retry take precedence over the handler's, which
+// only apply to the retry action.
+func newRetrier(step *Step, h *ErrorHandler) (*retrier, error) {
+	retries, policy := step.Retries, step.Retry
+	if h != nil && h.Action == ErrorRetry {
+		if retries == 0 {
+			retries = h.Retries
+		}
+		if retries == 0 {
+			retries = DefaultRetries
+		}
+		if policy == nil {
+			policy = h.Retry
+		}
+	}
+	if policy == nil {
+		policy = &RetryPolicy{}
+	}
+
+	r := &retrier{
+		retries:    retries,
+		backoff:    DefaultBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		multiplier: policy.Multiplier,
+		jitter:     policy.Jitter,
+	}
+	var err error
+	if policy.Backoff != "" {
+		if r.backoff, err = time.ParseDuration(policy.Backoff); err != nil {
+			return nil, fmt.Errorf("invalid retry backoff: %w", err)
+		}
+	}
+	if policy.MaxBackoff != "" {
+		if r.maxBackoff, err = time.ParseDuration(policy.MaxBackoff); err != nil {
+			return nil, fmt.Errorf("invalid retry max_backoff: %w", err)
+		}
+	}
+	if r.multiplier == 0 {
+		r.multiplier = 2
+	}
+	if len(policy.On) > 0 {
+		r.on = make(map[string]bool, len(policy.On))
+		for _, class := range policy.On {
+			r.on[class] = true
+		}
+	}
+	return r, nil
+}
+
+// retryable reports whether an error of the given class is retried.
+func (r *retrier) retryable(class string) bool {
+	if r.on == nil {
+		return class != ErrorClassPermanent
+	}
+	return r.on[class]
+}
+
+// delay returns the backoff before retry n, counting from 1.
+func (r *retrier) delay(n int) time.Duration {
+	d := float64(r.backoff) * math.Pow(r.multiplier, float64(n-1))
+	if d > float64(r.maxBackoff) {
+		d = float64(r.maxBackoff)
+	}
+	if r.jitter > 0 {
+		d -= d * r.jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// attempt runs a step once, bounded by its timeout.
+func (e *DefaultEngine) attempt(ctx context.Context, step *Step, sc *scope, timeout time.Duration) (map[string]interface{}, []*StepResult, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if isControl(step.Type) {
+		return e.executeControl(ctx, step, sc.at(sc.stepPath(step.ID)))
+	}
+	if step.Type == StepTypeAgent {
+		step = agentStep(step)
+	}
+	output, err := e.executeAction(ctx, step, sc.inputs)
+	return output, nil, err
+}
+
+// handleError applies a failed step's error handler. It returns the error
+// the step fails with, or nil if the handler recovered.
+func (e *DefaultEngine) handleError(ctx context.Context, step *Step, sc *scope, h *ErrorHandler, result *StepResult) error {
+	err := result.Error
+	if h == nil {
+		return err
+	}
+	if h.Message != "" {
+		err = fmt.Errorf("%s: %w", h.Message, err)
+		result.Error = err
+	}
+
+	switch h.Action {
+	case ErrorContinue:
+		return nil
+	case ErrorFallback, ErrorRun:
+		if h.Step == nil {
+			return fmt.Errorf("on_error %s: no step: %w", h.Action, err)
+		}
+		recovery, rerr := e.recover(ctx, step, sc, h, result)
+		result.Recovery = recovery
+		if h.Action == ErrorRun {
+			if rerr != nil {
+				return fmt.Errorf("%w (cleanup %s: %v)", err, recovery.StepID, rerr)
+			}
+			return err
+		}
+		if rerr != nil {
+			return fmt.Errorf("%w (fallback %s: %v)", err, recovery.StepID, rerr)
+		}
+		if recovery.Status != StatusCompleted {
+			return err
+		}
+		result.Status = StatusCompleted
+		result.Output = recovery.Output
+		result.Error = nil
+		return nil
+	}
+	return err
+}
+
+// recover runs the fallback or cleanup step of h. It sees the failed
+// step's result under steps.<id>, so it can read its status and error.
+func (e *DefaultEngine) recover(ctx context.Context, step *Step, sc *scope, h *ErrorHandler, failed *StepResult) (*StepResult, error) {
+	hs := handlerStep(h, step.ID)
+	steps := make(map[string]*StepResult, len(sc.steps)+1)
+	for id, r := range sc.steps {
+		steps[id] = r
+	}
+	steps[step.ID] = failed
+	// The handler step has only its own handler, so a workflow-level
+	// fallback cannot recurse into itself.
+	hsc := sc.with(sc.env, steps)
+	hsc.onError = nil
+
+	var cond *Expr
+	if hs.If != "" {
+		var err error
+		if cond, err = CompileExpr(hs.If); err != nil {
+			return unstartedResult(hs, err), err
+		}
+	}
+	resolved, env, run, err := prepareStep(hs, cond, hsc.exprContext())
+	if err != nil || !run {
+		return unstartedResult(hs, err), err
+	}
+	hsc.env = env
+	return e.executeStep(WithEnv(ctx, env), resolved, hsc)
+}
+
+// handlerStep returns the step of h, named after the step it handles if
+// it has no ID.
+func handlerStep(h *ErrorHandler, owner string) *Step {
+	hs := *h.Step
+	if hs.ID == "" {
+		hs.ID = owner + "." + string(h.Action)
+	}
+	return &hs
+}
+
+// checkErrorHandling checks a step's retry settings and error handler.
+func checkErrorHandling(d *diagnostics, step *Step, p string, sc *refScope) {
+	if step.Retries < 0 {
+		d.add(p+".retries", "must not be negative")
+	}
+	checkRetryPolicy(d, step.Retry, p+".retry")
+	if step.OnError == nil {
+		return
+	}
+	// The handler step also sees the failed step.
+	visible := make(map[string]bool, len(sc.visible)+1)
+	for id := range sc.visible {
+		visible[id] = true
+	}
+	visible[step.ID] = true
+	checkHandler(d, step.OnError, step.ID, p+".on_error", visible, sc.inLoop)
+}
+
+// checkHandler checks an error handler. owner is the ID of the step it
+// belongs to, used to name a handler step without one.
+func checkHandler(d *diagnostics, h *ErrorHandler, owner, p string, visible map[string]bool, inLoop bool) {
+	switch h.Action {
+	case "", ErrorFail, ErrorContinue, ErrorRetry:
+		if h.Step != nil {
+			d.add(p+".step", fmt.Sprintf("not used by action %q", h.Action))
+		}
+	case ErrorFallback, ErrorRun:
+		if h.Step == nil {
+			d.add(p+".step", fmt.Sprintf("required for action %q", h.Action))
+		}
+	default:
+		d.add(p+".action", fmt.Sprintf("unknown action %q", h.Action))
+	}
+	if h.Retries < 0 {
+		d.add(p+".retries", "must not be negative")
+	}
+	checkRetryPolicy(d, h.Retry, p+".retry")
+	if h.Step == nil {
+		return
+	}
+	hs := handlerStep(h, owner)
+	if len(hs.DependsOn) > 0 {
+		d.add(p+".step.depends_on", "not allowed in on_error steps")
+	}
+	checkStep(d, hs, p+".step", &refScope{visible: visible, level: map[string]bool{hs.ID: true}, inLoop: inLoop})
+	if hs.Name == "" {
+		d.add(p+".step.name", "required")
+	}
+}
+
+// checkRetryPolicy checks the durations, factors and classes of a retry
+// policy.
+func checkRetryPolicy(d *diagnostics, policy *RetryPolicy, p string) {
+	if policy == nil {
+		return
+	}
+	for _, b := range []struct{ field, value string }{{"backoff", policy.Backoff}, {"max_backoff", policy.MaxBackoff}} {
+		if b.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(b.value); err != nil || v < 0 {
+			d.add(p+"."+b.field, fmt.Sprintf("invalid duration %q", b.value))
+		}
+	}
+	if policy.Multiplier != 0 && policy.Multiplier < 1 {
+		d.add(p+".multiplier", "must be at least 1")
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		d.add(p+".jitter", "must be between 0 and 1")
+	}
+	for i, class := range policy.On {
+		if !errorClasses[class] {
+			d.add(fmt.Sprintf("%s.on[%d]", p, i), fmt.Sprintf("unknown error class %q", class))
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flaky returns an action failing with err for its first n calls.
+func flaky(n int, err error) (ActionHandler, func() int) {
+	var mu sync.Mutex
+	calls := 0
+	handler := func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls <= n {
+			return nil, err
+		}
+		return map[string]interface{}{"calls": calls}, nil
+	}
+	return handler, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+func TestRetries(t *testing.T) {
+	e := newControlEngine()
+	handler, calls := flaky(2, errors.New("anthropic error (status 529): overloaded"))
+	e.RegisterAction("llm", handler)
+
+	w := &Workflow{Name: "test", Steps: []Step{{
+		ID: "ask", Name: "Ask", Action: "llm", Retries: 2,
+		Retry: &RetryPolicy{Backoff: "1ms", Multiplier: 2},
+	}}}
+	result, err := e.Execute(context.Background(), w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	step := result.Steps[0]
+	if step.Status != StatusCompleted || calls() != 3 || len(step.Attempts) != 3 {
+		t.Fatalf("expected success on the third attempt, got %s after %d attempts", step.Status, len(step.Attempts))
+	}
+	first := step.Attempts[0]
+	if first.Status != StatusFailed || first.Class != ErrorClassTransient || first.Backoff != time.Millisecond {
+		t.Errorf("unexpected first attempt %+v", first)
+	}
+	if step.Attempts[1].Backoff != 2*time.Millisecond || step.Attempts[2].Status != StatusCompleted {
+		t.Errorf("unexpected attempts %+v", step.Attempts)
+	}
+
+	// Retries run out.
+	handler, calls = flaky(5, errors.New("boom"))
+	e.RegisterAction("llm", handler)
+	result, err = e.Execute(context.Background(), w)
+	if err == nil || calls() != 3 || len(result.Steps[0].Attempts) != 3 {
+		t.Errorf("expected failure after 3 attempts, got %v after %d", err, calls())
+	}
+
+	// Only listed error classes are retried.
+	handler, calls = flaky(1, errors.New("openai error (status 429): slow down"))
+	e.RegisterAction("llm", handler)
+	w.Steps[0].Retry.On = []string{ErrorClassTimeout}
+	if _, err := e.Execute(context.Background(), w); err == nil || calls() != 1 {
+		t.Errorf("expected no retry of a rate limit, got %v after %d calls", err, calls())
+	}
+	w.Steps[0].Retry.On = []string{ErrorClassRateLimit}
+	handler, calls = flaky(1, errors.New("openai error (status 429): slow down"))
+	e.RegisterAction("llm", handler)
+	if _, err := e.Execute(context.Background(), w); err != nil || calls() != 2 {
+		t.Errorf("expected a retried rate limit, got %v after %d calls", err, calls())
+	}
+
+	// Permanent errors are not retried by default.
+	w.Steps[0].Retry.On = nil
+	handler, calls = flaky(1, Classify(errors.New("bad request"), ErrorClassPermanent))
+	e.RegisterAction("llm", handler)
+	if _, err := e.Execute(context.Background(), w); err == nil || calls() != 1 {
+		t.Errorf("expected no retry of a permanent error, got %v after %d calls", err, calls())
+	}
+
+	// The step timeout bounds each attempt, and timeouts are retried.
+	w.Steps[0] = Step{ID: "slow", Name: "Slow", Action: "sleep", Timeout: "5ms", Retries: 1, Retry: &RetryPolicy{Backoff: "1ms"}}
+	result, err = e.Execute(context.Background(), w)
+	if !errors.Is(err, context.DeadlineExceeded) || len(result.Steps[0].Attempts) != 2 {
+		t.Errorf("expected two timed out attempts, got %v", err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	r, err := newRetrier(&Step{Retries: 5, Retry: &RetryPolicy{Backoff: "100ms", MaxBackoff: "300ms"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, d := range want {
+		if got := r.delay(i + 1); got != d {
+			t.Errorf("delay(%d): expected %s, got %s", i+1, d, got)
+		}
+	}
+
+	r.jitter = 0.5
+	for i := 0; i < 20; i++ {
+		if d := r.delay(1); d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("expected jittered delay in [50ms, 100ms], got %s", d)
+		}
+	}
+
+	// The retry action supplies retries when the step has none.
+	r, _ = newRetrier(&Step{}, &ErrorHandler{Action: ErrorRetry})
+	if r.retries != DefaultRetries {
+		t.Errorf("expected %d retries, got %d", DefaultRetries, r.retries)
+	}
+	r, _ = newRetrier(&Step{}, &ErrorHandler{Action: ErrorFail, Retries: 3})
+	if r.retries != 0 {
+		t.Errorf("expected no retries for the fail action, got %d", r.retries)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{context.DeadlineExceeded, ErrorClassTimeout},
+		{fmt.Errorf("complete: %w", context.DeadlineExceeded), ErrorClassTimeout},
+		{errors.New("openai error (status 429): rate limited"), ErrorClassRateLimit},
+		{errors.New("ollama error (status 503): unavailable"), ErrorClassTransient},
+		{errors.New("anthropic error (status 400): bad request"), ErrorClassPermanent},
+		{Classify(errors.New("flaky"), ErrorClassTransient), ErrorClassTransient},
+		{fmt.Errorf("wrapped: %w", Classify(errors.New("no"), ErrorClassPermanent)), ErrorClassPermanent},
+		{errors.New("boom"), ErrorClassError},
+	}
+	for _, tt := range tests {
+		if got := ErrorClass(tt.err); got != tt.want {
+			t.Errorf("%v: expected %s, got %s", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestErrorHandlers(t *testing.T) {
+	e := newControlEngine()
+
+	// continue lets dependents run.
+	w := &Workflow{Name: "test", Steps: []Step{
+		{ID: "bad", Name: "Bad", Action: "fail", OnError: &ErrorHandler{Action: ErrorContinue}},
+		{ID: "next", Name: "Next", Action: "echo", DependsOn: []string{"bad"}, With: map[string]any{"value": "${{ steps.bad.error }}"}},
+	}}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	result, err := e.Execute(context.Background(), w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Steps[0].Status != StatusFailed || result.Steps[1].Output["value"] != "boom" {
+		t.Errorf("expected next to see the failure, got %s and %v", result.Steps[0].Status, result.Steps[1].Output)
+	}
+
+	// fallback completes the step with the alternate step's output.
+	w.Steps[0].OnError = &ErrorHandler{Action: ErrorFallback, Step: &Step{
+		Name: "Cached", Action: "echo", With: map[string]any{"value": "cached after ${{ steps.bad.attempts }} attempt"},
+	}}
+	w.Steps[1].With = map[string]any{"value": "${{ steps.bad.output.value }}"}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	result, err = e.Execute(context.Background(), w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bad := result.Steps[0]
+	if bad.Status != StatusCompleted || bad.Recovery == nil || bad.Recovery.StepID != "bad.fallback" || len(bad.Attempts) != 1 {
+		t.Errorf("unexpected fallback result %+v", bad)
+	}
+	if got := result.Steps[1].Output["value"]; got != "cached after 1 attempt" {
+		t.Errorf("expected fallback output, got %v", got)
+	}
+
+	// run cleans up, then fails the step with the handler's message.
+	w.Steps[0].OnError = &ErrorHandler{Action: ErrorRun, Message: "deploy failed", Step: &Step{
+		ID: "rollback", Name: "Rollback", Action: "echo", With: map[string]any{"value": "${{ steps.bad.status }}"},
+	}}
+	result, err = e.Execute(context.Background(), w)
+	if err == nil || err.Error() != "step bad: deploy failed: boom" {
+		t.Errorf("expected handler message, got %v", err)
+	}
+	if r := result.Steps[0].Recovery; r == nil || r.Output["value"] != "failed" || result.Steps[1].Status != StatusSkipped {
+		t.Errorf("expected cleanup to run and next to be skipped, got %+v", result.Steps[0])
+	}
+
+	// The workflow handler is the default; step handlers override it.
+	handler, calls := flaky(1, errors.New("flaky"))
+	e.RegisterAction("llm", handler)
+	w = &Workflow{
+		Name:    "test",
+		OnError: &ErrorHandler{Action: ErrorRetry, Retries: 2, Retry: &RetryPolicy{Backoff: "1ms"}},
+		Steps: []Step{
+			{ID: "ask", Name: "Ask", Action: "llm"},
+			{ID: "optional", Name: "Optional", Action: "fail", OnError: &ErrorHandler{Action: ErrorContinue}},
+		},
+	}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+	result, err = e.Execute(context.Background(), w)
+	if err != nil || calls() != 2 {
+		t.Fatalf("expected the workflow handler to retry, got %v after %d calls", err, calls())
+	}
+	if len(result.Steps[1].Attempts) != 1 {
+		t.Errorf("expected the step handler not to retry, got %d attempts", len(result.Steps[1].Attempts))
+	}
+
+	// A failing fallback fails the step.
+	w.OnError = &ErrorHandler{Action: ErrorFallback, Step: &Step{Name: "Also bad", Action: "fail"}}
+	w.Steps[1].OnError = nil
+	_, err = e.Execute(context.Background(), w)
+	if err == nil || !strings.Contains(err.Error(), "(fallback optional.fallback: boom)") {
+		t.Errorf("expected fallback error, got %v", err)
+	}
+}
+
+func TestValidatorErrorHandling(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    Step
+		onError *ErrorHandler
+		want    string
+	}{
+		{"negative retries", Step{Retries: -1}, nil, "steps[0].retries: must not be negative"},
+		{"bad backoff", Step{Retry: &RetryPolicy{Backoff: "soon"}}, nil, `steps[0].retry.backoff: invalid duration "soon"`},
+		{"bad jitter", Step{Retry: &RetryPolicy{Jitter: 2}}, nil, "between 0 and 1"},
+		{"bad multiplier", Step{Retry: &RetryPolicy{Multiplier: 0.5}}, nil, "at least 1"},
+		{"unknown class", Step{Retry: &RetryPolicy{On: []string{"sometimes"}}}, nil, `steps[0].retry.on[0]: unknown error class "sometimes"`},
+		{"unknown action", Step{OnError: &ErrorHandler{Action: "ignore"}}, nil, `steps[0].on_error.action: unknown action "ignore"`},
+		{"fallback without step", Step{OnError: &ErrorHandler{Action: ErrorFallback}}, nil, "steps[0].on_error.step: required"},
+		{"step unused", Step{OnError: &ErrorHandler{Action: ErrorContinue, Step: &Step{Name: "X"}}}, nil, "not used"},
+		{"handler step depends", Step{OnError: &ErrorHandler{Action: ErrorRun, Step: &Step{Name: "X", DependsOn: []string{"s"}}}}, nil, "not allowed in on_error steps"},
+		{"handler step unknown ref", Step{OnError: &ErrorHandler{Action: ErrorRun, Step: &Step{Name: "X", If: "steps.other.status == 'failed'"}}}, nil, `steps[0].on_error.step.if: unknown step "other"`},
+		{"handler step unnamed", Step{OnError: &ErrorHandler{Action: ErrorRun, Step: &Step{}}}, nil, "steps[0].on_error.step.name: required"},
+		{"workflow handler refs", Step{}, &ErrorHandler{Action: ErrorRun, Step: &Step{Name: "X", If: "steps.s.status == 'failed'"}}, `on_error.step.if: unknown step "s"`},
+		{"workflow handler backoff", Step{}, &ErrorHandler{Action: ErrorRetry, Retry: &RetryPolicy{MaxBackoff: "-1s"}}, "on_error.retry.max_backoff"},
+	}
+	for _, tt := range tests {
+		tt.step.ID, tt.step.Name = "s", "S"
+		err := NewValidator().Validate(&Workflow{Name: "test", Steps: []Step{tt.step}, OnError: tt.onError})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
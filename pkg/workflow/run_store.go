@@ -0,0 +1,222 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrRunNotFound is returned when a run does not exist in a store.
+var ErrRunNotFound = errors.New("run not found")
+
+// RunStore persists the state of workflow runs so they can be resumed.
+type RunStore interface {
+	// Save stores the state of a run, replacing any earlier state.
+	Save(ctx context.Context, state *RunState) error
+	// Load returns the state of a run.
+	Load(ctx context.Context, runID string) (*RunState, error)
+	// List returns the stored runs, oldest first.
+	List(ctx context.Context) ([]*RunState, error)
+	// Delete removes a run.
+	Delete(ctx context.Context, runID string) error
+}
+
+// MemoryRunStore implements RunStore in memory. States are copied on the
+// way in and out, as a persistent store would.
+type MemoryRunStore struct {
+	mu   sync.RWMutex
+	runs map[string][]byte
+}
+
+// NewMemoryRunStore creates an empty in-memory run store.
+func NewMemoryRunStore() *MemoryRunStore {
+	return &MemoryRunStore{runs: make(map[string][]byte)}
+}
+
+// Save stores the state of a run.
+func (s *MemoryRunStore) Save(ctx context.Context, state *RunState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encode run: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[state.RunID] = data
+	return nil
+}
+
+// Load returns the state of a run.
+func (s *MemoryRunStore) Load(ctx context.Context, runID string) (*RunState, error) {
+	s.mu.RLock()
+	data, ok := s.runs[runID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRunNotFound, runID)
+	}
+	return decodeRun(data)
+}
+
+// List returns the stored runs, oldest first.
+func (s *MemoryRunStore) List(ctx context.Context) ([]*RunState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	runs := make([]*RunState, 0, len(s.runs))
+	for _, data := range s.runs {
+		state, err := decodeRun(data)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, state)
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+// Delete removes a run.
+func (s *MemoryRunStore) Delete(ctx context.Context, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.runs[runID]; !ok {
+		return fmt.Errorf("%w: %s", ErrRunNotFound, runID)
+	}
+	delete(s.runs, runID)
+	return nil
+}
+
+// FileRunStore implements RunStore with one JSON file per run in a
+// directory. Each save writes a temporary file, syncs it and renames it
+// over the previous state, so a crash leaves either the old or the new
+// state.
+type FileRunStore struct {
+	dir string
+	// mu serializes writes, which share temporary file names per run.
+	mu sync.Mutex
+}
+
+// NewFileRunStore opens or creates a file-backed run store in dir.
+func NewFileRunStore(dir string) (*FileRunStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("run store: dir is required")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create run store dir: %w", err)
+	}
+	return &FileRunStore{dir: dir}, nil
+}
+
+func (s *FileRunStore) path(runID string) (string, error) {
+	if runID == "" || runID != filepath.Base(runID) || strings.HasPrefix(runID, ".") {
+		return "", fmt.Errorf("invalid run ID %q", runID)
+	}
+	return filepath.Join(s.dir, runID+".json"), nil
+}
+
+// Save stores the state of a run.
+func (s *FileRunStore) Save(ctx context.Context, state *RunState) error {
+	path, err := s.path(state.RunID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode run: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("write run: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write run: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync run: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write run: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace run: %w", err)
+	}
+	return nil
+}
+
+// Load returns the state of a run.
+func (s *FileRunStore) Load(ctx context.Context, runID string) (*RunState, error) {
+	path, err := s.path(runID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrRunNotFound, runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read run: %w", err)
+	}
+	return decodeRun(data)
+}
+
+// List returns the stored runs, oldest first.
+func (s *FileRunStore) List(ctx context.Context) ([]*RunState, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*RunState, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read run: %w", err)
+		}
+		state, err := decodeRun(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		runs = append(runs, state)
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+// Delete removes a run.
+func (s *FileRunStore) Delete(ctx context.Context, runID string) error {
+	path, err := s.path(runID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrRunNotFound, runID)
+	} else if err != nil {
+		return fmt.Errorf("delete run: %w", err)
+	}
+	return nil
+}
+
+func decodeRun(data []byte) (*RunState, error) {
+	var state RunState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("decode run: %w", err)
+	}
+	return &state, nil
+}
+
+func sortRuns(runs []*RunState) {
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].StartTime.Equal(runs[j].StartTime) {
+			return runs[i].StartTime.Before(runs[j].StartTime)
+		}
+		return runs[i].RunID < runs[j].RunID
+	})
+}
@@ -0,0 +1,115 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunStores(t *testing.T) {
+	dir := t.TempDir()
+	fileStore, err := NewFileRunStore(filepath.Join(dir, "runs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]RunStore{"memory": NewMemoryRunStore(), "file": fileStore}
+	ctx := context.Background()
+	start := time.Now().Truncate(time.Second)
+
+	for name, store := range stores {
+		for i, id := range []string{"second", "first"} {
+			state := &RunState{
+				RunID:     id,
+				Status:    StatusRunning,
+				Inputs:    map[string]interface{}{"n": 3},
+				Steps:     map[string]*StepState{"a": {Status: StatusCompleted, Output: map[string]interface{}{"ok": true}}},
+				StartTime: start.Add(-time.Duration(i) * time.Minute),
+			}
+			if err := store.Save(ctx, state); err != nil {
+				t.Fatalf("%s: save failed: %v", name, err)
+			}
+		}
+		// Saving again replaces the state.
+		if err := store.Save(ctx, &RunState{RunID: "second", Status: StatusCompleted, StartTime: start}); err != nil {
+			t.Fatalf("%s: save failed: %v", name, err)
+		}
+
+		got, err := store.Load(ctx, "first")
+		if err != nil {
+			t.Fatalf("%s: load failed: %v", name, err)
+		}
+		if got.Inputs["n"] != float64(3) || got.Steps["a"].Output["ok"] != true || !got.StartTime.Equal(start.Add(-time.Minute)) {
+			t.Errorf("%s: unexpected state %+v", name, got)
+		}
+		runs, err := store.List(ctx)
+		if err != nil || len(runs) != 2 || runs[0].RunID != "first" || runs[1].Status != StatusCompleted {
+			t.Errorf("%s: unexpected runs %v, %v", name, runs, err)
+		}
+
+		if err := store.Delete(ctx, "first"); err != nil {
+			t.Fatalf("%s: delete failed: %v", name, err)
+		}
+		if _, err := store.Load(ctx, "first"); !errors.Is(err, ErrRunNotFound) {
+			t.Errorf("%s: expected ErrRunNotFound, got %v", name, err)
+		}
+		if err := store.Delete(ctx, "first"); !errors.Is(err, ErrRunNotFound) {
+			t.Errorf("%s: expected ErrRunNotFound, got %v", name, err)
+		}
+	}
+
+	for _, id := range []string{"", "../escape", ".hidden", "a/b"} {
+		if err := fileStore.Save(ctx, &RunState{RunID: id}); err == nil {
+			t.Errorf("%q: expected invalid run ID error", id)
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "runs"))
+	if len(entries) != 1 || entries[0].Name() != "second.json" {
+		t.Errorf("expected only second.json, got %v", entries)
+	}
+	if _, err := NewFileRunStore(""); err == nil {
+		t.Error("expected error without a dir")
+	}
+}
+
+func TestFileRunStoreResume(t *testing.T) {
+	store, err := NewFileRunStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	newEngine := func(fail bool) *DefaultEngine {
+		e := NewEngine()
+		e.Store = store
+		e.RegisterAction("once", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+			calls++
+			return map[string]interface{}{"n": inputs["n"]}, nil
+		})
+		e.RegisterAction("flaky", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+			if fail {
+				return nil, errors.New("boom")
+			}
+			return map[string]interface{}{"n": inputs["n"]}, nil
+		})
+		return e
+	}
+	w := &Workflow{Name: "test", Steps: []Step{
+		{ID: "a", Name: "A", Action: "once", With: map[string]interface{}{"n": "${{ inputs.n }}"}},
+		{ID: "b", Name: "B", Action: "flaky", DependsOn: []string{"a"}, With: map[string]interface{}{"n": "${{ steps.a.output.n }}-b"}},
+	}}
+
+	result, err := newEngine(true).ExecuteWithInputs(context.Background(), w, map[string]interface{}{"n": 1})
+	if err == nil || result == nil {
+		t.Fatalf("expected a failed run, got %v", err)
+	}
+	// A new engine, as after a restart, resumes from the files.
+	resumed, err := newEngine(false).Resume(context.Background(), w, result.RunID)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if calls != 1 || resumed.Steps[1].Output["n"] != "1-b" {
+		t.Errorf("expected b to use a's checkpointed output, got %v after %d calls", resumed.Steps[1].Output, calls)
+	}
+}
@@ -0,0 +1,223 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A template is a string containing ${{ expression }} placeholders, as in
+// Step.With and Env values. A string that is exactly one placeholder
+// renders to the expression's value with its type preserved, so
+// "${{ steps.plan.output.files }}" yields the list itself. Placeholders
+// embedded in longer strings are interpolated: strings as-is, null as the
+// empty string, and other values as JSON. References in templates are
+// strict: a missing value is an error rather than null.
+
+// templatePart is a literal run of text or a placeholder.
+type templatePart struct {
+	text string
+	expr *Expr
+}
+
+// parseTemplate splits s into parts. It returns nil parts if s contains
+// no placeholders.
+func parseTemplate(s string) ([]templatePart, error) {
+	if !strings.Contains(s, "${{") {
+		return nil, nil
+	}
+	var parts []templatePart
+	rest := s
+	for {
+		i := strings.Index(rest, "${{")
+		if i < 0 {
+			if rest != "" {
+				parts = append(parts, templatePart{text: rest})
+			}
+			return parts, nil
+		}
+		if i > 0 {
+			parts = append(parts, templatePart{text: rest[:i]})
+		}
+		j := strings.Index(rest[i:], "}}")
+		if j < 0 {
+			return nil, fmt.Errorf("template %q: unterminated ${{", s)
+		}
+		expr, err := CompileExpr(rest[i : i+j+2])
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, templatePart{expr: expr})
+		rest = rest[i+j+2:]
+	}
+}
+
+// Render resolves the templates in v, recursing into maps and lists.
+// Values without templates are returned unchanged.
+func Render(v interface{}, ctx *ExprContext) (interface{}, error) {
+	if ctx == nil {
+		ctx = &ExprContext{}
+	}
+	strict := *ctx
+	strict.Strict = true
+	return render(v, &strict)
+}
+
+func render(v interface{}, ctx *ExprContext) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		return renderString(t, ctx)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			r, err := render(e, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, e := range t {
+			r, err := render(e, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = r
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func renderString(s string, ctx *ExprContext) (interface{}, error) {
+	parts, err := parseTemplate(s)
+	if err != nil || parts == nil {
+		return s, err
+	}
+	if len(parts) == 1 && parts[0].expr != nil {
+		return parts[0].expr.Eval(ctx)
+	}
+	var b strings.Builder
+	for _, p := range parts {
+		if p.expr == nil {
+			b.WriteString(p.text)
+			continue
+		}
+		v, err := p.expr.Eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		str, err := stringify(v)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", s, err)
+		}
+		b.WriteString(str)
+	}
+	return b.String(), nil
+}
+
+// stringify formats an interpolated value.
+func stringify(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case fmt.Stringer:
+		return t.String(), nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// renderEnv resolves the templates in env values. Each value must render
+// to a string, number or boolean.
+func renderEnv(env map[string]string, ctx *ExprContext) (map[string]string, error) {
+	out := make(map[string]string, len(env))
+	for k, v := range env {
+		r, err := Render(v, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", k, err)
+		}
+		switch r.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("env %s: value must be a scalar, got %T", k, r)
+		}
+		if out[k], err = stringify(r); err != nil {
+			return nil, fmt.Errorf("env %s: %w", k, err)
+		}
+	}
+	return out, nil
+}
+
+// templateExprs compiles every template in v and returns its expressions,
+// keyed by where they appear.
+func templateExprs(prefix string, v interface{}) (map[string][]*Expr, error) {
+	out := make(map[string][]*Expr)
+	var walk func(path string, v interface{}) error
+	walk = func(path string, v interface{}) error {
+		switch t := v.(type) {
+		case string:
+			parts, err := parseTemplate(t)
+			if err != nil {
+				return &ValidationError{Field: path, Message: err.Error()}
+			}
+			for _, p := range parts {
+				if p.expr != nil {
+					out[path] = append(out[path], p.expr)
+				}
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if err := walk(path+"."+k, t[k]); err != nil {
+					return err
+				}
+			}
+		case map[string]string:
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if err := walk(path+"."+k, t[k]); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for i, e := range t {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i), e); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return out, walk(prefix, v)
+}
+
+type envKey struct{}
+
+// WithEnv returns a context carrying a step's resolved environment, which
+// the engine passes to action handlers.
+func WithEnv(ctx context.Context, env map[string]string) context.Context {
+	return context.WithValue(ctx, envKey{}, env)
+}
+
+// EnvFromContext returns the environment of the step being executed, or nil.
+func EnvFromContext(ctx context.Context) map[string]string {
+	env, _ := ctx.Value(envKey{}).(map[string]string)
+	return env
+}
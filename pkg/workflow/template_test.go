@@ -0,0 +1,132 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	ctx := &ExprContext{
+		Env:    map[string]string{"REPO": "openagent"},
+		Inputs: map[string]interface{}{"limit": 5},
+		Steps: map[string]*StepResult{
+			"plan": {StepID: "plan", Status: StatusCompleted, Output: map[string]interface{}{
+				"files": []interface{}{"a.go", "b.go"},
+				"meta":  map[string]interface{}{"risky": false},
+				"note":  nil,
+			}},
+			"lint": {StepID: "lint", Status: StatusSkipped},
+		},
+	}
+
+	value := map[string]interface{}{
+		"files":   "${{ steps.plan.output.files }}",
+		"limit":   "${{inputs.limit}}",
+		"risky":   "${{ steps.plan.output.meta.risky }}",
+		"message": "review ${{ env.REPO }}: ${{ steps.plan.output.files }} (${{ steps.plan.output.note }})",
+		"nested":  []interface{}{map[string]interface{}{"first": "${{ steps.plan.output.files[0] }}"}, 7},
+		"plain":   "no templates",
+	}
+	got, err := Render(value, ctx)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"files":   []interface{}{"a.go", "b.go"},
+		"limit":   5,
+		"risky":   false,
+		"message": `review openagent: ["a.go","b.go"] ()`,
+		"nested":  []interface{}{map[string]interface{}{"first": "a.go"}, 7},
+		"plain":   "no templates",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %#v, got %#v", want, got)
+	}
+
+	errs := []struct {
+		value string
+		want  string
+	}{
+		{"${{ steps.plan.output.missing }}", `reference steps.plan.output.missing: steps.plan.output has no field "missing"`},
+		{"${{ steps.plan.output.files[9] }}", "has no index 9"},
+		{"${{ steps.deploy.output.url }}", `step "deploy" has not run`},
+		{"${{ steps.lint.output.count }}", `step "lint" is skipped`},
+		{"${{ env.UNSET }}", `env has no field "UNSET"`},
+		{"${{ inputs.x", "unterminated"},
+	}
+	for _, tt := range errs {
+		_, err := Render(map[string]interface{}{"k": tt.value}, ctx)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.value, tt.want, err)
+		}
+	}
+	var refErr *RefError
+	if _, err := Render("${{ env.UNSET }}", ctx); !errors.As(err, &refErr) || refErr.Ref != "env.UNSET" {
+		t.Errorf("expected RefError for env.UNSET, got %v", err)
+	}
+}
+
+func TestEngineTemplating(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAction("plan", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"files": []string{"main.go", "util.go"}, "count": 2}, nil
+	})
+	var got map[string]interface{}
+	var env map[string]string
+	e.RegisterAction("review", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		got, env = inputs, EnvFromContext(ctx)
+		return nil, nil
+	})
+
+	w := &Workflow{
+		Name: "chain",
+		Env:  map[string]string{"REPO": "${{ inputs.repo }}"},
+		Steps: []Step{
+			{ID: "plan", Name: "Plan", Action: "plan"},
+			{
+				ID: "review", Name: "Review", Action: "review", DependsOn: []string{"plan"},
+				Env:  map[string]string{"TITLE": "${{ env.REPO }} (${{ steps.plan.output.count }} files)"},
+				With: map[string]any{"files": "${{ steps.plan.output.files }}", "title": "${{ env.TITLE }}"},
+			},
+		},
+	}
+	if err := NewValidator().Validate(w); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if _, err := e.ExecuteWithInputs(context.Background(), w, map[string]interface{}{"repo": "openagent"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files, ok := got["files"].([]string); !ok || len(files) != 2 {
+		t.Errorf("expected files list to keep its type, got %#v", got["files"])
+	}
+	if got["title"] != "openagent (2 files)" || got["repo"] != "openagent" {
+		t.Errorf("unexpected inputs %v", got)
+	}
+	if env["REPO"] != "openagent" || env["TITLE"] != "openagent (2 files)" {
+		t.Errorf("unexpected env %v", env)
+	}
+
+	// A missing reference fails the step with a clear error.
+	w.Steps[1].With = map[string]any{"files": "${{ steps.plan.output.paths }}"}
+	result, err := e.ExecuteWithInputs(context.Background(), w, map[string]interface{}{"repo": "openagent"})
+	if err == nil || !strings.Contains(err.Error(), `steps.plan.output has no field "paths"`) {
+		t.Errorf("expected missing reference error, got %v", err)
+	}
+	if result == nil || result.Steps[1].Status != StatusFailed {
+		t.Error("expected review to fail")
+	}
+
+	// Template references are checked at validate time.
+	w.Steps[1].DependsOn = nil
+	if err := NewValidator().Validate(w); err == nil || !strings.Contains(err.Error(), "not a dependency") {
+		t.Errorf("expected dependency error, got %v", err)
+	}
+	w.Steps[1].DependsOn = []string{"plan"}
+	w.Env = map[string]string{"X": "${{ steps.plan.output.count }}"}
+	if err := NewValidator().Validate(w); err == nil || !strings.Contains(err.Error(), "workflow env cannot reference steps") {
+		t.Errorf("expected workflow env error, got %v", err)
+	}
+}
@@ -0,0 +1,328 @@
+package workflow
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultValidator implements the Validator interface.
+type DefaultValidator struct {
+	// Actions, if set, is checked for the action of every task and agent
+	// step, so that a workflow naming an action the engine does not have
+	// fails before it runs.
+	Actions ActionRegistry
+	// Agents, if set, is checked for the agent named by every agent step,
+	// so that a workflow naming an undefined agent fails before it runs.
+	Agents AgentRegistry
+}
+
+// NewValidator creates a new validator.
+func NewValidator() *DefaultValidator {
+	return &DefaultValidator{}
+}
+
+// Validate checks if a workflow is valid. It reports every problem it
+// finds as ValidationErrors; for a workflow parsed by YAMLParser they
+// carry their line and column and include fields the workflow format does
+// not know.
+func (v *DefaultValidator) Validate(w *Workflow) error {
+	_, err := v.Check(w)
+	return err
+}
+
+// Check validates w like Validate and also returns warnings about steps
+// that can never run, such as a step whose condition is always false.
+// They do not make the workflow invalid: if: false is a way to disable a
+// step, which the engine then reports as skipped.
+func (v *DefaultValidator) Check(w *Workflow) (warnings ValidationErrors, err error) {
+	d := &diagnostics{}
+	if w.Name == "" {
+		d.add("name", "required")
+	}
+	if len(w.Steps) == 0 {
+		d.add("steps", "at least one step required")
+	}
+	if w.MaxParallel < 0 {
+		d.add("max_parallel", "must not be negative")
+	}
+	switch w.FailurePolicy {
+	case "", FailureCancel, FailureContinue:
+	default:
+		d.add("failure_policy", fmt.Sprintf("unknown policy %q", w.FailurePolicy))
+	}
+	if w.Timeout != "" {
+		if t, err := time.ParseDuration(w.Timeout); err != nil || t <= 0 {
+			d.add("timeout", fmt.Sprintf("invalid duration %q", w.Timeout))
+		}
+	}
+	checkSteps(w, d)
+	checkNames(d, w.Steps, "")
+	if v.Actions != nil {
+		checkActions(d, w, v.Actions)
+	}
+	if v.Agents != nil {
+		checkAgents(d, w, v.Agents)
+	}
+	if w.node != nil {
+		checkFields(d, w.node, reflect.TypeOf(Workflow{}), "")
+		d.locate(w.node)
+	}
+	return d.warnings, d.err()
+}
+
+// diagnostics collects the problems found validating a workflow.
+type diagnostics struct {
+	errs     []*ValidationError
+	warnings ValidationErrors
+}
+
+func (d *diagnostics) add(field, msg string) {
+	d.errs = append(d.errs, &ValidationError{Field: field, Message: msg})
+}
+
+// warn records a problem that does not make the workflow invalid.
+func (d *diagnostics) warn(field, msg string) {
+	d.warnings = append(d.warnings, &ValidationError{Field: field, Message: msg})
+}
+
+// addErr records an error compiling field, keeping the field of a
+// ValidationError.
+func (d *diagnostics) addErr(field string, err error) {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		d.errs = append(d.errs, verr)
+		return
+	}
+	d.add(field, err.Error())
+}
+
+func (d *diagnostics) err() error {
+	if len(d.errs) == 0 {
+		return nil
+	}
+	return ValidationErrors(d.errs)
+}
+
+// locate positions each problem without one at the YAML node its field
+// names, or the closest enclosing node present, and sorts them by
+// position.
+func (d *diagnostics) locate(root *yaml.Node) {
+	for _, errs := range [][]*ValidationError{d.errs, d.warnings} {
+		for _, verr := range errs {
+			if verr.Line == 0 {
+				n := lookup(root, verr.Field)
+				verr.Line, verr.Column = n.Line, n.Column
+			}
+		}
+		sort.SliceStable(errs, func(i, j int) bool {
+			a, b := errs[i], errs[j]
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			return a.Column < b.Column
+		})
+	}
+}
+
+// lookup returns the node of field, a path such as steps[0].retry.backoff,
+// under root. A mapping entry is located at its key.
+func lookup(root *yaml.Node, field string) *yaml.Node {
+	at, n := root, root
+	for _, seg := range fieldPath(field) {
+		n = resolve(n)
+		var key, value *yaml.Node
+		switch s := seg.(type) {
+		case string:
+			if n.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					if n.Content[i].Value == s {
+						key, value = n.Content[i], n.Content[i+1]
+						break
+					}
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && s < len(n.Content) {
+				value = n.Content[s]
+			}
+		}
+		if value == nil {
+			break
+		}
+		at, n = value, value
+		if key != nil {
+			at = key
+		}
+	}
+	return at
+}
+
+// fieldPath splits a field path into its keys and indexes.
+func fieldPath(field string) []interface{} {
+	var segs []interface{}
+	for _, part := range strings.Split(field, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name != "" {
+			segs = append(segs, name)
+		}
+		for rest != "" {
+			index, after, _ := strings.Cut(rest, "]")
+			if i, err := strconv.Atoi(index); err == nil {
+				segs = append(segs, i)
+			}
+			_, rest, _ = strings.Cut(after, "[")
+		}
+	}
+	return segs
+}
+
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+// checkFields reports the keys of mapping n that are not fields of t, and
+// checks the values of those that are. Maps, such as with and env, may
+// hold any keys.
+func checkFields(d *diagnostics, n *yaml.Node, t reflect.Type, path string) {
+	n = resolve(n)
+	switch {
+	case t == reflect.TypeOf(AgentRef{}):
+		// A mapping is an inline agent; a scalar names one.
+		if n.Kind == yaml.MappingNode {
+			checkFields(d, n, reflect.TypeOf(AgentConfig{}), path)
+		}
+		return
+	case t.Kind() == reflect.Ptr:
+		checkFields(d, n, t.Elem(), path)
+		return
+	case t.Kind() == reflect.Slice:
+		if n.Kind == yaml.SequenceNode {
+			for i, item := range n.Content {
+				checkFields(d, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+		return
+	case t.Kind() != reflect.Struct || n.Kind != yaml.MappingNode:
+		return
+	}
+
+	fields := yamlFields(t)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Value == "<<" {
+			continue
+		}
+		p := key.Value
+		if path != "" {
+			p = path + "." + key.Value
+		}
+		ft, ok := fields[key.Value]
+		if !ok {
+			d.errs = append(d.errs, &ValidationError{Field: p, Message: "unknown field", Line: key.Line, Column: key.Column})
+			continue
+		}
+		checkFields(d, value, ft, p)
+	}
+}
+
+// yamlFields maps the YAML keys of struct type t to their types.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// checkActions reports task and agent steps whose action is not in
+// actions. Agent steps without an action run AgentAction.
+func checkActions(d *diagnostics, w *Workflow, actions ActionRegistry) {
+	walkSteps(w, func(step *Step, p string) {
+		switch step.Type {
+		case "", StepTypeTask:
+			if step.Action == "" {
+				d.add(p+".action", "required")
+				return
+			}
+		case StepTypeAgent:
+		default:
+			return
+		}
+		action := step.Action
+		if action == "" {
+			action = AgentAction
+		}
+		if !actions.HasAction(action) {
+			d.add(p+".action", fmt.Sprintf("unknown action %q", action))
+		}
+	})
+}
+
+// checkAgents reports agent steps naming an agent that is not in agents.
+func checkAgents(d *diagnostics, w *Workflow, agents AgentRegistry) {
+	walkSteps(w, func(step *Step, p string) {
+		if step.Type != StepTypeAgent || step.Agent == nil || step.Agent.Name == "" {
+			return
+		}
+		if !agents.HasAgent(step.Agent.Name) {
+			d.add(p+".agent", fmt.Sprintf("unknown agent %q", step.Agent.Name))
+		}
+	})
+}
+
+// walkSteps calls fn with every step of w and its field path, including
+// nested steps and the steps of error handlers.
+func walkSteps(w *Workflow, fn func(step *Step, p string)) {
+	var walk func(steps []Step, prefix string)
+	visit := func(step *Step, p string) {
+		fn(step, p)
+		walk(step.Steps, p+".")
+		for j := range step.Branches {
+			walk(step.Branches[j].Steps, fmt.Sprintf("%s.branches[%d].", p, j))
+		}
+	}
+	walk = func(steps []Step, prefix string) {
+		for i := range steps {
+			p := fmt.Sprintf("%ssteps[%d]", prefix, i)
+			visit(&steps[i], p)
+			if h := steps[i].OnError; h != nil && h.Step != nil {
+				visit(h.Step, p+".on_error.step")
+			}
+		}
+	}
+	if w.OnError != nil && w.OnError.Step != nil {
+		visit(w.OnError.Step, "on_error.step")
+	}
+	walk(w.Steps, "")
+}
+
+//go:embed workflow.schema.json
+var schema []byte
+
+// JSONSchema returns the JSON Schema of workflow files, which editors use
+// to complete and check them as they are written.
+func JSONSchema() []byte {
+	return append([]byte(nil), schema...)
+}
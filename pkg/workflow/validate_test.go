@@ -0,0 +1,284 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	w, err := NewParser().Parse([]byte(`name: demo
+timeout: forever
+steps:
+  - id: fetch
+    name: Fetch
+    action: echo
+  - id: fetch
+    name: Fetch again
+    action: echo
+    timeot: 10s
+  - id: build
+    name: Build
+    type: tsk
+    depends_on: [test]
+  - id: test
+    action: echo
+    depends_on: [build]
+    with:
+      value: ${{ steps.fetch.output.value }}
+    retry:
+      backoff: soon
+  - id: agent
+    name: Agent
+    type: agent
+    prompt: hi
+    agent:
+      model: small
+      temprature: 0.2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewValidator().Validate(w)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	want := []string{
+		`2:1: timeout: invalid duration "forever"`,
+		`3:1: steps: dependency cycle: build -> test -> build`,
+		`7:5: steps[1].id: duplicate step id "fetch"`,
+		`10:5: steps[1].timeot: unknown field`,
+		`13:5: steps[2].type: unknown step type "tsk"`,
+		`15:5: steps[3].name: required`,
+		`19:7: steps[3].with.value: step "fetch" is not a dependency`,
+		`21:7: steps[3].retry.backoff: invalid duration "soon"`,
+		`28:7: steps[4].agent.temprature: unknown field`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d:\n%v", len(want), len(errs), err)
+	}
+	for i, verr := range errs {
+		if verr.Error() != want[i] {
+			t.Errorf("error %d: expected %q, got %q", i, want[i], verr.Error())
+		}
+	}
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Field != "timeout" || verr.Line != 2 {
+		t.Errorf("expected errors.As to find the first error, got %+v", verr)
+	}
+	if !strings.Contains(err.Error(), "\n3:1: steps: dependency cycle") {
+		t.Errorf("expected one error per line, got %q", err.Error())
+	}
+}
+
+// registry is an ActionRegistry of fixed actions.
+type registry map[string]bool
+
+func (r registry) HasAction(name string) bool { return r[name] }
+
+func TestValidatorActions(t *testing.T) {
+	w := &Workflow{Name: "test", Steps: []Step{
+		{ID: "a", Name: "A", Action: "echo"},
+		{ID: "b", Name: "B", Action: "missing"},
+		{ID: "c", Name: "C"},
+		{ID: "d", Name: "D", Type: StepTypeAgent, Prompt: "hi"},
+		{ID: "e", Name: "E", Type: StepTypeSequence, Steps: []Step{
+			{ID: "f", Name: "F", Action: "missing", OnError: &ErrorHandler{Action: ErrorFallback, Step: &Step{Name: "G", Action: "gone"}}},
+		}},
+	}}
+	v := &DefaultValidator{Actions: registry{"echo": true}}
+	err := v.Validate(w)
+	want := []string{
+		`steps[1].action: unknown action "missing"`,
+		`steps[2].action: required`,
+		`steps[3].action: unknown action "agent.run"`,
+		`steps[4].steps[0].action: unknown action "missing"`,
+		`steps[4].steps[0].on_error.step.action: unknown action "gone"`,
+	}
+	if err == nil || err.Error() != strings.Join(want, "\n") {
+		t.Errorf("expected\n%s\ngot\n%v", strings.Join(want, "\n"), err)
+	}
+
+	// Without a registry, actions are not checked.
+	if err := NewValidator().Validate(w); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// agentRegistry is an AgentRegistry of fixed agents.
+type agentRegistry map[string]bool
+
+func (r agentRegistry) HasAgent(name string) bool { return r[name] }
+
+func TestValidatorAgents(t *testing.T) {
+	w := &Workflow{Name: "test", Steps: []Step{
+		{ID: "a", Name: "A", Type: StepTypeAgent, Agent: &AgentRef{Name: "reviewer"}, Prompt: "hi"},
+		{ID: "b", Name: "B", Type: StepTypeAgent, Agent: &AgentRef{Name: "nobody"}, Prompt: "hi"},
+		{ID: "c", Name: "C", Type: StepTypeAgent, Agent: &AgentRef{Inline: &AgentConfig{Model: "m"}}, Prompt: "hi"},
+	}}
+	v := &DefaultValidator{Agents: agentRegistry{"reviewer": true}}
+	if err := v.Validate(w); err == nil || err.Error() != `steps[1].agent: unknown agent "nobody"` {
+		t.Errorf("expected unknown agent error, got %v", err)
+	}
+
+	// Without a registry, agent names are not checked.
+	if err := NewValidator().Validate(w); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidatorUnreachableSteps(t *testing.T) {
+	body := []Step{{ID: "x", Name: "X", Action: "echo"}}
+	tests := []struct {
+		name string
+		step Step
+		want string
+	}{
+		{"false condition", Step{Action: "echo", If: "false"}, "steps[0].if: always false, so the step never runs"},
+		{"constant comparison", Step{Action: "echo", If: "1 == 2 || !true"}, "steps[0].if: always false"},
+		{"false while", Step{Type: StepTypeLoop, While: "'a' == 'b'", Steps: body}, "steps[0].while: always false, so the loop body never runs"},
+		{"false branch", Step{Type: StepTypeDecision, Branches: []Branch{{If: "false", Steps: body}, {Steps: body}}}, "steps[0].branches[0].if: always false"},
+		{"branch after true", Step{Type: StepTypeDecision, Branches: []Branch{{Name: "always", If: "true", Steps: body}, {Steps: body}}}, "steps[0].branches[1]: unreachable: branch always always runs first"},
+	}
+	for _, tt := range tests {
+		tt.step.ID, tt.step.Name = "s", "S"
+		warnings, err := NewValidator().Check(&Workflow{Name: "test", Steps: []Step{tt.step}})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if warnings == nil || !strings.Contains(warnings.Error(), tt.want) {
+			t.Errorf("%s: expected warning containing %q, got %v", tt.name, tt.want, warnings)
+		}
+	}
+
+	// Conditions that read their context may go either way.
+	w := &Workflow{Name: "test", Steps: []Step{
+		{ID: "a", Name: "A", Action: "echo", If: "inputs.enabled"},
+		{ID: "b", Name: "B", Type: StepTypeDecision, Branches: []Branch{
+			{If: "inputs.fast == true", Steps: body},
+			{If: "true", Steps: body},
+		}},
+	}}
+	if warnings, err := NewValidator().Check(w); warnings != nil || err != nil {
+		t.Errorf("unexpected warnings %v, error %v", warnings, err)
+	}
+}
+
+func TestDisabledStepRuns(t *testing.T) {
+	// A step disabled with if: false only draws a warning: the engine
+	// runs the workflow and records the step as skipped.
+	w, err := NewParser().Parse([]byte(`name: test
+steps:
+  - id: a
+    name: A
+    action: echo
+  - id: b
+    name: B
+    action: echo
+    if: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings, err := NewValidator().Check(w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Error() != "9:5: steps[1].if: always false, so the step never runs" {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+	result, err := newControlEngine().Execute(context.Background(), w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != StatusCompleted || result.Steps[0].Status != StatusCompleted || result.Steps[1].Status != StatusSkipped {
+		t.Errorf("expected b to be skipped, got %s, %s", result.Steps[0].Status, result.Steps[1].Status)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	w, err := NewParser().Parse([]byte(`name: test
+steps:
+  - id: a
+    name: A
+    on_error:
+      action: run
+      step:
+        name: Clean
+        if: steps.b.status == 'failed'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		field        string
+		line, column int
+	}{
+		{"steps[0].on_error.step.if", 9, 9},
+		{"steps[0].on_error.step.timeout", 7, 7},
+		{"steps[0]", 3, 5},
+		{"steps[3].id", 2, 1},
+		{"", 1, 1},
+	}
+	for _, tt := range tests {
+		n := lookup(w.node, tt.field)
+		if n.Line != tt.line || n.Column != tt.column {
+			t.Errorf("%q: expected %d:%d, got %d:%d", tt.field, tt.line, tt.column, n.Line, n.Column)
+		}
+	}
+
+	// Errors are positioned at the deepest node their field names.
+	err = NewValidator().Validate(w)
+	if err == nil || err.Error() != `9:9: steps[0].on_error.step.if: unknown step "b"` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	var schema struct {
+		Properties  map[string]json.RawMessage `json:"properties"`
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(JSONSchema(), &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+
+	// The schema describes every field the parser reads.
+	types := map[string]reflect.Type{
+		"step":         reflect.TypeOf(Step{}),
+		"branch":       reflect.TypeOf(Branch{}),
+		"errorHandler": reflect.TypeOf(ErrorHandler{}),
+		"retryPolicy":  reflect.TypeOf(RetryPolicy{}),
+		"agentConfig":  reflect.TypeOf(AgentConfig{}),
+		"agentPolicy":  reflect.TypeOf(AgentPolicy{}),
+	}
+	check := func(name string, props map[string]json.RawMessage, typ reflect.Type) {
+		fields := yamlFields(typ)
+		for field := range fields {
+			if _, ok := props[field]; !ok {
+				t.Errorf("%s: schema lacks %s", name, field)
+			}
+		}
+		for prop := range props {
+			if _, ok := fields[prop]; !ok {
+				t.Errorf("%s: schema has unknown field %s", name, prop)
+			}
+		}
+	}
+	check("workflow", schema.Properties, reflect.TypeOf(Workflow{}))
+	for name, typ := range types {
+		def, ok := schema.Definitions[name]
+		if !ok {
+			t.Errorf("schema lacks definition %s", name)
+			continue
+		}
+		check(name, def.Properties, typ)
+	}
+}
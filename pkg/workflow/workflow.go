@@ -4,7 +4,10 @@ package workflow
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Workflow represents a complete workflow definition.
@@ -16,6 +19,16 @@ type Workflow struct {
 	Steps       []Step            `yaml:"steps" json:"steps"`
 	OnError     *ErrorHandler     `yaml:"on_error,omitempty" json:"on_error,omitempty"`
 	Timeout     string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// MaxParallel limits how many steps run at once. Zero uses the
+	// engine's limit.
+	MaxParallel int `yaml:"max_parallel,omitempty" json:"max_parallel,omitempty"`
+	// FailurePolicy decides what happens to the rest of the run when a step
+	// fails. Empty uses the engine's policy.
+	FailurePolicy FailurePolicy `yaml:"failure_policy,omitempty" json:"failure_policy,omitempty"`
+
+	// node is the YAML the workflow was parsed from, which the validator
+	// uses to report positions and unknown fields.
+	node *yaml.Node
 }
 
 // Step represents a single workflow step.
@@ -31,6 +44,46 @@ type Step struct {
 	Timeout   string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 	Retries   int               `yaml:"retries,omitempty" json:"retries,omitempty"`
 	OnError   *ErrorHandler     `yaml:"on_error,omitempty" json:"on_error,omitempty"`
+	// Retry configures the delay between retries and which errors are
+	// retried. Retries sets how many.
+	Retry *RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+
+	// Agent is the agent an agent step runs, by name or inline.
+	Agent *AgentRef `yaml:"agent,omitempty" json:"agent,omitempty"`
+	// Prompt is the ${{ }} template an agent step sends to its agent.
+	Prompt string `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+	// Session names a conversation shared by the agent steps of a run:
+	// each step in it sees the messages of the steps before, and the
+	// first one to run sets up the agent.
+	Session string `yaml:"session,omitempty" json:"session,omitempty"`
+
+	// Steps are the children of parallel and sequence steps and the body
+	// of loop steps.
+	Steps []Step `yaml:"steps,omitempty" json:"steps,omitempty"`
+	// MaxParallel limits concurrent children of a parallel step and
+	// concurrent iterations of a for_each loop, which default to the
+	// workflow limit and 1.
+	MaxParallel int `yaml:"max_parallel,omitempty" json:"max_parallel,omitempty"`
+	// ForEach is a ${{ }} template yielding the list a loop iterates over.
+	ForEach string `yaml:"for_each,omitempty" json:"for_each,omitempty"`
+	// As names the loop variable holding the current item, loop.item by
+	// default.
+	As string `yaml:"as,omitempty" json:"as,omitempty"`
+	// While is an expression checked before each loop iteration.
+	While string `yaml:"while,omitempty" json:"while,omitempty"`
+	// MaxIterations bounds a loop; exceeding it fails the step. Zero uses
+	// DefaultMaxIterations for while loops and no bound for for_each.
+	MaxIterations int `yaml:"max_iterations,omitempty" json:"max_iterations,omitempty"`
+	// Branches are the arms of a decision step.
+	Branches []Branch `yaml:"branches,omitempty" json:"branches,omitempty"`
+}
+
+// Branch is one arm of a decision step. The first branch whose If is true
+// runs; a branch without If is the default and must come last.
+type Branch struct {
+	Name  string `yaml:"name,omitempty" json:"name,omitempty"`
+	If    string `yaml:"if,omitempty" json:"if,omitempty"`
+	Steps []Step `yaml:"steps" json:"steps"`
 }
 
 // StepType represents the type of step.
@@ -45,10 +98,64 @@ const (
 	StepTypeLoop     StepType = "loop"
 )
 
-// ErrorHandler defines error handling behavior.
+// FailurePolicy decides how a step failure affects the rest of a run.
+type FailurePolicy string
+
+const (
+	// FailureCancel cancels running steps and skips every step not yet
+	// started.
+	FailureCancel FailurePolicy = "cancel"
+	// FailureContinue skips only the steps that depend on the failed step,
+	// directly or transitively; independent branches run to completion.
+	FailureContinue FailurePolicy = "continue"
+)
+
+// ErrorHandler decides what happens when a step fails once its retries
+// are used up. A step's handler overrides the workflow's.
 type ErrorHandler struct {
-	Action  string `yaml:"action" json:"action"`
-	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	Action  ErrorAction `yaml:"action" json:"action"`
+	Message string      `yaml:"message,omitempty" json:"message,omitempty"`
+	// Retries and Retry configure the retry action for steps that do not
+	// set their own. Zero retries uses DefaultRetries.
+	Retries int          `yaml:"retries,omitempty" json:"retries,omitempty"`
+	Retry   *RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// Step is the alternate step of the fallback action or the cleanup
+	// step of the run action.
+	Step *Step `yaml:"step,omitempty" json:"step,omitempty"`
+}
+
+// ErrorAction is what an ErrorHandler does with a failed step.
+type ErrorAction string
+
+const (
+	// ErrorFail fails the step. It is the default.
+	ErrorFail ErrorAction = "fail"
+	// ErrorContinue records the step as failed but lets the run go on as
+	// if it had completed, so its dependents still run.
+	ErrorContinue ErrorAction = "continue"
+	// ErrorRetry retries the step, then fails it.
+	ErrorRetry ErrorAction = "retry"
+	// ErrorFallback runs the handler's step in place of the failed step;
+	// the step completes with its output if it succeeds.
+	ErrorFallback ErrorAction = "fallback"
+	// ErrorRun runs the handler's step to clean up, then fails the step.
+	ErrorRun ErrorAction = "run"
+)
+
+// RetryPolicy configures retries. The delay before retry n is
+// Backoff * Multiplier^(n-1), capped at MaxBackoff and reduced by up to
+// Jitter (a fraction between 0 and 1) at random.
+type RetryPolicy struct {
+	// Backoff is the delay before the first retry; DefaultBackoff if empty.
+	Backoff string `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+	// MaxBackoff caps the delay; DefaultMaxBackoff if empty.
+	MaxBackoff string `yaml:"max_backoff,omitempty" json:"max_backoff,omitempty"`
+	// Multiplier grows the delay after each retry; 2 if zero.
+	Multiplier float64 `yaml:"multiplier,omitempty" json:"multiplier,omitempty"`
+	Jitter     float64 `yaml:"jitter,omitempty" json:"jitter,omitempty"`
+	// On lists the error classes to retry (see ErrorClass). Empty retries
+	// every error except permanent ones.
+	On []string `yaml:"on,omitempty" json:"on,omitempty"`
 }
 
 // StepResult contains the result of a step execution.
@@ -60,6 +167,28 @@ type StepResult struct {
 	StartTime time.Time              `json:"start_time"`
 	EndTime   time.Time              `json:"end_time"`
 	Duration  time.Duration          `json:"duration"`
+	// Children are the results of a control step's nested steps. Loop
+	// steps have one child per iteration, holding that iteration's steps.
+	Children []*StepResult `json:"children,omitempty"`
+	// Attempts records each attempt at running the step, including
+	// retries.
+	Attempts []Attempt `json:"attempts,omitempty"`
+	// Recovery is the result of the step's fallback or cleanup step.
+	Recovery *StepResult `json:"recovery,omitempty"`
+}
+
+// Attempt is one try at running a step.
+type Attempt struct {
+	Number int        `json:"number"`
+	Status StepStatus `json:"status"`
+	// Error and Class describe a failed attempt.
+	Error     string        `json:"error,omitempty"`
+	Class     string        `json:"class,omitempty"`
+	StartTime time.Time     `json:"start_time"`
+	EndTime   time.Time     `json:"end_time"`
+	Duration  time.Duration `json:"duration"`
+	// Backoff is the delay before the next attempt.
+	Backoff time.Duration `json:"backoff,omitempty"`
 }
 
 // StepStatus represents the status of a step.
@@ -75,6 +204,8 @@ const (
 
 // WorkflowResult contains the result of a workflow execution.
 type WorkflowResult struct {
+	// RunID identifies the run, as in the engine's run store.
+	RunID        string        `json:"run_id,omitempty"`
 	WorkflowName string        `json:"workflow_name"`
 	Status       StepStatus    `json:"status"`
 	Steps        []*StepResult `json:"steps"`
@@ -111,12 +242,51 @@ type Validator interface {
 	Validate(w *Workflow) error
 }
 
-// ValidationError represents a validation error.
+// ActionRegistry reports which actions can run, as DefaultEngine does.
+type ActionRegistry interface {
+	// HasAction reports whether an action is registered under name.
+	HasAction(name string) bool
+}
+
+// AgentRegistry is the set of named agents a workflow may refer to.
+type AgentRegistry interface {
+	// HasAgent reports whether an agent is defined under name.
+	HasAgent(name string) bool
+}
+
+// ValidationError represents a validation error. Line and Column locate
+// it in the YAML of a parsed workflow, and are zero otherwise.
 type ValidationError struct {
 	Field   string `json:"field"`
 	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
 }
 
 func (e *ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Field, e.Message)
+	}
 	return fmt.Sprintf("%s: %s", e.Field, e.Message)
 }
+
+// ValidationErrors are all the problems found validating a workflow, in
+// the order they appear in it.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors, so errors.As finds a ValidationError.
+func (errs ValidationErrors) Unwrap() []error {
+	out := make([]error, len(errs))
+	for i, err := range errs {
+		out[i] = err
+	}
+	return out
+}
@@ -2,7 +2,12 @@ package workflow
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestYAMLParser(t *testing.T) {
@@ -136,6 +141,171 @@ func TestEngineExecuteWorkflow(t *testing.T) {
 	}
 }
 
+func TestEngineDAG(t *testing.T) {
+	e := NewEngine()
+
+	var running, peak int32
+	e.RegisterAction("work", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return map[string]interface{}{"done": true}, nil
+	})
+
+	w := &Workflow{
+		Name:        "pipeline",
+		MaxParallel: 2,
+		Steps: []Step{
+			{ID: "report", Name: "Report", Action: "work", DependsOn: []string{"lint", "test", "review"}},
+			{ID: "lint", Name: "Lint", Action: "work"},
+			{ID: "test", Name: "Test", Action: "work"},
+			{ID: "review", Name: "Review", Action: "work"},
+		},
+	}
+
+	result, err := e.Execute(context.Background(), w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if peak != 2 {
+		t.Errorf("expected 2 steps at once, got %d", peak)
+	}
+	if len(result.Steps) != 4 || result.Steps[0].StepID != "report" {
+		t.Fatalf("expected results in definition order, got %v", result.Steps)
+	}
+	report := result.Steps[0]
+	for _, r := range result.Steps[1:] {
+		if r.Status != StatusCompleted {
+			t.Errorf("expected %s completed, got %s", r.StepID, r.Status)
+		}
+		if report.StartTime.Before(r.EndTime) {
+			t.Errorf("report started before %s finished", r.StepID)
+		}
+	}
+}
+
+func TestEngineFailurePolicy(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAction("ok", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return nil, nil
+	})
+	e.RegisterAction("fail", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	var mu sync.Mutex
+	cancelled := false
+	e.RegisterAction("slow", func(ctx context.Context, inputs map[string]interface{}) (map[string]interface{}, error) {
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			cancelled = true
+			mu.Unlock()
+			return nil, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil, nil
+		}
+	})
+
+	steps := []Step{
+		{ID: "build", Name: "Build", Action: "fail"},
+		{ID: "slow", Name: "Slow", Action: "slow"},
+		{ID: "deploy", Name: "Deploy", Action: "ok", DependsOn: []string{"build"}},
+		{ID: "notify", Name: "Notify", Action: "ok", DependsOn: []string{"deploy"}},
+		{ID: "docs", Name: "Docs", Action: "ok", DependsOn: []string{"slow"}},
+	}
+	statuses := func(r *WorkflowResult) map[string]StepStatus {
+		out := make(map[string]StepStatus)
+		for _, s := range r.Steps {
+			out[s.StepID] = s.Status
+		}
+		return out
+	}
+
+	// Continue: only dependents of the failed step are skipped.
+	w := &Workflow{Name: "test", Steps: steps, FailurePolicy: FailureContinue}
+	result, err := e.Execute(context.Background(), w)
+	if err == nil || result.Status != StatusFailed {
+		t.Fatalf("expected failure, got %v", err)
+	}
+	want := map[string]StepStatus{
+		"build": StatusFailed, "slow": StatusCompleted, "deploy": StatusSkipped,
+		"notify": StatusSkipped, "docs": StatusCompleted,
+	}
+	for id, status := range want {
+		if got := statuses(result)[id]; got != status {
+			t.Errorf("continue: expected %s %s, got %s", id, status, got)
+		}
+	}
+
+	// Cancel: running steps are cancelled and nothing else starts.
+	w.FailurePolicy = FailureCancel
+	result, _ = e.Execute(context.Background(), w)
+	if got := statuses(result); got["docs"] != StatusSkipped || got["deploy"] != StatusSkipped || got["slow"] != StatusFailed {
+		t.Errorf("cancel: unexpected statuses %v", got)
+	}
+	if !cancelled {
+		t.Error("expected running step to be cancelled")
+	}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	steps := []Step{
+		{ID: "c", DependsOn: []string{"a", "b"}},
+		{ID: "b", DependsOn: []string{"a"}},
+		{ID: "a"},
+		{ID: "d"},
+	}
+	order, err := TopologicalOrder(steps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(order, ","); got != "a,b,c,d" {
+		t.Errorf("expected a,b,c,d, got %s", got)
+	}
+}
+
+func TestValidatorDependencies(t *testing.T) {
+	v := NewValidator()
+	tests := []struct {
+		name  string
+		steps []Step
+		want  string
+	}{
+		{"self", []Step{{ID: "a", Name: "A", DependsOn: []string{"a"}}}, "depends on itself"},
+		{"unknown", []Step{{ID: "a", Name: "A", DependsOn: []string{"missing"}}}, `unknown step "missing"`},
+		{"duplicate", []Step{{ID: "a", Name: "A"}, {ID: "a", Name: "A2"}}, "duplicate step id"},
+		{"cycle", []Step{
+			{ID: "a", Name: "A", DependsOn: []string{"c"}},
+			{ID: "b", Name: "B", DependsOn: []string{"a"}},
+			{ID: "c", Name: "C", DependsOn: []string{"b"}},
+		}, "dependency cycle: a -> c -> b -> a"},
+	}
+	for _, tt := range tests {
+		err := v.Validate(&Workflow{Name: "test", Steps: tt.steps})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	// The engine refuses to run an invalid graph.
+	e := NewEngine()
+	_, err := e.Execute(context.Background(), &Workflow{Name: "test", Steps: tests[3].steps})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Errorf("expected ValidationError from Execute, got %v", err)
+	}
+
+	if err := v.Validate(&Workflow{Name: "test", Steps: []Step{{ID: "a", Name: "A"}}, FailurePolicy: "retry"}); err == nil {
+		t.Error("expected error for unknown failure policy")
+	}
+}
+
 func TestValidationError(t *testing.T) {
 	err := &ValidationError{Field: "name", Message: "required"}
 	if err.Error() != "name: required" {